@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/apikey"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// defaultAPIKeysSecret is the Secret shared by the server and the apikey command
+const defaultAPIKeysSecret = "k8s-controller-tutorial-apikeys"
+
+var apiKeySecret string
+var apiKeyName string
+var apiKeyNamespaces []string
+var apiKeyVerbs []string
+var apiKeyExpiresIn time.Duration
+var apiKeyCreatedBy string
+var apiKeyCreatorGroups []string
+
+var apiKeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage API keys for automation clients",
+}
+
+// newAPIKeyStore connects to the cluster and loads the stored keys
+func newAPIKeyStore(ctx context.Context) *apikey.Store {
+	k8sClient, err := NewFrontendPageClient(inCluster, kubeconfig)
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating client")
+		os.Exit(1)
+	}
+	store := apikey.NewStore(k8sClient, namespace, apiKeySecret)
+	if err := store.Load(ctx); err != nil {
+		log.Error().Err(err).Msg("Error loading API keys")
+		os.Exit(1)
+	}
+	return store
+}
+
+var apiKeyCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an API key and print it once",
+	Run: func(cmd *cobra.Command, args []string) {
+		level := SetLogLevel(LogLevel)
+		ConfigureLogger(level)
+
+		opts := apikey.CreateOptions{
+			Name:       apiKeyName,
+			Namespaces: apiKeyNamespaces,
+			Verbs:      apiKeyVerbs,
+			CreatedBy:  apiKeyCreatedBy,
+			// With an authorizer the key works while this identity is allowed
+			CreatorGroups: apiKeyCreatorGroups,
+		}
+		if len(opts.Namespaces) == 0 {
+			opts.Namespaces = []string{namespace}
+		}
+		if apiKeyExpiresIn > 0 {
+			expiresAt := time.Now().Add(apiKeyExpiresIn).UTC().Truncate(time.Second)
+			opts.ExpiresAt = &expiresAt
+		}
+		if err := opts.Validate(); err != nil {
+			log.Error().Err(err).Msg("Invalid API key")
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		raw, key, err := newAPIKeyStore(ctx).Create(ctx, opts)
+		if err != nil {
+			log.Error().Err(err).Msg("Error creating API key")
+			os.Exit(1)
+		}
+		output, _ := json.MarshalIndent(map[string]any{"key": raw, "apiKey": key}, "", "  ")
+		fmt.Println(string(output))
+	},
+}
+
+var apiKeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API keys with their last use and usage count",
+	Run: func(cmd *cobra.Command, args []string) {
+		level := SetLogLevel(LogLevel)
+		ConfigureLogger(level)
+
+		output, _ := json.MarshalIndent(newAPIKeyStore(context.Background()).List(), "", "  ")
+		fmt.Println(string(output))
+	},
+}
+
+var apiKeyDeleteCmd = &cobra.Command{
+	Use:   "delete <id>",
+	Short: "Delete an API key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		level := SetLogLevel(LogLevel)
+		ConfigureLogger(level)
+
+		ctx := context.Background()
+		if err := newAPIKeyStore(ctx).Delete(ctx, args[0]); err != nil {
+			log.Error().Err(err).Str("id", args[0]).Msg("Error deleting API key")
+			os.Exit(1)
+		}
+		log.Info().Str("id", args[0]).Msg("API key deleted")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(apiKeyCmd)
+	apiKeyCmd.AddCommand(apiKeyCreateCmd, apiKeyListCmd, apiKeyDeleteCmd)
+	apiKeyCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file")
+	apiKeyCmd.PersistentFlags().BoolVar(&inCluster, "in-cluster", false, "Use in-cluster configuration")
+	apiKeyCmd.PersistentFlags().StringVar(&namespace, "namespace", "default", "Namespace of the API keys Secret")
+	apiKeyCmd.PersistentFlags().StringVar(&apiKeySecret, "secret", defaultAPIKeysSecret, "Secret that stores the hashed API keys")
+	apiKeyCreateCmd.Flags().StringVar(&apiKeyName, "name", "", "Name of the key, such as the CI pipeline using it")
+	apiKeyCreateCmd.Flags().StringSliceVar(&apiKeyNamespaces, "namespaces", nil, "Namespaces the key is valid for (defaults to --namespace)")
+	apiKeyCreateCmd.Flags().StringSliceVar(&apiKeyVerbs, "verbs", nil, "Verbs the key may use: get, list, watch, create, update, patch, delete")
+	apiKeyCreateCmd.Flags().DurationVar(&apiKeyExpiresIn, "expires-in", 0, "Lifetime of the key, such as 720h (no expiry by default)")
+	apiKeyCreateCmd.Flags().StringVar(&apiKeyCreatedBy, "created-by", "cli", "User the key acts for, authorized on each request when the server has an authorizer")
+	apiKeyCreateCmd.Flags().StringSliceVar(&apiKeyCreatorGroups, "created-by-groups", nil, "Groups of --created-by")
+}
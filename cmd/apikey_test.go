@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestAPIKeyCmd(t *testing.T) {
+	if apiKeyCmd.Use != "apikey" {
+		t.Errorf("apiKeyCmd.Use should be 'apikey'")
+	}
+
+	for _, name := range []string{"kubeconfig", "namespace", "secret"} {
+		if apiKeyCmd.PersistentFlags().Lookup(name) == nil {
+			t.Errorf("expected %s flag to be defined", name)
+		}
+	}
+	for _, name := range []string{"name", "namespaces", "verbs", "expires-in"} {
+		if apiKeyCreateCmd.Flags().Lookup(name) == nil {
+			t.Errorf("expected %s flag to be defined on create", name)
+		}
+	}
+
+	names := map[string]bool{}
+	for _, sub := range apiKeyCmd.Commands() {
+		names[sub.Name()] = true
+	}
+	for _, name := range []string{"create", "list", "delete"} {
+		if !names[name] {
+			t.Errorf("expected %s subcommand", name)
+		}
+	}
+}
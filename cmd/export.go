@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/bundle"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var exportFormat string
+var exportOutput string
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export FrontendPages as a YAML or JSON bundle",
+	Run: func(cmd *cobra.Command, args []string) {
+		level := SetLogLevel(LogLevel)
+		ConfigureLogger(level)
+
+		k8sClient, err := NewFrontendPageClient(inCluster, kubeconfig)
+		if err != nil {
+			log.Error().Err(err).Msg("Error creating client")
+			os.Exit(1)
+		}
+
+		pages, err := bundle.Export(context.Background(), k8sClient, namespace)
+		if err != nil {
+			log.Error().Err(err).Msg("Error exporting frontend pages")
+			os.Exit(1)
+		}
+
+		var out io.Writer = os.Stdout
+		if exportOutput != "" && exportOutput != "-" {
+			file, err := os.Create(exportOutput)
+			if err != nil {
+				log.Error().Err(err).Msg("Error creating output file")
+				os.Exit(1)
+			}
+			defer file.Close()
+			out = file
+		}
+
+		if err := bundle.Encode(out, pages, exportFormat); err != nil {
+			log.Error().Err(err).Msg("Error writing bundle")
+			os.Exit(1)
+		}
+		log.Debug().Msgf("Exported %d frontend pages from namespace %s", len(pages), namespace)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file")
+	exportCmd.Flags().BoolVar(&inCluster, "in-cluster", false, "Use in-cluster configuration")
+	exportCmd.Flags().StringVar(&namespace, "namespace", "default", "Namespace to export frontend pages from")
+	exportCmd.Flags().StringVar(&exportFormat, "format", bundle.FormatYAML, "Bundle format (yaml or json)")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "File to write the bundle to (defaults to stdout)")
+}
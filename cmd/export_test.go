@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestExportCmd(t *testing.T) {
+	if exportCmd.Use != "export" {
+		t.Errorf("exportCmd.Use should be 'export'")
+	}
+
+	for _, name := range []string{"kubeconfig", "namespace", "format", "output"} {
+		if exportCmd.Flags().Lookup(name) == nil {
+			t.Errorf("expected %s flag to be defined", name)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/bundle"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var importFile string
+var importDryRun bool
+var importConflict string
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Create or update FrontendPages from a YAML or JSON bundle",
+	Run: func(cmd *cobra.Command, args []string) {
+		level := SetLogLevel(LogLevel)
+		ConfigureLogger(level)
+
+		if err := bundle.ValidateConflictPolicy(importConflict); err != nil {
+			log.Error().Err(err).Msg("Invalid conflict policy")
+			os.Exit(1)
+		}
+
+		var in io.Reader = os.Stdin
+		if importFile != "" && importFile != "-" {
+			file, err := os.Open(importFile)
+			if err != nil {
+				log.Error().Err(err).Msg("Error opening bundle")
+				os.Exit(1)
+			}
+			defer file.Close()
+			in = file
+		}
+
+		pages, err := bundle.Decode(in)
+		if err != nil {
+			log.Error().Err(err).Msg("Error reading bundle")
+			os.Exit(1)
+		}
+
+		k8sClient, err := NewFrontendPageClient(inCluster, kubeconfig)
+		if err != nil {
+			log.Error().Err(err).Msg("Error creating client")
+			os.Exit(1)
+		}
+
+		report, err := bundle.Import(context.Background(), k8sClient, pages, bundle.ImportOptions{
+			Namespace: namespace,
+			DryRun:    importDryRun,
+			Conflict:  importConflict,
+		})
+		if err != nil && !errors.Is(err, bundle.ErrConflict) {
+			log.Error().Err(err).Msg("Error importing frontend pages")
+			os.Exit(1)
+		}
+
+		output, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(output))
+		if err != nil || report.Failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file")
+	importCmd.Flags().BoolVar(&inCluster, "in-cluster", false, "Use in-cluster configuration")
+	importCmd.Flags().StringVar(&namespace, "namespace", "default", "Namespace to import frontend pages into")
+	importCmd.Flags().StringVarP(&importFile, "file", "f", "", "Bundle to import (defaults to stdin)")
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Validate the import with the API server without persisting anything")
+	importCmd.Flags().StringVar(&importConflict, "conflict", bundle.ConflictFail, "What to do with pages that already exist (skip, overwrite or fail)")
+}
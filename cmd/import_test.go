@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestImportCmd(t *testing.T) {
+	if importCmd.Use != "import" {
+		t.Errorf("importCmd.Use should be 'import'")
+	}
+
+	for _, name := range []string{"kubeconfig", "namespace", "file", "dry-run", "conflict"} {
+		if importCmd.Flags().Lookup(name) == nil {
+			t.Errorf("expected %s flag to be defined", name)
+		}
+	}
+}
@@ -4,102 +4,564 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/audit"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// NewMCPServer creates and configures a new MCP server for FrontendPage tools
-func NewMCPServer(serverName, version string) *server.MCPServer {
+// Limits advertised in the tool schemas and enforced by the handlers
+const (
+	mcpMaxReplicas  = 50
+	mcpDefaultPort  = 8080
+	mcpDefaultImage = "nginx:latest"
+	mcpNamePattern  = `^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+)
+
+// Levels of detail for get_frontendpage_status
+const (
+	statusDetailSummary = "summary"
+	statusDetailFull    = "full"
+)
+
+// nameArgument is the name property shared by the tools that target one page
+func nameArgument(description string) mcp.ToolOption {
+	return mcp.WithString("name",
+		mcp.Required(),
+		mcp.Description(description),
+		mcp.Pattern(mcpNamePattern),
+		mcp.MaxLength(63),
+	)
+}
+
+// mcpNameRegexp checks names where a tool can't rely on the client having
+// enforced the schema's pattern
+var mcpNameRegexp = regexp.MustCompile(mcpNamePattern)
+
+// validateNameArgument checks a required page name argument
+func validateNameArgument(name string) error {
+	if name == "" {
+		return api.Required("name")
+	}
+	if len(name) > 63 || !mcpNameRegexp.MatchString(name) {
+		return api.Invalid(api.ProblemCause{
+			Field:   "name",
+			Reason:  string(metav1.CauseTypeFieldValueInvalid),
+			Message: "name must be a DNS label: lowercase letters, digits and dashes, at most 63 characters",
+		})
+	}
+	return nil
+}
+
+// MCPOptions configure the auditing and confirmations of an MCP server
+type MCPOptions struct {
+	// Auditor records the calls of mutating tools. Nil disables auditing.
+	Auditor *audit.Logger
+	// ConfirmationTTL is how long a destructive tool call waits for its
+	// confirmation token to be echoed back. Zero runs destructive tools in
+	// one step.
+	ConfirmationTTL time.Duration
+}
+
+// mcpHandlers serves the tools, resources and prompts of an MCP server from
+// one FrontendPageService
+type mcpHandlers struct {
+	service api.FrontendPageService
+	auditor *audit.Logger
+	// confirmationTTL and confirmations hold the tokens issued by
+	// destructive tools
+	confirmationTTL time.Duration
+	confirmations   *confirmationStore
+}
+
+// MCPServer is the mcp-go server of the FrontendPage tools, resources and
+// prompts. mcp-go doesn't dispatch resources/subscribe and
+// resources/unsubscribe, so MCPServer answers them before passing messages
+// on, and the transports are set up to go through it.
+type MCPServer struct {
+	*server.MCPServer
+	resources *frontendPageResources
+}
+
+// NewMCPServer creates and configures a new MCP server for the FrontendPage
+// tools, resources and prompts of service. Page resources are kept current
+// from pages, the manager's FrontendPage informer, when it is set.
+func NewMCPServer(serverName, version string, service api.FrontendPageService, pages ctrlcache.Informer, opts MCPOptions) *MCPServer {
+	h := &mcpHandlers{
+		service:         service,
+		auditor:         opts.Auditor,
+		confirmationTTL: opts.ConfirmationTTL,
+		confirmations:   newConfirmationStore(),
+	}
+	hooks := &server.Hooks{}
+	hooks.AddAfterListResources(h.filterAuthorizedResources)
+	hooks.AddAfterListResourceTemplates(h.filterAuthorizedResourceTemplates)
 	s := server.NewMCPServer(
 		serverName,
 		version,
 		server.WithToolCapabilities(true),
+		// Subscriptions are answered by MCPServer, see handleSubscription
+		server.WithResourceCapabilities(true, true),
+		server.WithPromptCapabilities(false),
+		server.WithHooks(hooks),
+		server.WithToolFilter(h.filterAuthorizedTools),
 		server.WithLogging(),
 		server.WithRecovery(),
 	)
 
-	// List tool
+	resources := newFrontendPageResources(s, hooks, h)
+	if pages != nil {
+		if _, err := pages.AddEventHandler(resources); err != nil {
+			log.Error().Err(err).Msg("Failed to feed MCP resources from the frontend page informer")
+		}
+	}
+
+	h.addFrontendPagePrompts(s)
+
 	listTool := mcp.NewTool("list_frontendpages",
-		mcp.WithDescription("List all FrontendPage resources"),
+		mcp.WithDescription("List all FrontendPage resources. Returns {\"items\": [FrontendPage]}."),
+		outputSchema(api.FrontendPageDocList{}),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+	getTool := mcp.NewTool("get_frontendpage",
+		mcp.WithDescription("Get the spec of a FrontendPage: name, content, image, replicas and port"),
+		nameArgument("Name of the FrontendPage"),
+		outputSchema(api.FrontendPageDoc{}),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+	statusTool := mcp.NewTool("get_frontendpage_status",
+		mcp.WithDescription("Get the observed state of a FrontendPage: a phase (Ready, Progressing, Failed or NotDeployed) and the replica counts of its Deployment"),
+		nameArgument("Name of the FrontendPage"),
+		mcp.WithString("detail",
+			mcp.Description("summary leaves out the condition lists, full includes them"),
+			mcp.Enum(statusDetailSummary, statusDetailFull),
+			mcp.DefaultString(statusDetailSummary),
+		),
+		outputSchema(api.FrontendPageStatusDoc{}),
+		mcp.WithReadOnlyHintAnnotation(true),
 	)
-	// Create tool
 	createTool := mcp.NewTool("create_frontendpage",
-		mcp.WithDescription("Create a new FrontendPage resource"),
-		mcp.WithString("name", mcp.Description("Name of the FrontendPage")),
-		mcp.WithString("contents", mcp.Description("HTML contents")),
-		mcp.WithString("image", mcp.Description("Container image")),
-		mcp.WithNumber("replicas", mcp.Description("Number of replicas")),
+		mcp.WithDescription("Create a new FrontendPage resource. Returns the created FrontendPage."),
+		nameArgument("Name of the new FrontendPage, a DNS label"),
+		mcp.WithString("contents", mcp.Required(), mcp.Description("HTML contents served by the page")),
+		mcp.WithString("image", mcp.Description("Container image serving the contents"), mcp.DefaultString(mcpDefaultImage)),
+		mcp.WithNumber("replicas", mcp.Description("Number of replicas"), mcp.Min(0), mcp.Max(mcpMaxReplicas), mcp.DefaultNumber(1)),
+		mcp.WithNumber("port", mcp.Description("Container port"), mcp.Min(1), mcp.Max(65535), mcp.DefaultNumber(mcpDefaultPort)),
+		outputSchema(api.FrontendPageDoc{}),
+		mcp.WithDestructiveHintAnnotation(false),
+	)
+	updateTool := mcp.NewTool("update_frontendpage",
+		mcp.WithDescription("Change fields of a FrontendPage. Fields that are left out keep their value. Returns the updated FrontendPage."),
+		nameArgument("Name of the FrontendPage to update"),
+		mcp.WithString("contents", mcp.Description("New HTML contents")),
+		mcp.WithString("image", mcp.Description("New container image")),
+		mcp.WithNumber("replicas", mcp.Description("New number of replicas"), mcp.Min(0), mcp.Max(mcpMaxReplicas)),
+		mcp.WithNumber("port", mcp.Description("New container port"), mcp.Min(1), mcp.Max(65535)),
+		outputSchema(api.FrontendPageDoc{}),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+	)
+	scaleTool := mcp.NewTool("scale_frontendpage",
+		mcp.WithDescription("Set the number of replicas of a FrontendPage. Returns the name with the previous and new replica counts."),
+		nameArgument("Name of the FrontendPage to scale"),
+		mcp.WithNumber("replicas", mcp.Required(), mcp.Description("Number of replicas"), mcp.Min(0), mcp.Max(mcpMaxReplicas)),
+		outputSchema(scaleResult{}),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
 	)
-	// Delete tool
 	deleteTool := mcp.NewTool("delete_frontendpage",
-		mcp.WithDescription("Delete a FrontendPage resource"),
-		mcp.WithString("name", mcp.Description("Name of the FrontendPage to delete")),
+		mcp.WithDescription("Delete a FrontendPage resource and everything the controller created for it. "+
+			"Unless the server runs without confirmations, the first call only returns what would be deleted and a confirmationToken; "+
+			"call again with it as confirmation_token to delete. Pages labelled "+mcpProtectedLabel+"=true can't be deleted."),
+		nameArgument("Name of the FrontendPage to delete"),
+		mcp.WithBoolean("dry_run", mcp.Description("Only return what would be deleted, validated by the API server"), mcp.DefaultBool(false)),
+		mcp.WithString("confirmation_token", mcp.Description("Token returned by the previous call, to confirm the deletion")),
+		outputSchema(deleteResult{}),
+		mcp.WithReadOnlyHintAnnotation(false),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(false),
 	)
-	// TODO: Add update tools as needed
 
-	s.AddTool(listTool, listFrontendPagesHandler)
-	s.AddTool(createTool, createFrontendPageHandler)
-	s.AddTool(deleteTool, deleteFrontendPageHandler)
-	// TODO: Register update handlers
+	s.AddTool(listTool, h.authorizeTool(h.listFrontendPages))
+	s.AddTool(getTool, h.authorizeTool(h.getFrontendPage))
+	s.AddTool(statusTool, h.authorizeTool(h.getFrontendPageStatus))
+	s.AddTool(createTool, h.auditTool(api.VerbCreate, h.authorizeTool(h.createFrontendPage)))
+	s.AddTool(updateTool, h.auditTool(api.VerbPatch, h.authorizeTool(h.updateFrontendPage)))
+	s.AddTool(scaleTool, h.auditTool(api.VerbPatch, h.authorizeTool(h.scaleFrontendPage)))
+	s.AddTool(deleteTool, h.auditTool(api.VerbDelete, h.authorizeTool(h.deleteFrontendPage)))
+	h.addTroubleshootingTools(s)
+
+	return &MCPServer{MCPServer: s, resources: resources}
+}
+
+// toolError renders err as problem details and marks the result as an error.
+// The raw error of a 500 is only logged.
+func toolError(err error) *mcp.CallToolResult {
+	problem := api.ProblemFromError(err)
+	if problem.Detail == api.InternalErrorDetail {
+		log.Error().Err(err).Msg("MCP tool failed")
+	}
+	return mcp.NewToolResultError(problem.JSON())
+}
+
+// mcpError maps err with ProblemFromError for the resource and prompt
+// handlers, which return errors to mcp-go instead of results. Like toolError,
+// the raw error of a 500 is only logged.
+func mcpError(err error) error {
+	problem := api.ProblemFromError(err)
+	if problem.Detail == api.InternalErrorDetail {
+		log.Error().Err(err).Msg("MCP request failed")
+	}
+	return problem
+}
 
-	return s
+// mapResourceErrors wraps a resource handler so that its errors go through
+// mcpError
+func mapResourceErrors(handler server.ResourceTemplateHandlerFunc) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		contents, err := handler(ctx, req)
+		if err != nil {
+			return nil, mcpError(err)
+		}
+		return contents, nil
+	}
+}
+
+// mapPromptErrors wraps a prompt handler so that its errors go through
+// mcpError
+func mapPromptErrors(handler server.PromptHandlerFunc) server.PromptHandlerFunc {
+	return func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		result, err := handler(ctx, req)
+		if err != nil {
+			return nil, mcpError(err)
+		}
+		return result, nil
+	}
 }
 
-func listFrontendPagesHandler(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	if api.FrontendApi == nil {
-		return mcp.NewToolResultText("FrontendPageApi is not initialized"), nil
+// resultText joins the text content of a tool result
+func resultText(result *mcp.CallToolResult) string {
+	if result == nil {
+		return ""
 	}
-	docs, err := api.FrontendApi.ListFrontendPagesRaw(ctx)
+	var texts []string
+	for _, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// auditTool wraps a mutating tool handler for an audit record of each call
+func (h *mcpHandlers) auditTool(verb string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if h.auditor == nil {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		applied := false
+		result, err := handler(context.WithValue(ctx, appliedKey{}, &applied), req)
+
+		ev := audit.Event{
+			Time:      start,
+			Source:    audit.SourceMCP,
+			SourceIP:  audit.SourceIPFromContext(ctx),
+			Operation: req.Params.Name,
+			Verb:      verb,
+			Resource:  "frontendpages",
+			Name:      req.GetString("name", ""),
+			DryRun:    h.plansOnly(req, applied),
+			Outcome:   audit.OutcomeSuccess,
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if identity := api.IdentityFromContext(ctx); identity != nil {
+			ev.Actor = audit.Actor{Subject: identity.Subject, Groups: identity.Groups}
+		}
+		if h.service != nil {
+			ev.Namespace = h.service.PageNamespace()
+		}
+		text := resultText(result)
+		switch {
+		case err != nil:
+			ev.Outcome, ev.Error = audit.OutcomeFailure, err.Error()
+		case result == nil || result.IsError:
+			ev.Outcome, ev.Error = audit.OutcomeFailure, text
+		}
+		requestBody, _ := json.Marshal(req.GetArguments())
+		h.auditor.Record(ctx, ev, requestBody, []byte(text))
+		return result, err
+	}
+}
+
+// mcpConfirmedTools are the destructive tools that wait for a confirmation
+// token when MCPOptions.ConfirmationTTL is set
+var mcpConfirmedTools = map[string]bool{"delete_frontendpage": true}
+
+// appliedKey holds the flag a confirmed tool sets once its effect is carried out
+type appliedKey struct{}
+
+// markApplied records that a confirmed tool call carried out its effect
+func markApplied(ctx context.Context) {
+	if applied, ok := ctx.Value(appliedKey{}).(*bool); ok {
+		*applied = true
+	}
+}
+
+// plansOnly reports whether a tool call only planned its effect: dry runs,
+// and calls to confirmed tools that didn't apply it because they issued a
+// confirmation token or were refused one
+func (h *mcpHandlers) plansOnly(req mcp.CallToolRequest, applied bool) bool {
+	if req.GetBool("dry_run", false) {
+		return true
+	}
+	return h.confirmationTTL > 0 && mcpConfirmedTools[req.Params.Name] && !applied
+}
+
+// errAPINotInitialized is returned by servers created without a service
+var errAPINotInitialized = api.NewProblem(http.StatusServiceUnavailable, "FrontendPageApi is not initialized")
+
+// toolJSON returns v as the structuredContent of a successful result, which
+// matches the tool's outputSchema. The same document is the text content, for
+// clients that don't read structured results.
+func toolJSON(v any) *mcp.CallToolResult {
+	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error listing FrontendPages: %v", err)), nil
+		return toolError(err)
 	}
-	jsonBytes, err := json.MarshalIndent(docs, "", "  ")
+	return mcp.NewToolResultStructured(v, string(data))
+}
+
+// outputSchema declares the JSON encoding of v, a struct, as the outputSchema
+// of a tool. It is generated like the schemas of the OpenAPI document.
+func outputSchema(v any) mcp.ToolOption {
+	return mcp.WithRawOutputSchema(api.JSONSchema(v))
+}
+
+// intArgument reads an optional integer argument and checks it against the
+// range advertised in the schema, since clients don't have to enforce it
+func intArgument(req mcp.CallToolRequest, key string, min, max int) (int, bool, error) {
+	raw, ok := req.GetArguments()[key]
+	if !ok || raw == nil {
+		return 0, false, nil
+	}
+	value, isNumber := raw.(float64)
+	if !isNumber || value != float64(int(value)) || int(value) < min || int(value) > max {
+		return 0, false, api.Invalid(api.ProblemCause{
+			Field:   key,
+			Reason:  string(metav1.CauseTypeFieldValueInvalid),
+			Message: fmt.Sprintf("%s must be an integer from %d to %d", key, min, max),
+		})
+	}
+	return int(value), true, nil
+}
+
+// stringArgument reads an optional string argument
+func stringArgument(req mcp.CallToolRequest, key string) (string, bool) {
+	value, ok := req.GetArguments()[key].(string)
+	return value, ok
+}
+
+func (h *mcpHandlers) listFrontendPages(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	docs, err := h.service.ListFrontendPagesRaw(ctx)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error marshaling result: %v", err)), nil
+		return toolError(err), nil
 	}
-	return mcp.NewToolResultText(string(jsonBytes)), nil
+	return toolJSON(api.FrontendPageDocList{Items: docs}), nil
 }
 
-func createFrontendPageHandler(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	if api.FrontendApi == nil {
-		return mcp.NewToolResultText("FrontendPageApi is not initialized"), nil
+func (h *mcpHandlers) getFrontendPage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	doc, err := h.service.GetFrontendPageRaw(ctx, req.GetString("name", ""))
+	if err != nil {
+		return toolError(err), nil
 	}
+	return toolJSON(doc), nil
+}
 
-	name := req.GetString("name", "")
-	contents := req.GetString("contents", "")
-	image := req.GetString("image", "")
-	replicas := req.GetInt("replicas", 1)
-	port := req.GetInt("port", 8080)
+func (h *mcpHandlers) getFrontendPageStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	detail := req.GetString("detail", statusDetailSummary)
+	if detail != statusDetailSummary && detail != statusDetailFull {
+		return toolError(api.Invalid(api.ProblemCause{
+			Field:   "detail",
+			Reason:  string(metav1.CauseTypeFieldValueNotSupported),
+			Message: fmt.Sprintf("detail must be %s or %s", statusDetailSummary, statusDetailFull),
+		})), nil
+	}
+	status, err := h.service.FrontendPageStatusRaw(ctx, req.GetString("name", ""))
+	if err != nil {
+		return toolError(err), nil
+	}
+	if detail == statusDetailSummary {
+		status.Conditions = nil
+		if status.Deployment != nil {
+			status.Deployment.Conditions = nil
+		}
+	}
+	return toolJSON(status), nil
+}
 
+func (h *mcpHandlers) createFrontendPage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name := req.GetString("name", "")
+	if err := validateNameArgument(name); err != nil {
+		return toolError(err), nil
+	}
 	doc := api.FrontendPageDoc{
 		Name:     name,
-		Content:  contents,
-		Image:    image,
-		Replicas: replicas,
-		Port:     port,
+		Content:  req.GetString("contents", ""),
+		Image:    req.GetString("image", mcpDefaultImage),
+		Replicas: 1,
+		Port:     mcpDefaultPort,
+	}
+	if replicas, ok, err := intArgument(req, "replicas", 0, mcpMaxReplicas); err != nil {
+		return toolError(err), nil
+	} else if ok {
+		doc.Replicas = replicas
+	}
+	if port, ok, err := intArgument(req, "port", 1, 65535); err != nil {
+		return toolError(err), nil
+	} else if ok {
+		doc.Port = port
 	}
 
-	err := api.FrontendApi.CreateFrontendPageRaw(ctx, doc)
+	created, err := h.service.CreateFrontendPageRaw(ctx, doc)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error creating FrontendPage: %v", err)), nil
+		return toolError(err), nil
 	}
-
-	return mcp.NewToolResultText(fmt.Sprintf("FrontendPage '%s' created successfully", name)), nil
+	return toolJSON(created), nil
 }
 
-func deleteFrontendPageHandler(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	if api.FrontendApi == nil {
-		return mcp.NewToolResultText("FrontendPageApi is not initialized"), nil
+func (h *mcpHandlers) updateFrontendPage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var patch api.FrontendPagePatchDoc
+	if contents, ok := stringArgument(req, "contents"); ok {
+		patch.Content = &contents
+	}
+	if image, ok := stringArgument(req, "image"); ok {
+		patch.Image = &image
+	}
+	if replicas, ok, err := intArgument(req, "replicas", 0, mcpMaxReplicas); err != nil {
+		return toolError(err), nil
+	} else if ok {
+		patch.Replicas = &replicas
+	}
+	if port, ok, err := intArgument(req, "port", 1, 65535); err != nil {
+		return toolError(err), nil
+	} else if ok {
+		patch.Port = &port
+	}
+	if patch == (api.FrontendPagePatchDoc{}) {
+		return toolError(api.BadRequest("nothing to update, pass at least one of contents, image, replicas or port")), nil
 	}
 
+	doc, err := h.service.PatchFrontendPageRaw(ctx, req.GetString("name", ""), patch)
+	if err != nil {
+		return toolError(err), nil
+	}
+	return toolJSON(doc), nil
+}
+
+// scaleResult is the result of scale_frontendpage
+type scaleResult struct {
+	Name             string `json:"name"`
+	PreviousReplicas int    `json:"previousReplicas"`
+	Replicas         int    `json:"replicas"`
+}
+
+func (h *mcpHandlers) scaleFrontendPage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	name := req.GetString("name", "")
+	replicas, ok, err := intArgument(req, "replicas", 0, mcpMaxReplicas)
+	if err != nil {
+		return toolError(err), nil
+	}
+	if !ok {
+		return toolError(api.Required("replicas")), nil
+	}
+	current, err := h.service.GetFrontendPageRaw(ctx, name)
+	if err != nil {
+		return toolError(err), nil
+	}
+	doc, err := h.service.PatchFrontendPageRaw(ctx, name, api.FrontendPagePatchDoc{Replicas: &replicas})
+	if err != nil {
+		return toolError(err), nil
+	}
+	return toolJSON(scaleResult{Name: doc.Name, PreviousReplicas: current.Replicas, Replicas: doc.Replicas}), nil
+}
+
+// deleteResult is the result of delete_frontendpage. With dry_run, or
+// without a confirmation token, it only plans the deletion.
+type deleteResult struct {
+	Name              string          `json:"name"`
+	Status            string          `json:"status"`
+	DryRun            bool            `json:"dryRun,omitempty"`
+	ConfirmationToken string          `json:"confirmationToken,omitempty"`
+	ExpiresAt         *time.Time      `json:"expiresAt,omitempty"`
+	Deletes           []api.ObjectRef `json:"deletes,omitempty"`
+}
 
-	err := api.FrontendApi.DeleteFrontendPageRaw(ctx, name)
+// Statuses of delete_frontendpage
+const (
+	deleteStatusDeleted              = "deleted"
+	deleteStatusWouldDelete          = "wouldDelete"
+	deleteStatusConfirmationRequired = "confirmationRequired"
+)
+
+func (h *mcpHandlers) deleteFrontendPage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name := req.GetString("name", "")
+	if err := validateNameArgument(name); err != nil {
+		return toolError(err), nil
+	}
+	plan, err := h.service.FrontendPageDeletePlanRaw(ctx, name)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error deleting FrontendPage: %v", err)), nil
+		return toolError(err), nil
+	}
+	if err := checkNotProtected(name, plan.Labels); err != nil {
+		return toolError(err), nil
+	}
+	// Deleting a page recreated since the plan would be a surprise
+	preconditions := client.Preconditions{UID: &plan.UID}
+
+	if req.GetBool("dry_run", false) {
+		if err := h.service.DeleteFrontendPageRaw(ctx, name, preconditions, client.DryRunAll); err != nil {
+			return toolError(err), nil
+		}
+		return toolJSON(deleteResult{Name: name, Status: deleteStatusWouldDelete, DryRun: true, Deletes: plan.Deletes}), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("FrontendPage '%s' deleted successfully", name)), nil
+	if h.confirmationTTL > 0 && mcpConfirmedTools[req.Params.Name] {
+		subject := ""
+		if identity := api.IdentityFromContext(ctx); identity != nil {
+			subject = identity.Subject
+		}
+		token, ok := stringArgument(req, "confirmation_token")
+		if !ok || token == "" {
+			token, expires, err := h.confirmations.issue(req.Params.Name, subject, plan.UID, h.confirmationTTL)
+			if err != nil {
+				return toolError(err), nil
+			}
+			return toolJSON(deleteResult{
+				Name:              name,
+				Status:            deleteStatusConfirmationRequired,
+				ConfirmationToken: token,
+				ExpiresAt:         &expires,
+				Deletes:           plan.Deletes,
+			}), nil
+		}
+		if err := h.confirmations.redeem(token, req.Params.Name, subject, plan.UID); err != nil {
+			return toolError(err), nil
+		}
+	}
+
+	markApplied(ctx)
+	if err := h.service.DeleteFrontendPageRaw(ctx, name, preconditions); err != nil {
+		return toolError(err), nil
+	}
+	return toolJSON(deleteResult{Name: name, Status: deleteStatusDeleted, Deletes: plan.Deletes}), nil
 }
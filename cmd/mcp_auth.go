@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/user"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// mcpToolVerbs are the verbs on FrontendPages each tool needs, the ones of
+// the equivalent REST route. Tools that aren't listed are refused.
+var mcpToolVerbs = map[string][]string{
+	"list_frontendpages":      {api.VerbList},
+	"get_frontendpage":        {api.VerbGet},
+	"get_frontendpage_status": {api.VerbGet},
+	"create_frontendpage":     {api.VerbCreate},
+	"update_frontendpage":     {api.VerbPatch},
+	"scale_frontendpage":      {api.VerbPatch},
+	"delete_frontendpage":     {api.VerbDelete},
+	// The troubleshooting tools read what get_frontendpage_status summarizes.
+	// Logs can hold more than the page, so they need their own verb.
+	"get_frontendpage_pods":            {api.VerbGet},
+	"get_frontendpage_logs":            {api.VerbGet, api.VerbLogs},
+	"get_frontendpage_events":          {api.VerbGet},
+	"get_frontendpage_rollout_history": {api.VerbGet},
+}
+
+// mcpPromptVerbs are the verbs each prompt needs to gather its context
+var mcpPromptVerbs = map[string][]string{
+	"create_landing_page":      {api.VerbList},
+	"diagnose_frontendpage":    {api.VerbGet},
+	"summarize_recent_changes": {api.VerbList},
+}
+
+// authorize checks that the caller in ctx may use verbs on the named page,
+// or on any page when name is empty
+func (h *mcpHandlers) authorize(ctx context.Context, name string, verbs []string) error {
+	if h.service == nil {
+		return errAPINotInitialized
+	}
+	identity := api.IdentityFromContext(ctx)
+	if identity == nil {
+		return api.NewProblem(http.StatusUnauthorized, "missing bearer token")
+	}
+	for _, verb := range verbs {
+		decision, err := h.service.AuthorizeIdentity(ctx, identity, api.Attributes{
+			Verb:      verb,
+			Namespace: h.service.PageNamespace(),
+			Name:      name,
+		})
+		if err != nil {
+			return err
+		}
+		if !decision.Allowed {
+			problem := api.NewProblem(http.StatusForbidden, decision.Reason)
+			problem.Reason = "Forbidden"
+			return problem
+		}
+	}
+	return nil
+}
+
+// authorizeTool checks the caller against the tool's verbs before calling
+// handler. Wrap it in auditTool so that denied calls are audited.
+func (h *mcpHandlers) authorizeTool(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		verbs, ok := mcpToolVerbs[req.Params.Name]
+		if !ok {
+			return toolError(api.NewProblem(http.StatusForbidden, fmt.Sprintf("tool %s has no authorization rule", req.Params.Name))), nil
+		}
+		if err := h.authorize(ctx, req.GetString("name", ""), verbs); err != nil {
+			return toolError(err), nil
+		}
+		return handler(ctx, req)
+	}
+}
+
+// authorizePrompt checks the caller against the prompt's verbs before
+// calling handler
+func (h *mcpHandlers) authorizePrompt(handler server.PromptHandlerFunc) server.PromptHandlerFunc {
+	return func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		verbs, ok := mcpPromptVerbs[req.Params.Name]
+		if !ok {
+			return nil, api.NewProblem(http.StatusForbidden, fmt.Sprintf("prompt %s has no authorization rule", req.Params.Name))
+		}
+		if err := h.authorize(ctx, req.Params.Arguments["name"], verbs); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// filterAuthorizedTools hides the tools the caller can't use on every page
+// from tools/list
+func (h *mcpHandlers) filterAuthorizedTools(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	allowed := make([]mcp.Tool, 0, len(tools))
+	for _, tool := range tools {
+		verbs, ok := mcpToolVerbs[tool.Name]
+		if ok && h.authorize(ctx, "", verbs) == nil {
+			allowed = append(allowed, tool)
+		}
+	}
+	return allowed
+}
+
+// filterAuthorizedResources hides from resources/list the pages the caller
+// can't get, and all of them when they can't list pages
+func (h *mcpHandlers) filterAuthorizedResources(ctx context.Context, _ any, _ *mcp.ListResourcesRequest, result *mcp.ListResourcesResult) {
+	allowed := make([]mcp.Resource, 0, len(result.Resources))
+	if h.authorize(ctx, "", []string{api.VerbList}) == nil {
+		for _, resource := range result.Resources {
+			_, name, err := parseFrontendPageURI(resource.URI)
+			if err == nil && h.authorize(ctx, name, []string{api.VerbGet}) == nil {
+				allowed = append(allowed, resource)
+			}
+		}
+	}
+	result.Resources = allowed
+}
+
+// filterAuthorizedResourceTemplates hides the page template from
+// resources/templates/list when the caller can't get pages
+func (h *mcpHandlers) filterAuthorizedResourceTemplates(ctx context.Context, _ any, _ *mcp.ListResourceTemplatesRequest, result *mcp.ListResourceTemplatesResult) {
+	if h.authorize(ctx, "", []string{api.VerbGet}) != nil {
+		result.ResourceTemplates = []mcp.ResourceTemplate{}
+	}
+}
+
+// localIdentity is the caller of the stdio transport: the user running the
+// process, whose kubeconfig credentials the cluster authorizes
+func localIdentity() *api.Identity {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return &api.Identity{Subject: "local:" + current.Username}
+	}
+	return &api.Identity{Subject: "local"}
+}
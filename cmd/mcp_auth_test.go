@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/audit"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// verbAuthorizer allows each subject the listed verbs
+type verbAuthorizer map[string][]string
+
+func (a verbAuthorizer) Authorize(_ context.Context, identity *api.Identity, attrs api.Attributes) (api.Decision, error) {
+	if slices.Contains(a[identity.Subject], attrs.Verb) {
+		return api.Decision{Allowed: true}, nil
+	}
+	return api.Decision{Reason: identity.Subject + " may not " + attrs.Verb + " frontendpages"}, nil
+}
+
+func TestMCPAuthorization(t *testing.T) {
+	page := &frontendv1alpha1.FrontendPage{
+		ObjectMeta: metav1.ObjectMeta{Name: "page", Namespace: "default"},
+		Spec:       frontendv1alpha1.FrontendPageSpec{Content: "<h1>page</h1>", Image: "nginx:latest", Replicas: 1, Port: 80},
+	}
+	frontendApi := newFrontendApi(t, page)
+	frontendApi.Authorizer = verbAuthorizer{"viewer": {api.VerbGet, api.VerbList}, "operator": {api.VerbGet, api.VerbLogs}}
+	capture := &auditCapture{}
+	auditor := audit.NewLogger(&audit.Policy{Rules: []audit.Rule{{Level: audit.LevelMetadata}}}, capture)
+	s := NewMCPServer("test", "0.0.0", frontendApi, nil, MCPOptions{Auditor: auditor})
+	viewer := api.WithIdentity(context.Background(), &api.Identity{Subject: "viewer", Groups: []string{"readers"}})
+
+	var tools struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	require.Empty(t, mcpRequest(t, viewer, s, "tools/list", map[string]any{}, &tools))
+	var names []string
+	for _, tool := range tools.Tools {
+		names = append(names, tool.Name)
+	}
+	require.ElementsMatch(t, []string{"list_frontendpages", "get_frontendpage", "get_frontendpage_status", "get_frontendpage_pods",
+		"get_frontendpage_events", "get_frontendpage_rollout_history"}, names, "pod logs need the logs verb")
+
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	call := func(ctx context.Context, name string) string {
+		require.Empty(t, mcpRequest(t, ctx, s, "tools/call", map[string]any{"name": name, "arguments": map[string]any{"name": "page"}}, &result))
+		return result.Content[0].Text
+	}
+	call(viewer, "get_frontendpage")
+	require.False(t, result.IsError)
+
+	require.Contains(t, call(viewer, "delete_frontendpage"), "viewer may not delete frontendpages")
+	require.True(t, result.IsError)
+	require.Len(t, capture.events, 1, "denied calls are audited")
+	require.Equal(t, audit.Actor{Subject: "viewer", Groups: []string{"readers"}}, capture.events[0].Actor)
+	require.Equal(t, audit.OutcomeFailure, capture.events[0].Outcome)
+
+	require.Contains(t, call(viewer, "get_frontendpage_logs"), "viewer may not logs frontendpages")
+	require.True(t, result.IsError)
+	operator := api.WithIdentity(context.Background(), &api.Identity{Subject: "operator"})
+	require.NotContains(t, call(operator, "get_frontendpage_logs"), "may not")
+
+	require.Contains(t, call(context.Background(), "get_frontendpage"), "missing bearer token")
+	require.True(t, result.IsError)
+
+	// API keys are limited to their verbs, and authorized as their creator
+	key := api.WithIdentity(context.Background(), &api.Identity{Subject: "apikey:ci", APIKey: "k1", Verbs: []string{api.VerbList},
+		Creator: &api.Identity{Subject: "viewer"}})
+	require.Contains(t, call(key, "get_frontendpage"), `credentials are not valid for \"get\"`)
+	require.Empty(t, mcpRequest(t, key, s, "tools/list", map[string]any{}, &tools))
+	require.Len(t, tools.Tools, 1)
+
+	// Resources and prompts need the verbs of the data they return
+	var read map[string]any
+	require.Contains(t, mcpRequest(t, api.WithIdentity(context.Background(), &api.Identity{Subject: "nobody"}), s,
+		"resources/read", map[string]any{"uri": "frontendpage://default/page"}, &read), "nobody may not get")
+	require.Contains(t, mcpRequest(t, key, s, "prompts/get",
+		map[string]any{"name": "diagnose_frontendpage", "arguments": map[string]string{"name": "page"}}, &read), "not valid")
+
+	// and so do their listings
+	s.resources.OnAdd(page, false)
+	nobody := api.WithIdentity(context.Background(), &api.Identity{Subject: "nobody"})
+	for ctx, listed := range map[context.Context]int{viewer: 1, operator: 0, key: 0, nobody: 0} {
+		var list struct {
+			Resources []map[string]any `json:"resources"`
+		}
+		require.Empty(t, mcpRequest(t, ctx, s, "resources/list", map[string]any{}, &list))
+		require.Len(t, list.Resources, listed, api.IdentityFromContext(ctx).Subject)
+	}
+	for ctx, listed := range map[context.Context]int{viewer: 1, operator: 1, nobody: 0} {
+		var templates struct {
+			ResourceTemplates []map[string]any `json:"resourceTemplates"`
+		}
+		require.Empty(t, mcpRequest(t, ctx, s, "resources/templates/list", map[string]any{}, &templates))
+		require.Len(t, templates.ResourceTemplates, listed, api.IdentityFromContext(ctx).Subject)
+	}
+}
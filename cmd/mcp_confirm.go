@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// mcpProtectedLabel marks pages that destructive MCP tools refuse to touch.
+// They can still be deleted through the REST API or kubectl.
+const mcpProtectedLabel = "frontend.jraver.io/protected"
+
+// defaultMCPConfirmationTTL is the default of --mcp-confirmation-ttl
+const defaultMCPConfirmationTTL = 2 * time.Minute
+
+// mcpConfirmationTTL is the --mcp-confirmation-ttl flag, passed to the MCP
+// server as MCPOptions.ConfirmationTTL
+var mcpConfirmationTTL time.Duration
+
+// pendingConfirmation is what a confirmation token was issued for. A token
+// only confirms the same call by the same caller on the same object, so it
+// can't be replayed against a page recreated under the same name.
+type pendingConfirmation struct {
+	tool    string
+	subject string
+	uid     types.UID
+	expires time.Time
+}
+
+// confirmationStore keeps confirmation tokens in memory until they are
+// used or expire. Like MCP sessions, they are local to the server process.
+type confirmationStore struct {
+	mu      sync.Mutex
+	pending map[string]pendingConfirmation
+}
+
+func newConfirmationStore() *confirmationStore {
+	return &confirmationStore{pending: map[string]pendingConfirmation{}}
+}
+
+func (c *confirmationStore) prune(now time.Time) {
+	for token, p := range c.pending {
+		if now.After(p.expires) {
+			delete(c.pending, token)
+		}
+	}
+}
+
+// issue returns a new token for a call of tool by subject on the object
+// with uid, valid for ttl
+func (c *confirmationStore) issue(tool, subject string, uid types.UID, ttl time.Duration) (string, time.Time, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+	now := time.Now()
+	expires := now.Add(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune(now)
+	c.pending[token] = pendingConfirmation{tool: tool, subject: subject, uid: uid, expires: expires}
+	return token, expires, nil
+}
+
+// redeem consumes token if it was issued for this call. A token that
+// doesn't match is consumed too, so it can't be guessed at.
+func (c *confirmationStore) redeem(token, tool, subject string, uid types.UID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune(time.Now())
+	p, ok := c.pending[token]
+	delete(c.pending, token)
+	if !ok || p.tool != tool || p.subject != subject || p.uid != uid {
+		problem := api.NewProblem(http.StatusConflict, "confirmation token is unknown, expired, already used or was issued for another call, call the tool again without it for a new one")
+		problem.Reason = "ConfirmationInvalid"
+		return problem
+	}
+	return nil
+}
+
+// checkNotProtected refuses destructive tool calls on protected pages
+func checkNotProtected(name string, labels map[string]string) error {
+	if labels[mcpProtectedLabel] != "true" {
+		return nil
+	}
+	problem := api.NewProblem(http.StatusForbidden,
+		fmt.Sprintf("frontend page %s is protected by the %s label and can't be deleted through MCP", name, mcpProtectedLabel))
+	problem.Reason = "Protected"
+	return problem
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/audit"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// deleteAs calls delete_frontendpage as the caller in ctx
+func deleteAs(t *testing.T, ctx context.Context, s *MCPServer, args map[string]any) (bool, map[string]any) {
+	t.Helper()
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	require.Empty(t, mcpRequest(t, ctx, s, "tools/call", map[string]any{"name": "delete_frontendpage", "arguments": args}, &result))
+	require.Len(t, result.Content, 1)
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal([]byte(result.Content[0].Text), &doc), result.Content[0].Text)
+	return result.IsError, doc
+}
+
+func TestConfirmationStore(t *testing.T) {
+	store := newConfirmationStore()
+	token, expires, err := store.issue("delete_frontendpage", "alice", "uid", time.Minute)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Minute), expires, time.Second)
+	require.NoError(t, store.redeem(token, "delete_frontendpage", "alice", "uid"))
+	require.Error(t, store.redeem(token, "delete_frontendpage", "alice", "uid"), "tokens work once")
+
+	token, _, err = store.issue("delete_frontendpage", "alice", "uid", time.Minute)
+	require.NoError(t, err)
+	require.Error(t, store.redeem(token, "delete_frontendpage", "alice", "other-uid"))
+	require.Error(t, store.redeem(token, "delete_frontendpage", "alice", "uid"), "mismatches consume the token")
+
+	token, _, err = store.issue("delete_frontendpage", "alice", "uid", -time.Second)
+	require.NoError(t, err)
+	err = store.redeem(token, "delete_frontendpage", "alice", "uid")
+	require.Equal(t, "ConfirmationInvalid", api.ProblemFromError(err).Reason, "expired tokens are refused")
+	require.Empty(t, store.pending)
+}
+
+func TestDeleteFrontendPage_Safeguards(t *testing.T) {
+	page := &frontendv1alpha1.FrontendPage{ObjectMeta: metav1.ObjectMeta{Name: "page", Namespace: "default", UID: "page-uid"}}
+	protected := &frontendv1alpha1.FrontendPage{ObjectMeta: metav1.ObjectMeta{
+		Name: "home", Namespace: "default", UID: "home-uid", Labels: map[string]string{mcpProtectedLabel: "true"},
+	}}
+	frontendApi := newFrontendApi(t, page, protected,
+		&appsv1.Deployment{ObjectMeta: controlledBy("page", "deploy-uid", page)})
+	capture := &auditCapture{}
+	auditor := audit.NewLogger(&audit.Policy{Rules: []audit.Rule{{Level: audit.LevelMetadata}}}, capture)
+	s := NewMCPServer("test", "0.0.0", frontendApi, nil, MCPOptions{Auditor: auditor, ConfirmationTTL: defaultMCPConfirmationTTL})
+	ctx := mcpContext()
+	// lastDryRun is the audited dry run flag of the last call
+	lastDryRun := func() bool {
+		t.Helper()
+		require.NotEmpty(t, capture.events)
+		return capture.events[len(capture.events)-1].DryRun
+	}
+	exists := func(name string) bool {
+		return frontendApi.K8SClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: name}, &frontendv1alpha1.FrontendPage{}) == nil
+	}
+	deletes := []any{
+		map[string]any{"kind": "FrontendPage", "name": "page"},
+		map[string]any{"kind": "Deployment", "name": "page"},
+	}
+
+	for name, reason := range map[string]string{"": "Invalid", "Bad_Name": "Invalid", "missing": "NotFound", "home": "Protected"} {
+		isError, doc := deleteAs(t, ctx, s, map[string]any{"name": name, "dry_run": true})
+		require.True(t, isError, name)
+		require.Equal(t, reason, doc["reason"], name)
+	}
+
+	isError, doc := deleteAs(t, ctx, s, map[string]any{"name": "page", "dry_run": true})
+	require.False(t, isError, doc)
+	require.Equal(t, "wouldDelete", doc["status"])
+	require.Equal(t, deletes, doc["deletes"])
+	require.True(t, exists("page"))
+
+	// The first call only plans, and its token is bound to the caller
+	isError, doc = deleteAs(t, ctx, s, map[string]any{"name": "page"})
+	require.False(t, isError, doc)
+	require.Equal(t, "confirmationRequired", doc["status"])
+	require.Equal(t, deletes, doc["deletes"])
+	require.True(t, exists("page"))
+	require.True(t, lastDryRun(), "issuing a token is audited as a plan")
+	other := api.WithIdentity(context.Background(), &api.Identity{Subject: "mallory"})
+	isError, doc = deleteAs(t, other, s, map[string]any{"name": "page", "confirmation_token": doc["confirmationToken"]})
+	require.True(t, isError)
+	require.Equal(t, "ConfirmationInvalid", doc["reason"])
+	isError, _ = deleteAs(t, ctx, s, map[string]any{"name": "page", "confirmation_token": "guess"})
+	require.True(t, isError)
+	require.True(t, exists("page"))
+	require.True(t, lastDryRun(), "refused tokens delete nothing")
+
+	_, doc = deleteAs(t, ctx, s, map[string]any{"name": "page", "confirmation_token": ""})
+	require.Equal(t, "confirmationRequired", doc["status"])
+	require.True(t, lastDryRun(), "an empty token only issues a new one")
+	isError, doc = deleteAs(t, ctx, s, map[string]any{"name": "page", "confirmation_token": doc["confirmationToken"]})
+	require.False(t, isError, doc)
+	require.Equal(t, "deleted", doc["status"])
+	require.False(t, exists("page"))
+	require.False(t, lastDryRun())
+
+	// Without confirmations, the first call deletes, but never protected pages
+	s = NewMCPServer("test", "0.0.0", frontendApi, nil, MCPOptions{Auditor: auditor})
+	isError, doc = deleteAs(t, ctx, s, map[string]any{"name": "home"})
+	require.True(t, isError)
+	require.Equal(t, "Protected", doc["reason"])
+	require.True(t, exists("home"))
+	require.NoError(t, frontendApi.K8SClient.Create(context.Background(), &frontendv1alpha1.FrontendPage{
+		ObjectMeta: metav1.ObjectMeta{Name: "draft", Namespace: "default"},
+	}))
+	isError, doc = deleteAs(t, ctx, s, map[string]any{"name": "draft"})
+	require.False(t, isError, doc)
+	require.Equal(t, "deleted", doc["status"])
+	require.False(t, lastDryRun())
+}
+
+func TestPlansOnly(t *testing.T) {
+	h := &mcpHandlers{confirmationTTL: defaultMCPConfirmationTTL}
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "delete_frontendpage"
+	req.Params.Arguments = map[string]any{"name": "page", "confirmation_token": ""}
+	require.True(t, h.plansOnly(req, false), "calls that only return a token plan")
+	require.False(t, h.plansOnly(req, true))
+	req.Params.Arguments = map[string]any{"name": "page", "confirmation_token": "t", "dry_run": true}
+	require.True(t, h.plansOnly(req, false))
+	req.Params.Name = "scale_frontendpage"
+	req.Params.Arguments = map[string]any{"name": "page"}
+	require.False(t, h.plansOnly(req, false))
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/audit"
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// mcpHTTPContext passes the caller authenticated by the transport's
+// middleware and its address to tools, for both HTTP transports
+func mcpHTTPContext(ctx context.Context, r *http.Request) context.Context {
+	if identity := api.IdentityFromContext(r.Context()); identity != nil {
+		ctx = api.WithIdentity(ctx, identity)
+	}
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	return audit.WithSourceIP(ctx, host)
+}
+
+// validateMCPBaseURL checks the public URL the SSE transport announces its
+// message endpoint under. mcp-go silently ignores URLs it can't use.
+func validateMCPBaseURL(baseURL string) error {
+	if baseURL == "" {
+		return nil
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("--mcp-base-url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.HasPrefix(u.Host, ":") {
+		return fmt.Errorf("--mcp-base-url %q must be an absolute http or https URL with a host", baseURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("--mcp-base-url %q must not have a query or fragment", baseURL)
+	}
+	return nil
+}
+
+// newStreamableHTTPHandler serves the streamable HTTP transport on the API
+// listener, behind JwtMiddleware. Responses go through fasthttpadaptor,
+// which buffers them, so the GET stream for server-initiated messages is
+// refused with 405 as the transport allows, and clients get notifications
+// in their POST responses.
+func newStreamableHTTPHandler(s *MCPServer) fasthttp.RequestHandler {
+	streamable := mcpserver.NewStreamableHTTPServer(s.MCPServer,
+		mcpserver.WithHTTPContextFunc(mcpHTTPContext),
+	)
+	handler := fasthttpadaptor.NewFastHTTPHandler(s.subscriptionHandler(streamable,
+		func(r *http.Request) string { return r.Header.Get(mcpserver.HeaderKeySessionID) },
+		func(w http.ResponseWriter, _ string, response mcp.JSONRPCMessage) {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(response); err != nil {
+				log.Debug().Err(err).Msg("Failed to write an MCP response")
+			}
+		}))
+	return func(ctx *fasthttp.RequestCtx) {
+		if ctx.IsGet() {
+			ctx.Response.Header.Set("Allow", "POST, DELETE")
+			api.WriteProblem(ctx, api.NewProblem(fasthttp.StatusMethodNotAllowed, "this endpoint has no server-initiated stream, POST requests instead"))
+			return
+		}
+		handler(ctx)
+	}
+}
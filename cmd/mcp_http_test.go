@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	"github.com/buaazp/fasthttprouter"
+	"github.com/golang-jwt/jwt/v5"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestValidateMCPBaseURL(t *testing.T) {
+	for _, valid := range []string{"", "https://mcp.example.com", "http://localhost:9090", "https://example.com/mcp/"} {
+		require.NoError(t, validateMCPBaseURL(valid), valid)
+	}
+	for _, invalid := range []string{"http://:9090/mcp", "mcp.example.com", "ftp://example.com", "https://example.com?x=1", "https://example.com#top"} {
+		require.Error(t, validateMCPBaseURL(invalid), invalid)
+	}
+}
+
+// toolNames returns the names in a JSON-RPC tools/list response
+func toolNames(t *testing.T, response []byte) []string {
+	t.Helper()
+	var decoded struct {
+		Result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(response, &decoded), string(response))
+	var names []string
+	for _, tool := range decoded.Result.Tools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+const (
+	mcpInitializeRequest = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`
+	mcpToolsListRequest  = `{"jsonrpc":"2.0","id":2,"method":"tools/list","params":{}}`
+	mcpSubscribeRequest  = `{"jsonrpc":"2.0","id":3,"method":"resources/subscribe","params":{"uri":"` + mcpSubscribedURI + `"}}`
+	mcpSubscribeResponse = `{"jsonrpc":"2.0","id":3,"result":{}}`
+	mcpSubscribedURI     = "frontendpage://default/home"
+)
+
+func TestMCPTransports_ShareRegistry(t *testing.T) {
+	frontendApi := newFrontendApi(t)
+	s := NewMCPServer("test", "0.0.0", frontendApi, nil, MCPOptions{})
+	want := toolNames(t, func() []byte {
+		response, err := json.Marshal(s.HandleMessage(mcpContext(), []byte(mcpToolsListRequest)))
+		require.NoError(t, err)
+		return response
+	}())
+	require.NotEmpty(t, want)
+
+	// Streamable HTTP on the API listener
+	config := api.NewConfig()
+	config.JWTSecret = "test-secret"
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "tester",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(config.JWTSecret))
+	require.NoError(t, err)
+	signed := token
+	ln := fasthttputil.NewInmemoryListener()
+	// Served through the route table, like the server does
+	pages := &api.Router{Service: frontendApi, Config: config, MCP: newStreamableHTTPHandler(s)}
+	router := fasthttprouter.New()
+	pages.RegisterRoutes(router, nil)
+	go (&fasthttp.Server{Handler: router.Handler}).Serve(ln)
+	t.Cleanup(func() { ln.Close() })
+	client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+	do := func(method, body, sessionID string) *fasthttp.Response {
+		req, resp := fasthttp.AcquireRequest(), &fasthttp.Response{}
+		defer fasthttp.ReleaseRequest(req)
+		req.Header.SetMethod(method)
+		req.SetRequestURI("http://api/mcp")
+		req.Header.SetContentType("application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if sessionID != "" {
+			req.Header.Set("Mcp-Session-Id", sessionID)
+		}
+		req.SetBodyString(body)
+		require.NoError(t, client.Do(req, resp))
+		return resp
+	}
+	resp := do(fasthttp.MethodPost, mcpInitializeRequest, "")
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), string(resp.Body()))
+	requireDocumented(t, fasthttp.MethodPost, resp)
+	sessionID := string(resp.Header.Peek("Mcp-Session-Id"))
+	require.NotEmpty(t, sessionID)
+	resp = do(fasthttp.MethodPost, `{"jsonrpc":"2.0","method":"notifications/initialized"}`, sessionID)
+	require.Equal(t, fasthttp.StatusAccepted, resp.StatusCode(), string(resp.Body()))
+	requireDocumented(t, fasthttp.MethodPost, resp)
+	resp = do(fasthttp.MethodPost, mcpToolsListRequest, sessionID)
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), string(resp.Body()))
+	require.Equal(t, want, toolNames(t, resp.Body()))
+	resp = do(fasthttp.MethodPost, mcpSubscribeRequest, sessionID)
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), string(resp.Body()))
+	requireDocumented(t, fasthttp.MethodPost, resp)
+	require.JSONEq(t, mcpSubscribeResponse, string(resp.Body()))
+	require.Contains(t, s.resources.subscribers[mcpSubscribedURI], sessionID)
+
+	resp = do(fasthttp.MethodGet, "", sessionID)
+	require.Equal(t, fasthttp.StatusMethodNotAllowed, resp.StatusCode())
+	require.Equal(t, "POST, DELETE", string(resp.Header.Peek("Allow")))
+	requireDocumented(t, fasthttp.MethodGet, resp)
+
+	token = ""
+	resp = do(fasthttp.MethodPost, mcpToolsListRequest, sessionID)
+	require.Equal(t, fasthttp.StatusUnauthorized, resp.StatusCode())
+	requireDocumented(t, fasthttp.MethodPost, resp)
+
+	token = signed
+	resp = do(fasthttp.MethodDelete, "", sessionID)
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), string(resp.Body()))
+	requireDocumented(t, fasthttp.MethodDelete, resp)
+
+	// stdio
+	stdin, input := io.Pipe()
+	output, stdout := io.Pipe()
+	go func() {
+		_ = s.serveStdio(api.WithIdentity(t.Context(), localIdentity()), stdin, stdout)
+	}()
+	t.Cleanup(func() { input.Close() })
+	lines := bufio.NewReader(output)
+	responses := map[string][]byte{}
+	for _, request := range []string{mcpInitializeRequest, mcpToolsListRequest, mcpSubscribeRequest} {
+		_, err = io.WriteString(input, request+"\n")
+		require.NoError(t, err)
+		responses[request], err = lines.ReadBytes('\n')
+		require.NoError(t, err)
+	}
+	require.Equal(t, want, toolNames(t, responses[mcpToolsListRequest]))
+	require.JSONEq(t, mcpSubscribeResponse, string(responses[mcpSubscribeRequest]))
+	require.Contains(t, s.resources.subscribers[mcpSubscribedURI], mcpStdioSessionID)
+}
+
+func TestMCPSSE_Subscribe(t *testing.T) {
+	s := NewMCPServer("test", "0.0.0", newFrontendApi(t), nil, MCPOptions{})
+	sse := mcpserver.NewSSEServer(s.MCPServer, mcpserver.WithSSEContextFunc(mcpHTTPContext))
+	handler := s.newSSEHandler(sse)
+	// Stands in for HTTPAuthMiddleware
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(api.WithIdentity(r.Context(), testIdentity)))
+	}))
+	t.Cleanup(ts.Close)
+
+	stream, err := http.Get(ts.URL + "/sse")
+	require.NoError(t, err)
+	t.Cleanup(func() { stream.Body.Close() })
+	events := bufio.NewReader(stream.Body)
+	// nextData returns the data of the next event on the stream
+	nextData := func() string {
+		for {
+			line, err := events.ReadString('\n')
+			require.NoError(t, err)
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				return strings.TrimSpace(data)
+			}
+		}
+	}
+	endpoint := nextData()
+	require.Contains(t, endpoint, "sessionId=")
+
+	post := func(body string) {
+		resp, err := http.Post(ts.URL+endpoint, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	}
+	post(mcpInitializeRequest)
+	nextData()
+	// The subscription is answered on the stream, like other requests
+	post(mcpSubscribeRequest)
+	require.JSONEq(t, mcpSubscribeResponse, nextData())
+	require.Len(t, s.resources.subscribers[mcpSubscribedURI], 1)
+
+	resp, err := http.Post(ts.URL+"/message?sessionId=unknown", "application/json", strings.NewReader(mcpSubscribeRequest))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// requireDocumented checks that the OpenAPI document lists the status and
+// media type of a response of the MCP endpoint
+func requireDocumented(t *testing.T, method string, resp *fasthttp.Response) {
+	t.Helper()
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Responses map[string]struct {
+				Content map[string]any `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(api.OpenAPIDocument(), &doc))
+	op, ok := doc.Paths[api.DefaultMCPPath][strings.ToLower(method)]
+	require.True(t, ok, "%s %s is not documented", method, api.DefaultMCPPath)
+	documented, ok := op.Responses[strconv.Itoa(resp.StatusCode())]
+	require.True(t, ok, "%s %s answers %d, which is not documented", method, api.DefaultMCPPath, resp.StatusCode())
+	if len(documented.Content) == 0 {
+		require.Empty(t, resp.Body())
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(string(resp.Header.ContentType()))
+	require.NoError(t, err)
+	require.Contains(t, documented.Content, mediaType, "%s %s answers %s, which is not documented", method, api.DefaultMCPPath, mediaType)
+}
+
+func TestLoadMCPKubeConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(`apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com:6443
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: dev
+    namespace: pages
+users:
+- name: dev
+  user:
+    token: secret
+`), 0o600))
+
+	config, contextNamespace, err := loadMCPKubeConfig(false, path)
+	require.NoError(t, err)
+	require.Equal(t, "https://dev.example.com:6443", config.Host)
+	require.Equal(t, "pages", contextNamespace)
+
+	t.Setenv("KUBECONFIG", path)
+	config, _, err = loadMCPKubeConfig(false, "")
+	require.NoError(t, err)
+	require.Equal(t, "https://dev.example.com:6443", config.Host, "$KUBECONFIG is used without --kubeconfig")
+
+	require.NotNil(t, mcpCmd.Flags().Lookup("namespace"))
+}
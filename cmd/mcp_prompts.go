@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultChangesSince is how far back summarize_recent_changes looks
+const defaultChangesSince = 24 * time.Hour
+
+// Procedures the prompts ask the assistant to follow. The cluster context
+// they refer to is attached to the prompt as a JSON resource.
+const (
+	createLandingPageProcedure = `Create a landing page named %q for: %s
+
+Follow this procedure:
+1. If "nameTaken" is true in the attached context, stop and ask for another name.
+2. Write a single self-contained HTML document: inline CSS, no external scripts, a <title> and one <h1>.
+3. Use the image, port and replicas in "conventions" unless the request says otherwise; they are what the other pages in the namespace use.
+4. Show the HTML and the settings, and call create_frontendpage only once they are confirmed.
+5. Check get_frontendpage_status until the phase is Ready, and report the outcome.`
+
+	diagnoseFrontendPageProcedure = `Diagnose FrontendPage %q.
+
+Follow this procedure:
+1. Start from "status.phase": NotDeployed means the controller hasn't created the Deployment yet, Failed means the rollout exceeded its deadline, Progressing means replicas aren't ready yet.
+2. Compare the desired, updated, ready and available replicas of "status.deployment" to find where the rollout is stuck.
+3. Read the Warning "events", newest first. BackOff, Failed and FailedScheduling events on Pods usually name the cause, such as a wrong image or missing resources.
+4. Check the conditions of the page and of its Deployment for reasons and messages.
+5. Report the most likely cause with the evidence for it, then propose a fix using update_frontendpage or scale_frontendpage. Don't apply it without confirmation.`
+
+	summarizeRecentChangesProcedure = `Summarize the changes to %s since %s.
+
+Follow this procedure:
+1. Go through "changes" in order; each holds the spec after an ADDED, MODIFIED or DELETED change. Diff consecutive specs of a page to say what changed: content, image, replicas or port.
+2. Use the Audit events in "events" to say who made each change; their message names the actor.
+3. Mention Warning events that followed a change, since they may have been caused by it.
+4. Group the summary by page, oldest change first, in a few bullet points each.`
+)
+
+// landingPageConventions are the settings most pages in the namespace share
+type landingPageConventions struct {
+	Image    string `json:"image"`
+	Port     int    `json:"port"`
+	Replicas int    `json:"replicas"`
+}
+
+type createLandingPageContext struct {
+	Name          string                 `json:"name"`
+	Purpose       string                 `json:"purpose"`
+	NameTaken     bool                   `json:"nameTaken"`
+	Conventions   landingPageConventions `json:"conventions"`
+	ExistingPages []string               `json:"existingPages"`
+}
+
+type diagnoseFrontendPageContext struct {
+	Spec   api.FrontendPageDoc       `json:"spec"`
+	Status api.FrontendPageStatusDoc `json:"status"`
+	Events []api.EventDoc            `json:"events"`
+}
+
+type recentChangesContext struct {
+	Name  string    `json:"name,omitempty"`
+	Since time.Time `json:"since"`
+	// Changes is null when the server keeps no change history
+	Changes []api.PageChange `json:"changes"`
+	Events  []api.EventDoc   `json:"events"`
+}
+
+// addFrontendPagePrompts registers the page authoring prompts on s
+func (h *mcpHandlers) addFrontendPagePrompts(s *server.MCPServer) {
+	s.AddPrompt(mcp.NewPrompt("create_landing_page",
+		mcp.WithPromptDescription("Create a landing page that follows the conventions of the existing pages"),
+		mcp.WithArgument("name", mcp.RequiredArgument(), mcp.ArgumentDescription("Name of the new FrontendPage")),
+		mcp.WithArgument("purpose", mcp.RequiredArgument(), mcp.ArgumentDescription("What the page is for and what it should say")),
+		mcp.WithArgument("image", mcp.ArgumentDescription("Container image, instead of the one most pages use")),
+	), mapPromptErrors(h.authorizePrompt(h.createLandingPagePrompt)))
+	s.AddPrompt(mcp.NewPrompt("diagnose_frontendpage",
+		mcp.WithPromptDescription("Find out why a FrontendPage isn't ready, from its status, Deployment and events"),
+		mcp.WithArgument("name", mcp.RequiredArgument(), mcp.ArgumentDescription("Name of the FrontendPage")),
+	), mapPromptErrors(h.authorizePrompt(h.diagnoseFrontendPagePrompt)))
+	s.AddPrompt(mcp.NewPrompt("summarize_recent_changes",
+		mcp.WithPromptDescription("Summarize recent changes to FrontendPages and who made them"),
+		mcp.WithArgument("name", mcp.ArgumentDescription("Name of a FrontendPage, all pages when left out")),
+		mcp.WithArgument("since", mcp.ArgumentDescription("How far back to look as a Go duration, 24h by default")),
+	), mapPromptErrors(h.authorizePrompt(h.summarizeRecentChangesPrompt)))
+}
+
+// promptResult returns the procedure followed by the context it refers to
+func promptResult(description, procedure, uri string, context any) (*mcp.GetPromptResult, error) {
+	data, err := json.MarshalIndent(context, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewGetPromptResult(description, []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(procedure)),
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: "application/json",
+			Text:     string(data),
+		})),
+	}), nil
+}
+
+// requiredPromptArgument reads an argument clients must send, since mcp-go
+// doesn't check prompt arguments
+func requiredPromptArgument(req mcp.GetPromptRequest, key string) (string, error) {
+	value := req.Params.Arguments[key]
+	if value == "" {
+		return "", api.Required(key)
+	}
+	return value, nil
+}
+
+// mostCommon returns the value most pages use, the first one seen on a tie
+func mostCommon[T comparable](values []T, fallback T) T {
+	counts := map[T]int{}
+	best := fallback
+	for _, value := range values {
+		counts[value]++
+		if counts[value] > counts[best] {
+			best = value
+		}
+	}
+	return best
+}
+
+func (h *mcpHandlers) createLandingPagePrompt(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	name, err := requiredPromptArgument(req, "name")
+	if err != nil {
+		return nil, err
+	}
+	purpose, err := requiredPromptArgument(req, "purpose")
+	if err != nil {
+		return nil, err
+	}
+	pages, err := h.service.ListFrontendPagesRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	promptContext := createLandingPageContext{Name: name, Purpose: purpose, ExistingPages: []string{}}
+	var images []string
+	var ports, replicas []int
+	for _, page := range pages {
+		promptContext.ExistingPages = append(promptContext.ExistingPages, page.Name)
+		promptContext.NameTaken = promptContext.NameTaken || page.Name == name
+		images = append(images, page.Image)
+		ports = append(ports, page.Port)
+		replicas = append(replicas, page.Replicas)
+	}
+	promptContext.Conventions = landingPageConventions{
+		Image:    mostCommon(images, mcpDefaultImage),
+		Port:     mostCommon(ports, mcpDefaultPort),
+		Replicas: mostCommon(replicas, 1),
+	}
+	if image := req.Params.Arguments["image"]; image != "" {
+		promptContext.Conventions.Image = image
+	}
+	return promptResult("Create landing page "+name, fmt.Sprintf(createLandingPageProcedure, name, purpose),
+		frontendPageURI(h.service.PageNamespace(), name), promptContext)
+}
+
+func (h *mcpHandlers) diagnoseFrontendPagePrompt(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	name, err := requiredPromptArgument(req, "name")
+	if err != nil {
+		return nil, err
+	}
+	spec, err := h.service.GetFrontendPageRaw(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	status, err := h.service.FrontendPageStatusRaw(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	events, err := h.service.FrontendPageEventsRaw(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return promptResult("Diagnose FrontendPage "+name, fmt.Sprintf(diagnoseFrontendPageProcedure, name),
+		frontendPageURI(h.service.PageNamespace(), name), diagnoseFrontendPageContext{Spec: spec, Status: status, Events: events})
+}
+
+func (h *mcpHandlers) summarizeRecentChangesPrompt(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	name := req.Params.Arguments["name"]
+	window := defaultChangesSince
+	if value := req.Params.Arguments["since"]; value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return nil, api.Invalid(api.ProblemCause{
+				Field:   "since",
+				Reason:  string(metav1.CauseTypeFieldValueInvalid),
+				Message: "since must be a positive Go duration such as 30m or 24h",
+			})
+		}
+		window = parsed
+	}
+	since := time.Now().Add(-window)
+
+	events, err := h.service.FrontendPageEventsRaw(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	promptContext := recentChangesContext{Name: name, Since: since, Events: []api.EventDoc{}}
+	for _, event := range events {
+		if !event.LastSeen.Before(since) {
+			promptContext.Events = append(promptContext.Events, event)
+		}
+	}
+	if changes, ok := h.service.FrontendPageChangesRaw(name, since); ok {
+		promptContext.Changes = append([]api.PageChange{}, changes...)
+	}
+
+	subject, uri := "all FrontendPages", frontendPageURIScheme+h.service.PageNamespace()
+	if name != "" {
+		subject, uri = "FrontendPage "+name, frontendPageURI(h.service.PageNamespace(), name)
+	}
+	return promptResult("Summarize recent changes to "+subject,
+		fmt.Sprintf(summarizeRecentChangesProcedure, subject, since.UTC().Format(time.RFC3339)), uri, promptContext)
+}
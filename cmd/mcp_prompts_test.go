@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// getPrompt gets a prompt and decodes its procedure and context. It returns
+// the message of an error response.
+func getPrompt(t *testing.T, s *MCPServer, name string, args map[string]string, promptContext any) (string, string) {
+	t.Helper()
+	var result struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content struct {
+				Type     string `json:"type"`
+				Text     string `json:"text"`
+				Resource struct {
+					URI      string `json:"uri"`
+					MIMEType string `json:"mimeType"`
+					Text     string `json:"text"`
+				} `json:"resource"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	if message := mcpRequest(t, mcpContext(), s, "prompts/get", map[string]any{"name": name, "arguments": args}, &result); message != "" {
+		return "", message
+	}
+	require.Len(t, result.Messages, 2)
+	require.Equal(t, "text", result.Messages[0].Content.Type)
+	resource := result.Messages[1].Content
+	require.Equal(t, "resource", resource.Type)
+	require.Equal(t, "application/json", resource.Resource.MIMEType)
+	require.NoError(t, json.Unmarshal([]byte(resource.Resource.Text), promptContext))
+	return result.Messages[0].Content.Text, ""
+}
+
+func TestMCPPrompts_List(t *testing.T) {
+	var result struct {
+		Prompts []struct {
+			Name      string `json:"name"`
+			Arguments []struct {
+				Name     string `json:"name"`
+				Required bool   `json:"required"`
+			} `json:"arguments"`
+		} `json:"prompts"`
+	}
+	mcpCall(t, NewMCPServer("test", "0.0.0", nil, nil, MCPOptions{}), "prompts/list", map[string]any{}, &result)
+	required := map[string][]string{}
+	for _, prompt := range result.Prompts {
+		required[prompt.Name] = []string{}
+		for _, arg := range prompt.Arguments {
+			if arg.Required {
+				required[prompt.Name] = append(required[prompt.Name], arg.Name)
+			}
+		}
+	}
+	require.Equal(t, map[string][]string{
+		"create_landing_page":      {"name", "purpose"},
+		"diagnose_frontendpage":    {"name"},
+		"summarize_recent_changes": {},
+	}, required)
+}
+
+func TestMCPPrompts_CreateLandingPage(t *testing.T) {
+	page := func(name, image string) *frontendv1alpha1.FrontendPage {
+		return &frontendv1alpha1.FrontendPage{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       frontendv1alpha1.FrontendPageSpec{Content: "<h1>" + name + "</h1>", Image: image, Replicas: 2, Port: 80},
+		}
+	}
+	frontendApi := newFrontendApi(t, page("home", "nginx:1.27"), page("docs", "nginx:1.27"), page("blog", "httpd:2.4"))
+	s := NewMCPServer("test", "0.0.0", frontendApi, nil, MCPOptions{})
+
+	var promptContext createLandingPageContext
+	procedure, message := getPrompt(t, s, "create_landing_page", map[string]string{"name": "launch", "purpose": "announce the launch"}, &promptContext)
+	require.Empty(t, message)
+	require.Contains(t, procedure, "announce the launch")
+	require.False(t, promptContext.NameTaken)
+	require.Equal(t, landingPageConventions{Image: "nginx:1.27", Port: 80, Replicas: 2}, promptContext.Conventions)
+	require.ElementsMatch(t, []string{"home", "docs", "blog"}, promptContext.ExistingPages)
+
+	_, message = getPrompt(t, s, "create_landing_page", map[string]string{"name": "home", "purpose": "x", "image": "caddy:2"}, &promptContext)
+	require.Empty(t, message)
+	require.True(t, promptContext.NameTaken)
+	require.Equal(t, "caddy:2", promptContext.Conventions.Image)
+
+	_, message = getPrompt(t, s, "create_landing_page", map[string]string{"name": "launch"}, &promptContext)
+	require.Contains(t, message, "purpose")
+}
+
+func TestMCPPrompts_DiagnoseFrontendPage(t *testing.T) {
+	replicas := int32(2)
+	page := &frontendv1alpha1.FrontendPage{
+		ObjectMeta: metav1.ObjectMeta{Name: "page", Namespace: "default", UID: "page-uid"},
+		Spec:       frontendv1alpha1.FrontendPageSpec{Content: "<h1>page</h1>", Image: "nginx:typo", Replicas: 2, Port: 80},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: controlledBy("page", "deploy-uid", page),
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{UpdatedReplicas: 2, UnavailableReplicas: 2,
+			Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded"}}},
+	}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: controlledBy("page-6d4cf56db6", "rs-uid", deployment)}
+	frontendApi := newFrontendApi(t, page, deployment, replicaSet,
+		&corev1.Pod{ObjectMeta: controlledBy("page-6d4cf56db6-x2x7q", "pod-uid", replicaSet)},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "page-pull", Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "page-6d4cf56db6-x2x7q"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "Failed",
+			Message:        `Failed to pull image "nginx:typo"`,
+			LastTimestamp:  metav1.Now(),
+		},
+	)
+	s := NewMCPServer("test", "0.0.0", frontendApi, nil, MCPOptions{})
+
+	var promptContext diagnoseFrontendPageContext
+	_, message := getPrompt(t, s, "diagnose_frontendpage", map[string]string{"name": "page"}, &promptContext)
+	require.Empty(t, message)
+	require.Equal(t, "nginx:typo", promptContext.Spec.Image)
+	require.Equal(t, api.FrontendPagePhaseFailed, promptContext.Status.Phase)
+	require.Equal(t, "ProgressDeadlineExceeded", promptContext.Status.Deployment.Conditions[0].Reason)
+	require.Len(t, promptContext.Events, 1)
+	require.Equal(t, "Failed", promptContext.Events[0].Reason)
+
+	_, message = getPrompt(t, s, "diagnose_frontendpage", map[string]string{"name": "missing"}, &promptContext)
+	require.Contains(t, message, "not found")
+	_, message = getPrompt(t, s, "diagnose_frontendpage", nil, &promptContext)
+	require.Contains(t, message, "name")
+}
+
+func TestMCPPrompts_SummarizeRecentChanges(t *testing.T) {
+	frontendApi := newFrontendApi(t, &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "page-audit", Namespace: "default"},
+		InvolvedObject: corev1.ObjectReference{Kind: "FrontendPage", Name: "page"},
+		Type:           corev1.EventTypeNormal,
+		Reason:         "AuditPatch",
+		Message:        "patch by alice",
+		LastTimestamp:  metav1.NewTime(time.Now().Add(-time.Hour)),
+	})
+	s := NewMCPServer("test", "0.0.0", frontendApi, nil, MCPOptions{})
+
+	var promptContext recentChangesContext
+	_, message := getPrompt(t, s, "summarize_recent_changes", map[string]string{}, &promptContext)
+	require.Empty(t, message)
+	require.Nil(t, promptContext.Changes, "there is no change history without a watcher")
+	require.Len(t, promptContext.Events, 1)
+
+	frontendApi.Watcher = api.NewFrontendPageWatcher("default")
+	frontendApi.Watcher.OnAdd(&frontendv1alpha1.FrontendPage{ObjectMeta: metav1.ObjectMeta{Name: "page", Namespace: "default", ResourceVersion: "1"}}, false)
+	procedure, message := getPrompt(t, s, "summarize_recent_changes", map[string]string{"name": "page", "since": "30m"}, &promptContext)
+	require.Empty(t, message)
+	require.Contains(t, procedure, "FrontendPage page")
+	require.Len(t, promptContext.Changes, 1)
+	require.Equal(t, "ADDED", promptContext.Changes[0].Type)
+	require.Empty(t, promptContext.Events, "the audit event is older than 30m")
+
+	_, message = getPrompt(t, s, "summarize_recent_changes", map[string]string{"since": "yesterday"}, &promptContext)
+	require.Contains(t, message, "since")
+}
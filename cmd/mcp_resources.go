@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// frontendPageURIScheme prefixes the resource URI of every page,
+// frontendpage://{namespace}/{name}
+const frontendPageURIScheme = "frontendpage://"
+
+// frontendPageURITemplate is the resource template for discovering pages
+const frontendPageURITemplate = frontendPageURIScheme + "{namespace}/{name}"
+
+func frontendPageURI(namespace, name string) string {
+	return frontendPageURIScheme + namespace + "/" + name
+}
+
+// parseFrontendPageURI splits a frontendpage:// URI into namespace and name
+func parseFrontendPageURI(uri string) (string, string, error) {
+	path, ok := strings.CutPrefix(uri, frontendPageURIScheme)
+	namespace, name, found := strings.Cut(path, "/")
+	if !ok || !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", api.BadRequest("resource URI %q is not of the form %s", uri, frontendPageURITemplate)
+	}
+	return namespace, name, nil
+}
+
+// frontendPageResource is the JSON document of a page resource
+type frontendPageResource struct {
+	Spec   api.FrontendPageDoc       `json:"spec"`
+	Status api.FrontendPageStatusDoc `json:"status"`
+}
+
+// frontendPageResources publishes FrontendPages as MCP resources and keeps
+// them current from the manager's informer. Adding and removing pages sends
+// notifications/resources/list_changed, and sessions that subscribed to a
+// page receive notifications/resources/updated when it changes.
+type frontendPageResources struct {
+	server *server.MCPServer
+	h      *mcpHandlers
+
+	mu sync.Mutex
+	// sessions holds the IDs of the registered sessions
+	sessions map[string]struct{}
+	// subscribers holds the IDs of the sessions subscribed to each URI
+	subscribers map[string]map[string]struct{}
+}
+
+// errMCPSessionUnknown is returned for subscriptions of sessions that aren't
+// registered with the server
+var errMCPSessionUnknown = errors.New("subscriptions need an initialized session")
+
+// newFrontendPageResources registers the resource template on s. hooks must
+// be the hooks s was created with.
+func newFrontendPageResources(s *server.MCPServer, hooks *server.Hooks, h *mcpHandlers) *frontendPageResources {
+	r := &frontendPageResources{
+		server:      s,
+		h:           h,
+		sessions:    map[string]struct{}{},
+		subscribers: map[string]map[string]struct{}{},
+	}
+	s.AddResourceTemplate(mcp.NewResourceTemplate(frontendPageURITemplate, "FrontendPage",
+		mcp.WithTemplateDescription("Spec, status and rendered HTML content of a FrontendPage"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), mapResourceErrors(r.read))
+	hooks.AddOnRegisterSession(func(_ context.Context, session server.ClientSession) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.sessions[session.SessionID()] = struct{}{}
+	})
+	hooks.AddOnUnregisterSession(func(_ context.Context, session server.ClientSession) {
+		r.unsubscribeSession(session.SessionID())
+	})
+	return r
+}
+
+// authorizedPage returns the name of the page behind uri, once the caller is
+// allowed to get it
+func (r *frontendPageResources) authorizedPage(ctx context.Context, uri string) (string, error) {
+	if r.h.service == nil {
+		return "", errAPINotInitialized
+	}
+	namespace, name, err := parseFrontendPageURI(uri)
+	if err != nil {
+		return "", err
+	}
+	if namespace != r.h.service.PageNamespace() {
+		return "", api.NewProblem(http.StatusNotFound, fmt.Sprintf("namespace %s is not served, only %s", namespace, r.h.service.PageNamespace()))
+	}
+	if err := r.h.authorize(ctx, name, []string{api.VerbGet}); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// read returns the page as a JSON document, followed by its rendered content
+// once the controller has generated it
+func (r *frontendPageResources) read(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	name, err := r.authorizedPage(ctx, req.Params.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := r.h.service.GetFrontendPageRaw(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	status, err := r.h.service.FrontendPageStatusRaw(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(frontendPageResource{Spec: spec, Status: status}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	contents := []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      req.Params.URI,
+		MIMEType: "application/json",
+		Text:     string(data),
+	}}
+
+	content, err := r.h.service.GetFrontendPageContentRaw(ctx, name)
+	switch {
+	case err == nil:
+		contents = append(contents, mcp.TextResourceContents{
+			URI:      req.Params.URI,
+			MIMEType: "text/html",
+			Text:     content,
+		})
+	case api.ProblemFromError(err).Status != http.StatusNotFound:
+		return nil, err
+	}
+	return contents, nil
+}
+
+// subscribe records the session as a subscriber of uri. Pages that don't
+// exist yet can be subscribed to, like they can be read through the template.
+func (r *frontendPageResources) subscribe(ctx context.Context, sessionID, uri string) error {
+	if _, err := r.authorizedPage(ctx, uri); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.sessions[sessionID]; !ok {
+		return errMCPSessionUnknown
+	}
+	sessions, ok := r.subscribers[uri]
+	if !ok {
+		sessions = map[string]struct{}{}
+		r.subscribers[uri] = sessions
+	}
+	sessions[sessionID] = struct{}{}
+	return nil
+}
+
+// unsubscribe removes the session from the subscribers of uri, whether it
+// subscribed or not
+func (r *frontendPageResources) unsubscribe(sessionID, uri string) error {
+	if _, _, err := parseFrontendPageURI(uri); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.sessions[sessionID]; !ok {
+		return errMCPSessionUnknown
+	}
+	delete(r.subscribers[uri], sessionID)
+	if len(r.subscribers[uri]) == 0 {
+		delete(r.subscribers, uri)
+	}
+	return nil
+}
+
+func (r *frontendPageResources) unsubscribeSession(sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, sessionID)
+	for uri, sessions := range r.subscribers {
+		delete(sessions, sessionID)
+		if len(sessions) == 0 {
+			delete(r.subscribers, uri)
+		}
+	}
+}
+
+// notifyUpdated sends notifications/resources/updated to the subscribers of
+// uri, and forgets sessions that are gone
+func (r *frontendPageResources) notifyUpdated(uri string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for sessionID := range r.subscribers[uri] {
+		err := r.server.SendNotificationToSpecificClient(sessionID, mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+		if errors.Is(err, server.ErrSessionNotFound) {
+			delete(r.subscribers[uri], sessionID)
+		} else if err != nil {
+			log.Debug().Err(err).Str("uri", uri).Msg("Failed to notify MCP session of a resource update")
+		}
+	}
+}
+
+// served filters informer events down to pages in the API's namespace
+func (r *frontendPageResources) served(obj interface{}) (*frontendv1alpha1.FrontendPage, bool) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	page, ok := obj.(*frontendv1alpha1.FrontendPage)
+	if !ok {
+		log.Error().Msgf("MCP resources received unexpected object %T", obj)
+		return nil, false
+	}
+	if r.h.service != nil && page.Namespace != r.h.service.PageNamespace() {
+		return nil, false
+	}
+	return page, true
+}
+
+// OnAdd implements cache.ResourceEventHandler
+func (r *frontendPageResources) OnAdd(obj interface{}, _ bool) {
+	page, ok := r.served(obj)
+	if !ok {
+		return
+	}
+	uri := frontendPageURI(page.Namespace, page.Name)
+	r.server.AddResource(mcp.NewResource(uri, page.Name,
+		mcp.WithResourceDescription(fmt.Sprintf("FrontendPage %s/%s", page.Namespace, page.Name)),
+		mcp.WithMIMEType("application/json"),
+	), r.read)
+	// Sessions can subscribe to pages before they are created
+	r.notifyUpdated(uri)
+}
+
+// OnUpdate implements cache.ResourceEventHandler
+func (r *frontendPageResources) OnUpdate(oldObj, newObj interface{}) {
+	page, ok := r.served(newObj)
+	if !ok {
+		return
+	}
+	// Resyncs deliver unchanged objects
+	if old, ok := oldObj.(*frontendv1alpha1.FrontendPage); ok && old.ResourceVersion == page.ResourceVersion {
+		return
+	}
+	r.notifyUpdated(frontendPageURI(page.Namespace, page.Name))
+}
+
+// OnDelete implements cache.ResourceEventHandler
+func (r *frontendPageResources) OnDelete(obj interface{}) {
+	page, ok := r.served(obj)
+	if !ok {
+		return
+	}
+	uri := frontendPageURI(page.Namespace, page.Name)
+	r.server.RemoveResource(uri)
+	// Subscriptions outlive the page, until the session unsubscribes
+	r.notifyUpdated(uri)
+}
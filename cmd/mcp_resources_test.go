@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	apifake "github.com/JRaver/k8s-controller-tutorial/pkg/api/fake"
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/ctrl"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// fakeInformer hands its event handler to the test
+type fakeInformer struct {
+	ctrlcache.Informer
+	handler toolscache.ResourceEventHandler
+}
+
+func (f *fakeInformer) AddEventHandler(handler toolscache.ResourceEventHandler) (toolscache.ResourceEventHandlerRegistration, error) {
+	f.handler = handler
+	return nil, nil
+}
+
+// testSession is an initialized MCP session that buffers notifications
+type testSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *testSession) Initialize()                                         {}
+func (s *testSession) Initialized() bool                                   { return true }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.notifications }
+func (s *testSession) SessionID() string                                   { return s.id }
+
+// nextNotification returns the method of the next buffered notification
+func (s *testSession) nextNotification(t *testing.T) (string, any) {
+	t.Helper()
+	select {
+	case n := <-s.notifications:
+		return n.Method, n.Params.AdditionalFields["uri"]
+	default:
+		t.Fatal("expected a notification")
+		return "", nil
+	}
+}
+
+func TestParseFrontendPageURI(t *testing.T) {
+	namespace, name, err := parseFrontendPageURI("frontendpage://default/landing")
+	require.NoError(t, err)
+	require.Equal(t, "default", namespace)
+	require.Equal(t, "landing", name)
+
+	for _, uri := range []string{"frontendpage://default", "frontendpage:///landing", "frontendpage://default/a/b", "https://default/landing"} {
+		_, _, err := parseFrontendPageURI(uri)
+		require.Error(t, err, uri)
+	}
+}
+
+func TestFrontendPageResources(t *testing.T) {
+	page := &frontendv1alpha1.FrontendPage{
+		ObjectMeta: metav1.ObjectMeta{Name: "page", Namespace: "default", ResourceVersion: "1"},
+		Spec:       frontendv1alpha1.FrontendPageSpec{Content: "<h1>page</h1>", Image: "nginx:latest", Replicas: 1, Port: 80},
+	}
+	isController := true
+	content := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "page", Namespace: "default", OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: frontendv1alpha1.SchemeGroupVersion.String(), Kind: "FrontendPage", Name: "page", UID: "uid", Controller: &isController,
+		}}},
+		Data: map[string]string{ctrl.ContentKey: "<html><h1>page</h1></html>"},
+	}
+	frontendApi := newFrontendApi(t, page)
+	require.NoError(t, frontendApi.K8SClient.Create(context.Background(), content))
+
+	informer := &fakeInformer{}
+	s := NewMCPServer("test", "0.0.0", frontendApi, informer, MCPOptions{})
+	require.NotNil(t, informer.handler)
+	session := &testSession{id: "session", notifications: make(chan mcp.JSONRPCNotification, 10)}
+	ctx := mcpContext()
+	require.NoError(t, s.RegisterSession(ctx, session))
+	ctx = s.WithContext(ctx, session)
+
+	var templates struct {
+		ResourceTemplates []struct {
+			URITemplate string `json:"uriTemplate"`
+		} `json:"resourceTemplates"`
+	}
+	mcpCall(t, s, "resources/templates/list", map[string]any{}, &templates)
+	require.Equal(t, frontendPageURITemplate, templates.ResourceTemplates[0].URITemplate)
+
+	// Pages from the informer are listed, other namespaces are not
+	informer.handler.OnAdd(page, true)
+	informer.handler.OnAdd(&frontendv1alpha1.FrontendPage{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "kube-system"}}, true)
+	method, _ := session.nextNotification(t)
+	require.Equal(t, mcp.MethodNotificationResourcesListChanged, method)
+	var list struct {
+		Resources []mcp.Resource `json:"resources"`
+	}
+	mcpCall(t, s, "resources/list", map[string]any{}, &list)
+	require.Len(t, list.Resources, 1)
+	require.Equal(t, "frontendpage://default/page", list.Resources[0].URI)
+
+	var read struct {
+		Contents []mcp.TextResourceContents `json:"contents"`
+	}
+	require.Empty(t, mcpRequest(t, ctx, s, "resources/read", map[string]any{"uri": "frontendpage://default/page"}, &read))
+	require.Len(t, read.Contents, 2)
+	require.Equal(t, "application/json", read.Contents[0].MIMEType)
+	require.Contains(t, read.Contents[0].Text, `"image": "nginx:latest"`)
+	require.Contains(t, read.Contents[0].Text, `"phase": "NotDeployed"`)
+	require.Equal(t, "text/html", read.Contents[1].MIMEType)
+	require.Equal(t, "<html><h1>page</h1></html>", read.Contents[1].Text)
+
+	// The template serves pages that aren't listed yet, and reports missing ones
+	require.NoError(t, frontendApi.K8SClient.Create(context.Background(), &frontendv1alpha1.FrontendPage{
+		ObjectMeta: metav1.ObjectMeta{Name: "draft", Namespace: "default"},
+	}))
+	require.Empty(t, mcpRequest(t, mcpContext(), s, "resources/read", map[string]any{"uri": "frontendpage://default/draft"}, &read))
+	require.Len(t, read.Contents, 1, "content that hasn't been generated is left out")
+	require.Contains(t, mcpRequest(t, mcpContext(), s, "resources/read", map[string]any{"uri": "frontendpage://default/missing"}, &read), "not found")
+
+	// Reading a page doesn't subscribe to it
+	updated := page.DeepCopy()
+	updated.ResourceVersion = "2"
+	informer.handler.OnUpdate(page, updated)
+	require.Empty(t, session.notifications)
+
+	var empty map[string]any
+	require.Empty(t, mcpRequest(t, ctx, s, "resources/subscribe", map[string]any{"uri": "frontendpage://default/page"}, &empty))
+	require.Empty(t, empty)
+	require.Contains(t, mcpRequest(t, ctx, s, "resources/subscribe", map[string]any{"uri": "frontendpage://default"}, &empty), "not of the form")
+	require.Contains(t, mcpRequest(t, ctx, s, "resources/subscribe", map[string]any{"uri": "frontendpage://kube-system/page"}, &empty), "not served")
+	require.Contains(t, mcpRequest(t, mcpContext(), s, "resources/subscribe", map[string]any{"uri": "frontendpage://default/page"}, &empty), "session")
+
+	// The session subscribed to the page, so it hears about changes to it
+	informer.handler.OnUpdate(updated, updated.DeepCopy())
+	require.Empty(t, session.notifications, "resyncs are not changes")
+	changed := updated.DeepCopy()
+	changed.ResourceVersion = "3"
+	informer.handler.OnUpdate(updated, changed)
+	method, uri := session.nextNotification(t)
+	require.Equal(t, mcp.MethodNotificationResourceUpdated, method)
+	require.Equal(t, "frontendpage://default/page", uri)
+
+	informer.handler.OnDelete(toolscache.DeletedFinalStateUnknown{Obj: changed})
+	method, _ = session.nextNotification(t)
+	require.Equal(t, mcp.MethodNotificationResourcesListChanged, method)
+	method, _ = session.nextNotification(t)
+	require.Equal(t, mcp.MethodNotificationResourceUpdated, method)
+	mcpCall(t, s, "resources/list", map[string]any{}, &list)
+	require.Empty(t, list.Resources)
+
+	// The subscription outlives the page, and hears about it coming back
+	informer.handler.OnAdd(page, false)
+	method, _ = session.nextNotification(t)
+	require.Equal(t, mcp.MethodNotificationResourcesListChanged, method)
+	method, uri = session.nextNotification(t)
+	require.Equal(t, mcp.MethodNotificationResourceUpdated, method)
+	require.Equal(t, "frontendpage://default/page", uri)
+
+	require.Empty(t, mcpRequest(t, ctx, s, "resources/unsubscribe", map[string]any{"uri": "frontendpage://default/page"}, &empty))
+	informer.handler.OnUpdate(page, changed)
+	require.Empty(t, session.notifications)
+
+	require.Empty(t, mcpRequest(t, ctx, s, "resources/subscribe", map[string]any{"uri": "frontendpage://default/page"}, &empty))
+	s.UnregisterSession(ctx, session.id)
+	require.Empty(t, s.resources.subscribers)
+	require.Empty(t, s.resources.sessions)
+}
+
+func TestFrontendPageResources_SubscribeAuthorization(t *testing.T) {
+	frontendApi := newFrontendApi(t)
+	frontendApi.Authorizer = verbAuthorizer{"viewer": {api.VerbGet}, "operator": {api.VerbList}}
+	s := NewMCPServer("test", "0.0.0", frontendApi, nil, MCPOptions{})
+	session := &testSession{id: "session", notifications: make(chan mcp.JSONRPCNotification, 10)}
+	require.NoError(t, s.RegisterSession(context.Background(), session))
+
+	// The capability is advertised now that subscriptions are answered
+	var initialized struct {
+		Capabilities struct {
+			Resources struct {
+				Subscribe bool `json:"subscribe"`
+			} `json:"resources"`
+		} `json:"capabilities"`
+	}
+	mcpCall(t, s, "initialize", map[string]any{"protocolVersion": "2025-03-26", "capabilities": map[string]any{}, "clientInfo": map[string]any{"name": "test", "version": "1"}}, &initialized)
+	require.True(t, initialized.Capabilities.Resources.Subscribe)
+
+	var empty map[string]any
+	operator := s.WithContext(api.WithIdentity(context.Background(), &api.Identity{Subject: "operator"}), session)
+	require.NotEmpty(t, mcpRequest(t, operator, s, "resources/subscribe", map[string]any{"uri": "frontendpage://default/page"}, &empty))
+	require.Empty(t, s.resources.subscribers, "subscribing needs the get verb, like reading")
+	viewer := s.WithContext(api.WithIdentity(context.Background(), &api.Identity{Subject: "viewer"}), session)
+	require.Empty(t, mcpRequest(t, viewer, s, "resources/subscribe", map[string]any{"uri": "frontendpage://default/page"}, &empty))
+	require.Len(t, s.resources.subscribers, 1)
+}
+
+var _ server.ClientSession = &testSession{}
+
+// brokenService fails to get pages with an error that must not reach clients
+type brokenService struct {
+	*apifake.Service
+}
+
+func (brokenService) GetFrontendPageRaw(context.Context, string) (api.FrontendPageDoc, error) {
+	return api.FrontendPageDoc{}, errors.New("dial tcp 10.0.0.5:6443: connection refused")
+}
+
+func TestFrontendPageResources_InternalErrors(t *testing.T) {
+	t.Parallel()
+	s := NewMCPServer("test", "0.0.0", brokenService{apifake.NewService("default")}, nil, MCPOptions{})
+
+	var read map[string]any
+	message := mcpRequest(t, mcpContext(), s, "resources/read", map[string]any{"uri": "frontendpage://default/page"}, &read)
+	require.Equal(t, api.InternalErrorDetail, message)
+	var prompt map[string]any
+	message = mcpRequest(t, mcpContext(), s, "prompts/get", map[string]any{"name": "diagnose_frontendpage", "arguments": map[string]any{"name": "page"}}, &prompt)
+	require.Equal(t, api.InternalErrorDetail, message)
+
+	// Problems are still sent as they are
+	message = mcpRequest(t, mcpContext(), s, "prompts/get", map[string]any{"name": "diagnose_frontendpage", "arguments": map[string]any{}}, &prompt)
+	require.Contains(t, message, "name")
+}
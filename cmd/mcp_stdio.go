@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrlruntime "sigs.k8s.io/controller-runtime"
+	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// mcpCmd serves the MCP tools, resources and prompts over stdio, so desktop
+// clients can launch it as a local process with the user's kubeconfig
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve the MCP server over stdio",
+	Long: `Serve the MCP server over stdin and stdout, for MCP clients that launch
+servers as local processes. It talks to the cluster with the current kubeconfig
+context, or --kubeconfig, and logs to stderr.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		level := SetLogLevel(LogLevel)
+		ConfigureLogger(level)
+		// stdout carries the protocol
+		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339})
+		ctrlruntime.SetLogger(zap.New(zap.WriteTo(os.Stderr)))
+
+		config, contextNamespace, err := loadMCPKubeConfig(inCluster, kubeconfig)
+		if err != nil {
+			log.Error().Err(err).Msg("Error loading kubeconfig")
+			os.Exit(1)
+		}
+		if !cmd.Flags().Changed("namespace") && contextNamespace != "" {
+			namespace = contextNamespace
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		frontendApi, pages, err := newStandaloneFrontendApi(ctx, config, namespace)
+		if err != nil {
+			log.Error().Err(err).Msg("Error creating FrontendPage client")
+			os.Exit(1)
+		}
+
+		log.Info().Msgf("Serving MCP over stdio for namespace %s", namespace)
+		mcpServer := NewMCPServer("K8S controller MCP", "1.0.0", frontendApi, pages, MCPOptions{ConfirmationTTL: mcpConfirmationTTL})
+		// The process runs with the user's own credentials, which the
+		// cluster authorizes, so there are no tokens to check
+		identityCtx := api.WithIdentity(ctx, localIdentity())
+		if err := mcpServer.serveStdio(identityCtx, os.Stdin, os.Stdout); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("MCP stdio server failed")
+			os.Exit(1)
+		}
+	},
+}
+
+// loadMCPKubeConfig returns the cluster config and the namespace of the
+// kubeconfig context. Without --kubeconfig it follows $KUBECONFIG and
+// ~/.kube/config like kubectl does.
+func loadMCPKubeConfig(inCluster bool, kubeconfig string) (*rest.Config, string, error) {
+	if inCluster {
+		_, config, err := ChooseKubeConnectionType(true, "")
+		return config, "", err
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{})
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	contextNamespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, "", err
+	}
+	return config, contextNamespace, nil
+}
+
+// newStandaloneFrontendApi wires the API up without a manager: reads go to
+// the API server, and an informer cache started on ctx feeds the MCP
+// resources and the change history of the prompts
+func newStandaloneFrontendApi(ctx context.Context, config *rest.Config, namespace string) (*api.FrontendPageApi, ctrlcache.Informer, error) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, nil, err
+	}
+	if err := frontendv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, nil, err
+	}
+	k8sClient, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	cache, err := ctrlcache.New(config, ctrlcache.Options{
+		Scheme:            scheme,
+		DefaultNamespaces: map[string]ctrlcache.Config{namespace: {}},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	pages, err := cache.GetInformer(ctx, &frontendv1alpha1.FrontendPage{})
+	if err != nil {
+		return nil, nil, err
+	}
+	watcher := api.NewFrontendPageWatcher(namespace)
+	if _, err := pages.AddEventHandler(watcher); err != nil {
+		return nil, nil, err
+	}
+	go func() {
+		if err := cache.Start(ctx); err != nil {
+			log.Error().Err(err).Msg("FrontendPage cache stopped")
+		}
+	}()
+	return &api.FrontendPageApi{
+		K8SClient: k8sClient,
+		Clientset: clientset,
+		Namespace: namespace,
+		Watcher:   watcher,
+	}, pages, nil
+}
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+	mcpCmd.Flags().StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file (defaults to $KUBECONFIG or ~/.kube/config)")
+	mcpCmd.Flags().BoolVar(&inCluster, "in-cluster", false, "Use in-cluster configuration")
+	mcpCmd.Flags().DurationVar(&mcpConfirmationTTL, "mcp-confirmation-ttl", defaultMCPConfirmationTTL, "How long destructive tools wait for their confirmation token (0 runs them in one step)")
+	mcpCmd.Flags().StringVar(&namespace, "namespace", "default", "Namespace of the FrontendPages (defaults to the kubeconfig context's namespace)")
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+)
+
+// Methods of the subscription requests, which mcp-go has types but no
+// handlers for
+const (
+	mcpMethodSubscribe   = "resources/subscribe"
+	mcpMethodUnsubscribe = "resources/unsubscribe"
+)
+
+// mcpStdioSessionID is the ID of mcp-go's only stdio session
+const mcpStdioSessionID = "stdio"
+
+// HandleMessage answers subscription requests of the session in ctx, and
+// passes other messages to mcp-go
+func (s *MCPServer) HandleMessage(ctx context.Context, message json.RawMessage) mcp.JSONRPCMessage {
+	sessionID := ""
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		sessionID = session.SessionID()
+	}
+	if response, ok := s.handleSubscription(ctx, sessionID, message); ok {
+		return response
+	}
+	return s.MCPServer.HandleMessage(ctx, message)
+}
+
+// handleSubscription answers message when it is a resources/subscribe or
+// resources/unsubscribe request of sessionID. It returns false for every
+// other message, which mcp-go handles, malformed ones included.
+func (s *MCPServer) handleSubscription(ctx context.Context, sessionID string, message []byte) (mcp.JSONRPCMessage, bool) {
+	var request struct {
+		JSONRPC string        `json:"jsonrpc"`
+		ID      mcp.RequestId `json:"id"`
+		Method  string        `json:"method"`
+		Params  struct {
+			URI string `json:"uri"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(message, &request); err != nil || request.JSONRPC != mcp.JSONRPC_VERSION || request.ID.IsNil() {
+		return nil, false
+	}
+
+	var err error
+	switch request.Method {
+	case mcpMethodSubscribe:
+		err = s.resources.subscribe(ctx, sessionID, request.Params.URI)
+	case mcpMethodUnsubscribe:
+		err = s.resources.unsubscribe(sessionID, request.Params.URI)
+	default:
+		return nil, false
+	}
+	if err != nil {
+		return subscriptionError(request.ID, err), true
+	}
+	return mcp.NewJSONRPCResultResponse(request.ID, mcp.EmptyResult{}), true
+}
+
+// subscriptionError maps err to a JSON-RPC error. The raw error of a 500 is
+// only logged.
+func subscriptionError(id mcp.RequestId, err error) mcp.JSONRPCMessage {
+	if errors.Is(err, errMCPSessionUnknown) {
+		return mcp.NewJSONRPCError(id, mcp.INVALID_REQUEST, err.Error(), nil)
+	}
+	problem := api.ProblemFromError(err)
+	code := mcp.INTERNAL_ERROR
+	switch problem.Status {
+	case http.StatusBadRequest:
+		code = mcp.INVALID_PARAMS
+	case http.StatusNotFound:
+		code = mcp.RESOURCE_NOT_FOUND
+	case http.StatusUnauthorized, http.StatusForbidden:
+		code = mcp.INVALID_REQUEST
+	}
+	if problem.Detail == api.InternalErrorDetail {
+		log.Error().Err(err).Msg("MCP subscription failed")
+	}
+	return mcp.NewJSONRPCError(id, code, problem.Detail, nil)
+}
+
+// subscriptionHandler answers the subscription requests POSTed to an HTTP
+// transport, and passes other requests to next. sessionID reads the session
+// of a request, and reply delivers the response the way the transport does.
+func (s *MCPServer) subscriptionHandler(next http.Handler, sessionID func(*http.Request) string,
+	reply func(w http.ResponseWriter, sessionID string, response mcp.JSONRPCMessage)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := sessionID(r)
+		if r.Method != http.MethodPost || session == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read the request", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		response, ok := s.handleSubscription(mcpHTTPContext(r.Context(), r), session, body)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		reply(w, session, response)
+	})
+}
+
+// newSSEHandler serves the SSE transport. Responses to the messages POSTed
+// by a session are sent on its event stream.
+func (s *MCPServer) newSSEHandler(sse *server.SSEServer) http.Handler {
+	return s.subscriptionHandler(sse,
+		func(r *http.Request) string { return r.URL.Query().Get("sessionId") },
+		func(w http.ResponseWriter, sessionID string, response mcp.JSONRPCMessage) {
+			if err := sse.SendEventToSession(sessionID, response); err != nil {
+				http.Error(w, "Invalid session ID", http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+		})
+}
+
+// syncWriter serializes the lines written by the stdio server and by
+// serveStdio, each of which is one Write
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// serveStdio runs the stdio transport until ctx is done or stdin is closed.
+// Subscription requests are answered from the input on ctx, which must carry
+// the caller's identity, and other lines are passed to mcp-go.
+func (s *MCPServer) serveStdio(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+	out := &syncWriter{w: stdout}
+	lines, input := io.Pipe()
+	go func() {
+		input.CloseWithError(s.filterStdio(ctx, stdin, input, out))
+	}()
+	defer lines.Close()
+	return server.NewStdioServer(s.MCPServer).Listen(ctx, lines, out)
+}
+
+// filterStdio copies stdin to input line by line, except for subscription
+// requests, whose responses it writes to out
+func (s *MCPServer) filterStdio(ctx context.Context, stdin io.Reader, input io.Writer, out io.Writer) error {
+	reader := bufio.NewReader(stdin)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if response, ok := s.handleSubscription(ctx, mcpStdioSessionID, line); ok {
+				data, err := json.Marshal(response)
+				if err != nil {
+					return err
+				}
+				if _, err := out.Write(append(data, '\n')); err != nil {
+					return err
+				}
+			} else if _, err := input.Write(line); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	apifake "github.com/JRaver/k8s-controller-tutorial/pkg/api/fake"
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/audit"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type auditCapture struct {
+	events []audit.Event
+}
+
+func (c *auditCapture) Write(_ context.Context, ev *audit.Event) error {
+	c.events = append(c.events, *ev)
+	return nil
+}
+
+func TestAuditTool(t *testing.T) {
+	capture := &auditCapture{}
+	auditor := audit.NewLogger(&audit.Policy{Rules: []audit.Rule{{Level: audit.LevelRequest}}}, capture)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "delete_frontendpage"
+	req.Params.Arguments = map[string]any{"name": "page"}
+	ctx := audit.WithSourceIP(context.Background(), "10.0.0.1")
+
+	handler := (&mcpHandlers{service: newFrontendApi(t), auditor: auditor}).auditTool(api.VerbDelete, func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError("not found"), nil
+	})
+	result, err := handler(ctx, req)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+
+	require.Len(t, capture.events, 1)
+	ev := capture.events[0]
+	require.Equal(t, audit.SourceMCP, ev.Source)
+	require.Equal(t, "10.0.0.1", ev.SourceIP)
+	require.Equal(t, "delete_frontendpage", ev.Operation)
+	require.Equal(t, "page", ev.Name)
+	require.Equal(t, audit.OutcomeFailure, ev.Outcome)
+	require.Equal(t, "not found", ev.Error)
+	require.JSONEq(t, `{"name":"page"}`, ev.RequestBody)
+}
+
+// newFrontendApi returns a FrontendPageApi on a fake client holding objs
+func newFrontendApi(t *testing.T, objs ...client.Object) *api.FrontendPageApi {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	require.NoError(t, frontendv1alpha1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	frontendApi := &api.FrontendPageApi{
+		K8SClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		Namespace: "default",
+	}
+	return frontendApi
+}
+
+// testIdentity is the caller of test requests, allowed everything without
+// an Authorizer
+var testIdentity = &api.Identity{Subject: "tester", Groups: []string{"editors"}}
+
+// mcpContext is the context of an authenticated MCP caller
+func mcpContext() context.Context {
+	return api.WithIdentity(context.Background(), testIdentity)
+}
+
+// mcpRequest sends a JSON-RPC request through s and decodes the result into
+// out. It returns the message of an error response.
+func mcpRequest(t *testing.T, ctx context.Context, s *MCPServer, method string, params any, out any) string {
+	t.Helper()
+	request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	require.NoError(t, err)
+	response, err := json.Marshal(s.HandleMessage(ctx, request))
+	require.NoError(t, err)
+	var decoded struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(response, &decoded))
+	if decoded.Error != nil {
+		return decoded.Error.Message
+	}
+	require.NoError(t, json.Unmarshal(decoded.Result, out))
+	return ""
+}
+
+// mcpCall is mcpRequest for requests that must succeed
+func mcpCall(t *testing.T, s *MCPServer, method string, params any, out any) {
+	t.Helper()
+	require.Empty(t, mcpRequest(t, mcpContext(), s, method, params, out))
+}
+
+// callTool calls a tool and decodes the JSON document it returns. Successful
+// results carry it as structuredContent, declared by the tool's outputSchema,
+// and as text for clients that don't read structured results.
+func callTool(t *testing.T, s *MCPServer, name string, args map[string]any) (bool, map[string]any) {
+	t.Helper()
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		StructuredContent map[string]any `json:"structuredContent"`
+		IsError           bool           `json:"isError"`
+	}
+	mcpCall(t, s, "tools/call", map[string]any{"name": name, "arguments": args}, &result)
+	require.Len(t, result.Content, 1)
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal([]byte(result.Content[0].Text), &doc), result.Content[0].Text)
+	if result.IsError {
+		return true, doc
+	}
+
+	require.Equal(t, doc, result.StructuredContent, "the text fallback differs from structuredContent")
+	var schema struct {
+		Type       string         `json:"type"`
+		Properties map[string]any `json:"properties"`
+		Required   []string       `json:"required"`
+	}
+	var tools struct {
+		Tools []struct {
+			Name         string          `json:"name"`
+			OutputSchema json.RawMessage `json:"outputSchema"`
+		} `json:"tools"`
+	}
+	mcpCall(t, s, "tools/list", map[string]any{}, &tools)
+	for _, tool := range tools.Tools {
+		if tool.Name == name {
+			require.NoError(t, json.Unmarshal(tool.OutputSchema, &schema), "%s has no outputSchema", name)
+		}
+	}
+	require.Equal(t, "object", schema.Type)
+	for _, property := range schema.Required {
+		require.Contains(t, doc, property, "%s leaves out a required property", name)
+	}
+	for property := range doc {
+		require.Contains(t, schema.Properties, property, "%s returns a property its outputSchema doesn't declare", name)
+	}
+	return false, doc
+}
+
+// listTools returns the tools s advertises by name
+func listTools(t *testing.T, s *MCPServer) map[string]mcp.Tool {
+	t.Helper()
+	var result struct {
+		Tools []mcp.Tool `json:"tools"`
+	}
+	mcpCall(t, s, "tools/list", map[string]any{}, &result)
+	tools := map[string]mcp.Tool{}
+	for _, tool := range result.Tools {
+		tools[tool.Name] = tool
+	}
+	return tools
+}
+
+func TestMCPTools(t *testing.T) {
+	replicas := int32(2)
+	frontendApi := newFrontendApi(t,
+		&frontendv1alpha1.FrontendPage{
+			ObjectMeta: metav1.ObjectMeta{Name: "page", Namespace: "default"},
+			Spec:       frontendv1alpha1.FrontendPageSpec{Content: "<h1>page</h1>", Image: "nginx:latest", Replicas: 2, Port: 80},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "page", Namespace: "default"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 1, AvailableReplicas: 1, UpdatedReplicas: 2, UnavailableReplicas: 1,
+				Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentProgressing, Status: "True", Reason: "ReplicaSetUpdated"}}},
+		},
+	)
+	s := NewMCPServer("test", "0.0.0", frontendApi, nil, MCPOptions{ConfirmationTTL: defaultMCPConfirmationTTL})
+
+	isError, doc := callTool(t, s, "get_frontendpage", map[string]any{"name": "page"})
+	require.False(t, isError)
+	require.Equal(t, "<h1>page</h1>", doc["content"])
+
+	isError, doc = callTool(t, s, "get_frontendpage", map[string]any{"name": "missing"})
+	require.True(t, isError)
+	require.Equal(t, "NotFound", doc["reason"])
+
+	_, doc = callTool(t, s, "get_frontendpage_status", map[string]any{"name": "page"})
+	require.Equal(t, api.FrontendPagePhaseProgressing, doc["phase"])
+	require.Equal(t, false, doc["ready"])
+	require.NotContains(t, doc["deployment"], "conditions")
+	_, doc = callTool(t, s, "get_frontendpage_status", map[string]any{"name": "page", "detail": "full"})
+	require.Contains(t, doc["deployment"], "conditions")
+	isError, _ = callTool(t, s, "get_frontendpage_status", map[string]any{"name": "page", "detail": "everything"})
+	require.True(t, isError)
+
+	isError, doc = callTool(t, s, "create_frontendpage", map[string]any{"name": "new", "contents": "<p>new</p>"})
+	require.False(t, isError)
+	require.Equal(t, map[string]any{"name": "new", "content": "<p>new</p>", "image": "nginx:latest", "replicas": 1.0, "port": 8080.0}, doc)
+	isError, doc = callTool(t, s, "create_frontendpage", map[string]any{"name": "big", "contents": "x", "replicas": 500.0})
+	require.True(t, isError)
+	require.Equal(t, "Invalid", doc["reason"])
+	for _, name := range []string{"", "Bad_Name", "page.example"} {
+		isError, doc = callTool(t, s, "create_frontendpage", map[string]any{"name": name, "contents": "x"})
+		require.True(t, isError, name)
+		require.Equal(t, "Invalid", doc["reason"], name)
+	}
+
+	isError, doc = callTool(t, s, "update_frontendpage", map[string]any{"name": "page", "image": "nginx:1.27"})
+	require.False(t, isError)
+	require.Equal(t, "nginx:1.27", doc["image"])
+	require.Equal(t, "<h1>page</h1>", doc["content"], "fields that are left out keep their value")
+	isError, _ = callTool(t, s, "update_frontendpage", map[string]any{"name": "page"})
+	require.True(t, isError)
+
+	isError, doc = callTool(t, s, "scale_frontendpage", map[string]any{"name": "page", "replicas": 4.0})
+	require.False(t, isError)
+	require.Equal(t, map[string]any{"name": "page", "previousReplicas": 2.0, "replicas": 4.0}, doc)
+	isError, _ = callTool(t, s, "scale_frontendpage", map[string]any{"name": "page"})
+	require.True(t, isError)
+
+	_, doc = callTool(t, s, "list_frontendpages", map[string]any{})
+	require.Len(t, doc["items"], 2)
+
+	isError, doc = callTool(t, s, "delete_frontendpage", map[string]any{"name": "new"})
+	require.False(t, isError)
+	require.Equal(t, "confirmationRequired", doc["status"], "see TestDeleteFrontendPage_Safeguards")
+}
+
+func TestMCPTools_FakeService(t *testing.T) {
+	t.Parallel()
+	// Servers share no state, so each one only sees its own service
+	staging := apifake.NewService("staging", api.FrontendPageDoc{Name: "home", Image: "nginx:1.27", Replicas: 1, Port: 80})
+	production := apifake.NewService("production")
+	opts := MCPOptions{ConfirmationTTL: defaultMCPConfirmationTTL}
+	stagingServer := NewMCPServer("test", "0.0.0", staging, nil, opts)
+	productionServer := NewMCPServer("test", "0.0.0", production, nil, opts)
+
+	isError, doc := callTool(t, stagingServer, "scale_frontendpage", map[string]any{"name": "home", "replicas": 3.0})
+	require.False(t, isError, doc)
+	isError, doc = callTool(t, productionServer, "get_frontendpage", map[string]any{"name": "home"})
+	require.True(t, isError)
+	require.Equal(t, "NotFound", doc["reason"])
+
+	_, doc = callTool(t, stagingServer, "delete_frontendpage", map[string]any{"name": "home"})
+	isError, doc = callTool(t, stagingServer, "delete_frontendpage", map[string]any{"name": "home", "confirmation_token": doc["confirmationToken"]})
+	require.False(t, isError, doc)
+	require.Equal(t, "deleted", doc["status"])
+	changes, _ := staging.FrontendPageChangesRaw("home", time.Time{})
+	require.Len(t, changes, 2)
+	require.Equal(t, api.WatchEventDeleted, changes[1].Type)
+}
+
+func TestMCPTools_Schemas(t *testing.T) {
+	frontendApi := newFrontendApi(t)
+	tools := listTools(t, NewMCPServer("test", "0.0.0", frontendApi, nil, MCPOptions{}))
+	for name, required := range map[string][]string{
+		"list_frontendpages":      nil,
+		"get_frontendpage":        {"name"},
+		"get_frontendpage_status": {"name"},
+		"create_frontendpage":     {"name", "contents"},
+		"update_frontendpage":     {"name"},
+		"scale_frontendpage":      {"name", "replicas"},
+		"delete_frontendpage":     {"name"},
+
+		"get_frontendpage_pods":            {"name"},
+		"get_frontendpage_logs":            {"name"},
+		"get_frontendpage_events":          {"name"},
+		"get_frontendpage_rollout_history": {"name"},
+	} {
+		tool, ok := tools[name]
+		require.True(t, ok, name)
+		require.ElementsMatch(t, required, tool.InputSchema.Required, name)
+		require.Equal(t, "object", tool.OutputSchema.Type, "%s has no outputSchema", name)
+	}
+	require.Len(t, tools, 11)
+
+	// Output schemas come from the same generator as the OpenAPI document
+	status := tools["get_frontendpage_status"].OutputSchema
+	require.Contains(t, status.Properties, "phase")
+	require.Equal(t, map[string]any{"$ref": "#/$defs/api.DeploymentStatusDoc"}, status.Properties["deployment"])
+	require.Contains(t, status.Defs, "v1.Condition")
+
+	replicas := tools["scale_frontendpage"].InputSchema.Properties["replicas"].(map[string]any)
+	require.Equal(t, 0.0, replicas["minimum"])
+	require.Equal(t, float64(mcpMaxReplicas), replicas["maximum"])
+	port := tools["create_frontendpage"].InputSchema.Properties["port"].(map[string]any)
+	require.Equal(t, float64(mcpDefaultPort), port["default"])
+	detail := tools["get_frontendpage_status"].InputSchema.Properties["detail"].(map[string]any)
+	require.Equal(t, []any{"summary", "full"}, detail["enum"])
+	require.Equal(t, "summary", detail["default"])
+	require.True(t, *tools["delete_frontendpage"].Annotations.DestructiveHint)
+	require.False(t, *tools["delete_frontendpage"].Annotations.IdempotentHint)
+	require.Contains(t, tools["delete_frontendpage"].InputSchema.Properties, "dry_run")
+	require.True(t, *tools["get_frontendpage"].Annotations.ReadOnlyHint)
+	require.True(t, *tools["get_frontendpage_logs"].Annotations.ReadOnlyHint)
+	tailLines := tools["get_frontendpage_logs"].InputSchema.Properties["tailLines"].(map[string]any)
+	require.Equal(t, float64(mcpMaxLogLines), tailLines["maximum"])
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Bounds of the troubleshooting tools. Pod logs and events end up in the
+// model's context, so they are capped even when the caller asks for more.
+const (
+	mcpDefaultLogLines  = 100
+	mcpMaxLogLines      = 2000
+	mcpDefaultLogBytes  = 16 << 10
+	mcpMaxLogBytes      = 64 << 10
+	mcpMaxLogTotalBytes = 256 << 10
+	mcpDefaultEvents    = 50
+	mcpMaxEvents        = 200
+
+	// mcpLogRedactionBytes are read past limitBytes, so that a secret the
+	// limit cuts through is still whole when it is redacted
+	mcpLogRedactionBytes = 16 << 10
+)
+
+// redactedText replaces secrets in tool output
+const redactedText = "[REDACTED]"
+
+// secretPatterns match credentials that commonly leak into logs, events and
+// termination messages. The groups around the secret are kept.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(\b(?:bearer|basic)\s+)[A-Za-z0-9._~+/=-]+`),
+	regexp.MustCompile(`()\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	regexp.MustCompile(`(?i)(\b[a-z0-9_.-]*(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|credentials?)["']?\s*[:=]\s*["']?)[^\s"',;&]+`),
+	regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+(@)`),
+	regexp.MustCompile(`()\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+}
+
+var (
+	privateKeyBegin = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+	privateKeyEnd   = regexp.MustCompile(`-----END [A-Z ]*PRIVATE KEY-----`)
+)
+
+// redactPrivateKeys masks PEM private keys, keeping their BEGIN line. A key
+// whose beginning was cut off by tailLines is masked up to its END line, and
+// one whose end is missing up to the end of s.
+func redactPrivateKeys(s string) string {
+	var b strings.Builder
+	for {
+		begin := privateKeyBegin.FindStringIndex(s)
+		end := privateKeyEnd.FindStringIndex(s)
+		switch {
+		case end != nil && (begin == nil || end[0] < begin[0]):
+			b.WriteString(redactedText)
+		case begin != nil:
+			b.WriteString(s[:begin[1]])
+			b.WriteString(redactedText)
+			if end = privateKeyEnd.FindStringIndex(s[begin[1]:]); end == nil {
+				return b.String()
+			}
+			end[1] += begin[1]
+		default:
+			b.WriteString(s)
+			return b.String()
+		}
+		s = s[end[1]:]
+	}
+}
+
+// redactSecrets masks private keys and the credentials secretPatterns find
+// in s. Text must be redacted before it is cut to a limit, or a secret cut in
+// half may no longer match.
+func redactSecrets(s string) string {
+	s = redactPrivateKeys(s)
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+redactedText+"${2}")
+	}
+	return s
+}
+
+// podsResult is the result of get_frontendpage_pods. Structured tool output
+// is an object, so the lists of the troubleshooting tools are wrapped in one.
+type podsResult struct {
+	Items []api.PodStatusDoc `json:"items"`
+}
+
+// logsResult is the result of get_frontendpage_logs
+type logsResult struct {
+	Items []api.PodLogsDoc `json:"items"`
+}
+
+// eventsResult is the result of get_frontendpage_events
+type eventsResult struct {
+	Items []api.EventDoc `json:"items"`
+}
+
+// rolloutResult is the result of get_frontendpage_rollout_history
+type rolloutResult struct {
+	Items []api.RolloutRevisionDoc `json:"items"`
+}
+
+// addTroubleshootingTools registers the read-only tools that inspect the
+// workload the operator generates for a page
+func (h *mcpHandlers) addTroubleshootingTools(s *server.MCPServer) {
+	podsTool := mcp.NewTool("get_frontendpage_pods",
+		mcp.WithDescription("Get the pods serving a FrontendPage: phase, readiness, restarts, node and the state of each container"),
+		nameArgument("Name of the FrontendPage"),
+		outputSchema(podsResult{}),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+	logsTool := mcp.NewTool("get_frontendpage_logs",
+		mcp.WithDescription("Get the last lines of the logs of the pods serving a FrontendPage. Output is bounded and secrets are redacted."),
+		nameArgument("Name of the FrontendPage"),
+		mcp.WithString("pod", mcp.Description("Only this pod, one of those returned by get_frontendpage_pods")),
+		mcp.WithString("container", mcp.Description("Only this container")),
+		mcp.WithNumber("tailLines", mcp.Description("Lines to return from the end of each container's logs"),
+			mcp.Min(1), mcp.Max(mcpMaxLogLines), mcp.DefaultNumber(mcpDefaultLogLines)),
+		mcp.WithNumber("limitBytes", mcp.Description("Bytes to return for each container"),
+			mcp.Min(1), mcp.Max(mcpMaxLogBytes), mcp.DefaultNumber(mcpDefaultLogBytes)),
+		mcp.WithBoolean("previous", mcp.Description("Logs of the previous, terminated instance of the containers"), mcp.DefaultBool(false)),
+		outputSchema(logsResult{}),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+	eventsTool := mcp.NewTool("get_frontendpage_events",
+		mcp.WithDescription("Get the Kubernetes events about a FrontendPage and the objects it controls, newest first"),
+		nameArgument("Name of the FrontendPage"),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of events"),
+			mcp.Min(1), mcp.Max(mcpMaxEvents), mcp.DefaultNumber(mcpDefaultEvents)),
+		outputSchema(eventsResult{}),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+	rolloutTool := mcp.NewTool("get_frontendpage_rollout_history",
+		mcp.WithDescription("Get the revisions of the Deployment serving a FrontendPage, newest first, with their images and which one is current"),
+		nameArgument("Name of the FrontendPage"),
+		outputSchema(rolloutResult{}),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+
+	s.AddTool(podsTool, h.authorizeTool(h.getFrontendPagePods))
+	s.AddTool(logsTool, h.authorizeTool(h.getFrontendPageLogs))
+	s.AddTool(eventsTool, h.authorizeTool(h.getFrontendPageEvents))
+	s.AddTool(rolloutTool, h.authorizeTool(h.getFrontendPageRolloutHistory))
+}
+
+func (h *mcpHandlers) getFrontendPagePods(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	pods, err := h.service.FrontendPagePodsRaw(ctx, req.GetString("name", ""))
+	if err != nil {
+		return toolError(err), nil
+	}
+	for i := range pods {
+		pods[i].Message = redactSecrets(pods[i].Message)
+		for j := range pods[i].Containers {
+			pods[i].Containers[j].Message = redactSecrets(pods[i].Containers[j].Message)
+		}
+	}
+	return toolJSON(podsResult{Items: pods}), nil
+}
+
+func (h *mcpHandlers) getFrontendPageLogs(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	tailLines, ok, err := intArgument(req, "tailLines", 1, mcpMaxLogLines)
+	if err != nil {
+		return toolError(err), nil
+	}
+	if !ok {
+		tailLines = mcpDefaultLogLines
+	}
+	limitBytes, ok, err := intArgument(req, "limitBytes", 1, mcpMaxLogBytes)
+	if err != nil {
+		return toolError(err), nil
+	}
+	if !ok {
+		limitBytes = mcpDefaultLogBytes
+	}
+	pod, _ := stringArgument(req, "pod")
+	container, _ := stringArgument(req, "container")
+	logs, err := h.service.FrontendPageLogsRaw(ctx, req.GetString("name", ""), api.PodLogsOptions{
+		Pod:        pod,
+		Container:  container,
+		TailLines:  int64(tailLines),
+		LimitBytes: int64(limitBytes + mcpLogRedactionBytes),
+		Previous:   req.GetBool("previous", false),
+	})
+	if err != nil {
+		return toolError(err), nil
+	}
+
+	// Redacted first, then cut to limitBytes and to a budget for all
+	// containers, since many pods times limitBytes could still flood the caller
+	budget := mcpMaxLogTotalBytes
+	for i := range logs {
+		logs[i].Logs = redactSecrets(logs[i].Logs)
+		logs[i].Error = redactSecrets(logs[i].Error)
+		if limit := min(limitBytes, budget); len(logs[i].Logs) > limit {
+			logs[i].Logs = logs[i].Logs[:limit]
+			logs[i].Truncated = true
+		}
+		budget -= len(logs[i].Logs)
+	}
+	return toolJSON(logsResult{Items: logs}), nil
+}
+
+func (h *mcpHandlers) getFrontendPageEvents(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	limit, ok, err := intArgument(req, "limit", 1, mcpMaxEvents)
+	if err != nil {
+		return toolError(err), nil
+	}
+	if !ok {
+		limit = mcpDefaultEvents
+	}
+	events, err := h.service.FrontendPageEventsRaw(ctx, req.GetString("name", ""))
+	if err != nil {
+		return toolError(err), nil
+	}
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	for i := range events {
+		events[i].Message = redactSecrets(events[i].Message)
+	}
+	return toolJSON(eventsResult{Items: events}), nil
+}
+
+func (h *mcpHandlers) getFrontendPageRolloutHistory(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	revisions, err := h.service.FrontendPageRolloutHistoryRaw(ctx, req.GetString("name", ""))
+	if err != nil {
+		return toolError(err), nil
+	}
+	for i := range revisions {
+		revisions[i].ChangeCause = redactSecrets(revisions[i].ChangeCause)
+	}
+	return toolJSON(rolloutResult{Items: revisions}), nil
+}
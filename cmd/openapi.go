@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var openapiOutput string
+
+var openapiCmd = &cobra.Command{
+	Use:   "openapi",
+	Short: "Print the OpenAPI document of the REST API",
+	Long: `Print the OpenAPI 3.1 document the server serves at /openapi.json. It is
+generated from the route table, regenerate docs/openapi.json with:
+
+  go run . openapi -o docs/openapi.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		out := cmd.OutOrStdout()
+		if openapiOutput != "" && openapiOutput != "-" {
+			file, err := os.Create(openapiOutput)
+			if err != nil {
+				log.Error().Err(err).Msg("Error creating output file")
+				os.Exit(1)
+			}
+			defer file.Close()
+			out = file
+		}
+		if _, err := out.Write(api.OpenAPIDocument()); err != nil {
+			log.Error().Err(err).Msg("Error writing OpenAPI document")
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(openapiCmd)
+	openapiCmd.Flags().StringVarP(&openapiOutput, "output", "o", "", "File to write the document to (defaults to stdout)")
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPICmd(t *testing.T) {
+	require.NotNil(t, openapiCmd.Flags().Lookup("output"))
+
+	var out bytes.Buffer
+	openapiCmd.SetOut(&out)
+	t.Cleanup(func() { openapiCmd.SetOut(nil) })
+	openapiCmd.Run(openapiCmd, nil)
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &doc))
+	require.Equal(t, "3.1.0", doc.OpenAPI)
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	pageclient "github.com/JRaver/k8s-controller-tutorial/pkg/client"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// pagesPasswordEnv holds the password for --username, so that it doesn't
+// show up in process lists or shell history
+const pagesPasswordEnv = "FRONTENDPAGE_PASSWORD"
+
+var pagesServer string
+var pagesToken string
+var pagesAPIKey string
+var pagesUsername string
+var pagesLimit int
+var pagesWatchResourceVersion string
+var pagesWatchSelector string
+
+var pagesCmd = &cobra.Command{
+	Use:   "pages",
+	Short: "Manage FrontendPages through the REST API of a running server",
+	Long: `Manage FrontendPages through the REST API of a running server, with the
+server's authentication and authorization rather than kubeconfig access.
+Authenticate with --token, --api-key, or --username and the password in the
+` + pagesPasswordEnv + ` environment variable.`,
+}
+
+// newPagesClient creates the REST client from the pages flags
+func newPagesClient() (*pageclient.Client, error) {
+	var opts []pageclient.Option
+	switch {
+	case pagesAPIKey != "":
+		opts = append(opts, pageclient.WithAPIKey(pagesAPIKey))
+	case pagesToken != "":
+		opts = append(opts, pageclient.WithToken(pagesToken))
+	case pagesUsername != "":
+		opts = append(opts, pageclient.WithCredentials(pageclient.Credentials{
+			Username: pagesUsername,
+			Password: os.Getenv(pagesPasswordEnv),
+		}))
+	}
+	return pageclient.New(pagesServer, opts...)
+}
+
+// runPagesCommand runs fn with the REST client and exits on errors
+func runPagesCommand(fn func(ctx context.Context, c *pageclient.Client, args []string, out io.Writer) error) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		level := SetLogLevel(LogLevel)
+		ConfigureLogger(level)
+
+		c, err := newPagesClient()
+		if err != nil {
+			log.Error().Err(err).Msg("Error creating client")
+			os.Exit(1)
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		if err := fn(ctx, c, args, cmd.OutOrStdout()); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Request failed")
+			os.Exit(1)
+		}
+	}
+}
+
+func printJSON(out io.Writer, v any) error {
+	output, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(output))
+	return err
+}
+
+func pagesList(ctx context.Context, c *pageclient.Client, _ []string, out io.Writer) error {
+	pages, err := c.ListAll(ctx, pagesLimit)
+	if err != nil {
+		return err
+	}
+	return printJSON(out, pageclient.PageList{Items: pages})
+}
+
+func pagesGet(ctx context.Context, c *pageclient.Client, args []string, out io.Writer) error {
+	page, err := c.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return printJSON(out, page)
+}
+
+func pagesDelete(ctx context.Context, c *pageclient.Client, args []string, _ io.Writer) error {
+	if err := c.Delete(ctx, args[0]); err != nil {
+		return err
+	}
+	log.Info().Str("name", args[0]).Msg("Frontend page deleted")
+	return nil
+}
+
+// pagesWatch prints one JSON event per line until interrupted
+func pagesWatch(ctx context.Context, c *pageclient.Client, _ []string, out io.Writer) error {
+	watcher, err := c.Watch(ctx, pageclient.WatchOptions{
+		ResourceVersion: pagesWatchResourceVersion,
+		LabelSelector:   pagesWatchSelector,
+	})
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	encoder := json.NewEncoder(out)
+	for {
+		event, err := watcher.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+}
+
+var pagesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List FrontendPages",
+	Run:   runPagesCommand(pagesList),
+}
+
+var pagesGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print a FrontendPage",
+	Args:  cobra.ExactArgs(1),
+	Run:   runPagesCommand(pagesGet),
+}
+
+var pagesDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a FrontendPage",
+	Args:  cobra.ExactArgs(1),
+	Run:   runPagesCommand(pagesDelete),
+}
+
+var pagesWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Print changes to FrontendPages as JSON lines until interrupted",
+	Run:   runPagesCommand(pagesWatch),
+}
+
+func init() {
+	rootCmd.AddCommand(pagesCmd)
+	pagesCmd.AddCommand(pagesListCmd, pagesGetCmd, pagesDeleteCmd, pagesWatchCmd)
+	pagesCmd.PersistentFlags().StringVar(&pagesServer, "server", "http://localhost:8080", "Base URL of the server")
+	pagesCmd.PersistentFlags().StringVar(&pagesToken, "token", "", "Bearer token to authenticate with")
+	pagesCmd.PersistentFlags().StringVar(&pagesAPIKey, "api-key", "", "API key to authenticate with")
+	pagesCmd.PersistentFlags().StringVar(&pagesUsername, "username", "", "User to get tokens for, with the password in $"+pagesPasswordEnv)
+	pagesListCmd.Flags().IntVar(&pagesLimit, "limit", 100, "Pages to fetch per request (0 fetches all at once)")
+	pagesWatchCmd.Flags().StringVar(&pagesWatchResourceVersion, "resource-version", "", "Resume after this resource version instead of listing current pages first")
+	pagesWatchCmd.Flags().StringVarP(&pagesWatchSelector, "selector", "l", "", "Only watch pages matching this label selector")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pageclient "github.com/JRaver/k8s-controller-tutorial/pkg/client"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagesCmd(t *testing.T) {
+	for _, name := range []string{"server", "token", "api-key", "username"} {
+		require.NotNil(t, pagesCmd.PersistentFlags().Lookup(name), name)
+	}
+	names := map[string]bool{}
+	for _, sub := range pagesCmd.Commands() {
+		names[sub.Name()] = true
+	}
+	require.Equal(t, map[string]bool{"list": true, "get": true, "delete": true, "watch": true}, names)
+}
+
+func TestPagesList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "key", r.Header.Get(pageclient.APIKeyHeader))
+		switch r.URL.Path {
+		case "/api/frontendpages":
+			list := pageclient.PageList{Items: []pageclient.Page{{Name: "a"}}, Continue: "next"}
+			if r.URL.Query().Get("continue") == "next" {
+				list = pageclient.PageList{Items: []pageclient.Page{{Name: "b"}}}
+			}
+			json.NewEncoder(w).Encode(list)
+		default:
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"type":"about:blank","title":"Not Found","status":404,"reason":"NotFound"}`))
+		}
+	}))
+	defer server.Close()
+	pagesServer, pagesAPIKey, pagesLimit = server.URL, "key", 1
+	t.Cleanup(func() { pagesServer, pagesAPIKey, pagesLimit = "http://localhost:8080", "", 100 })
+
+	c, err := newPagesClient()
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, pagesList(context.Background(), c, nil, &out))
+	var list pageclient.PageList
+	require.NoError(t, json.Unmarshal(out.Bytes(), &list))
+	require.Equal(t, []pageclient.Page{{Name: "a"}, {Name: "b"}}, list.Items)
+
+	err = pagesGet(context.Background(), c, []string{"missing"}, &out)
+	require.True(t, pageclient.IsNotFound(err), err)
+}
@@ -2,13 +2,18 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"time"
 
-	_ "github.com/JRaver/k8s-controller-tutorial/docs"
 	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/apikey"
 	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/audit"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/certs"
 	"github.com/JRaver/k8s-controller-tutorial/pkg/ctrl"
 	"github.com/JRaver/k8s-controller-tutorial/pkg/informer"
 	"github.com/JRaver/k8s-controller-tutorial/pkg/telemetry"
@@ -16,9 +21,7 @@ import (
 	mcpserver "github.com/mark3labs/mcp-go/server"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
-	httpSwagger "github.com/swaggo/http-swagger"
 	"github.com/valyala/fasthttp"
-	"github.com/valyala/fasthttp/fasthttpadaptor"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrlruntime "sigs.k8s.io/controller-runtime"
@@ -27,21 +30,56 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
 )
 
-// @title K8s Controller Tutorial API
-// @version 1.0
-// @description My awesome lab controller with Swagger UI
-// @host localhost:8080
-// @BasePath /
-
 var serverPort int = 8080
 var enableLeaderElection bool
 var leaderElectionNamespace string
 var metricsPort int
 var enableMCP bool
 var mcpPort int
+var mcpPath string
+var mcpBaseURL string
 var enableOtel bool
-var FrontendApi *api.FrontendPageApi
 var jwtSecret string
+var jwtIssuer string
+var jwtAudience string
+var jwtTTL time.Duration
+var jwtRefreshTTL time.Duration
+var revocationStore string
+var revocationStoreName string
+var revocationSync time.Duration
+var insecureDefaultSecret bool
+var usersFile string
+var enableTokenReview bool
+var tokenReviewAudiences []string
+var authorizationMode string
+var authzPolicyFile string
+var authzPolicyConfigMap string
+var authzPolicyReload time.Duration
+var authzCacheTTL time.Duration
+var jwtLeeway time.Duration
+var oidcJWKSURL string
+var oidcJWKSFile string
+var oidcIssuer string
+var oidcAudience string
+var oidcJWKSRefresh time.Duration
+var watchHeartbeat time.Duration
+var tlsOptions certs.Options
+var tlsReload time.Duration
+var auditLogPath string
+var auditLogMaxSize int
+var auditLogMaxBackup int
+var auditEvents bool
+var auditPolicyFile string
+var enableAPIKeys bool
+var apiKeysSecret string
+var apiKeysSync time.Duration
+var rateLimit float64
+var rateLimitBurst int
+var rateLimitRoutes []string
+var maxBodySize int
+var readTimeout time.Duration
+var writeTimeout time.Duration
+var corsOptions api.CORSOptions
 
 var serverCmd = &cobra.Command{
 	Use:   "server",
@@ -51,6 +89,42 @@ var serverCmd = &cobra.Command{
 		level := SetLogLevel(LogLevel)
 		ConfigureLogger(level)
 
+		if err := validateJWTSecret(jwtSecret, insecureDefaultSecret); err != nil {
+			log.Error().Err(err).Msg("Refusing to start")
+			os.Exit(1)
+		}
+
+		apiConfig := api.NewConfig()
+		if rateLimit > 0 || len(rateLimitRoutes) > 0 {
+			routes := map[string]api.RateLimit{}
+			for _, value := range rateLimitRoutes {
+				route, budget, err := api.ParseRouteRateLimit(value)
+				if err != nil {
+					log.Error().Err(err).Msg("Invalid --rate-limit-route")
+					os.Exit(1)
+				}
+				routes[route] = budget
+			}
+			apiConfig.RateLimits = api.NewRateLimiter(api.RateLimit{Rate: rateLimit, Burst: rateLimitBurst}, routes)
+		}
+
+		var tlsReloader *certs.Reloader
+		if tlsOptions.Enabled() {
+			reloader, err := certs.NewReloader(tlsOptions)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to load TLS certificate")
+				os.Exit(1)
+			}
+			tlsReloader = reloader
+			if tlsOptions.SelfSigned {
+				log.Warn().Str("sha256", certs.Fingerprint(tlsReloader.Certificate())).
+					Msg("Serving a self-signed certificate, for development only")
+			}
+		} else if tlsOptions.ClientCAFile != "" {
+			log.Error().Msg("--tls-client-ca-file needs --tls-cert-file and --tls-key-file or --tls-self-signed")
+			os.Exit(1)
+		}
+
 		// Initialize OpenTelemetry if enabled
 		var shutdownOtel func(context.Context) error
 		if enableOtel {
@@ -126,6 +200,19 @@ var serverCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		// Feed the watch stream from the manager's FrontendPage informer
+		frontendPageInformer, err := mgr.GetCache().GetInformer(ctx, &frontendv1alpha1.FrontendPage{})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to get frontend page informer")
+			os.Exit(1)
+		}
+		watcher := api.NewFrontendPageWatcher(namespace)
+		watcher.HeartbeatInterval = watchHeartbeat
+		if _, err := frontendPageInformer.AddEventHandler(watcher); err != nil {
+			log.Error().Err(err).Msg("Failed to register frontend page watcher")
+			os.Exit(1)
+		}
+
 		router := fasthttprouter.New()
 
 		// Function to wrap handlers with OpenTelemetry middleware
@@ -136,57 +223,144 @@ var serverCmd = &cobra.Command{
 			return handler
 		}
 
-		// Wrap all API endpoints with OpenTelemetry middleware
-		router.POST("/api/token", wrapHandler(api.TraceableHandler("GenerateToken", api.TokenHandler)))
+		apiConfig.JWTSecret = jwtSecret
+		apiConfig.JWTIssuer = jwtIssuer
+		apiConfig.JWTAudience = jwtAudience
+		apiConfig.JWTTTL = jwtTTL
+		apiConfig.JWTRefreshTTL = jwtRefreshTTL
+		apiConfig.JWTLeeway = jwtLeeway
+		switch revocationStore {
+		case revocationStoreMemory:
+		case revocationStoreConfigMap, revocationStoreSecret:
+			apiConfig.Revocations = api.NewRevocationList(&api.KubernetesRevocationBackend{
+				Client:    mgr.GetClient(),
+				Namespace: namespace,
+				Name:      revocationStoreName,
+				Secret:    revocationStore == revocationStoreSecret,
+			})
+			if err := apiConfig.Revocations.Sync(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to load token revocations")
+				os.Exit(1)
+			}
+			go apiConfig.Revocations.Run(ctx, revocationSync)
+		default:
+			log.Error().Msgf("Unknown revocation store %q", revocationStore)
+			os.Exit(1)
+		}
+		if enableAPIKeys {
+			apiConfig.APIKeys = apikey.NewStore(mgr.GetClient(), namespace, apiKeysSecret)
+			if err := apiConfig.APIKeys.Load(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to load API keys")
+				os.Exit(1)
+			}
+			go apiConfig.APIKeys.Run(ctx, apiKeysSync)
+		}
+		if oidcJWKSURL != "" || oidcJWKSFile != "" {
+			if oidcIssuer == "" {
+				log.Error().Msg("--oidc-issuer is required with --oidc-jwks-url or --oidc-jwks-file")
+				os.Exit(1)
+			}
+			if oidcAudience == "" {
+				log.Warn().Msg("No --oidc-audience set, tokens the identity provider issued for other clients are accepted")
+			}
+			source := api.JWKSFile(oidcJWKSFile)
+			if oidcJWKSURL != "" {
+				source = api.JWKSURL(oidcJWKSURL)
+			}
+			keySet := api.NewKeySet(source)
+			if err := keySet.Refresh(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to load JWKS")
+				os.Exit(1)
+			}
+			go keySet.Run(ctx, oidcJWKSRefresh)
+			apiConfig.OIDC = &api.OIDCConfig{KeySet: keySet, Issuer: oidcIssuer, Audience: oidcAudience}
+		}
+		var authenticators api.Authenticators
+		if usersFile != "" {
+			users, err := api.LoadUsersFile(usersFile)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to load users file")
+				os.Exit(1)
+			}
+			authenticators = append(authenticators, users)
+		}
+		if enableTokenReview {
+			authenticators = append(authenticators, &api.TokenReviewAuthenticator{
+				Client:    mgr.GetClient(),
+				Audiences: tokenReviewAudiences,
+			})
+		}
+		if len(authenticators) > 0 {
+			apiConfig.TokenAuthenticator = authenticators
+		} else {
+			log.Warn().Msg("No authenticators configured, /api/token will not issue tokens")
+		}
 
 		frontedApi := &api.FrontendPageApi{
 			K8SClient: mgr.GetClient(),
+			APIReader: mgr.GetAPIReader(),
+			Clientset: clientset,
 			Namespace: namespace,
+			Watcher:   watcher,
 		}
-		api.FrontendApi = frontedApi
-
-		router.GET("/api/frontendpages", wrapHandler(api.TraceableHandler("ListFrontendPages", api.JwtMiddleware(frontedApi.ListFrontendPages))))
-		router.POST("/api/frontendpages", wrapHandler(api.TraceableHandler("CreateFrontendPage", api.JwtMiddleware(frontedApi.CreateFrontendPage))))
-		router.GET("/api/frontendpages/:name", wrapHandler(api.TraceableHandler("GetFrontendPage", api.JwtMiddleware(frontedApi.GetFrontendPage))))
-		router.PUT("/api/frontendpages/:name", wrapHandler(api.TraceableHandler("UpdateFrontendPage", api.JwtMiddleware(frontedApi.UpdateFrontendPage))))
-		router.DELETE("/api/frontendpages/:name", wrapHandler(api.TraceableHandler("DeleteFrontendPage", api.JwtMiddleware(frontedApi.DeleteFrontendPage))))
-
-		router.GET("/health", wrapHandler(api.TraceableHandler("HealthCheck", func(ctx *fasthttp.RequestCtx) {
-			ctx.Response.Header.Set("Content-Type", "application/json")
-			ctx.SetStatusCode(fasthttp.StatusOK)
-			ctx.WriteString(`{"status": "ok"}`)
-		})))
-
-		CORS := func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
-			return func(ctx *fasthttp.RequestCtx) {
-				ctx.Response.Header.Set("Access-Control-Allow-Origin", "*")
-				ctx.Response.Header.Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
-				ctx.Response.Header.Set("Access-Control-Allow-Headers", "Content-Type,Authorization")
-				if string(ctx.Method()) == fasthttp.MethodOptions {
-					ctx.SetStatusCode(fasthttp.StatusOK)
-					return
-				}
-				h(ctx)
-			}
-		}
-		router.GET("/swagger/*any", CORS(fasthttpadaptor.NewFastHTTPHandler(httpSwagger.WrapHandler)))
-
-		router.GET("/deployments", wrapHandler(api.TraceableHandler("ListDeployments", func(ctx *fasthttp.RequestCtx) {
-			ctx.Response.Header.Set("Content-Type", "application/json")
-			deployments := informer.GetDeploymentsNames()
-			ctx.SetStatusCode(fasthttp.StatusOK)
-			ctx.WriteString("[")
-			for i, name := range deployments {
-				ctx.WriteString("\"")
-				ctx.WriteString(string(name))
-				ctx.WriteString("\"")
-				if i < len(deployments)-1 {
-					ctx.WriteString(",")
+		switch authorizationMode {
+		case authorizationModeNone:
+			log.Warn().Msg("Authorization is disabled, every authenticated caller can do everything")
+		case authorizationModePolicy:
+			var source api.PolicySource
+			switch {
+			case authzPolicyFile != "":
+				source = api.PolicyFile(authzPolicyFile)
+			case authzPolicyConfigMap != "":
+				source = api.PolicyConfigMap(mgr.GetAPIReader(), namespace, authzPolicyConfigMap)
+			default:
+				log.Error().Msg("--authorization-mode=policy needs --authz-policy-file or --authz-policy-configmap")
+				os.Exit(1)
+			}
+			authorizer, err := api.LoadPolicyAuthorizer(ctx, source)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to load authorization policy")
+				os.Exit(1)
+			}
+			go authorizer.Watch(ctx, source, authzPolicyReload)
+			frontedApi.Authorizer = authorizer
+		case authorizationModeSAR:
+			frontedApi.Authorizer = api.NewSubjectAccessReviewAuthorizer(mgr.GetClient(), authzCacheTTL)
+		default:
+			log.Error().Msgf("Unknown authorization mode %q", authorizationMode)
+			os.Exit(1)
+		}
+
+		var auditSinks []audit.Sink
+		switch auditLogPath {
+		case "":
+		case "-":
+			auditSinks = append(auditSinks, audit.NewWriterSink(os.Stdout))
+		default:
+			sink, err := audit.NewFileSink(auditLogPath, int64(auditLogMaxSize)<<20, auditLogMaxBackup)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to open audit log")
+				os.Exit(1)
+			}
+			defer sink.Close()
+			auditSinks = append(auditSinks, sink)
+		}
+		if auditEvents {
+			auditSinks = append(auditSinks, &audit.EventSink{Recorder: mgr.GetEventRecorderFor("frontendpage-api")})
+		}
+		if len(auditSinks) > 0 {
+			var auditPolicy *audit.Policy
+			if auditPolicyFile != "" {
+				auditPolicy, err = audit.LoadPolicyFile(auditPolicyFile)
+				if err != nil {
+					log.Error().Err(err).Msg("Failed to load audit policy")
+					os.Exit(1)
 				}
 			}
-			ctx.WriteString("]")
-		})))
-		api.JWTSecret = jwtSecret
+			apiConfig.Auditor = audit.NewLogger(auditPolicy, auditSinks...)
+		} else if auditPolicyFile != "" {
+			log.Warn().Msg("--audit-policy-file has no effect without --audit-log-path or --audit-events")
+		}
 
 		go func() {
 			log.Info().Msg("Starting controller-runtime manager...")
@@ -196,33 +370,141 @@ var serverCmd = &cobra.Command{
 			}
 		}()
 
+		var tlsConfig *tls.Config
+		if tlsReloader != nil {
+			go tlsReloader.Run(ctx, tlsReload)
+			tlsConfig = tlsReloader.TLSConfig()
+		}
+
+		apiRouter := &api.Router{
+			Service:         frontedApi,
+			Config:          apiConfig,
+			Watcher:         watcher,
+			DeploymentNames: informer.GetDeploymentsNames,
+		}
 		if enableMCP {
-			go func() {
-				mcpServer := NewMCPServer("K8S controller MCP", "1.0.0")
-				sseServer := mcpserver.NewSSEServer(mcpServer,
-					mcpserver.WithBaseURL(fmt.Sprintf("http://:%d/mcp", mcpPort)),
-				)
-				log.Info().Msgf("Starting MCP server on port %d", mcpPort)
-				if err := sseServer.Start(fmt.Sprintf(":%d", mcpPort)); err != nil {
-					log.Error().Err(err).Msg("Failed to start SSe server")
-					os.Exit(1)
-				}
-			}()
-			log.Info().Msgf("MCP server started on port %d", mcpPort)
+			if err := validateMCPBaseURL(mcpBaseURL); err != nil {
+				log.Error().Err(err).Msg("Invalid MCP configuration")
+				os.Exit(1)
+			}
+			// Every transport serves the same tools, resources and prompts
+			mcpServer := NewMCPServer("K8S controller MCP", "1.0.0", frontedApi, frontendPageInformer, MCPOptions{
+				Auditor:         apiConfig.Auditor,
+				ConfirmationTTL: mcpConfirmationTTL,
+			})
+
+			if mcpPath != "" {
+				apiRouter.MCP = newStreamableHTTPHandler(mcpServer)
+				apiRouter.MCPPath = mcpPath
+				log.Info().Msgf("Serving MCP over streamable HTTP at %s on the API listener", mcpPath)
+			}
+
+			if mcpPort != 0 {
+				go func() {
+					mcpAddr := fmt.Sprintf(":%d", mcpPort)
+					httpServer := &http.Server{Addr: mcpAddr, TLSConfig: tlsConfig}
+					sseServer := mcpserver.NewSSEServer(mcpServer.MCPServer,
+						// Without a base URL the message endpoint is announced as
+						// a path, which clients resolve against the SSE URL
+						mcpserver.WithBaseURL(mcpBaseURL),
+						mcpserver.WithHTTPServer(httpServer),
+						mcpserver.WithSSEContextFunc(mcpHTTPContext),
+					)
+					httpServer.Handler = apiConfig.HTTPAuthMiddleware(mcpServer.newSSEHandler(sseServer))
+					log.Info().Msgf("Starting MCP SSE server on port %d", mcpPort)
+					var err error
+					if tlsConfig != nil {
+						// The certificate comes from tlsConfig
+						err = httpServer.ListenAndServeTLS("", "")
+					} else {
+						err = sseServer.Start(mcpAddr)
+					}
+					if err != nil {
+						log.Error().Err(err).Msg("Failed to start SSE server")
+						os.Exit(1)
+					}
+				}()
+			}
 		}
 
+		// Wrap all API endpoints with OpenTelemetry middleware. The route table
+		// also serves the health check, the deployments, the MCP streamable
+		// endpoint and its OpenAPI document at /openapi.json.
+		apiRouter.RegisterRoutes(router, wrapHandler)
+
 		addr := fmt.Sprintf(":%d", serverPort)
 		log.Info().Msgf("Starting server on %s", addr)
 		if enableOtel {
 			log.Info().Msg("OpenTelemetry tracing is enabled for all API endpoints")
 		}
-		if err := fasthttp.ListenAndServe(addr, router.Handler); err != nil {
+		if tlsConfig != nil {
+			log.Info().Msg("Serving HTTPS")
+		}
+		handler := router.Handler
+		if len(corsOptions.AllowedOrigins) > 0 {
+			cors, err := api.NewCORS(corsOptions)
+			if err != nil {
+				log.Error().Err(err).Msg("Invalid CORS configuration")
+				os.Exit(1)
+			}
+			// Wrapping the router answers preflight requests for every route
+			handler = cors.Handler(handler)
+			// Browsers don't apply CORS to WebSockets, the watch checks it
+			watcher.CORS = cors
+		}
+		httpServer := &fasthttp.Server{
+			Handler:            handler,
+			MaxRequestBodySize: maxBodySize,
+			ReadTimeout:        readTimeout,
+			WriteTimeout:       writeTimeout,
+			ErrorHandler:       api.ServerErrorHandler,
+		}
+		if err := listenAndServe(addr, httpServer, tlsConfig); err != nil {
 			log.Error().Err(err).Msg("Failed to start server")
 			os.Exit(1)
 		}
 	},
 }
 
+const defaultJWTSecret = "secret"
+
+// listenAndServe serves srv on addr, over TLS when tlsConfig is set
+func listenAndServe(addr string, srv *fasthttp.Server, tlsConfig *tls.Config) error {
+	if tlsConfig == nil {
+		return srv.ListenAndServe(addr)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(tls.NewListener(ln, tlsConfig))
+}
+
+// Where revoked token IDs are kept
+const (
+	revocationStoreMemory    = "memory"
+	revocationStoreConfigMap = "configmap"
+	revocationStoreSecret    = "secret"
+)
+
+// Authorization modes for the REST API
+const (
+	authorizationModeNone   = "none"
+	authorizationModePolicy = "policy"
+	authorizationModeSAR    = "subjectaccessreview"
+)
+
+// validateJWTSecret refuses empty secrets, and the default one unless insecure is set
+func validateJWTSecret(secret string, insecure bool) error {
+	if secret == "" {
+		return fmt.Errorf("--jwt-secret must not be empty")
+	}
+	if secret == defaultJWTSecret && !insecure {
+		return fmt.Errorf("--jwt-secret is the default value, set a real secret or pass --insecure-default-secret")
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(serverCmd)
 	serverCmd.Flags().IntVar(&serverPort, "port", 8080, "Port to listen on")
@@ -233,7 +515,60 @@ func init() {
 	serverCmd.Flags().StringVar(&leaderElectionNamespace, "leader-election-namespace", "default", "Namespace for leader election")
 	serverCmd.Flags().IntVar(&metricsPort, "metrics-port", 8081, "Port for metrics")
 	serverCmd.Flags().BoolVar(&enableMCP, "enable-mcp", false, "Enable MCP server")
-	serverCmd.Flags().IntVar(&mcpPort, "mcp-port", 9090, "Port for MCP server")
+	serverCmd.Flags().IntVar(&mcpPort, "mcp-port", 9090, "Port for the MCP SSE server (0 disables it)")
+	serverCmd.Flags().StringVar(&mcpPath, "mcp-path", api.DefaultMCPPath, "Path of the MCP streamable HTTP endpoint on the API listener (empty disables it)")
+	serverCmd.Flags().DurationVar(&mcpConfirmationTTL, "mcp-confirmation-ttl", defaultMCPConfirmationTTL, "How long destructive MCP tools wait for their confirmation token (0 runs them in one step)")
+	serverCmd.Flags().StringVar(&mcpBaseURL, "mcp-base-url", "", "Public URL of the MCP SSE server, such as https://mcp.example.com, used in the endpoint it announces")
 	serverCmd.Flags().BoolVar(&enableOtel, "enable-otel", false, "Enable OpenTelemetry tracing")
-	serverCmd.Flags().StringVar(&jwtSecret, "jwt-secret", "secret", "JWT secret (required for token-based authentication)")
+	serverCmd.Flags().StringVar(&jwtSecret, "jwt-secret", defaultJWTSecret, "JWT secret (required for token-based authentication)")
+	serverCmd.Flags().BoolVar(&insecureDefaultSecret, "insecure-default-secret", false, "Allow starting with the default JWT secret (development only)")
+	serverCmd.Flags().StringVar(&jwtIssuer, "jwt-issuer", "k8s-controller-tutorial", "Issuer set on and required in API tokens")
+	serverCmd.Flags().StringVar(&jwtAudience, "jwt-audience", "k8s-controller-tutorial", "Audience set on and required in API tokens")
+	serverCmd.Flags().DurationVar(&jwtTTL, "jwt-ttl", 15*time.Minute, "Lifetime of issued access tokens")
+	serverCmd.Flags().DurationVar(&jwtRefreshTTL, "jwt-refresh-ttl", 24*time.Hour, "Lifetime of issued refresh tokens")
+	serverCmd.Flags().StringVar(&revocationStore, "revocation-store", revocationStoreMemory, "Where revoked tokens are kept: memory, configmap or secret")
+	serverCmd.Flags().StringVar(&revocationStoreName, "revocation-store-name", "k8s-controller-tutorial-revocations", "Name of the ConfigMap or Secret in --namespace for revoked tokens")
+	serverCmd.Flags().DurationVar(&revocationSync, "revocation-sync", 30*time.Second, "How often revocations from other replicas are loaded")
+	serverCmd.Flags().DurationVar(&jwtLeeway, "jwt-leeway", 30*time.Second, "Clock skew tolerated when checking token expiry")
+	serverCmd.Flags().StringVar(&oidcJWKSURL, "oidc-jwks-url", "", "URL of the identity provider's JWKS for RS256, ES256 and EdDSA tokens")
+	serverCmd.Flags().StringVar(&oidcJWKSFile, "oidc-jwks-file", "", "Local JWKS file, used when --oidc-jwks-url is not set")
+	serverCmd.Flags().StringVar(&oidcIssuer, "oidc-issuer", "", "Issuer required in identity provider tokens")
+	serverCmd.Flags().StringVar(&oidcAudience, "oidc-audience", "", "Audience required in identity provider tokens")
+	serverCmd.Flags().DurationVar(&oidcJWKSRefresh, "oidc-jwks-refresh", 5*time.Minute, "How often the JWKS is refreshed")
+	serverCmd.Flags().StringVar(&usersFile, "users-file", "", "YAML file with users and bcrypt password hashes accepted by /api/token")
+	serverCmd.Flags().BoolVar(&enableTokenReview, "token-review", false, "Accept Kubernetes ServiceAccount tokens at /api/token, validated with TokenReview")
+	serverCmd.Flags().StringSliceVar(&tokenReviewAudiences, "token-review-audiences", nil, "Audiences ServiceAccount tokens must be issued for")
+	serverCmd.Flags().StringVar(&authorizationMode, "authorization-mode", authorizationModeNone, "How API requests are authorized: none, policy or subjectaccessreview")
+	serverCmd.Flags().StringVar(&authzPolicyFile, "authz-policy-file", "", "File with the role policy for --authorization-mode=policy")
+	serverCmd.Flags().StringVar(&authzPolicyConfigMap, "authz-policy-configmap", "", "ConfigMap in --namespace with the role policy under policy.yaml")
+	serverCmd.Flags().DurationVar(&authzPolicyReload, "authz-policy-reload", 30*time.Second, "How often the role policy is reloaded")
+	serverCmd.Flags().DurationVar(&authzCacheTTL, "authz-cache-ttl", 10*time.Second, "How long SubjectAccessReview decisions are cached")
+	serverCmd.Flags().StringVar(&tlsOptions.CertFile, "tls-cert-file", "", "PEM certificate for the API and MCP listeners, reloaded when it changes")
+	serverCmd.Flags().StringVar(&tlsOptions.KeyFile, "tls-key-file", "", "PEM private key for --tls-cert-file")
+	serverCmd.Flags().StringVar(&tlsOptions.ClientCAFile, "tls-client-ca-file", "", "CA bundle for verifying client certificates, which authenticate as CN with O as groups")
+	serverCmd.Flags().StringVar(&tlsOptions.ClientAuth, "tls-client-auth", certs.ClientAuthRequire, "With --tls-client-ca-file: request (verify certificates that are sent) or require")
+	serverCmd.Flags().BoolVar(&tlsOptions.SelfSigned, "tls-self-signed", false, "Serve a generated self-signed certificate (development only)")
+	serverCmd.Flags().StringSliceVar(&tlsOptions.Hosts, "tls-self-signed-hosts", []string{"localhost", "127.0.0.1"}, "DNS names and IPs in the self-signed certificate")
+	serverCmd.Flags().DurationVar(&tlsReload, "tls-reload", 30*time.Second, "How often the certificate files are checked for changes")
+	serverCmd.Flags().StringVar(&auditLogPath, "audit-log-path", "", "Write audit events as JSON lines to this file, or - for stdout")
+	serverCmd.Flags().IntVar(&auditLogMaxSize, "audit-log-maxsize", 100, "Size in megabytes at which the audit log is rotated")
+	serverCmd.Flags().IntVar(&auditLogMaxBackup, "audit-log-maxbackup", 10, "Number of rotated audit logs to keep")
+	serverCmd.Flags().BoolVar(&auditEvents, "audit-events", false, "Record audited operations as Kubernetes Events on the FrontendPage")
+	serverCmd.Flags().StringVar(&auditPolicyFile, "audit-policy-file", "", "Audit policy with levels per user, verb and resource (defaults to Metadata for everything)")
+	serverCmd.Flags().BoolVar(&enableAPIKeys, "api-keys", false, "Accept API keys in the X-API-Key header and serve /api/apikeys")
+	serverCmd.Flags().StringVar(&apiKeysSecret, "api-keys-secret", defaultAPIKeysSecret, "Secret in --namespace that stores the hashed API keys")
+	serverCmd.Flags().DurationVar(&apiKeysSync, "api-keys-sync", 30*time.Second, "How often API key usage is recorded and keys from other replicas are loaded")
+	serverCmd.Flags().Float64Var(&rateLimit, "rate-limit", 0, "Requests per second each client may make to a route, 0 disables rate limiting")
+	serverCmd.Flags().IntVar(&rateLimitBurst, "rate-limit-burst", 20, "Requests a client may make at once before --rate-limit applies")
+	serverCmd.Flags().StringSliceVar(&rateLimitRoutes, "rate-limit-route", nil, "Budget for one route as Route=rate:burst, e.g. ListFrontendPages=2:5")
+	serverCmd.Flags().IntVar(&maxBodySize, "max-body-size", 4*1024*1024, "Largest request body in bytes, larger ones are rejected with 413")
+	serverCmd.Flags().DurationVar(&readTimeout, "read-timeout", 30*time.Second, "Time allowed for reading a request, including the body")
+	serverCmd.Flags().DurationVar(&writeTimeout, "write-timeout", 30*time.Second, "Time allowed for writing a response (watch streams extend it as they go)")
+	serverCmd.Flags().StringSliceVar(&corsOptions.AllowedOrigins, "cors-allowed-origins", nil, "Origins browsers may call the API from, such as https://ui.example.com, https://*.example.com or *")
+	serverCmd.Flags().StringSliceVar(&corsOptions.AllowedMethods, "cors-allowed-methods", []string{"GET", "POST", "PUT", "PATCH", "DELETE"}, "Methods allowed in cross-origin requests")
+	serverCmd.Flags().StringSliceVar(&corsOptions.AllowedHeaders, "cors-allowed-headers", []string{"Content-Type", "Authorization", api.APIKeyHeader}, "Request headers allowed in cross-origin requests, or *")
+	serverCmd.Flags().StringSliceVar(&corsOptions.ExposedHeaders, "cors-exposed-headers", []string{"Retry-After"}, "Response headers scripts on allowed origins may read")
+	serverCmd.Flags().BoolVar(&corsOptions.AllowCredentials, "cors-allow-credentials", false, "Allow cross-origin requests with cookies and client certificates")
+	serverCmd.Flags().DurationVar(&corsOptions.MaxAge, "cors-max-age", 10*time.Minute, "How long browsers may cache preflight responses")
+	serverCmd.Flags().DurationVar(&watchHeartbeat, "watch-heartbeat", 15*time.Second, "Heartbeat interval for FrontendPage watch streams")
 }
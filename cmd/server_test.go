@@ -20,4 +20,33 @@ func TestServerCmd(t *testing.T) {
 	if serverCmd.Flags().Lookup("namespace") == nil {
 		t.Errorf("expected namespace flag to be defined")
 	}
-}
\ No newline at end of file
+}
+
+func TestValidateJWTSecret(t *testing.T) {
+	for _, name := range []string{"jwt-issuer", "jwt-audience", "jwt-ttl", "users-file", "token-review", "insecure-default-secret"} {
+		if serverCmd.Flags().Lookup(name) == nil {
+			t.Errorf("expected %s flag to be defined", name)
+		}
+	}
+
+	if err := validateJWTSecret(defaultJWTSecret, false); err == nil {
+		t.Errorf("expected the default secret to be refused")
+	}
+	if err := validateJWTSecret(defaultJWTSecret, true); err != nil {
+		t.Errorf("expected the default secret to be allowed with --insecure-default-secret: %v", err)
+	}
+	if err := validateJWTSecret("", true); err == nil {
+		t.Errorf("expected an empty secret to be refused")
+	}
+}
+
+func TestServerCmd_SecurityFlags(t *testing.T) {
+	for _, name := range []string{"tls-cert-file", "tls-key-file", "tls-client-ca-file", "tls-client-auth", "tls-self-signed", "tls-self-signed-hosts", "tls-reload",
+		"audit-log-path", "audit-log-maxsize", "audit-log-maxbackup", "audit-events", "audit-policy-file",
+		"rate-limit", "rate-limit-burst", "rate-limit-route", "max-body-size", "read-timeout", "write-timeout",
+		"cors-allowed-origins", "cors-allowed-methods", "cors-allowed-headers", "cors-exposed-headers", "cors-allow-credentials", "cors-max-age"} {
+		if serverCmd.Flags().Lookup(name) == nil {
+			t.Errorf("expected %s flag to be defined", name)
+		}
+	}
+}
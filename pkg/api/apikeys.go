@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/apikey"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// APIKeyHeader carries API keys as an alternative to bearer tokens
+const APIKeyHeader = "X-API-Key"
+
+// authenticateAPIKey turns a valid key into an identity limited to the key's
+// namespaces and verbs
+func (c *Config) authenticateAPIKey(ctx context.Context, raw string) (*Identity, error) {
+	if c.APIKeys == nil {
+		return nil, errors.New("API keys are not enabled")
+	}
+	key, err := c.APIKeys.Authenticate(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Subject:    "apikey:" + key.Name,
+		Namespaces: key.Namespaces,
+		Verbs:      key.Verbs,
+		APIKey:     key.ID,
+		Creator:    &Identity{Subject: key.CreatedBy, Groups: key.CreatorGroups},
+	}, nil
+}
+
+// CreateAPIKeyRequest describes a new API key
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" openapi:"required"`
+	// Namespaces default to the namespace served by the API
+	Namespaces []string   `json:"namespaces,omitempty"`
+	Verbs      []string   `json:"verbs" openapi:"required"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+// CreateAPIKeyResponse holds the new key. The key itself is only returned once.
+type CreateAPIKeyResponse struct {
+	Key    string     `json:"key"`
+	APIKey apikey.Key `json:"apiKey"`
+}
+
+// apiKeyManager returns the caller for API key management, or writes an error.
+// Keys can't be used to manage other keys.
+func (c *Config) apiKeyManager(ctx *fasthttp.RequestCtx) *Identity {
+	if c.APIKeys == nil {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusServiceUnavailable, "API keys are not enabled"))
+		return nil
+	}
+	identity := IdentityFromRequest(ctx)
+	if identity == nil {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusUnauthorized, "missing bearer token"))
+		return nil
+	}
+	if identity.APIKey != "" {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusForbidden, "API keys can't manage API keys"))
+		return nil
+	}
+	return identity
+}
+
+// ListAPIKeys serves GET /api/apikeys
+func (r *Router) ListAPIKeys(ctx *fasthttp.RequestCtx) {
+	identity := r.Config.apiKeyManager(ctx)
+	if identity == nil {
+		return
+	}
+	keys := []apikey.Key{}
+	for _, key := range r.Config.APIKeys.List() {
+		if key.CreatedBy == identity.Subject {
+			keys = append(keys, key)
+		}
+	}
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(keys)
+}
+
+// CreateAPIKey serves POST /api/apikeys
+func (r *Router) CreateAPIKey(ctx *fasthttp.RequestCtx) {
+	identity := r.Config.apiKeyManager(ctx)
+	if identity == nil {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		WriteProblem(ctx, BadRequest("invalid request body: %v", err))
+		return
+	}
+	if len(req.Namespaces) == 0 {
+		req.Namespaces = []string{r.Service.PageNamespace()}
+	}
+	opts := apikey.CreateOptions{
+		Name:       req.Name,
+		Namespaces: req.Namespaces,
+		Verbs:      req.Verbs,
+		ExpiresAt:  req.ExpiresAt,
+		CreatedBy:  identity.Subject,
+		// The creator is authorized again on each use of the key
+		CreatorGroups: identity.Groups,
+	}
+	if err := opts.Validate(); err != nil {
+		WriteProblem(ctx, BadRequest("%v", err))
+		return
+	}
+
+	// A key can't grant more than its creator may do
+	for _, namespace := range opts.Namespaces {
+		for _, verb := range opts.Verbs {
+			decision, err := r.authorize(ctx, identity, Attributes{Verb: verb, Namespace: namespace})
+			if err != nil {
+				WriteError(ctx, err)
+				return
+			}
+			if !decision.Allowed {
+				problem := NewProblem(fasthttp.StatusForbidden, decision.Reason)
+				problem.Reason = "Forbidden"
+				WriteProblem(ctx, problem)
+				return
+			}
+		}
+	}
+
+	raw, key, err := r.Config.APIKeys.Create(GetOtelContextFromRequest(ctx), opts)
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+	AddSpanAttributes(ctx,
+		attribute.String("apikey.id", key.ID),
+		attribute.String("apikey.name", key.Name),
+	)
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(fasthttp.StatusCreated)
+	json.NewEncoder(ctx).Encode(CreateAPIKeyResponse{Key: raw, APIKey: *key})
+}
+
+// DeleteAPIKey serves DELETE /api/apikeys/{id}
+func (r *Router) DeleteAPIKey(ctx *fasthttp.RequestCtx) {
+	identity := r.Config.apiKeyManager(ctx)
+	if identity == nil {
+		return
+	}
+	id, _ := ctx.UserValue("id").(string)
+	key, ok := r.Config.APIKeys.Get(id)
+	if !ok || key.CreatedBy != identity.Subject {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusNotFound, "API key not found"))
+		return
+	}
+	err := r.Config.APIKeys.Delete(GetOtelContextFromRequest(ctx), id)
+	if errors.Is(err, apikey.ErrNotFound) {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusNotFound, "API key not found"))
+		return
+	}
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+	AddSpanAttributes(ctx, attribute.String("apikey.id", id))
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
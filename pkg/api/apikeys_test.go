@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/apikey"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newAPIKeysConfig returns a Config with an API key store on a fake client
+func newAPIKeysConfig(t *testing.T) *Config {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	config := NewConfig()
+	config.APIKeys = apikey.NewStore(fake.NewClientBuilder().WithScheme(scheme).Build(), "default", "apikeys")
+	return config
+}
+
+func apiKeyRequest(identity *Identity, body string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue(identityKey, identity)
+	ctx.Request.SetBodyString(body)
+	return ctx
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Parallel()
+	config := newAPIKeysConfig(t)
+	policy, err := ParsePolicy([]byte(`
+bindings:
+  - role: editor
+    groups: [editors]
+  - role: viewer
+    users: [bob]
+`))
+	require.NoError(t, err)
+	api := &FrontendPageApi{Namespace: "default", Authorizer: NewPolicyAuthorizer(policy)}
+	router := &Router{Service: api, Config: config}
+	alice := &Identity{Subject: "alice", Groups: []string{"editors"}}
+
+	// Keys can't grant more than their creator may do
+	ctx := apiKeyRequest(&Identity{Subject: "bob"}, `{"name":"ci","verbs":["create"]}`)
+	router.CreateAPIKey(ctx)
+	require.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode())
+
+	ctx = apiKeyRequest(alice, `{"name":"ci","verbs":["escalate"]}`)
+	router.CreateAPIKey(ctx)
+	require.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+
+	ctx = apiKeyRequest(alice, `{"name":"ci","verbs":["get","create"]}`)
+	router.CreateAPIKey(ctx)
+	require.Equal(t, fasthttp.StatusCreated, ctx.Response.StatusCode())
+	var created CreateAPIKeyResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &created))
+	require.Equal(t, []string{"default"}, created.APIKey.Namespaces)
+	require.Equal(t, "alice", created.APIKey.CreatedBy)
+	require.Equal(t, []string{"editors"}, created.APIKey.CreatorGroups)
+
+	// The key authenticates through JwtMiddleware and is limited to its verbs,
+	// without a policy binding for its subject
+	call := func(key string, verb string) (*fasthttp.RequestCtx, bool) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/api/frontendpages/page")
+		ctx.Request.Header.Set(APIKeyHeader, key)
+		called := false
+		config.JwtMiddleware(router.Authorize(func(*fasthttp.RequestCtx) { called = true }, verb))(ctx)
+		return ctx, called
+	}
+	_, called := call(created.Key, VerbCreate)
+	require.True(t, called)
+	ctx, called = call(created.Key, VerbDelete)
+	require.False(t, called)
+	require.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode())
+	ctx, called = call(created.Key+"x", VerbGet)
+	require.False(t, called)
+	require.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+
+	// Keys can't manage keys
+	keyIdentity, err := config.authenticateAPIKey(ctx, created.Key)
+	require.NoError(t, err)
+	ctx = apiKeyRequest(keyIdentity, "")
+	router.ListAPIKeys(ctx)
+	require.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode())
+
+	// Callers only see and delete their own keys
+	ctx = apiKeyRequest(alice, "")
+	router.ListAPIKeys(ctx)
+	var listed []apikey.Key
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &listed))
+	require.Len(t, listed, 1)
+	require.Empty(t, listed[0].Hash)
+	require.Equal(t, int64(3), listed[0].UsageCount)
+
+	ctx = apiKeyRequest(&Identity{Subject: "bob"}, "")
+	router.ListAPIKeys(ctx)
+	require.JSONEq(t, "[]", string(ctx.Response.Body()))
+
+	ctx = apiKeyRequest(&Identity{Subject: "bob"}, "")
+	ctx.SetUserValue("id", created.APIKey.ID)
+	router.DeleteAPIKey(ctx)
+	require.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+
+	// Keys act for their creator, who is authorized again on each request
+	policy, err = ParsePolicy([]byte(`
+bindings:
+  - role: viewer
+    groups: [editors]
+`))
+	require.NoError(t, err)
+	api.Authorizer = NewPolicyAuthorizer(policy)
+	_, called = call(created.Key, VerbGet)
+	require.True(t, called)
+	ctx, called = call(created.Key, VerbCreate)
+	require.False(t, called, "the creator lost create")
+	require.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode())
+	require.Contains(t, string(ctx.Response.Body()), `creator \"alice\" of the API key is no longer allowed`)
+
+	ctx = apiKeyRequest(alice, "")
+	ctx.SetUserValue("id", created.APIKey.ID)
+	router.DeleteAPIKey(ctx)
+	require.Equal(t, fasthttp.StatusNoContent, ctx.Response.StatusCode())
+	_, called = call(created.Key, VerbGet)
+	require.False(t, called)
+}
+
+func TestAPIKeys_Disabled(t *testing.T) {
+	t.Parallel()
+	config := NewConfig()
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set(APIKeyHeader, "fpk_abc_def")
+	called := false
+	config.JwtMiddleware(func(*fasthttp.RequestCtx) { called = true })(ctx)
+	require.False(t, called)
+	require.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+
+	ctx = apiKeyRequest(&Identity{Subject: "alice"}, "")
+	(&Router{Service: &FrontendPageApi{}, Config: config}).ListAPIKeys(ctx)
+	require.Equal(t, fasthttp.StatusServiceUnavailable, ctx.Response.StatusCode())
+}
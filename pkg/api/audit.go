@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/audit"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// apiKeysResource names API keys in audit events
+const apiKeysResource = "apikeys"
+
+// Audit wraps a handler for an audit record of each request, if one of verbs
+// is mutating. It must run after JwtMiddleware and before Authorize, so that
+// denied requests are recorded too.
+func (r *Router) Audit(operation string, next fasthttp.RequestHandler, verbs ...string) fasthttp.RequestHandler {
+	return r.audit(operation, frontendPagesResource, true, next, verbs...)
+}
+
+// audit records bodies only when withBodies is set, so that secrets such as
+// new API keys never end up in the audit log
+func (r *Router) audit(operation, resource string, withBodies bool, next fasthttp.RequestHandler, verbs ...string) fasthttp.RequestHandler {
+	verb := ""
+	for _, v := range verbs {
+		if audit.IsMutating(v) {
+			verb = v
+			break
+		}
+	}
+	if verb == "" {
+		return next
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		if r.Config.Auditor == nil {
+			next(ctx)
+			return
+		}
+		start := time.Now()
+		next(ctx)
+
+		ev := audit.Event{
+			Time:      start,
+			Source:    audit.SourceAPI,
+			SourceIP:  ctx.RemoteIP().String(),
+			Operation: operation,
+			Route:     string(ctx.Method()) + " " + string(ctx.Path()),
+			Verb:      verb,
+			Resource:  resource,
+			Namespace: r.Service.PageNamespace(),
+			Name:      auditTargetName(ctx),
+			DryRun:    string(ctx.QueryArgs().Peek("dryRun")) != "",
+			Status:    ctx.Response.StatusCode(),
+			Outcome:   audit.OutcomeSuccess,
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if identity := IdentityFromRequest(ctx); identity != nil {
+			ev.Actor = audit.Actor{Subject: identity.Subject, Groups: identity.Groups}
+		}
+		if ev.Status >= fasthttp.StatusBadRequest {
+			ev.Outcome = audit.OutcomeFailure
+			var problem Problem
+			if json.Unmarshal(ctx.Response.Body(), &problem) == nil {
+				ev.Error = problem.Detail
+			}
+		}
+
+		var requestBody, responseBody []byte
+		if withBodies {
+			requestBody, responseBody = ctx.PostBody(), ctx.Response.Body()
+		}
+		r.Config.Auditor.Record(GetOtelContextFromRequest(ctx), ev, requestBody, responseBody)
+		AddSpanAttributes(ctx, attribute.String("audit.outcome", ev.Outcome))
+	}
+}
+
+// auditTargetName is the :name or :id route parameter, or the name in the
+// body of create requests
+func auditTargetName(ctx *fasthttp.RequestCtx) string {
+	for _, param := range []string{"name", "id"} {
+		if name, ok := ctx.UserValue(param).(string); ok {
+			return name
+		}
+	}
+	var body struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(ctx.PostBody(), &body)
+	return body.Name
+}
+
+// AuditAPIKeys wraps an API key handler for an audit record without bodies,
+// since they contain the new key
+func (r *Router) AuditAPIKeys(operation string, next fasthttp.RequestHandler, verb string) fasthttp.RequestHandler {
+	return r.audit(operation, apiKeysResource, false, next, verb)
+}
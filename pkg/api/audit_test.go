@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/audit"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+type auditCapture struct {
+	events []audit.Event
+}
+
+func (c *auditCapture) Write(_ context.Context, ev *audit.Event) error {
+	c.events = append(c.events, *ev)
+	return nil
+}
+
+// newAuditConfig returns a Config whose Auditor records at level into the
+// returned capture
+func newAuditConfig(level audit.Level) (*Config, *auditCapture) {
+	capture := &auditCapture{}
+	config := NewConfig()
+	config.Auditor = audit.NewLogger(&audit.Policy{Rules: []audit.Rule{{Level: level}}}, capture)
+	return config, capture
+}
+
+func TestAudit(t *testing.T) {
+	t.Parallel()
+	config, capture := newAuditConfig(audit.LevelRequestResponse)
+	policy, err := ParsePolicy([]byte(`
+bindings:
+  - role: editor
+    users: [alice]
+`))
+	require.NoError(t, err)
+	router := &Router{Service: &FrontendPageApi{Namespace: "default", Authorizer: NewPolicyAuthorizer(policy)}, Config: config}
+
+	request := func(identity *Identity, method, uri, body string, handler fasthttp.RequestHandler, verbs ...string) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		ctx.Request.SetRequestURI(uri)
+		ctx.Request.SetBodyString(body)
+		ctx.SetUserValue(identityKey, identity)
+		router.Audit("Operation", router.Authorize(handler, verbs...), verbs...)(ctx)
+	}
+	ok := func(ctx *fasthttp.RequestCtx) { ctx.WriteString(`{"ok":true}`) }
+	alice := &Identity{Subject: "alice", Groups: []string{"editors"}}
+
+	request(alice, "POST", "/api/frontendpages?dryRun=All", `{"name":"page"}`, ok, VerbCreate)
+	require.Len(t, capture.events, 1)
+	ev := capture.events[0]
+	require.Equal(t, audit.Actor{Subject: "alice", Groups: []string{"editors"}}, ev.Actor)
+	require.Equal(t, "POST /api/frontendpages", ev.Route)
+	require.Equal(t, VerbCreate, ev.Verb)
+	require.Equal(t, "default", ev.Namespace)
+	require.Equal(t, "page", ev.Name, "the target of creates comes from the body")
+	require.True(t, ev.DryRun)
+	require.Equal(t, audit.OutcomeSuccess, ev.Outcome)
+	require.Equal(t, fasthttp.StatusOK, ev.Status)
+	require.Equal(t, `{"name":"page"}`, ev.RequestBody)
+	require.Equal(t, `{"ok":true}`, ev.ResponseBody)
+	require.NotEmpty(t, ev.SourceIP)
+
+	// Denied requests are recorded as failures
+	request(&Identity{Subject: "bob"}, "DELETE", "/api/frontendpages/page", "", ok, VerbDelete)
+	require.Len(t, capture.events, 2)
+	ev = capture.events[1]
+	require.Equal(t, audit.OutcomeFailure, ev.Outcome)
+	require.Equal(t, fasthttp.StatusForbidden, ev.Status)
+	require.NotEmpty(t, ev.Error)
+
+	// Reads are not audited
+	request(alice, "GET", "/api/frontendpages", "", ok, VerbList)
+	require.Len(t, capture.events, 2)
+
+	// API key bodies are never recorded
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetBodyString(`{"name":"ci"}`)
+	ctx.SetUserValue(identityKey, alice)
+	router.AuditAPIKeys("CreateAPIKey", func(ctx *fasthttp.RequestCtx) { ctx.WriteString(`{"key":"fpk_secret"}`) }, VerbCreate)(ctx)
+	require.Len(t, capture.events, 3)
+	ev = capture.events[2]
+	require.Equal(t, apiKeysResource, ev.Resource)
+	require.Equal(t, "ci", ev.Name)
+	require.Empty(t, ev.RequestBody)
+	require.Empty(t, ev.ResponseBody)
+}
+
+func TestWithReservedNames_ClearsName(t *testing.T) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue("name", "import")
+	var name any = "unset"
+	WithReservedNames(map[string]fasthttp.RequestHandler{
+		"import": func(ctx *fasthttp.RequestCtx) { name = ctx.UserValue("name") },
+	}, NotFound)(ctx)
+	require.Nil(t, name)
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// ErrInvalidCredentials is returned when no authenticator accepts the credentials
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Credentials are presented to the token endpoint. Either Username and
+// Password or ServiceAccountToken is set.
+type Credentials struct {
+	Username            string `json:"username,omitempty"`
+	Password            string `json:"password,omitempty"`
+	ServiceAccountToken string `json:"serviceAccountToken,omitempty"`
+}
+
+// Identity is an authenticated caller
+type Identity struct {
+	Subject string
+	Groups  []string
+	// Namespaces the caller is limited to. Empty means no limit.
+	Namespaces []string
+	// Verbs the caller is limited to. Empty means no limit.
+	Verbs []string
+	// APIKey is the ID of the API key the caller authenticated with
+	APIKey string
+	// Creator is who created the API key. The Authorizer checks the creator,
+	// and the key's own scope applies on top.
+	Creator *Identity
+}
+
+// Authenticator verifies credentials. It returns a nil identity and no error
+// for credentials of a kind it doesn't handle, so authenticators can be chained.
+type Authenticator interface {
+	Authenticate(ctx context.Context, creds Credentials) (*Identity, error)
+}
+
+// Authenticators tries each authenticator in turn
+type Authenticators []Authenticator
+
+// Authenticate returns the first identity accepted by an authenticator
+func (a Authenticators) Authenticate(ctx context.Context, creds Credentials) (*Identity, error) {
+	for _, authenticator := range a {
+		identity, err := authenticator.Authenticate(ctx, creds)
+		if err != nil {
+			return nil, err
+		}
+		if identity != nil {
+			return identity, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// StaticUser is an entry of the users file
+type StaticUser struct {
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"passwordHash"`
+	Groups       []string `json:"groups,omitempty"`
+	Namespaces   []string `json:"namespaces,omitempty"`
+}
+
+// StaticUsers authenticates usernames and passwords against bcrypt hashes
+type StaticUsers struct {
+	users map[string]StaticUser
+}
+
+// used when the user doesn't exist, so that unknown and known users take
+// the same time to reject
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	return hash
+})
+
+// NewStaticUsers checks the users and indexes them by name
+func NewStaticUsers(users []StaticUser) (*StaticUsers, error) {
+	s := &StaticUsers{users: map[string]StaticUser{}}
+	for _, user := range users {
+		if user.Username == "" {
+			return nil, fmt.Errorf("user without a username")
+		}
+		if _, ok := s.users[user.Username]; ok {
+			return nil, fmt.Errorf("user %q is defined more than once", user.Username)
+		}
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("user %q: password hash is not a bcrypt hash: %w", user.Username, err)
+		}
+		s.users[user.Username] = user
+	}
+	return s, nil
+}
+
+// LoadUsersFile reads a YAML or JSON file with a list of users:
+//
+//	users:
+//	  - username: alice
+//	    passwordHash: $2y$10$...
+//	    groups: [editors]
+//	    namespaces: [staging]
+func LoadUsersFile(path string) (*StaticUsers, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Users []StaticUser `json:"users"`
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("parse users file %s: %w", path, err)
+	}
+	return NewStaticUsers(file.Users)
+}
+
+// Authenticate implements Authenticator
+func (s *StaticUsers) Authenticate(_ context.Context, creds Credentials) (*Identity, error) {
+	if creds.Username == "" {
+		return nil, nil
+	}
+	user, ok := s.users[creds.Username]
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(creds.Password))
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(creds.Password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{Subject: user.Username, Groups: user.Groups, Namespaces: user.Namespaces}, nil
+}
+
+// TokenReviewAuthenticator validates Kubernetes ServiceAccount tokens with the
+// TokenReview API
+type TokenReviewAuthenticator struct {
+	Client client.Client
+	// Audiences the token must be issued for. Empty means the API server's default.
+	Audiences []string
+}
+
+// Authenticate implements Authenticator
+func (t *TokenReviewAuthenticator) Authenticate(ctx context.Context, creds Credentials) (*Identity, error) {
+	if creds.ServiceAccountToken == "" {
+		return nil, nil
+	}
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     creds.ServiceAccountToken,
+			Audiences: t.Audiences,
+		},
+	}
+	if err := t.Client.Create(ctx, review); err != nil {
+		return nil, fmt.Errorf("token review: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{
+		Subject: review.Status.User.Username,
+		Groups:  review.Status.User.Groups,
+	}, nil
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestLoadUsersFile(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("users:\n  - username: alice\n    passwordHash: "+string(hash)+"\n    groups: [editors]\n"), 0o600))
+	users, err := LoadUsersFile(path)
+	require.NoError(t, err)
+	identity, err := users.Authenticate(context.Background(), Credentials{Username: "alice", Password: "s3cret"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"editors"}, identity.Groups)
+
+	require.NoError(t, os.WriteFile(path, []byte("users:\n  - username: bob\n    passwordHash: plain\n"), 0o600))
+	_, err = LoadUsersFile(path)
+	require.ErrorContains(t, err, "not a bcrypt hash")
+}
+
+func TestTokenReviewAuthenticator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, authenticationv1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			review := obj.(*authenticationv1.TokenReview)
+			if review.Spec.Token == "valid" {
+				review.Status.Authenticated = true
+				review.Status.User = authenticationv1.UserInfo{
+					Username: "system:serviceaccount:default:editor",
+					Groups:   []string{"system:serviceaccounts"},
+				}
+			}
+			return nil
+		},
+	}).Build()
+
+	authenticator := Authenticators{newTestUsers(t), &TokenReviewAuthenticator{Client: c}}
+
+	identity, err := authenticator.Authenticate(context.Background(), Credentials{ServiceAccountToken: "valid"})
+	require.NoError(t, err)
+	require.Equal(t, "system:serviceaccount:default:editor", identity.Subject)
+
+	_, err = authenticator.Authenticate(context.Background(), Credentials{ServiceAccountToken: "forged"})
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	identity, err = authenticator.Authenticate(context.Background(), Credentials{Username: "alice", Password: "s3cret"})
+	require.NoError(t, err)
+	require.Equal(t, "alice", identity.Subject)
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Verbs checked by the authorizer. They match the Kubernetes verbs for the
+// equivalent operation on FrontendPages.
+const (
+	VerbGet    = "get"
+	VerbList   = "list"
+	VerbWatch  = "watch"
+	VerbCreate = "create"
+	VerbUpdate = "update"
+	VerbPatch  = "patch"
+	VerbDelete = "delete"
+	// VerbLogs reads the logs of the pods serving a page. It has no
+	// FrontendPage equivalent, SubjectAccessReviews check get on pods/log.
+	VerbLogs = "logs"
+)
+
+// Attributes describe the action being authorized
+type Attributes struct {
+	Verb      string
+	Namespace string
+	Name      string
+}
+
+// Decision is the result of an authorization check
+type Decision struct {
+	Allowed bool
+	Reason  string
+}
+
+// Authorizer decides whether an identity may perform an action
+type Authorizer interface {
+	Authorize(ctx context.Context, identity *Identity, attrs Attributes) (Decision, error)
+}
+
+// checkNamespaces enforces the namespaces claim of the token, which applies
+// on top of any authorizer
+func checkNamespaces(identity *Identity, namespace string) (Decision, bool) {
+	if len(identity.Namespaces) == 0 || slices.Contains(identity.Namespaces, namespace) {
+		return Decision{}, true
+	}
+	return Decision{Reason: fmt.Sprintf("token is not valid for namespace %q", namespace)}, false
+}
+
+// Authorize wraps a handler with an authorization check for each of verbs. It
+// must run after JwtMiddleware. List requests with watch=true are checked
+// for the watch verb. Without an Authorizer every authenticated caller is
+// allowed, subject to the namespaces claim and the scope of API keys. API
+// keys are authorized as their creator.
+func (r *Router) Authorize(next fasthttp.RequestHandler, verbs ...string) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		identity := IdentityFromRequest(ctx)
+		if identity == nil {
+			WriteProblem(ctx, NewProblem(fasthttp.StatusUnauthorized, "missing bearer token"))
+			return
+		}
+
+		name, _ := ctx.UserValue("name").(string)
+		for _, verb := range verbs {
+			if verb == VerbList && ctx.QueryArgs().GetBool("watch") {
+				verb = VerbWatch
+			}
+			attrs := Attributes{Verb: verb, Namespace: r.Service.PageNamespace(), Name: name}
+			decision, err := r.authorize(ctx, identity, attrs)
+			if err != nil {
+				WriteError(ctx, err)
+				return
+			}
+
+			AddSpanAttributes(ctx,
+				attribute.String("authz.subject", identity.Subject),
+				attribute.String("authz.verb", verb),
+				attribute.Bool("authz.allowed", decision.Allowed),
+			)
+			if !decision.Allowed {
+				AddSpanEventToRequest(ctx, "authorization_denied",
+					attribute.String("authz.reason", decision.Reason),
+				)
+				problem := NewProblem(fasthttp.StatusForbidden, decision.Reason)
+				problem.Reason = "Forbidden"
+				WriteProblem(ctx, problem)
+				return
+			}
+		}
+		next(ctx)
+	}
+}
+
+func (r *Router) authorize(ctx *fasthttp.RequestCtx, identity *Identity, attrs Attributes) (Decision, error) {
+	return r.Service.AuthorizeIdentity(GetOtelContextFromRequest(ctx), identity, attrs)
+}
+
+// AuthorizeIdentity decides whether identity may perform attrs with the same
+// rules as Authorize, for callers outside the REST API such as MCP tools
+func (api *FrontendPageApi) AuthorizeIdentity(ctx context.Context, identity *Identity, attrs Attributes) (Decision, error) {
+	if decision, ok := checkNamespaces(identity, attrs.Namespace); !ok {
+		return decision, nil
+	}
+	if len(identity.Verbs) > 0 && !slices.Contains(identity.Verbs, attrs.Verb) {
+		return Decision{Reason: fmt.Sprintf("credentials are not valid for %q", attrs.Verb)}, nil
+	}
+	if api.Authorizer == nil {
+		return Decision{Allowed: true}, nil
+	}
+	if identity.APIKey == "" {
+		return api.Authorizer.Authorize(ctx, identity, attrs)
+	}
+
+	// API keys act for their creator, so a key stops working as soon as its
+	// creator loses access. Decisions are cached by the Authorizer.
+	if identity.Creator == nil || identity.Creator.Subject == "" {
+		return Decision{Reason: "API key has no creator to authorize"}, nil
+	}
+	decision, err := api.Authorizer.Authorize(ctx, identity.Creator, attrs)
+	if err != nil || decision.Allowed {
+		return decision, err
+	}
+	decision.Reason = fmt.Sprintf("creator %q of the API key is no longer allowed: %s", identity.Creator.Subject, decision.Reason)
+	return decision, nil
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Built-in roles, used unless the policy redefines them
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// PolicyConfigMapKey is the ConfigMap key that holds the policy
+const PolicyConfigMapKey = "policy.yaml"
+
+var defaultRoles = map[string][]string{
+	RoleViewer: {VerbGet, VerbList, VerbWatch},
+	RoleEditor: {VerbGet, VerbList, VerbWatch, VerbCreate, VerbUpdate, VerbPatch},
+	RoleAdmin:  {"*"},
+}
+
+// RoleBinding grants a role to users and groups, optionally only in some namespaces
+type RoleBinding struct {
+	Role       string   `json:"role"`
+	Users      []string `json:"users,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// Policy maps roles to verbs and binds them to callers:
+//
+//	roles:
+//	  publisher: [get, list, create, update]
+//	bindings:
+//	  - role: editor
+//	    groups: [editors]
+//	    namespaces: [staging]
+//	  - role: admin
+//	    users: [alice]
+type Policy struct {
+	Roles    map[string][]string `json:"roles,omitempty"`
+	Bindings []RoleBinding       `json:"bindings"`
+}
+
+// ParsePolicy reads a YAML or JSON policy and adds the built-in roles it
+// doesn't redefine
+func ParsePolicy(data []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("parse policy: %w", err)
+	}
+	if policy.Roles == nil {
+		policy.Roles = map[string][]string{}
+	}
+	for role, verbs := range defaultRoles {
+		if _, ok := policy.Roles[role]; !ok {
+			policy.Roles[role] = verbs
+		}
+	}
+	for i, binding := range policy.Bindings {
+		if _, ok := policy.Roles[binding.Role]; !ok {
+			return nil, fmt.Errorf("binding %d: unknown role %q", i, binding.Role)
+		}
+		if len(binding.Users) == 0 && len(binding.Groups) == 0 {
+			return nil, fmt.Errorf("binding %d: no users or groups", i)
+		}
+	}
+	return policy, nil
+}
+
+func (b *RoleBinding) matches(identity *Identity, namespace string) bool {
+	if len(b.Namespaces) > 0 && !slices.Contains(b.Namespaces, namespace) {
+		return false
+	}
+	if slices.Contains(b.Users, identity.Subject) {
+		return true
+	}
+	for _, group := range identity.Groups {
+		if slices.Contains(b.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize implements Authorizer
+func (p *Policy) Authorize(_ context.Context, identity *Identity, attrs Attributes) (Decision, error) {
+	for _, binding := range p.Bindings {
+		if !binding.matches(identity, attrs.Namespace) {
+			continue
+		}
+		verbs := p.Roles[binding.Role]
+		if slices.Contains(verbs, "*") || slices.Contains(verbs, attrs.Verb) {
+			return Decision{Allowed: true, Reason: fmt.Sprintf("allowed by role %q", binding.Role)}, nil
+		}
+	}
+	return Decision{Reason: fmt.Sprintf("user %q has no role that allows %q on frontendpages in namespace %q",
+		identity.Subject, attrs.Verb, attrs.Namespace)}, nil
+}
+
+// PolicyAuthorizer authorizes with a policy that can be replaced at runtime
+type PolicyAuthorizer struct {
+	policy atomic.Pointer[Policy]
+}
+
+// NewPolicyAuthorizer starts with policy
+func NewPolicyAuthorizer(policy *Policy) *PolicyAuthorizer {
+	a := &PolicyAuthorizer{}
+	a.policy.Store(policy)
+	return a
+}
+
+// SetPolicy replaces the policy for subsequent requests
+func (a *PolicyAuthorizer) SetPolicy(policy *Policy) {
+	a.policy.Store(policy)
+}
+
+// Authorize implements Authorizer
+func (a *PolicyAuthorizer) Authorize(ctx context.Context, identity *Identity, attrs Attributes) (Decision, error) {
+	return a.policy.Load().Authorize(ctx, identity, attrs)
+}
+
+// PolicySource loads the raw policy document
+type PolicySource func(ctx context.Context) ([]byte, error)
+
+// PolicyFile reads the policy from a file
+func PolicyFile(path string) PolicySource {
+	return func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}
+}
+
+// PolicyConfigMap reads the policy from the policy.yaml key of a ConfigMap
+func PolicyConfigMap(reader client.Reader, namespace, name string) PolicySource {
+	return func(ctx context.Context) ([]byte, error) {
+		cm := &corev1.ConfigMap{}
+		if err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, cm); err != nil {
+			return nil, err
+		}
+		data, ok := cm.Data[PolicyConfigMapKey]
+		if !ok {
+			return nil, fmt.Errorf("configmap %s/%s has no %s key", namespace, name, PolicyConfigMapKey)
+		}
+		return []byte(data), nil
+	}
+}
+
+// LoadPolicyAuthorizer loads the initial policy from source
+func LoadPolicyAuthorizer(ctx context.Context, source PolicySource) (*PolicyAuthorizer, error) {
+	data, err := source(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewPolicyAuthorizer(policy), nil
+}
+
+// Watch reloads the policy from source every interval until ctx is done. A
+// policy that fails to load or parse is logged and the previous one is kept.
+func (a *PolicyAuthorizer) Watch(ctx context.Context, source PolicySource, interval time.Duration) {
+	var last []byte
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, err := source(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to read authorization policy, keeping the current one")
+			continue
+		}
+		if last != nil && bytes.Equal(data, last) {
+			continue
+		}
+		policy, err := ParsePolicy(data)
+		if err != nil {
+			log.Error().Err(err).Msg("Invalid authorization policy, keeping the current one")
+			continue
+		}
+		if last != nil {
+			log.Info().Msg("Reloaded authorization policy")
+		}
+		last = data
+		a.SetPolicy(policy)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPolicyAuthorize(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`
+roles:
+  publisher: [get, list, create]
+bindings:
+  - role: editor
+    groups: [editors]
+    namespaces: [staging]
+  - role: admin
+    users: [alice]
+  - role: publisher
+    users: [carol]
+`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		identity Identity
+		attrs    Attributes
+		allowed  bool
+	}{
+		{"admin can delete", Identity{Subject: "alice"}, Attributes{Verb: VerbDelete, Namespace: "default"}, true},
+		{"editor in scope", Identity{Subject: "bob", Groups: []string{"editors"}}, Attributes{Verb: VerbPatch, Namespace: "staging"}, true},
+		{"editor can't delete", Identity{Subject: "bob", Groups: []string{"editors"}}, Attributes{Verb: VerbDelete, Namespace: "staging"}, false},
+		{"editor out of scope", Identity{Subject: "bob", Groups: []string{"editors"}}, Attributes{Verb: VerbGet, Namespace: "default"}, false},
+		{"custom role", Identity{Subject: "carol"}, Attributes{Verb: VerbCreate, Namespace: "default"}, true},
+		{"custom role verbs", Identity{Subject: "carol"}, Attributes{Verb: VerbUpdate, Namespace: "default"}, false},
+		{"no binding", Identity{Subject: "dave"}, Attributes{Verb: VerbGet, Namespace: "default"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := policy.Authorize(context.Background(), &tt.identity, tt.attrs)
+			require.NoError(t, err)
+			require.Equal(t, tt.allowed, decision.Allowed, decision.Reason)
+		})
+	}
+
+	_, err = ParsePolicy([]byte("bindings:\n  - role: owner\n    users: [alice]\n"))
+	require.ErrorContains(t, err, "unknown role")
+}
+
+func TestPolicyAuthorizer_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("bindings:\n  - role: viewer\n    users: [bob]\n"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	authorizer, err := LoadPolicyAuthorizer(ctx, PolicyFile(path))
+	require.NoError(t, err)
+	go authorizer.Watch(ctx, PolicyFile(path), 10*time.Millisecond)
+
+	bob := &Identity{Subject: "bob"}
+	deleteAttrs := Attributes{Verb: VerbDelete, Namespace: "default"}
+	decision, err := authorizer.Authorize(ctx, bob, deleteAttrs)
+	require.NoError(t, err)
+	require.False(t, decision.Allowed)
+
+	// Invalid policies are ignored
+	require.NoError(t, os.WriteFile(path, []byte("bindings: [\n"), 0o600))
+	time.Sleep(50 * time.Millisecond)
+	decision, _ = authorizer.Authorize(ctx, bob, Attributes{Verb: VerbGet, Namespace: "default"})
+	require.True(t, decision.Allowed)
+
+	require.NoError(t, os.WriteFile(path, []byte("bindings:\n  - role: admin\n    users: [bob]\n"), 0o600))
+	require.Eventually(t, func() bool {
+		decision, _ := authorizer.Authorize(ctx, bob, deleteAttrs)
+		return decision.Allowed
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPolicyConfigMap(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "authz", Namespace: "default"},
+		Data:       map[string]string{PolicyConfigMapKey: "bindings:\n  - role: admin\n    groups: [admins]\n"},
+	}).Build()
+
+	authorizer, err := LoadPolicyAuthorizer(context.Background(), PolicyConfigMap(c, "default", "authz"))
+	require.NoError(t, err)
+	decision, err := authorizer.Authorize(context.Background(), &Identity{Subject: "x", Groups: []string{"admins"}}, Attributes{Verb: VerbDelete})
+	require.NoError(t, err)
+	require.True(t, decision.Allowed)
+
+	_, err = LoadPolicyAuthorizer(context.Background(), PolicyConfigMap(c, "default", "missing"))
+	require.Error(t, err)
+}
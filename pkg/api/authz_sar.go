@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// frontendPagesResource is the resource checked in SubjectAccessReviews
+const frontendPagesResource = "frontendpages"
+
+// SubjectAccessReviewAuthorizer asks the Kubernetes API server whether the
+// caller could perform the same verb on FrontendPages directly, so cluster
+// RBAC decides who may use the API
+type SubjectAccessReviewAuthorizer struct {
+	Client client.Client
+	// TTL for cached decisions. Zero disables caching.
+	TTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedDecision
+	now   func() time.Time
+}
+
+type cachedDecision struct {
+	decision Decision
+	expires  time.Time
+}
+
+// NewSubjectAccessReviewAuthorizer caches decisions for ttl
+func NewSubjectAccessReviewAuthorizer(c client.Client, ttl time.Duration) *SubjectAccessReviewAuthorizer {
+	return &SubjectAccessReviewAuthorizer{
+		Client: c,
+		TTL:    ttl,
+		cache:  map[string]cachedDecision{},
+		now:    time.Now,
+	}
+}
+
+func sarCacheKey(identity *Identity, attrs Attributes) string {
+	return strings.Join([]string{
+		identity.Subject,
+		strings.Join(identity.Groups, ","),
+		attrs.Verb,
+		attrs.Namespace,
+		attrs.Name,
+	}, "\x00")
+}
+
+// Authorize implements Authorizer
+func (s *SubjectAccessReviewAuthorizer) Authorize(ctx context.Context, identity *Identity, attrs Attributes) (Decision, error) {
+	key := sarCacheKey(identity, attrs)
+	if s.TTL > 0 {
+		s.mu.Lock()
+		cached, ok := s.cache[key]
+		s.mu.Unlock()
+		if ok && s.now().Before(cached.expires) {
+			return cached.decision, nil
+		}
+	}
+
+	resource := &authorizationv1.ResourceAttributes{
+		Namespace: attrs.Namespace,
+		Verb:      attrs.Verb,
+		Group:     frontendv1alpha1.SchemeGroupVersion.Group,
+		Resource:  frontendPagesResource,
+		Name:      attrs.Name,
+	}
+	if attrs.Verb == VerbLogs {
+		// The same check as kubectl logs, for any pod in the namespace
+		resource = &authorizationv1.ResourceAttributes{
+			Namespace:   attrs.Namespace,
+			Verb:        VerbGet,
+			Resource:    "pods",
+			Subresource: "log",
+		}
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:               identity.Subject,
+			Groups:             identity.Groups,
+			ResourceAttributes: resource,
+		},
+	}
+	if err := s.Client.Create(ctx, review); err != nil {
+		return Decision{}, fmt.Errorf("subject access review: %w", err)
+	}
+
+	decision := Decision{Allowed: review.Status.Allowed && !review.Status.Denied, Reason: review.Status.Reason}
+	if !decision.Allowed && decision.Reason == "" {
+		target := resource.Resource + "." + resource.Group
+		if resource.Subresource != "" {
+			target = resource.Resource + "/" + resource.Subresource
+		}
+		decision.Reason = fmt.Sprintf("user %q cannot %s %s in namespace %q",
+			identity.Subject, resource.Verb, target, attrs.Namespace)
+	}
+
+	if s.TTL > 0 {
+		s.mu.Lock()
+		now := s.now()
+		for k, cached := range s.cache {
+			if !now.Before(cached.expires) {
+				delete(s.cache, k)
+			}
+		}
+		s.cache[key] = cachedDecision{decision: decision, expires: now.Add(s.TTL)}
+		s.mu.Unlock()
+	}
+	return decision, nil
+}
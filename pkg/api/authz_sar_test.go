@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestSubjectAccessReviewAuthorizer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, authorizationv1.AddToScheme(scheme))
+	reviews := 0
+	c := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			reviews++
+			review := obj.(*authorizationv1.SubjectAccessReview)
+			attrs := review.Spec.ResourceAttributes
+			if attrs.Subresource == "log" {
+				// Pod logs are checked on pods/log, which only alice may get
+				require.Equal(t, "pods", attrs.Resource)
+				require.Equal(t, VerbGet, attrs.Verb)
+				review.Status.Allowed = review.Spec.User == "alice"
+				return nil
+			}
+			require.Equal(t, "frontend.jraver.io", attrs.Group)
+			require.Equal(t, "frontendpages", attrs.Resource)
+			review.Status.Allowed = review.Spec.User == "alice" || attrs.Verb == VerbGet
+			return nil
+		},
+	}).Build()
+
+	now := time.Now()
+	authorizer := NewSubjectAccessReviewAuthorizer(c, time.Minute)
+	authorizer.now = func() time.Time { return now }
+	ctx := context.Background()
+	bob := &Identity{Subject: "bob", Groups: []string{"system:authenticated"}}
+
+	decision, err := authorizer.Authorize(ctx, bob, Attributes{Verb: VerbDelete, Namespace: "default", Name: "page"})
+	require.NoError(t, err)
+	require.False(t, decision.Allowed)
+	require.Contains(t, decision.Reason, `user "bob" cannot delete`)
+
+	decision, err = authorizer.Authorize(ctx, bob, Attributes{Verb: VerbGet, Namespace: "default", Name: "page"})
+	require.NoError(t, err)
+	require.True(t, decision.Allowed)
+
+	decision, err = authorizer.Authorize(ctx, &Identity{Subject: "alice"}, Attributes{Verb: VerbDelete, Namespace: "default", Name: "page"})
+	require.NoError(t, err)
+	require.True(t, decision.Allowed)
+	require.Equal(t, 3, reviews)
+
+	// Decisions are cached until the TTL passes
+	_, err = authorizer.Authorize(ctx, bob, Attributes{Verb: VerbDelete, Namespace: "default", Name: "page"})
+	require.NoError(t, err)
+	require.Equal(t, 3, reviews)
+
+	now = now.Add(2 * time.Minute)
+	_, err = authorizer.Authorize(ctx, bob, Attributes{Verb: VerbDelete, Namespace: "default", Name: "page"})
+	require.NoError(t, err)
+	require.Equal(t, 4, reviews)
+
+	// Reading a page doesn't allow reading the logs of its pods
+	decision, err = authorizer.Authorize(ctx, bob, Attributes{Verb: VerbLogs, Namespace: "default", Name: "page"})
+	require.NoError(t, err)
+	require.False(t, decision.Allowed)
+	require.Contains(t, decision.Reason, `user "bob" cannot get pods/log`)
+}
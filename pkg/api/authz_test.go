@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func authorizeRequest(t *testing.T, api *FrontendPageApi, identity *Identity, uri string, verbs ...string) (*fasthttp.RequestCtx, bool) {
+	t.Helper()
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(uri)
+	ctx.SetUserValue(identityKey, identity)
+	called := false
+	(&Router{Service: api}).Authorize(func(*fasthttp.RequestCtx) { called = true }, verbs...)(ctx)
+	return ctx, called
+}
+
+func TestAuthorize(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`
+bindings:
+  - role: viewer
+    groups: [readers]
+`))
+	require.NoError(t, err)
+	api := &FrontendPageApi{Namespace: "default", Authorizer: NewPolicyAuthorizer(policy)}
+	reader := &Identity{Subject: "bob", Groups: []string{"readers"}}
+
+	_, called := authorizeRequest(t, api, reader, "/api/frontendpages?watch=true", VerbList)
+	require.True(t, called)
+
+	ctx, called := authorizeRequest(t, api, reader, "/api/frontendpages/page", VerbDelete)
+	require.False(t, called)
+	require.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode())
+	var problem Problem
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &problem))
+	require.Equal(t, "Forbidden", problem.Reason)
+	require.Contains(t, problem.Detail, `"delete"`)
+
+	// The namespaces claim limits the token even without an authorizer
+	api = &FrontendPageApi{Namespace: "default"}
+	_, called = authorizeRequest(t, api, &Identity{Subject: "bob"}, "/api/frontendpages", VerbList)
+	require.True(t, called)
+	ctx, called = authorizeRequest(t, api, &Identity{Subject: "bob", Namespaces: []string{"staging"}}, "/api/frontendpages", VerbList)
+	require.False(t, called)
+	require.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode())
+
+	ctx = &fasthttp.RequestCtx{}
+	(&Router{Service: api}).Authorize(func(*fasthttp.RequestCtx) {}, VerbList)(ctx)
+	require.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/apikey"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/audit"
+)
+
+// Config holds the authentication, audit and rate limit settings of a Router
+// and of the MCP listeners. Start from NewConfig.
+type Config struct {
+	// JWTSecret signs issued tokens and verifies HS256 tokens. Empty rejects
+	// them.
+	JWTSecret string
+	// JWTIssuer and JWTAudience are set on issued tokens and, when not empty,
+	// required on incoming ones
+	JWTIssuer   string
+	JWTAudience string
+	// JWTTTL is the lifetime of issued access tokens
+	JWTTTL time.Duration
+	// JWTRefreshTTL is the lifetime of issued refresh tokens
+	JWTRefreshTTL time.Duration
+	// JWTLeeway is the clock skew tolerated when checking exp, nbf and iat
+	JWTLeeway time.Duration
+	// OIDC verifies asymmetric tokens from an external identity provider. Nil
+	// means only tokens signed with JWTSecret are accepted.
+	OIDC *OIDCConfig
+	// Revocations lists revoked token IDs. It is checked by JwtMiddleware and
+	// updated by the revoke and refresh endpoints, and must be set.
+	Revocations *RevocationList
+	// APIKeys verifies keys sent in APIKeyHeader. Nil means API keys are
+	// disabled.
+	APIKeys *apikey.Store
+	// TokenAuthenticator checks the credentials sent to the token endpoint.
+	// No tokens are issued while it is nil.
+	TokenAuthenticator Authenticator
+	// Auditor records mutating requests. Nil disables auditing.
+	Auditor *audit.Logger
+	// RateLimits limits requests per client. Nil disables rate limiting.
+	RateLimits *RateLimiter
+}
+
+// NewConfig returns a Config with the default token lifetimes and an
+// in-memory revocation list
+func NewConfig() *Config {
+	return &Config{
+		JWTTTL:        15 * time.Minute,
+		JWTRefreshTTL: 24 * time.Hour,
+		Revocations:   NewRevocationList(nil),
+	}
+}
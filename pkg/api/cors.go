@@ -0,0 +1,184 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// CORSOptions configures which browser origins may call the API
+type CORSOptions struct {
+	// AllowedOrigins are exact origins such as https://ui.example.com,
+	// wildcard subdomains such as https://*.example.com, or * for any origin
+	AllowedOrigins []string
+	AllowedMethods []string
+	// AllowedHeaders are request headers clients may send, or * for any
+	AllowedHeaders []string
+	// ExposedHeaders are response headers scripts may read
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// Validate rejects origins that can't match and credentials for any origin
+func (o CORSOptions) Validate() error {
+	for _, origin := range o.AllowedOrigins {
+		if origin == "*" {
+			if o.AllowCredentials {
+				return fmt.Errorf("CORS credentials can't be allowed for any origin, list the origins instead")
+			}
+			continue
+		}
+		scheme, host, ok := strings.Cut(origin, "://")
+		if !ok || scheme == "" || host == "" || strings.Contains(host, "/") {
+			return fmt.Errorf("CORS origin %q must look like https://host[:port]", origin)
+		}
+		if strings.Contains(host, "*") && (!strings.HasPrefix(host, "*.") || strings.Count(host, "*") > 1) {
+			return fmt.Errorf("CORS origin %q may only use * for a whole subdomain, as in https://*.example.com", origin)
+		}
+	}
+	return nil
+}
+
+// CORS negotiates cross-origin requests and answers preflight requests for
+// every route it wraps
+type CORS struct {
+	options        CORSOptions
+	allowedMethods string
+	exposedHeaders string
+	maxAge         string
+}
+
+// NewCORS validates options and defaults methods to the ones the API serves
+func NewCORS(options CORSOptions) (*CORS, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	if len(options.AllowedMethods) == 0 {
+		options.AllowedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	}
+	for i, method := range options.AllowedMethods {
+		options.AllowedMethods[i] = strings.ToUpper(method)
+	}
+	c := &CORS{
+		options:        options,
+		allowedMethods: strings.Join(options.AllowedMethods, ", "),
+		exposedHeaders: strings.Join(options.ExposedHeaders, ", "),
+	}
+	if options.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(options.MaxAge.Seconds()))
+	}
+	return c, nil
+}
+
+// AllowOrigin reports whether origin matches one of the allowed origins
+func (c *CORS) AllowOrigin(origin string) bool {
+	for _, allowed := range c.options.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		// https://*.example.com matches https://ui.example.com and
+		// https://a.b.example.com, but not https://example.com
+		prefix, suffix, ok := strings.Cut(allowed, "*")
+		if ok && len(origin) > len(prefix)+len(suffix) &&
+			strings.EqualFold(origin[:len(prefix)], prefix) &&
+			strings.EqualFold(origin[len(origin)-len(suffix):], suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) allowMethod(method string) bool {
+	return slices.Contains(c.options.AllowedMethods, strings.ToUpper(method))
+}
+
+// allowHeaders checks a comma separated Access-Control-Request-Headers value
+func (c *CORS) allowHeaders(requested string) bool {
+	if slices.Contains(c.options.AllowedHeaders, "*") {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if !slices.ContainsFunc(c.options.AllowedHeaders, func(allowed string) bool {
+			return strings.EqualFold(allowed, header)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// setOrigin echoes the origin, unless any origin is allowed without
+// credentials, in which case responses can be cached for everyone
+func (c *CORS) setOrigin(ctx *fasthttp.RequestCtx, origin string) {
+	if slices.Contains(c.options.AllowedOrigins, "*") && !c.options.AllowCredentials {
+		ctx.Response.Header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		ctx.Response.Header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.options.AllowCredentials {
+		ctx.Response.Header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Handler wraps next with the CORS policy. Preflight requests are answered
+// without calling next, requests from other origins are served as usual but
+// without CORS headers, so browsers don't expose the response.
+func (c *CORS) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		origin := string(ctx.Request.Header.Peek("Origin"))
+		if origin == "" {
+			next(ctx)
+			return
+		}
+
+		requestMethod := string(ctx.Request.Header.Peek("Access-Control-Request-Method"))
+		if ctx.IsOptions() && requestMethod != "" {
+			ctx.Response.Header.Add("Vary", "Origin")
+			ctx.Response.Header.Add("Vary", "Access-Control-Request-Method")
+			ctx.Response.Header.Add("Vary", "Access-Control-Request-Headers")
+			requestHeaders := string(ctx.Request.Header.Peek("Access-Control-Request-Headers"))
+			switch {
+			case !c.AllowOrigin(origin):
+				WriteProblem(ctx, NewProblem(fasthttp.StatusForbidden, fmt.Sprintf("origin %s is not allowed", origin)))
+				return
+			case !c.allowMethod(requestMethod):
+				WriteProblem(ctx, NewProblem(fasthttp.StatusForbidden, fmt.Sprintf("method %s is not allowed for cross-origin requests", requestMethod)))
+				return
+			case !c.allowHeaders(requestHeaders):
+				WriteProblem(ctx, NewProblem(fasthttp.StatusForbidden, fmt.Sprintf("headers %s are not allowed for cross-origin requests", requestHeaders)))
+				return
+			}
+			c.setOrigin(ctx, origin)
+			ctx.Response.Header.Set("Access-Control-Allow-Methods", c.allowedMethods)
+			if requestHeaders != "" {
+				// The requested headers passed the check, echoing them also
+				// covers a * configuration
+				ctx.Response.Header.Set("Access-Control-Allow-Headers", requestHeaders)
+			}
+			if c.maxAge != "" {
+				ctx.Response.Header.Set("Access-Control-Max-Age", c.maxAge)
+			}
+			ctx.SetStatusCode(fasthttp.StatusNoContent)
+			return
+		}
+
+		ctx.Response.Header.Add("Vary", "Origin")
+		if c.AllowOrigin(origin) {
+			c.setOrigin(ctx, origin)
+			if c.exposedHeaders != "" {
+				ctx.Response.Header.Set("Access-Control-Expose-Headers", c.exposedHeaders)
+			}
+		}
+		next(ctx)
+	}
+}
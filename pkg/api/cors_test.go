@@ -0,0 +1,127 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestCORSOptions_Validate(t *testing.T) {
+	for _, options := range []CORSOptions{
+		{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"ui.example.com"}},
+		{AllowedOrigins: []string{"https://ui.example.com/app"}},
+		{AllowedOrigins: []string{"https://ui*.example.com"}},
+		{AllowedOrigins: []string{"https://*.*.example.com"}},
+	} {
+		require.Error(t, options.Validate(), "%+v", options)
+	}
+	require.NoError(t, CORSOptions{AllowedOrigins: []string{"*", "https://*.example.com", "http://localhost:3000"}}.Validate())
+}
+
+func TestCORS_AllowOrigin(t *testing.T) {
+	cors, err := NewCORS(CORSOptions{AllowedOrigins: []string{"https://ui.example.com", "https://*.preview.example.com"}})
+	require.NoError(t, err)
+
+	for origin, want := range map[string]bool{
+		"https://ui.example.com":              true,
+		"HTTPS://UI.EXAMPLE.COM":              true,
+		"http://ui.example.com":               false,
+		"https://ui.example.com:8443":         false,
+		"https://pr-1.preview.example.com":    true,
+		"https://a.pr-1.preview.example.com":  true,
+		"https://preview.example.com":         false,
+		"https://.preview.example.com":        false,
+		"https://evil.com?.preview.example.c": false,
+		"http://pr-1.preview.example.com":     false,
+	} {
+		require.Equal(t, want, cors.AllowOrigin(origin), origin)
+	}
+}
+
+func TestCORS_Handler(t *testing.T) {
+	cors, err := NewCORS(CORSOptions{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedMethods:   []string{"get", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"Retry-After"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	require.NoError(t, err)
+	called := false
+	handler := cors.Handler(func(ctx *fasthttp.RequestCtx) {
+		called = true
+		ctx.SetStatusCode(fasthttp.StatusOK)
+	})
+	request := func(method, origin string, headers map[string]string) *fasthttp.RequestCtx {
+		called = false
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		ctx.Request.SetRequestURI("/api/frontendpages")
+		if origin != "" {
+			ctx.Request.Header.Set("Origin", origin)
+		}
+		for k, v := range headers {
+			ctx.Request.Header.Set(k, v)
+		}
+		handler(ctx)
+		return ctx
+	}
+
+	// Preflight
+	ctx := request("OPTIONS", "https://ui.example.com", map[string]string{
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type, authorization",
+	})
+	require.False(t, called, "preflight requests don't reach the route")
+	require.Equal(t, fasthttp.StatusNoContent, ctx.Response.StatusCode())
+	require.Equal(t, "https://ui.example.com", string(ctx.Response.Header.Peek("Access-Control-Allow-Origin")))
+	require.Equal(t, "true", string(ctx.Response.Header.Peek("Access-Control-Allow-Credentials")))
+	require.Equal(t, "GET, POST", string(ctx.Response.Header.Peek("Access-Control-Allow-Methods")))
+	require.Equal(t, "content-type, authorization", string(ctx.Response.Header.Peek("Access-Control-Allow-Headers")))
+	require.Equal(t, "600", string(ctx.Response.Header.Peek("Access-Control-Max-Age")))
+
+	for _, headers := range []map[string]string{
+		{"Access-Control-Request-Method": "DELETE"},
+		{"Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "X-Custom"},
+	} {
+		ctx = request("OPTIONS", "https://ui.example.com", headers)
+		require.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode(), headers)
+		require.Empty(t, ctx.Response.Header.Peek("Access-Control-Allow-Origin"))
+	}
+	ctx = request("OPTIONS", "https://example.org", map[string]string{"Access-Control-Request-Method": "GET"})
+	require.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode())
+
+	// Actual requests
+	ctx = request("GET", "https://ui.example.com", nil)
+	require.True(t, called)
+	require.Equal(t, "https://ui.example.com", string(ctx.Response.Header.Peek("Access-Control-Allow-Origin")))
+	require.Equal(t, "Retry-After", string(ctx.Response.Header.Peek("Access-Control-Expose-Headers")))
+	require.Equal(t, "Origin", string(ctx.Response.Header.Peek("Vary")))
+
+	ctx = request("GET", "https://example.org", nil)
+	require.True(t, called, "other origins are served, the browser withholds the response")
+	require.Empty(t, ctx.Response.Header.Peek("Access-Control-Allow-Origin"))
+
+	ctx = request("OPTIONS", "", nil)
+	require.True(t, called, "requests without an origin pass through")
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	cors, err := NewCORS(CORSOptions{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}})
+	require.NoError(t, err)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("OPTIONS")
+	ctx.Request.Header.Set("Origin", "https://anywhere.test")
+	ctx.Request.Header.Set("Access-Control-Request-Method", "PATCH")
+	ctx.Request.Header.Set("Access-Control-Request-Headers", "X-Anything")
+	cors.Handler(NotFound)(ctx)
+
+	require.Equal(t, fasthttp.StatusNoContent, ctx.Response.StatusCode())
+	require.Equal(t, "*", string(ctx.Response.Header.Peek("Access-Control-Allow-Origin")))
+	require.Equal(t, "X-Anything", string(ctx.Response.Header.Peek("Access-Control-Allow-Headers")))
+	require.Empty(t, ctx.Response.Header.Peek("Access-Control-Allow-Credentials"))
+}
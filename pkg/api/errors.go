@@ -0,0 +1,181 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProblemContentType is the media type of RFC 7807 error responses
+const ProblemContentType = "application/problem+json"
+
+// ProblemCause describes a single field that caused a request to fail
+type ProblemCause struct {
+	Field   string `json:"field,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message"`
+	// SchemaPath points to the keyword of the OpenAPI document the field
+	// failed, such as #/components/schemas/api.FrontendPageDoc/properties/port/maximum
+	SchemaPath string `json:"schemaPath,omitempty"`
+}
+
+// Problem is the error returned by every API handler and MCP tool, rendered as
+// RFC 7807 problem details. Reason carries the Kubernetes status reason when
+// the error came from the apiserver.
+type Problem struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail,omitempty"`
+	Instance string         `json:"instance,omitempty"`
+	Reason   string         `json:"reason,omitempty"`
+	Causes   []ProblemCause `json:"causes,omitempty"`
+}
+
+// Error implements error
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Detail
+}
+
+// NewProblem creates a problem with the given HTTP status
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// BadRequest is returned for requests that can't be parsed
+func BadRequest(format string, args ...any) *Problem {
+	p := NewProblem(fasthttp.StatusBadRequest, fmt.Sprintf(format, args...))
+	p.Reason = string(metav1.StatusReasonBadRequest)
+	return p
+}
+
+// Invalid is returned when request fields fail validation
+func Invalid(causes ...ProblemCause) *Problem {
+	p := NewProblem(fasthttp.StatusUnprocessableEntity, "request is invalid")
+	if len(causes) == 1 {
+		p.Detail = causes[0].Message
+	}
+	p.Reason = string(metav1.StatusReasonInvalid)
+	p.Causes = causes
+	return p
+}
+
+// Required is a shortcut for Invalid with a single missing field
+func Required(field string) *Problem {
+	return Invalid(ProblemCause{
+		Field:   field,
+		Reason:  string(metav1.CauseTypeFieldValueRequired),
+		Message: field + " is required",
+	})
+}
+
+// InternalErrorDetail is the detail of every 500. The raw error can carry
+// client, network or apiserver messages, so it is only logged.
+const InternalErrorDetail = "internal error, see the server logs"
+
+// ProblemFromError maps an error to a problem. Kubernetes API errors keep their
+// reason and field causes, anything unknown becomes a 500 with a generic detail.
+func ProblemFromError(err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return NewProblem(fasthttp.StatusInternalServerError, InternalErrorDetail)
+	}
+
+	reason := apierrors.ReasonForError(err)
+	code := fasthttp.StatusInternalServerError
+	switch reason {
+	case metav1.StatusReasonBadRequest:
+		code = fasthttp.StatusBadRequest
+	case metav1.StatusReasonUnauthorized:
+		code = fasthttp.StatusUnauthorized
+	case metav1.StatusReasonForbidden:
+		code = fasthttp.StatusForbidden
+	case metav1.StatusReasonNotFound:
+		code = fasthttp.StatusNotFound
+	case metav1.StatusReasonAlreadyExists, metav1.StatusReasonConflict:
+		code = fasthttp.StatusConflict
+	case metav1.StatusReasonGone, metav1.StatusReasonExpired:
+		code = fasthttp.StatusGone
+	case metav1.StatusReasonInvalid:
+		code = fasthttp.StatusUnprocessableEntity
+	case metav1.StatusReasonTooManyRequests:
+		code = fasthttp.StatusTooManyRequests
+	case metav1.StatusReasonServiceUnavailable:
+		code = fasthttp.StatusServiceUnavailable
+	case metav1.StatusReasonTimeout, metav1.StatusReasonServerTimeout:
+		code = fasthttp.StatusGatewayTimeout
+	}
+
+	if code == fasthttp.StatusInternalServerError {
+		problem = NewProblem(code, InternalErrorDetail)
+		problem.Reason = string(reason)
+		return problem
+	}
+	problem = NewProblem(code, status.Status().Message)
+	problem.Reason = string(reason)
+	if details := status.Status().Details; details != nil {
+		for _, cause := range details.Causes {
+			problem.Causes = append(problem.Causes, ProblemCause{
+				Field:   cause.Field,
+				Reason:  string(cause.Type),
+				Message: cause.Message,
+			})
+		}
+	}
+	return problem
+}
+
+// JSON renders the problem, used where there is no HTTP response (MCP tools)
+func (p *Problem) JSON() string {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Sprintf(`{"title": %q, "status": %d}`, p.Title, p.Status)
+	}
+	return string(data)
+}
+
+// WriteProblem writes the problem as the response and records it on the span
+func WriteProblem(ctx *fasthttp.RequestCtx, problem *Problem) {
+	// Copy so that shared problems like ErrWatchExpired are never modified
+	p := *problem
+	if p.Instance == "" {
+		p.Instance = string(ctx.Path())
+	}
+	RecordSpanError(ctx, &p)
+	ctx.Response.Header.Set("Content-Type", ProblemContentType)
+	ctx.SetStatusCode(p.Status)
+	ctx.SetBodyString(p.JSON())
+}
+
+// WriteError maps err with ProblemFromError and writes it as the response. The
+// raw error of a 500 is logged and recorded on the span instead of being sent.
+func WriteError(ctx *fasthttp.RequestCtx, err error) {
+	problem := ProblemFromError(err)
+	WriteProblem(ctx, problem)
+	if problem.Detail != InternalErrorDetail {
+		return
+	}
+	log.Error().Err(err).Str("path", string(ctx.Path())).Msg("Request failed")
+	if GetSpanFromContext(ctx).IsRecording() {
+		RecordSpanError(ctx, err)
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestProblemFromError_KubernetesReasons(t *testing.T) {
+	gr := schema.GroupResource{Group: "frontend.jraver.io", Resource: "frontendpages"}
+	gk := schema.GroupKind{Group: "frontend.jraver.io", Kind: "FrontendPage"}
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		reason string
+	}{
+		{"not found", apierrors.NewNotFound(gr, "page"), fasthttp.StatusNotFound, "NotFound"},
+		{"already exists", apierrors.NewAlreadyExists(gr, "page"), fasthttp.StatusConflict, "AlreadyExists"},
+		{"conflict", apierrors.NewConflict(gr, "page", errors.New("modified")), fasthttp.StatusConflict, "Conflict"},
+		{"forbidden", apierrors.NewForbidden(gr, "page", errors.New("rbac")), fasthttp.StatusForbidden, "Forbidden"},
+		{"invalid", apierrors.NewInvalid(gk, "page", nil), fasthttp.StatusUnprocessableEntity, "Invalid"},
+		{"wrapped", fmt.Errorf("get page: %w", apierrors.NewNotFound(gr, "page")), fasthttp.StatusNotFound, "NotFound"},
+		{"unknown", errors.New("boom"), fasthttp.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := ProblemFromError(tt.err)
+			require.Equal(t, tt.status, problem.Status)
+			require.Equal(t, tt.reason, problem.Reason)
+		})
+	}
+}
+
+func TestProblemFromError_InternalErrorsAreNotSent(t *testing.T) {
+	for _, err := range []error{
+		errors.New("dial tcp 10.0.0.1:6443: connect: connection refused"),
+		apierrors.NewInternalError(errors.New("etcdserver: request timed out")),
+	} {
+		problem := ProblemFromError(err)
+		require.Equal(t, fasthttp.StatusInternalServerError, problem.Status)
+		require.Equal(t, InternalErrorDetail, problem.Detail)
+	}
+}
+
+func TestProblemFromError_InvalidCauses(t *testing.T) {
+	gk := schema.GroupKind{Group: "frontend.jraver.io", Kind: "FrontendPage"}
+	err := apierrors.NewInvalid(gk, "page", field.ErrorList{
+		field.Invalid(field.NewPath("spec", "replicas"), -1, "must be positive"),
+	})
+
+	problem := ProblemFromError(err)
+	require.Len(t, problem.Causes, 1)
+	require.Equal(t, "spec.replicas", problem.Causes[0].Field)
+	require.Equal(t, "FieldValueInvalid", problem.Causes[0].Reason)
+}
+
+func TestWriteProblem(t *testing.T) {
+	var logs bytes.Buffer
+	previous := log.Logger
+	log.Logger = zerolog.New(&logs)
+	t.Cleanup(func() { log.Logger = previous })
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/frontendpages/page")
+	WriteError(ctx, errors.New(`unexpected "quoted" value`))
+
+	require.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
+	require.Equal(t, ProblemContentType, string(ctx.Response.Header.ContentType()))
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &problem))
+	require.Equal(t, InternalErrorDetail, problem.Detail, "the raw error is not sent")
+	require.Equal(t, "/api/frontendpages/page", problem.Instance)
+	require.Equal(t, "Internal Server Error", problem.Title)
+	require.NotContains(t, string(ctx.Response.Body()), "quoted")
+
+	// but it is logged, also without tracing
+	var logged []map[string]any
+	for decoder := json.NewDecoder(&logs); decoder.More(); {
+		var entry map[string]any
+		require.NoError(t, decoder.Decode(&entry))
+		if entry["message"] == "Request failed" {
+			logged = append(logged, entry)
+		}
+	}
+	require.Len(t, logged, 1)
+	require.Equal(t, `unexpected "quoted" value`, logged[0]["error"])
+	require.Equal(t, "/api/frontendpages/page", logged[0]["path"])
+
+	// Shared problems are not modified by writing them
+	WriteProblem(ctx, ErrWatchExpired)
+	require.Empty(t, ErrWatchExpired.Instance)
+}
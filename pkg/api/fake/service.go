@@ -0,0 +1,479 @@
+// Package fake has an in-memory api.FrontendPageService, for unit tests of
+// the REST Router, the MCP server and other callers that don't need a cluster
+package fake
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/bundle"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var frontendPages = schema.GroupResource{Group: frontendv1alpha1.SchemeGroupVersion.Group, Resource: "frontendpages"}
+
+type page struct {
+	doc    api.FrontendPageDoc
+	uid    types.UID
+	labels map[string]string
+}
+
+// Service keeps FrontendPages in memory. Writes are recorded as changes, and
+// a deleted page comes back with a new UID, like in a cluster. The exported
+// fields are the read-only views of the workload of each page, keyed by page
+// name; set them before the service is shared.
+type Service struct {
+	// Namespace is reported as the namespace of the pages
+	Namespace string
+	// Authorizer is consulted by AuthorizeIdentity; nil allows every caller
+	Authorizer api.Authorizer
+
+	Contents  map[string]string
+	Statuses  map[string]api.FrontendPageStatusDoc
+	Events    map[string][]api.EventDoc
+	Pods      map[string][]api.PodStatusDoc
+	Logs      map[string][]api.PodLogsDoc
+	Revisions map[string][]api.RolloutRevisionDoc
+
+	mu      sync.Mutex
+	pages   map[string]*page
+	changes []api.PageChange
+	version int
+}
+
+var _ api.FrontendPageService = &Service{}
+
+// NewService returns a Service for namespace holding pages
+func NewService(namespace string, pages ...api.FrontendPageDoc) *Service {
+	s := &Service{
+		Namespace: namespace,
+		Contents:  map[string]string{},
+		Statuses:  map[string]api.FrontendPageStatusDoc{},
+		Events:    map[string][]api.EventDoc{},
+		Pods:      map[string][]api.PodStatusDoc{},
+		Logs:      map[string][]api.PodLogsDoc{},
+		Revisions: map[string][]api.RolloutRevisionDoc{},
+		pages:     map[string]*page{},
+	}
+	for _, doc := range pages {
+		s.version++
+		s.pages[doc.Name] = &page{doc: doc, uid: s.uid(doc.Name)}
+	}
+	return s
+}
+
+// SetLabels replaces the labels of a page, which FrontendPageDoc doesn't carry
+func (s *Service) SetLabels(name string, labels map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pages[name]
+	if !ok {
+		return apierrors.NewNotFound(frontendPages, name)
+	}
+	p.labels = maps.Clone(labels)
+	return nil
+}
+
+// uid is the UID of the current incarnation of a page, must hold mu
+func (s *Service) uid(name string) types.UID {
+	return types.UID(fmt.Sprintf("%s-%d", name, s.version))
+}
+
+// record appends a change, must hold mu
+func (s *Service) record(eventType string, doc api.FrontendPageDoc) {
+	s.version++
+	s.changes = append(s.changes, api.PageChange{
+		Time:       time.Now(),
+		WatchEvent: api.WatchEvent{Type: eventType, ResourceVersion: strconv.Itoa(s.version), Object: doc},
+	})
+}
+
+// get returns a page or a NotFound error, must hold mu
+func (s *Service) get(name string) (*page, error) {
+	if name == "" {
+		return nil, api.Required("name")
+	}
+	p, ok := s.pages[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(frontendPages, name)
+	}
+	return p, nil
+}
+
+// PageNamespace implements api.FrontendPageService
+func (s *Service) PageNamespace() string {
+	return s.Namespace
+}
+
+// AuthorizeIdentity implements api.FrontendPageService. Like FrontendPageApi
+// it enforces the namespaces and verbs of the credentials before asking the
+// Authorizer.
+func (s *Service) AuthorizeIdentity(ctx context.Context, identity *api.Identity, attrs api.Attributes) (api.Decision, error) {
+	if len(identity.Namespaces) > 0 && !slices.Contains(identity.Namespaces, attrs.Namespace) {
+		return api.Decision{Reason: fmt.Sprintf("token is not valid for namespace %q", attrs.Namespace)}, nil
+	}
+	if len(identity.Verbs) > 0 && !slices.Contains(identity.Verbs, attrs.Verb) {
+		return api.Decision{Reason: fmt.Sprintf("credentials are not valid for %q", attrs.Verb)}, nil
+	}
+	if identity.APIKey != "" || s.Authorizer == nil {
+		return api.Decision{Allowed: true}, nil
+	}
+	return s.Authorizer.Authorize(ctx, identity, attrs)
+}
+
+// ListFrontendPagesRaw implements api.FrontendPageService, sorted by name
+func (s *Service) ListFrontendPagesRaw(ctx context.Context) ([]api.FrontendPageDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	docs := make([]api.FrontendPageDoc, 0, len(s.pages))
+	for _, name := range slices.Sorted(maps.Keys(s.pages)) {
+		docs = append(docs, s.pages[name].doc)
+	}
+	return docs, nil
+}
+
+// GetFrontendPageRaw implements api.FrontendPageService
+func (s *Service) GetFrontendPageRaw(ctx context.Context, name string) (api.FrontendPageDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.get(name)
+	if err != nil {
+		return api.FrontendPageDoc{}, err
+	}
+	return p.doc, nil
+}
+
+// dryRun reports whether the options of a write ask for a dry run
+func dryRun(values []string) bool {
+	return slices.Contains(values, metav1.DryRunAll)
+}
+
+// CreateFrontendPageRaw implements api.FrontendPageService. It honours dry
+// runs.
+func (s *Service) CreateFrontendPageRaw(ctx context.Context, doc api.FrontendPageDoc, opts ...client.CreateOption) (api.FrontendPageDoc, error) {
+	if doc.Name == "" {
+		return api.FrontendPageDoc{}, api.Required("name")
+	}
+	options := &client.CreateOptions{}
+	options.ApplyOptions(opts)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pages[doc.Name]; ok {
+		return api.FrontendPageDoc{}, apierrors.NewAlreadyExists(frontendPages, doc.Name)
+	}
+	if dryRun(options.DryRun) {
+		return doc, nil
+	}
+	s.record(api.WatchEventAdded, doc)
+	s.pages[doc.Name] = &page{doc: doc, uid: s.uid(doc.Name)}
+	return doc, nil
+}
+
+// UpdateFrontendPageRaw implements api.FrontendPageService. It honours dry
+// runs.
+func (s *Service) UpdateFrontendPageRaw(ctx context.Context, name string, doc api.FrontendPageDoc, opts ...client.UpdateOption) (api.FrontendPageDoc, error) {
+	options := &client.UpdateOptions{}
+	options.ApplyOptions(opts)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.get(name)
+	if err != nil {
+		return api.FrontendPageDoc{}, err
+	}
+	doc.Name = name
+	if dryRun(options.DryRun) {
+		return doc, nil
+	}
+	p.doc = doc
+	s.record(api.WatchEventModified, p.doc)
+	return p.doc, nil
+}
+
+// PatchFrontendPageRaw implements api.FrontendPageService. It honours dry
+// runs.
+func (s *Service) PatchFrontendPageRaw(ctx context.Context, name string, patchDoc api.FrontendPagePatchDoc, opts ...client.PatchOption) (api.FrontendPageDoc, error) {
+	options := &client.PatchOptions{}
+	options.ApplyOptions(opts)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.get(name)
+	if err != nil {
+		return api.FrontendPageDoc{}, err
+	}
+	doc := p.doc
+	if patchDoc.Content != nil {
+		doc.Content = *patchDoc.Content
+	}
+	if patchDoc.Image != nil {
+		doc.Image = *patchDoc.Image
+	}
+	if patchDoc.Replicas != nil {
+		doc.Replicas = *patchDoc.Replicas
+	}
+	if patchDoc.Port != nil {
+		doc.Port = *patchDoc.Port
+	}
+	if dryRun(options.DryRun) {
+		return doc, nil
+	}
+	p.doc = doc
+	s.record(api.WatchEventModified, p.doc)
+	return p.doc, nil
+}
+
+// DeleteFrontendPageRaw implements api.FrontendPageService. It honours
+// dry runs and UID preconditions.
+func (s *Service) DeleteFrontendPageRaw(ctx context.Context, name string, opts ...client.DeleteOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.get(name)
+	if err != nil {
+		return err
+	}
+	options := &client.DeleteOptions{}
+	options.ApplyOptions(opts)
+	if options.Preconditions != nil && options.Preconditions.UID != nil && *options.Preconditions.UID != p.uid {
+		return apierrors.NewConflict(frontendPages, name, fmt.Errorf("the UID in the precondition (%s) does not match the UID in record (%s)", *options.Preconditions.UID, p.uid))
+	}
+	if dryRun(options.DryRun) {
+		return nil
+	}
+	delete(s.pages, name)
+	s.record(api.WatchEventDeleted, p.doc)
+	return nil
+}
+
+// FrontendPageDeletePlanRaw implements api.FrontendPageService. The fake
+// keeps no workload objects, so only the page itself is deleted.
+func (s *Service) FrontendPageDeletePlanRaw(ctx context.Context, name string) (*api.FrontendPageDeletePlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+	return &api.FrontendPageDeletePlan{
+		Name:    name,
+		UID:     p.uid,
+		Labels:  maps.Clone(p.labels),
+		Deletes: []api.ObjectRef{{Kind: "FrontendPage", Name: name}},
+	}, nil
+}
+
+// object returns a page as a FrontendPage, must hold mu
+func (s *Service) object(p *page) *frontendv1alpha1.FrontendPage {
+	return &frontendv1alpha1.FrontendPage{
+		ObjectMeta: metav1.ObjectMeta{Name: p.doc.Name, Namespace: s.Namespace, UID: p.uid, Labels: maps.Clone(p.labels)},
+		Spec: frontendv1alpha1.FrontendPageSpec{
+			Content:  p.doc.Content,
+			Image:    p.doc.Image,
+			Replicas: p.doc.Replicas,
+			Port:     p.doc.Port,
+		},
+	}
+}
+
+// cluster returns an api.FrontendPageApi over a fake client holding the
+// pages, for the features computed from Kubernetes objects; must hold mu
+func (s *Service) cluster() (*api.FrontendPageApi, error) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := frontendv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	builder := crfake.NewClientBuilder().WithScheme(scheme)
+	for _, p := range s.pages {
+		builder = builder.WithObjects(s.object(p))
+	}
+	return &api.FrontendPageApi{K8SClient: builder.Build(), Namespace: s.Namespace}, nil
+}
+
+// ExportFrontendPagesRaw implements api.FrontendPageService
+func (s *Service) ExportFrontendPagesRaw(ctx context.Context) ([]frontendv1alpha1.FrontendPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pages := make([]frontendv1alpha1.FrontendPage, 0, len(s.pages))
+	for _, name := range slices.Sorted(maps.Keys(s.pages)) {
+		pages = append(pages, bundle.Clean(s.object(s.pages[name])))
+	}
+	return pages, nil
+}
+
+// ImportFrontendPagesRaw implements api.FrontendPageService with
+// bundle.Import, then keeps the pages it created or updated. Annotations are
+// dropped, like on the other writes of the fake.
+func (s *Service) ImportFrontendPagesRaw(ctx context.Context, pages []frontendv1alpha1.FrontendPage, opts bundle.ImportOptions) (*bundle.ImportReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cluster, err := s.cluster()
+	if err != nil {
+		return nil, err
+	}
+	report, err := cluster.ImportFrontendPagesRaw(ctx, pages, opts)
+	if err != nil || report.DryRun {
+		return report, err
+	}
+	for _, item := range report.Items {
+		if item.Action != bundle.ActionCreated && item.Action != bundle.ActionUpdated {
+			continue
+		}
+		i := slices.IndexFunc(pages, func(page frontendv1alpha1.FrontendPage) bool { return page.Name == item.Name })
+		doc := api.FrontendPageDoc{
+			Name:     item.Name,
+			Content:  pages[i].Spec.Content,
+			Image:    pages[i].Spec.Image,
+			Replicas: pages[i].Spec.Replicas,
+			Port:     pages[i].Spec.Port,
+		}
+		if p, ok := s.pages[item.Name]; ok {
+			p.doc, p.labels = doc, maps.Clone(pages[i].Labels)
+			s.record(api.WatchEventModified, doc)
+			continue
+		}
+		s.record(api.WatchEventAdded, doc)
+		s.pages[item.Name] = &page{doc: doc, uid: s.uid(item.Name), labels: maps.Clone(pages[i].Labels)}
+	}
+	return report, nil
+}
+
+// PreviewFrontendPageRaw implements api.FrontendPageService. The fake keeps
+// no workload objects, so every resource is to be created.
+func (s *Service) PreviewFrontendPageRaw(ctx context.Context, name string, doc *api.FrontendPageDoc) (*api.FrontendPagePreview, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cluster, err := s.cluster()
+	if err != nil {
+		return nil, err
+	}
+	return cluster.PreviewFrontendPageRaw(ctx, name, doc)
+}
+
+// GetFrontendPageContentRaw implements api.FrontendPageService with Contents
+func (s *Service) GetFrontendPageContentRaw(ctx context.Context, name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.Contents[name]
+	if !ok {
+		return "", api.NewProblem(http.StatusNotFound, "content for frontend page "+name+" has not been generated")
+	}
+	return content, nil
+}
+
+// FrontendPageStatusRaw implements api.FrontendPageService with Statuses,
+// which default to the name and replicas of the page
+func (s *Service) FrontendPageStatusRaw(ctx context.Context, name string) (api.FrontendPageStatusDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.get(name)
+	if err != nil {
+		return api.FrontendPageStatusDoc{}, err
+	}
+	if status, ok := s.Statuses[name]; ok {
+		return status, nil
+	}
+	return api.FrontendPageStatusDoc{Name: name, Replicas: p.doc.Replicas}, nil
+}
+
+// FrontendPageEventsRaw implements api.FrontendPageService with Events,
+// which are kept after a page is deleted
+func (s *Service) FrontendPageEventsRaw(ctx context.Context, name string) ([]api.EventDoc, error) {
+	if name == "" {
+		return nil, api.Required("name")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.Events[name]), nil
+}
+
+// FrontendPagePodsRaw implements api.FrontendPageService with Pods
+func (s *Service) FrontendPagePodsRaw(ctx context.Context, name string) ([]api.PodStatusDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.get(name); err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.Pods[name]), nil
+}
+
+// FrontendPageLogsRaw implements api.FrontendPageService with Logs, filtered
+// by the pod and container of opts and cut to its limits like the kubelet
+// does: the first LimitBytes of the last TailLines
+func (s *Service) FrontendPageLogsRaw(ctx context.Context, name string, opts api.PodLogsOptions) ([]api.PodLogsDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.get(name); err != nil {
+		return nil, err
+	}
+	var logs []api.PodLogsDoc
+	for _, doc := range s.Logs[name] {
+		if (opts.Pod == "" || doc.Pod == opts.Pod) && (opts.Container == "" || doc.Container == opts.Container) {
+			if opts.TailLines > 0 {
+				doc.Logs = tailLines(doc.Logs, opts.TailLines)
+			}
+			if opts.LimitBytes > 0 && int64(len(doc.Logs)) > opts.LimitBytes {
+				doc.Logs, doc.Truncated = doc.Logs[:opts.LimitBytes], true
+			}
+			logs = append(logs, doc)
+		}
+	}
+	if opts.Pod != "" && len(logs) == 0 {
+		return nil, api.NewProblem(http.StatusNotFound, fmt.Sprintf("pod %s does not serve frontend page %s", opts.Pod, name))
+	}
+	return logs, nil
+}
+
+// tailLines returns the last n lines of logs
+func tailLines(logs string, n int64) string {
+	lines := strings.SplitAfter(logs, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if int64(len(lines)) <= n {
+		return logs
+	}
+	return strings.Join(lines[int64(len(lines))-n:], "")
+}
+
+// FrontendPageRolloutHistoryRaw implements api.FrontendPageService with
+// Revisions
+func (s *Service) FrontendPageRolloutHistoryRaw(ctx context.Context, name string) ([]api.RolloutRevisionDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.get(name); err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.Revisions[name]), nil
+}
+
+// FrontendPageChangesRaw implements api.FrontendPageService with the writes
+// made through the service
+func (s *Service) FrontendPageChangesRaw(name string, since time.Time) ([]api.PageChange, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var changes []api.PageChange
+	for _, change := range s.changes {
+		if change.Time.Before(since) || (name != "" && change.Object.Name != name) {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes, true
+}
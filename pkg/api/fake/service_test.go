@@ -0,0 +1,162 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/bundle"
+	"github.com/buaazp/fasthttprouter"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	s := NewService("default", api.FrontendPageDoc{Name: "home", Replicas: 1})
+
+	_, err := s.CreateFrontendPageRaw(ctx, api.FrontendPageDoc{Name: "home"})
+	require.True(t, apierrors.IsAlreadyExists(err))
+	_, err = s.GetFrontendPageRaw(ctx, "missing")
+	require.True(t, apierrors.IsNotFound(err))
+	_, err = s.CreateFrontendPageRaw(ctx, api.FrontendPageDoc{})
+	require.Equal(t, "Invalid", api.ProblemFromError(err).Reason)
+
+	replicas := 3
+	doc, err := s.PatchFrontendPageRaw(ctx, "home", api.FrontendPagePatchDoc{Replicas: &replicas})
+	require.NoError(t, err)
+	require.Equal(t, 3, doc.Replicas)
+	status, err := s.FrontendPageStatusRaw(ctx, "home")
+	require.NoError(t, err)
+	require.Equal(t, 3, status.Replicas)
+
+	require.NoError(t, s.SetLabels("home", map[string]string{"team": "web"}))
+	plan, err := s.FrontendPageDeletePlanRaw(ctx, "home")
+	require.NoError(t, err)
+	require.Equal(t, "web", plan.Labels["team"])
+	require.Equal(t, []api.ObjectRef{{Kind: "FrontendPage", Name: "home"}}, plan.Deletes)
+
+	require.NoError(t, s.DeleteFrontendPageRaw(ctx, "home", client.DryRunAll))
+	_, err = s.GetFrontendPageRaw(ctx, "home")
+	require.NoError(t, err, "dry runs keep the page")
+	require.True(t, apierrors.IsConflict(s.DeleteFrontendPageRaw(ctx, "home", client.Preconditions{UID: ptr(types.UID("other"))})))
+	require.NoError(t, s.DeleteFrontendPageRaw(ctx, "home", client.Preconditions{UID: &plan.UID}))
+
+	_, err = s.CreateFrontendPageRaw(ctx, api.FrontendPageDoc{Name: "home"})
+	require.NoError(t, err)
+	recreated, err := s.FrontendPageDeletePlanRaw(ctx, "home")
+	require.NoError(t, err)
+	require.NotEqual(t, plan.UID, recreated.UID, "a recreated page is a new object")
+	require.Empty(t, recreated.Labels)
+
+	changes, ok := s.FrontendPageChangesRaw("home", time.Time{})
+	require.True(t, ok)
+	var events []string
+	for _, change := range changes {
+		events = append(events, change.Type)
+	}
+	require.Equal(t, []string{api.WatchEventModified, api.WatchEventDeleted, api.WatchEventAdded}, events)
+}
+
+func TestService_AuthorizeIdentity(t *testing.T) {
+	s := NewService("default")
+	s.Authorizer = subjectAuthorizer("alice")
+	attrs := api.Attributes{Verb: api.VerbGet, Namespace: "default", Name: "home"}
+	for identity, allowed := range map[*api.Identity]bool{
+		{Subject: "alice"}: true,
+		{Subject: "bob"}:   false,
+		{Subject: "alice", Namespaces: []string{"x"}}: false,
+		{Subject: "alice", Verbs: []string{"list"}}:   false,
+		{Subject: "bob", APIKey: "key"}:               true,
+	} {
+		decision, err := s.AuthorizeIdentity(context.Background(), identity, attrs)
+		require.NoError(t, err)
+		require.Equal(t, allowed, decision.Allowed, identity)
+	}
+}
+
+// TestRouter serves the REST routes of two services in one process, each
+// with its own Config and signing secret
+func TestRouter(t *testing.T) {
+	t.Parallel()
+	staging := NewService("staging", api.FrontendPageDoc{Name: "home", Image: "nginx:1.27", Replicas: 1, Port: 80})
+	production := NewService("production")
+	serve := func(s *Service) func(method, uri, body string) *fasthttp.Response {
+		config := api.NewConfig()
+		config.JWTSecret = s.Namespace + "-secret"
+		tokens, err := config.IssueToken(&api.Identity{Subject: "alice"})
+		require.NoError(t, err)
+		router := fasthttprouter.New()
+		(&api.Router{Service: s, Config: config}).RegisterRoutes(router, nil)
+		return func(method, uri, body string) *fasthttp.Response {
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.Header.SetMethod(method)
+			ctx.Request.SetRequestURI(uri)
+			ctx.Request.Header.Set("Authorization", "Bearer "+tokens.Token)
+			if body != "" {
+				ctx.Request.Header.SetContentType("application/json")
+				ctx.Request.SetBodyString(body)
+			}
+			router.Handler(ctx)
+			resp := &fasthttp.Response{}
+			ctx.Response.CopyTo(resp)
+			return resp
+		}
+	}
+	stagingAPI, productionAPI := serve(staging), serve(production)
+	ctx := context.Background()
+
+	resp := stagingAPI("POST", "/api/frontendpages?dryRun=All", `{"name":"docs","content":"<h1>docs</h1>","image":"nginx:latest","replicas":1,"port":80}`)
+	require.Equal(t, fasthttp.StatusCreated, resp.StatusCode(), string(resp.Body()))
+	_, err := staging.GetFrontendPageRaw(ctx, "docs")
+	require.True(t, apierrors.IsNotFound(err), "dry runs create nothing")
+
+	resp = stagingAPI("PUT", "/api/frontendpages/home", `{"name":"home","content":"<h1>v2</h1>","image":"nginx:1.27","replicas":2,"port":80}`)
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), string(resp.Body()))
+	resp = stagingAPI("PATCH", "/api/frontendpages/home?dryRun=All", `{"replicas":5}`)
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), string(resp.Body()))
+	var doc api.FrontendPageDoc
+	require.NoError(t, json.Unmarshal(resp.Body(), &doc))
+	require.Equal(t, 5, doc.Replicas)
+	doc, err = staging.GetFrontendPageRaw(ctx, "home")
+	require.NoError(t, err)
+	require.Equal(t, 2, doc.Replicas, "dry runs keep the page")
+	require.Equal(t, "<h1>v2</h1>", doc.Content)
+
+	resp = stagingAPI("POST", "/api/frontendpages/home/preview", "")
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), string(resp.Body()))
+	var preview api.FrontendPagePreview
+	require.NoError(t, json.Unmarshal(resp.Body(), &preview))
+	require.True(t, preview.Exists)
+	require.NotEmpty(t, preview.Resources)
+
+	// A bundle exported from staging imports into production
+	resp = stagingAPI("GET", "/api/frontendpages/export?format=json", "")
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), string(resp.Body()))
+	resp = productionAPI("POST", "/api/frontendpages/import", string(resp.Body()))
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), string(resp.Body()))
+	var report bundle.ImportReport
+	require.NoError(t, json.Unmarshal(resp.Body(), &report))
+	require.Equal(t, 1, report.Created)
+	doc, err = production.GetFrontendPageRaw(ctx, "home")
+	require.NoError(t, err)
+	require.Equal(t, "<h1>v2</h1>", doc.Content)
+	changes, _ := production.FrontendPageChangesRaw("home", time.Time{})
+	require.Len(t, changes, 1)
+}
+
+// subjectAuthorizer allows one subject
+type subjectAuthorizer string
+
+func (a subjectAuthorizer) Authorize(_ context.Context, identity *api.Identity, _ api.Attributes) (api.Decision, error) {
+	return api.Decision{Allowed: identity.Subject == string(a)}, nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
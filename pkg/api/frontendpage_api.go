@@ -3,64 +3,111 @@ package api
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 
 	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
 	"github.com/valyala/fasthttp"
 	"go.opentelemetry.io/otel/attribute"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// FrontendPageApi is the API for the frontend page
+// FrontendPageApi is the FrontendPageService of the pages in a cluster
 type FrontendPageApi struct {
 	K8SClient client.Client
 	Namespace string
+	Watcher   *FrontendPageWatcher
+	// Authorizer checks requests wrapped with Authorize. Nil allows everything.
+	Authorizer Authorizer
+	// APIReader reads objects the manager doesn't cache, such as events.
+	// Nil reads them through K8SClient.
+	APIReader client.Reader
+	// Clientset reads pod logs, which the controller-runtime client can't.
+	// Nil leaves logs unavailable.
+	Clientset kubernetes.Interface
 }
 
-// FrontendPageApi is a shared instance for use by HTTP and MCP handlers
-var FrontendApi *FrontendPageApi
+// apiReader returns the reader for uncached objects
+func (api *FrontendPageApi) apiReader() client.Reader {
+	if api.APIReader != nil {
+		return api.APIReader
+	}
+	return api.K8SClient
+}
 
-// --- Swagger-only structs for doc
-// FrontendPageDoc is a simplified version of the FrontendPage type for swagger
+// FrontendPageDoc is a simplified version of the FrontendPage type for the
+// REST API. The openapi tags constrain it in the OpenAPI document.
 type FrontendPageDoc struct {
-	Name     string `json:"name"`
+	Name     string `json:"name" openapi:"maxLength=253"`
 	Content  string `json:"content"`
 	Image    string `json:"image"`
-	Replicas int    `json:"replicas"`
-	Port     int    `json:"port"`
+	Replicas int    `json:"replicas" openapi:"minimum=0"`
+	Port     int    `json:"port" openapi:"minimum=0,maximum=65535"`
 }
 
 // FrontendPageDocList is a list of FrontendPageDoc
 type FrontendPageDocList struct {
 	Items []FrontendPageDoc `json:"items"`
+	// Continue is set when more pages follow, pass it back to get them
+	Continue string `json:"continue,omitempty"`
+}
+
+// FrontendPagePatchDoc is a partial FrontendPageDoc, omitted fields are left unchanged
+type FrontendPagePatchDoc struct {
+	Content  *string `json:"content,omitempty"`
+	Image    *string `json:"image,omitempty"`
+	Replicas *int    `json:"replicas,omitempty" openapi:"minimum=0"`
+	Port     *int    `json:"port,omitempty" openapi:"minimum=0,maximum=65535"`
+}
+
+// StatusMessage is the body of responses that only confirm an action
+type StatusMessage struct {
+	Message string `json:"message"`
+}
+
+// dryRunFromQuery reads the dryRun query parameter. Like the Kubernetes API
+// the only accepted value is All.
+func dryRunFromQuery(ctx *fasthttp.RequestCtx) (bool, *Problem) {
+	value := string(ctx.QueryArgs().Peek("dryRun"))
+	switch value {
+	case "":
+		return false, nil
+	case metav1.DryRunAll:
+		return true, nil
+	default:
+		return false, BadRequest("unsupported dryRun value %q, only All is supported", value)
+	}
 }
 
 // --- API methods
-// ListFrontendPages godoc
-// @Summary List all frontend pages
-// @Description Get a list of all frontend pages
-// @Tags frontendpages
-// @Accept json
-// @Produce json
-// @Success 200 {object} FrontendPageDocList
-// @Router /api/frontendpages [get]
-// @Security ApiKeyAuth
-// @Param namespace query string false "Namespace to filter by"
-
-func (api *FrontendPageApi) ListFrontendPages(ctx *fasthttp.RequestCtx) {
+// ListFrontendPages serves GET /api/frontendpages
+func (r *Router) ListFrontendPages(ctx *fasthttp.RequestCtx) {
+	if ctx.QueryArgs().GetBool("watch") {
+		r.WatchFrontendPages(ctx)
+		return
+	}
+	limit, problem := listLimitFromQuery(ctx)
+	if problem != nil {
+		WriteProblem(ctx, problem)
+		return
+	}
+
 	// Create child span for Kubernetes operation
 	reqCtx, span := CreateChildSpan(ctx, "k8s_list_frontendpages",
-		attribute.String("namespace", api.Namespace),
+		attribute.String("namespace", r.Service.PageNamespace()),
 		attribute.String("operation", "list"),
 	)
 	defer span.End()
 
-	docs, err := api.ListFrontendPagesRaw(reqCtx)
+	docs, err := r.Service.ListFrontendPagesRaw(reqCtx)
 	if err != nil {
-		RecordSpanError(ctx, err)
-		ctx.SetStatusCode(fasthttp.StatusInternalServerError)
-		ctx.WriteString(fmt.Sprintf(`{"error": "%s"}`, err.Error()))
+		WriteError(ctx, err)
+		return
+	}
+	docs, continueToken, problem := paginate(docs, limit, string(ctx.QueryArgs().Peek("continue")))
+	if problem != nil {
+		WriteProblem(ctx, problem)
 		return
 	}
 
@@ -72,7 +119,8 @@ func (api *FrontendPageApi) ListFrontendPages(ctx *fasthttp.RequestCtx) {
 
 	ctx.SetContentType("application/json")
 	json.NewEncoder(ctx).Encode(FrontendPageDocList{
-		Items: docs,
+		Items:    docs,
+		Continue: continueToken,
 	})
 }
 
@@ -96,22 +144,11 @@ func (api *FrontendPageApi) ListFrontendPagesRaw(ctx context.Context) ([]Fronten
 	return docs, nil
 }
 
-// GetFrontendPage godoc
-// @Summary Get a frontend page
-// @Description Get a frontend page by name
-// @Tags frontendpages
-// @Accept json
-// @Produce json
-// @Success 200 {object} FrontendPageDoc
-// @Router /api/frontendpages/{name} [get]
-// @Security ApiKeyAuth
-// @Param name path string true "Name of the frontend page"
-
-func (api *FrontendPageApi) GetFrontendPage(ctx *fasthttp.RequestCtx) {
+// GetFrontendPage serves GET /api/frontendpages/{name}
+func (r *Router) GetFrontendPage(ctx *fasthttp.RequestCtx) {
 	nameValue := ctx.UserValue("name")
 	if nameValue == nil {
-		ctx.SetStatusCode(fasthttp.StatusBadRequest)
-		ctx.WriteString(`{"error": "name is required"}`)
+		WriteProblem(ctx, BadRequest("name is required"))
 		return
 	}
 
@@ -119,30 +156,18 @@ func (api *FrontendPageApi) GetFrontendPage(ctx *fasthttp.RequestCtx) {
 
 	// Create child span for Kubernetes operation
 	reqCtx, span := CreateChildSpan(ctx, "k8s_get_frontendpage",
-		attribute.String("namespace", api.Namespace),
+		attribute.String("namespace", r.Service.PageNamespace()),
 		attribute.String("name", name),
 		attribute.String("operation", "get"),
 	)
 	defer span.End()
 
-	page := &frontendv1alpha1.FrontendPage{}
-	err := api.K8SClient.Get(reqCtx, client.ObjectKey{Namespace: api.Namespace, Name: name}, page)
+	doc, err := r.Service.GetFrontendPageRaw(reqCtx, name)
 	if err != nil {
-		RecordSpanError(ctx, err)
-		ctx.SetStatusCode(fasthttp.StatusInternalServerError)
-		ctx.WriteString(fmt.Sprintf(`{"error": "%s"}`, err.Error()))
+		WriteError(ctx, err)
 		return
 	}
 
-	// Convert to FrontendPageDoc
-	doc := FrontendPageDoc{
-		Name:     page.Name,
-		Content:  page.Spec.Content,
-		Image:    page.Spec.Image,
-		Replicas: page.Spec.Replicas,
-		Port:     page.Spec.Port,
-	}
-
 	// Add result attributes
 	AddSpanAttributes(ctx,
 		attribute.String("result.name", doc.Name),
@@ -155,11 +180,55 @@ func (api *FrontendPageApi) GetFrontendPage(ctx *fasthttp.RequestCtx) {
 	json.NewEncoder(ctx).Encode(doc)
 }
 
-// CreateFrontendPageRaw creates a frontend page directly (for MCP usage)
-func (api *FrontendPageApi) CreateFrontendPageRaw(ctx context.Context, doc FrontendPageDoc) error {
+// frontendPageDoc converts a FrontendPage to its API representation
+func frontendPageDoc(page *frontendv1alpha1.FrontendPage) FrontendPageDoc {
+	return FrontendPageDoc{
+		Name:     page.Name,
+		Content:  page.Spec.Content,
+		Image:    page.Spec.Image,
+		Replicas: page.Spec.Replicas,
+		Port:     page.Spec.Port,
+	}
+}
+
+// GetFrontendPageRaw returns a frontend page directly (for MCP usage)
+func (api *FrontendPageApi) GetFrontendPageRaw(ctx context.Context, name string) (FrontendPageDoc, error) {
+	if name == "" {
+		return FrontendPageDoc{}, Required("name")
+	}
+	page := &frontendv1alpha1.FrontendPage{}
+	if err := api.K8SClient.Get(ctx, client.ObjectKey{Namespace: api.Namespace, Name: name}, page); err != nil {
+		return FrontendPageDoc{}, err
+	}
+	return frontendPageDoc(page), nil
+}
+
+// PatchFrontendPageRaw changes the given fields of a frontend page directly,
+// with options such as client.DryRunAll, and returns the result
+func (api *FrontendPageApi) PatchFrontendPageRaw(ctx context.Context, name string, patchDoc FrontendPagePatchDoc, opts ...client.PatchOption) (FrontendPageDoc, error) {
+	if name == "" {
+		return FrontendPageDoc{}, Required("name")
+	}
+	patch, err := json.Marshal(map[string]FrontendPagePatchDoc{"spec": patchDoc})
+	if err != nil {
+		return FrontendPageDoc{}, err
+	}
+	page := &frontendv1alpha1.FrontendPage{
+		ObjectMeta: metav1.ObjectMeta{Namespace: api.Namespace, Name: name},
+	}
+	if err := api.K8SClient.Patch(ctx, page, client.RawPatch(types.MergePatchType, patch), opts...); err != nil {
+		return FrontendPageDoc{}, err
+	}
+	return frontendPageDoc(page), nil
+}
+
+// CreateFrontendPageRaw creates a frontend page directly, with options such
+// as client.DryRunAll, and returns it as the API server stored it, or would
+// have for a dry run
+func (api *FrontendPageApi) CreateFrontendPageRaw(ctx context.Context, doc FrontendPageDoc, opts ...client.CreateOption) (FrontendPageDoc, error) {
 	// Validate required fields
 	if doc.Name == "" {
-		return fmt.Errorf("name is required")
+		return FrontendPageDoc{}, Required("name")
 	}
 
 	// Create FrontendPage from FrontendPageDoc
@@ -176,174 +245,205 @@ func (api *FrontendPageApi) CreateFrontendPageRaw(ctx context.Context, doc Front
 		},
 	}
 
-	return api.K8SClient.Create(ctx, object)
+	if err := api.K8SClient.Create(ctx, object, opts...); err != nil {
+		return FrontendPageDoc{}, err
+	}
+	return frontendPageDoc(object), nil
 }
 
-// CreateFrontendPage godoc
-// @Summary Create a frontend page
-// @Description Create a new frontend page
-// @Tags frontendpages
-// @Accept json
-// @Produce json
-// @Success 200 {object} FrontendPageDoc
-// @Router /api/frontendpages [post]
-// @Security ApiKeyAuth
-// @Param frontendpage body FrontendPageDoc true "Frontend page to create"
-func (api *FrontendPageApi) CreateFrontendPage(ctx *fasthttp.RequestCtx) {
+// CreateFrontendPage serves POST /api/frontendpages
+func (r *Router) CreateFrontendPage(ctx *fasthttp.RequestCtx) {
+	dryRun, problem := dryRunFromQuery(ctx)
+	if problem != nil {
+		WriteProblem(ctx, problem)
+		return
+	}
+
 	// Parse FrontendPageDoc from request body
 	var doc FrontendPageDoc
 	if err := json.Unmarshal(ctx.PostBody(), &doc); err != nil {
-		RecordSpanError(ctx, err)
-		ctx.SetStatusCode(fasthttp.StatusBadRequest)
-		ctx.WriteString(fmt.Sprintf(`{"error": "%s"}`, err.Error()))
+		WriteProblem(ctx, BadRequest("invalid request body: %v", err))
 		return
 	}
 
 	// Validate required fields
 	if doc.Name == "" {
-		ctx.SetStatusCode(fasthttp.StatusBadRequest)
-		ctx.WriteString(`{"error": "name is required"}`)
+		WriteProblem(ctx, Required("name"))
 		return
 	}
 
 	// Create child span for Kubernetes operation
 	reqCtx, span := CreateChildSpan(ctx, "k8s_create_frontendpage",
-		attribute.String("namespace", api.Namespace),
+		attribute.String("namespace", r.Service.PageNamespace()),
 		attribute.String("name", doc.Name),
 		attribute.String("operation", "create"),
 		attribute.String("image", doc.Image),
 		attribute.Int("replicas", doc.Replicas),
 		attribute.Int("port", doc.Port),
+		attribute.Bool("dry_run", dryRun),
 	)
 	defer span.End()
 
-	// Create FrontendPage from FrontendPageDoc
-	object := &frontendv1alpha1.FrontendPage{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      doc.Name,
-			Namespace: api.Namespace,
-		},
-		Spec: frontendv1alpha1.FrontendPageSpec{
-			Content:  doc.Content,
-			Image:    doc.Image,
-			Replicas: doc.Replicas,
-			Port:     doc.Port,
-		},
+	var opts []client.CreateOption
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
 	}
-
-	if err := api.K8SClient.Create(reqCtx, object); err != nil {
-		RecordSpanError(ctx, err)
-		ctx.SetStatusCode(fasthttp.StatusInternalServerError)
-		ctx.WriteString(fmt.Sprintf(`{"error": "%s"}`, err.Error()))
+	created, err := r.Service.CreateFrontendPageRaw(reqCtx, doc, opts...)
+	if err != nil {
+		WriteError(ctx, err)
 		return
 	}
 
 	// Add result attributes
 	AddSpanAttributes(ctx,
-		attribute.String("result.name", doc.Name),
+		attribute.String("result.name", created.Name),
 		attribute.Bool("result.success", true),
 	)
 
+	// Like the Kubernetes API, a dry run answers 201 with the object that
+	// would have been created
 	ctx.SetContentType("application/json")
 	ctx.SetStatusCode(fasthttp.StatusCreated)
-	json.NewEncoder(ctx).Encode(doc)
+	json.NewEncoder(ctx).Encode(created)
 }
 
-// UpdateFrontendPage godoc
-// @Summary Update a frontend page
-// @Description Update a frontend page by name
-// @Tags frontendpages
-// @Accept json
-// @Produce json
-// @Success 200 {object} FrontendPageDoc
-// @Router /api/frontendpages/{name} [put]
-// @Security ApiKeyAuth
-// @Param name path string true "Name of the frontend page"
-
-func (api *FrontendPageApi) UpdateFrontendPage(ctx *fasthttp.RequestCtx) {
+// UpdateFrontendPageRaw replaces the spec of a frontend page directly, with
+// options such as client.DryRunAll, and returns the result. The name in doc
+// is ignored.
+func (api *FrontendPageApi) UpdateFrontendPageRaw(ctx context.Context, name string, doc FrontendPageDoc, opts ...client.UpdateOption) (FrontendPageDoc, error) {
+	if name == "" {
+		return FrontendPageDoc{}, Required("name")
+	}
+	page := &frontendv1alpha1.FrontendPage{}
+	if err := api.K8SClient.Get(ctx, client.ObjectKey{Namespace: api.Namespace, Name: name}, page); err != nil {
+		return FrontendPageDoc{}, err
+	}
+
+	page.Spec.Content = doc.Content
+	page.Spec.Image = doc.Image
+	page.Spec.Replicas = doc.Replicas
+	page.Spec.Port = doc.Port
+	if err := api.K8SClient.Update(ctx, page, opts...); err != nil {
+		return FrontendPageDoc{}, err
+	}
+	return frontendPageDoc(page), nil
+}
+
+// UpdateFrontendPage serves PUT /api/frontendpages/{name}
+func (r *Router) UpdateFrontendPage(ctx *fasthttp.RequestCtx) {
+	dryRun, problem := dryRunFromQuery(ctx)
+	if problem != nil {
+		WriteProblem(ctx, problem)
+		return
+	}
+
 	nameValue := ctx.UserValue("name")
 	if nameValue == nil {
-		ctx.SetStatusCode(fasthttp.StatusBadRequest)
-		ctx.WriteString(`{"error": "name is required"}`)
+		WriteProblem(ctx, BadRequest("name is required"))
 		return
 	}
 
 	name := nameValue.(string)
 
-	// Fetch the existing page
-	existingPage := &frontendv1alpha1.FrontendPage{}
-	err := api.K8SClient.Get(context.Background(), client.ObjectKey{Namespace: api.Namespace, Name: name}, existingPage)
+	// Parse FrontendPageDoc from request body
+	var doc FrontendPageDoc
+	if err := json.Unmarshal(ctx.PostBody(), &doc); err != nil {
+		WriteProblem(ctx, BadRequest("invalid request body: %v", err))
+		return
+	}
+
+	var opts []client.UpdateOption
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	updated, err := r.Service.UpdateFrontendPageRaw(GetOtelContextFromRequest(ctx), name, doc, opts...)
 	if err != nil {
-		ctx.SetStatusCode(fasthttp.StatusInternalServerError)
-		ctx.WriteString(fmt.Sprintf(`{"error": "%s"}`, err.Error()))
+		WriteError(ctx, err)
 		return
 	}
 
-	// Parse FrontendPageDoc from request body
-	var doc FrontendPageDoc
-	if err := json.Unmarshal(ctx.PostBody(), &doc); err != nil {
-		ctx.SetStatusCode(fasthttp.StatusBadRequest)
-		ctx.WriteString(fmt.Sprintf(`{"error": "%s"}`, err.Error()))
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(updated)
+}
+
+// PatchFrontendPage serves PATCH /api/frontendpages/{name}
+func (r *Router) PatchFrontendPage(ctx *fasthttp.RequestCtx) {
+	dryRun, problem := dryRunFromQuery(ctx)
+	if problem != nil {
+		WriteProblem(ctx, problem)
+		return
+	}
+
+	nameValue := ctx.UserValue("name")
+	if nameValue == nil {
+		WriteProblem(ctx, BadRequest("name is required"))
+		return
+	}
+
+	name := nameValue.(string)
+
+	var patchDoc FrontendPagePatchDoc
+	if err := json.Unmarshal(ctx.PostBody(), &patchDoc); err != nil {
+		WriteProblem(ctx, BadRequest("invalid request body: %v", err))
 		return
 	}
 
-	// Update the page spec
-	existingPage.Spec.Content = doc.Content
-	existingPage.Spec.Image = doc.Image
-	existingPage.Spec.Replicas = doc.Replicas
-	existingPage.Spec.Port = doc.Port
+	// Create child span for Kubernetes operation
+	reqCtx, span := CreateChildSpan(ctx, "k8s_patch_frontendpage",
+		attribute.String("namespace", r.Service.PageNamespace()),
+		attribute.String("name", name),
+		attribute.String("operation", "patch"),
+		attribute.Bool("dry_run", dryRun),
+	)
+	defer span.End()
 
-	if err := api.K8SClient.Update(context.Background(), existingPage); err != nil {
-		ctx.SetStatusCode(fasthttp.StatusInternalServerError)
-		ctx.WriteString(fmt.Sprintf(`{"error": "%s"}`, err.Error()))
+	opts := []client.PatchOption{}
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	doc, err := r.Service.PatchFrontendPageRaw(reqCtx, name, patchDoc, opts...)
+	if err != nil {
+		WriteError(ctx, err)
 		return
 	}
 
+	AddSpanAttributes(ctx,
+		attribute.String("result.name", doc.Name),
+		attribute.Bool("result.success", true),
+	)
+
 	ctx.SetContentType("application/json")
 	json.NewEncoder(ctx).Encode(doc)
 }
 
-// DeleteFrontendPageRaw deletes a frontend page directly (for MCP usage)
-func (api *FrontendPageApi) DeleteFrontendPageRaw(ctx context.Context, name string) error {
+// DeleteFrontendPageRaw deletes a frontend page directly, with options such
+// as client.DryRunAll or UID preconditions (for MCP usage)
+func (api *FrontendPageApi) DeleteFrontendPageRaw(ctx context.Context, name string, opts ...client.DeleteOption) error {
 	if name == "" {
-		return fmt.Errorf("name is required")
+		return Required("name")
 	}
 
 	return api.K8SClient.Delete(ctx, &frontendv1alpha1.FrontendPage{
 		ObjectMeta: metav1.ObjectMeta{Namespace: api.Namespace, Name: name},
-	})
+	}, opts...)
 }
 
-// DeleteFrontendPage godoc
-// @Summary Delete a frontend page
-// @Description Delete a frontend page by name
-// @Tags frontendpages
-// @Accept json
-// @Produce json
-// @Success 200 {object} FrontendPageDoc
-// @Router /api/frontendpages/{name} [delete]
-// @Security ApiKeyAuth
-// @Param name path string true "Name of the frontend page"
-
-func (api *FrontendPageApi) DeleteFrontendPage(ctx *fasthttp.RequestCtx) {
+// DeleteFrontendPage serves DELETE /api/frontendpages/{name}
+func (r *Router) DeleteFrontendPage(ctx *fasthttp.RequestCtx) {
 	nameValue := ctx.UserValue("name")
 	if nameValue == nil {
-		ctx.SetStatusCode(fasthttp.StatusBadRequest)
-		ctx.WriteString(`{"error": "name is required"}`)
+		WriteProblem(ctx, BadRequest("name is required"))
 		return
 	}
 
 	name := nameValue.(string)
 
 	//Delete the page
-	if err := api.K8SClient.Delete(context.Background(), &frontendv1alpha1.FrontendPage{
-		ObjectMeta: metav1.ObjectMeta{Namespace: api.Namespace, Name: name},
-	}); err != nil {
-		ctx.SetStatusCode(fasthttp.StatusInternalServerError)
-		ctx.WriteString(fmt.Sprintf(`{"error": "%s"}`, err.Error()))
+	if err := r.Service.DeleteFrontendPageRaw(GetOtelContextFromRequest(ctx), name); err != nil {
+		WriteError(ctx, err)
 		return
 	}
 	ctx.SetContentType("application/json")
 	ctx.SetStatusCode(fasthttp.StatusOK)
-	ctx.WriteString(`{"message": "Frontend page deleted successfully"}`)
+	json.NewEncoder(ctx).Encode(StatusMessage{Message: "Frontend page deleted successfully"})
 }
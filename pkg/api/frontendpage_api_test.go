@@ -135,12 +135,13 @@ func TestFrontendPageAPI_E2E(t *testing.T) {
 
 	cleanupFrontendPages(t, k8sClient, "default")
 
+	pages := &Router{Service: api, Config: NewConfig()}
 	router := fasthttprouter.New()
-	router.GET("/api/frontendpages", adaptHandler(api.ListFrontendPages))
-	router.GET("/api/frontendpages/:name", adaptHandler(api.GetFrontendPage))
-	router.POST("/api/frontendpages", adaptHandler(api.CreateFrontendPage))
-	router.PUT("/api/frontendpages/:name", adaptHandler(api.UpdateFrontendPage))
-	router.DELETE("/api/frontendpages/:name", adaptHandler(api.DeleteFrontendPage))
+	router.GET("/api/frontendpages", adaptHandler(pages.ListFrontendPages))
+	router.GET("/api/frontendpages/:name", adaptHandler(pages.GetFrontendPage))
+	router.POST("/api/frontendpages", adaptHandler(pages.CreateFrontendPage))
+	router.PUT("/api/frontendpages/:name", adaptHandler(pages.UpdateFrontendPage))
+	router.DELETE("/api/frontendpages/:name", adaptHandler(pages.DeleteFrontendPage))
 
 	// --- Create ---
 	t.Logf("[TEST] POST /api/frontendpages (name=%s)", resourceName)
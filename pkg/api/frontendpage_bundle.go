@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/bundle"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ExportFrontendPages serves GET /api/frontendpages/export
+func (r *Router) ExportFrontendPages(ctx *fasthttp.RequestCtx) {
+	format := string(ctx.QueryArgs().Peek("format"))
+	if format == "" {
+		format = bundle.FormatYAML
+		accept := string(ctx.Request.Header.Peek("Accept"))
+		if strings.Contains(accept, "application/json") && !strings.Contains(accept, "yaml") {
+			format = bundle.FormatJSON
+		}
+	}
+	if format != bundle.FormatYAML && format != bundle.FormatJSON {
+		WriteProblem(ctx, BadRequest("unsupported format %q, use yaml or json", format))
+		return
+	}
+
+	reqCtx, span := CreateChildSpan(ctx, "k8s_export_frontendpages",
+		attribute.String("namespace", r.Service.PageNamespace()),
+		attribute.String("operation", "export"),
+		attribute.String("format", format),
+	)
+	defer span.End()
+
+	pages, err := r.Service.ExportFrontendPagesRaw(reqCtx)
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := bundle.Encode(&body, pages, format); err != nil {
+		WriteError(ctx, err)
+		return
+	}
+
+	AddSpanAttributes(ctx,
+		attribute.Int("result.count", len(pages)),
+		attribute.Bool("result.success", true),
+	)
+
+	if format == bundle.FormatJSON {
+		ctx.SetContentType("application/json")
+	} else {
+		ctx.SetContentType("application/yaml")
+	}
+	ctx.Response.Header.Set("Content-Disposition", `attachment; filename="frontendpages.`+format+`"`)
+	ctx.SetBody(body.Bytes())
+}
+
+// ImportFrontendPages serves POST /api/frontendpages/import
+func (r *Router) ImportFrontendPages(ctx *fasthttp.RequestCtx) {
+	opts := bundle.ImportOptions{
+		DryRun:   ctx.QueryArgs().GetBool("dryRun"),
+		Conflict: string(ctx.QueryArgs().Peek("conflict")),
+	}
+	if opts.Conflict != "" {
+		if err := bundle.ValidateConflictPolicy(opts.Conflict); err != nil {
+			WriteProblem(ctx, BadRequest("%v", err))
+			return
+		}
+	}
+
+	pages, err := bundle.Decode(bytes.NewReader(ctx.PostBody()))
+	if err != nil {
+		WriteProblem(ctx, BadRequest("invalid bundle: %v", err))
+		return
+	}
+
+	reqCtx, span := CreateChildSpan(ctx, "k8s_import_frontendpages",
+		attribute.String("namespace", r.Service.PageNamespace()),
+		attribute.String("operation", "import"),
+		attribute.Int("bundle.size", len(pages)),
+		attribute.Bool("dry_run", opts.DryRun),
+	)
+	defer span.End()
+
+	report, err := r.Service.ImportFrontendPagesRaw(reqCtx, pages, opts)
+	switch {
+	case errors.Is(err, bundle.ErrConflict):
+		RecordSpanError(ctx, err)
+		ctx.SetStatusCode(fasthttp.StatusConflict)
+	case errors.Is(err, bundle.ErrInvalidBundle):
+		WriteProblem(ctx, BadRequest("%v", err))
+		return
+	case err != nil:
+		WriteError(ctx, err)
+		return
+	default:
+		ctx.SetStatusCode(fasthttp.StatusOK)
+	}
+
+	AddSpanAttributes(ctx,
+		attribute.Int("result.created", report.Created),
+		attribute.Int("result.updated", report.Updated),
+		attribute.Int("result.skipped", report.Skipped),
+		attribute.Int("result.failed", report.Failed),
+	)
+
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(report)
+}
+
+// ExportFrontendPagesRaw implements FrontendPageService with bundle.Export
+func (api *FrontendPageApi) ExportFrontendPagesRaw(ctx context.Context) ([]frontendv1alpha1.FrontendPage, error) {
+	return bundle.Export(ctx, api.K8SClient, api.Namespace)
+}
+
+// ImportFrontendPagesRaw implements FrontendPageService with bundle.Import
+func (api *FrontendPageApi) ImportFrontendPagesRaw(ctx context.Context, pages []frontendv1alpha1.FrontendPage, opts bundle.ImportOptions) (*bundle.ImportReport, error) {
+	opts.Namespace = api.Namespace
+	return bundle.Import(ctx, api.K8SClient, pages, opts)
+}
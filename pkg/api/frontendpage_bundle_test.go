@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/bundle"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestExportImportFrontendPages(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, frontendv1alpha1.AddToScheme(scheme))
+	api := &FrontendPageApi{
+		K8SClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(testPage("a", "", nil)).Build(),
+		Namespace: "default",
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/frontendpages/export?format=json")
+	routerFor(api).ExportFrontendPages(ctx)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	require.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+	exported := append([]byte(nil), ctx.Response.Body()...)
+
+	// Importing the same bundle back conflicts with the existing page
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/frontendpages/import")
+	ctx.Request.SetBody(exported)
+	routerFor(api).ImportFrontendPages(ctx)
+	require.Equal(t, fasthttp.StatusConflict, ctx.Response.StatusCode())
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/frontendpages/import?conflict=skip")
+	ctx.Request.SetBody(exported)
+	routerFor(api).ImportFrontendPages(ctx)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	var report bundle.ImportReport
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &report))
+	require.Equal(t, 1, report.Skipped)
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/frontendpages/import?conflict=replace")
+	routerFor(api).ImportFrontendPages(ctx)
+	require.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+}
+
+func TestWithReservedNames(t *testing.T) {
+	var called string
+	handler := WithReservedNames(map[string]fasthttp.RequestHandler{
+		"export": func(*fasthttp.RequestCtx) { called = "export" },
+	}, func(*fasthttp.RequestCtx) { called = "get" })
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue("name", "export")
+	handler(ctx)
+	require.Equal(t, "export", called)
+
+	ctx.SetUserValue("name", "landing")
+	handler(ctx)
+	require.Equal(t, "get", called)
+}
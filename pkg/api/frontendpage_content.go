@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/ctrl"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetFrontendPageContent serves GET /api/frontendpages/{name}/content
+func (r *Router) GetFrontendPageContent(ctx *fasthttp.RequestCtx) {
+	nameValue := ctx.UserValue("name")
+	if nameValue == nil {
+		WriteProblem(ctx, BadRequest("name is required"))
+		return
+	}
+	name := nameValue.(string)
+
+	reqCtx, span := CreateChildSpan(ctx, "k8s_get_frontendpage_content",
+		attribute.String("namespace", r.Service.PageNamespace()),
+		attribute.String("name", name),
+		attribute.String("operation", "get_content"),
+	)
+	defer span.End()
+
+	content, err := r.Service.GetFrontendPageContentRaw(reqCtx, name)
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+	writeContent(ctx, content)
+}
+
+// GetFrontendPageContentRaw returns the rendered content of a frontend page
+// from its generated ConfigMap (for MCP usage)
+func (api *FrontendPageApi) GetFrontendPageContentRaw(ctx context.Context, name string) (string, error) {
+	cm := &corev1.ConfigMap{}
+	if err := api.K8SClient.Get(ctx, client.ObjectKey{Namespace: api.Namespace, Name: name}, cm); err != nil {
+		return "", err
+	}
+
+	// Only serve ConfigMaps generated for a FrontendPage, not any ConfigMap
+	// that happens to share its name
+	owner := metav1.GetControllerOf(cm)
+	if owner == nil || owner.Kind != "FrontendPage" || owner.Name != name {
+		return "", NewProblem(fasthttp.StatusNotFound, "content for frontend page "+name+" has not been generated")
+	}
+	return cm.Data[ctrl.ContentKey], nil
+}
+
+// RenderFrontendPageDraft serves POST /api/frontendpages/{name}/content
+func (r *Router) RenderFrontendPageDraft(ctx *fasthttp.RequestCtx) {
+	nameValue := ctx.UserValue("name")
+	if nameValue == nil {
+		WriteProblem(ctx, BadRequest("name is required"))
+		return
+	}
+	name := nameValue.(string)
+
+	var doc FrontendPageDoc
+	if err := json.Unmarshal(ctx.PostBody(), &doc); err != nil {
+		WriteProblem(ctx, BadRequest("invalid request body: %v", err))
+		return
+	}
+
+	page := &frontendv1alpha1.FrontendPage{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: r.Service.PageNamespace()},
+		Spec: frontendv1alpha1.FrontendPageSpec{
+			Content:  doc.Content,
+			Image:    doc.Image,
+			Replicas: doc.Replicas,
+			Port:     doc.Port,
+		},
+	}
+	cm := ctrl.BuildOwnedResources(page).ConfigMap
+
+	AddSpanAttributes(ctx,
+		attribute.String("name", name),
+		attribute.Int("result.size", len(cm.Data[ctrl.ContentKey])),
+	)
+
+	writeContent(ctx, cm.Data[ctrl.ContentKey])
+}
+
+// writeContent serves page content from the API origin. The sandbox policy
+// keeps scripts in the page away from the API's cookies and storage.
+func writeContent(ctx *fasthttp.RequestCtx, content string) {
+	ctx.Response.Header.Set("Content-Security-Policy", "sandbox")
+	ctx.Response.Header.Set("X-Content-Type-Options", "nosniff")
+	ctx.Response.Header.Set("Cache-Control", "no-store")
+	ctx.SetContentType(http.DetectContentType([]byte(content)))
+	ctx.SetBodyString(content)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"testing"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/ctrl"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetFrontendPageContent(t *testing.T) {
+	page := testPage("page", "", nil)
+	owned := ctrl.BuildOwnedResources(page)
+	controller := true
+	owned.ConfigMap.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: frontendv1alpha1.SchemeGroupVersion.String(),
+		Kind:       "FrontendPage",
+		Name:       "page",
+		Controller: &controller,
+	}}
+	stray := ctrl.BuildOwnedResources(testPage("stray", "", nil)).ConfigMap
+	api := newPreviewApi(t, page, owned.ConfigMap, stray)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue("name", "page")
+	routerFor(api).GetFrontendPageContent(ctx)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	require.Equal(t, "text/html; charset=utf-8", string(ctx.Response.Header.ContentType()))
+	require.Equal(t, "sandbox", string(ctx.Response.Header.Peek("Content-Security-Policy")))
+	require.Equal(t, "<h1>page</h1>", string(ctx.Response.Body()))
+
+	// ConfigMaps that don't belong to a FrontendPage are not served
+	ctx = &fasthttp.RequestCtx{}
+	ctx.SetUserValue("name", "stray")
+	routerFor(api).GetFrontendPageContent(ctx)
+	require.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.SetUserValue("name", "missing")
+	routerFor(api).GetFrontendPageContent(ctx)
+	require.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+}
+
+func TestRenderFrontendPageDraft(t *testing.T) {
+	api := newPreviewApi(t)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue("name", "draft")
+	ctx.Request.SetBody([]byte(`{"content":"plain text draft"}`))
+	routerFor(api).RenderFrontendPageDraft(ctx)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	require.Equal(t, "text/plain; charset=utf-8", string(ctx.Response.Header.ContentType()))
+	require.Equal(t, "plain text draft", string(ctx.Response.Body()))
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.SetUserValue("name", "draft")
+	ctx.Request.SetBody([]byte(`not json`))
+	routerFor(api).RenderFrontendPageDraft(ctx)
+	require.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+}
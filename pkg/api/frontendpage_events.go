@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// EventDoc is a Kubernetes event about a page or an object serving it
+type EventDoc struct {
+	Type     string    `json:"type"`
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message"`
+	Kind     string    `json:"kind"`
+	Name     string    `json:"name"`
+	Count    int32     `json:"count,omitempty"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// eventAboutPage reports whether an event is about an object of the
+// workload, or about any FrontendPage when there is no workload
+func eventAboutPage(event *corev1.Event, w *pageWorkload) bool {
+	object := event.InvolvedObject
+	if w == nil {
+		return object.Kind == "FrontendPage"
+	}
+	return w.owns(object.Kind, object.Name)
+}
+
+func eventLastSeen(event *corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// FrontendPageEventsRaw returns the events about a page and the objects it
+// controls, newest first, or the events about every page when name is
+// empty (for MCP usage)
+func (api *FrontendPageApi) FrontendPageEventsRaw(ctx context.Context, name string) ([]EventDoc, error) {
+	var w *pageWorkload
+	if name != "" {
+		var err error
+		w, err = api.pageWorkload(ctx, name)
+		switch {
+		case apierrors.IsNotFound(err):
+			// A deleted page controls nothing, but its own events remain
+			w = &pageWorkload{objects: map[ObjectRef]bool{}}
+			w.add("FrontendPage", name)
+		case err != nil:
+			return nil, err
+		}
+	}
+	list := &corev1.EventList{}
+	if err := api.apiReader().List(ctx, list, client.InNamespace(api.Namespace)); err != nil {
+		return nil, err
+	}
+	events := []EventDoc{}
+	for i := range list.Items {
+		event := &list.Items[i]
+		if !eventAboutPage(event, w) {
+			continue
+		}
+		events = append(events, EventDoc{
+			Type:     event.Type,
+			Reason:   event.Reason,
+			Message:  event.Message,
+			Kind:     event.InvolvedObject.Kind,
+			Name:     event.InvolvedObject.Name,
+			Count:    event.Count,
+			LastSeen: eventLastSeen(event),
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastSeen.After(events[j].LastSeen)
+	})
+	return events, nil
+}
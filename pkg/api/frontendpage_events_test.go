@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFrontendPageEventsRaw(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	event := func(name, kind, object, reason string, age time.Duration) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Name: object},
+			Type:           corev1.EventTypeNormal,
+			Reason:         reason,
+			LastTimestamp:  metav1.NewTime(now.Add(-age)),
+		}
+	}
+	objects := append(pageWorkloadObjects(),
+		event("e1", "FrontendPage", "page", "AuditCreate", 3*time.Minute),
+		event("e2", "Pod", "page-6d4cf56db6-x2x7q", "BackOff", time.Minute),
+		event("e3", "Deployment", "page", "ScalingReplicaSet", 2*time.Minute),
+		event("e4", "Pod", "page-other-abcde", "Pulled", time.Minute),
+		event("e5", "FrontendPage", "other", "AuditDelete", time.Minute),
+	)
+	api := newPreviewApi(t, objects...)
+	ctx := context.Background()
+
+	events, err := api.FrontendPageEventsRaw(ctx, "page")
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, []string{"BackOff", "ScalingReplicaSet", "AuditCreate"},
+		[]string{events[0].Reason, events[1].Reason, events[2].Reason})
+	require.True(t, now.Add(-time.Minute).Equal(events[0].LastSeen))
+
+	events, err = api.FrontendPageEventsRaw(ctx, "other")
+	require.NoError(t, err)
+	require.Len(t, events, 1, "events about deleted pages remain")
+
+	events, err = api.FrontendPageEventsRaw(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "other", events[0].Name)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxListLimit caps the limit query parameter of list requests
+const maxListLimit = 500
+
+// paginate returns up to limit docs, in name order, after the page named by
+// the continue token. Pages are cut here rather than by the apiserver,
+// since the manager's cached client ignores limit and continue.
+func paginate(docs []FrontendPageDoc, limit int, continueToken string) ([]FrontendPageDoc, string, *Problem) {
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Name < docs[j].Name })
+	if continueToken != "" {
+		after, err := base64.RawURLEncoding.DecodeString(continueToken)
+		if err != nil || len(after) == 0 {
+			return nil, "", BadRequest("invalid continue token")
+		}
+		start := sort.Search(len(docs), func(i int) bool { return docs[i].Name > string(after) })
+		docs = docs[start:]
+	}
+	if limit <= 0 || len(docs) <= limit {
+		return docs, "", nil
+	}
+	docs = docs[:limit]
+	return docs, base64.RawURLEncoding.EncodeToString([]byte(docs[limit-1].Name)), nil
+}
+
+// listLimitFromQuery reads the limit query parameter, 0 when it is not set
+func listLimitFromQuery(ctx *fasthttp.RequestCtx) (int, *Problem) {
+	value := string(ctx.QueryArgs().Peek("limit"))
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		return 0, Invalid(ProblemCause{
+			Field:   "limit",
+			Reason:  string(metav1.CauseTypeFieldValueInvalid),
+			Message: fmt.Sprintf("limit must be a number between 1 and %d", maxListLimit),
+		})
+	}
+	return limit, nil
+}
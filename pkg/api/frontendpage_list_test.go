@@ -0,0 +1,59 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestPaginate(t *testing.T) {
+	docs := func() []FrontendPageDoc {
+		return []FrontendPageDoc{{Name: "c"}, {Name: "a"}, {Name: "d"}, {Name: "b"}}
+	}
+	names := func(docs []FrontendPageDoc) []string {
+		var names []string
+		for _, doc := range docs {
+			names = append(names, doc.Name)
+		}
+		return names
+	}
+
+	page, next, problem := paginate(docs(), 0, "")
+	require.Nil(t, problem)
+	require.Equal(t, []string{"a", "b", "c", "d"}, names(page))
+	require.Empty(t, next)
+
+	page, next, problem = paginate(docs(), 3, "")
+	require.Nil(t, problem)
+	require.Equal(t, []string{"a", "b", "c"}, names(page))
+	page, next, problem = paginate(docs(), 3, next)
+	require.Nil(t, problem)
+	require.Equal(t, []string{"d"}, names(page))
+	require.Empty(t, next)
+
+	// Pages deleted between requests don't shift the next page
+	page, next, _ = paginate(docs(), 2, "")
+	require.Equal(t, []string{"a", "b"}, names(page))
+	page, _, _ = paginate([]FrontendPageDoc{{Name: "c"}, {Name: "d"}}, 2, next)
+	require.Equal(t, []string{"c", "d"}, names(page))
+
+	_, _, problem = paginate(docs(), 2, "not base64!")
+	require.Equal(t, fasthttp.StatusBadRequest, problem.Status)
+}
+
+func TestListLimitFromQuery(t *testing.T) {
+	for query, want := range map[string]int{"": 0, "limit=1": 1, "limit=500": 500} {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/api/frontendpages?" + query)
+		limit, problem := listLimitFromQuery(ctx)
+		require.Nil(t, problem, query)
+		require.Equal(t, want, limit, query)
+	}
+	for _, query := range []string{"limit=0", "limit=501", "limit=ten"} {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/api/frontendpages?" + query)
+		_, problem := listLimitFromQuery(ctx)
+		require.Equal(t, "limit", problem.Causes[0].Field, query)
+	}
+}
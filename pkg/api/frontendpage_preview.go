@@ -0,0 +1,269 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/ctrl"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Preview actions for each owned resource
+const (
+	PreviewActionCreate    = "create"
+	PreviewActionUpdate    = "update"
+	PreviewActionUnchanged = "unchanged"
+)
+
+// PreviewResource is one object the controller would produce for a page.
+// Desired is the whole object for a create, and otherwise the live object with
+// the fields the controller reconciles applied.
+type PreviewResource struct {
+	Kind    string         `json:"kind"`
+	Name    string         `json:"name"`
+	Action  string         `json:"action"`
+	Desired map[string]any `json:"desired"`
+	Diff    string         `json:"diff,omitempty"`
+}
+
+// FrontendPagePreview is the result of previewing a FrontendPage change
+type FrontendPagePreview struct {
+	Name      string            `json:"name"`
+	Exists    bool              `json:"exists"`
+	Resources []PreviewResource `json:"resources"`
+}
+
+// PreviewFrontendPage serves POST /api/frontendpages/{name}/preview
+func (r *Router) PreviewFrontendPage(ctx *fasthttp.RequestCtx) {
+	nameValue := ctx.UserValue("name")
+	if nameValue == nil {
+		WriteProblem(ctx, BadRequest("name is required"))
+		return
+	}
+	name := nameValue.(string)
+
+	var doc *FrontendPageDoc
+	if len(ctx.PostBody()) > 0 {
+		doc = &FrontendPageDoc{}
+		if err := json.Unmarshal(ctx.PostBody(), doc); err != nil {
+			WriteProblem(ctx, BadRequest("invalid request body: %v", err))
+			return
+		}
+	}
+
+	reqCtx, span := CreateChildSpan(ctx, "k8s_preview_frontendpage",
+		attribute.String("namespace", r.Service.PageNamespace()),
+		attribute.String("name", name),
+		attribute.String("operation", "preview"),
+	)
+	defer span.End()
+
+	preview, err := r.Service.PreviewFrontendPageRaw(reqCtx, name, doc)
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+
+	AddSpanAttributes(ctx,
+		attribute.Bool("result.exists", preview.Exists),
+		attribute.Bool("result.success", true),
+	)
+
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(preview)
+}
+
+// PreviewFrontendPageRaw renders the owned resources for the page, or for doc
+// when it is set, and diffs them against the live objects
+func (api *FrontendPageApi) PreviewFrontendPageRaw(ctx context.Context, name string, doc *FrontendPageDoc) (*FrontendPagePreview, error) {
+	page := &frontendv1alpha1.FrontendPage{}
+	err := api.K8SClient.Get(ctx, client.ObjectKey{Namespace: api.Namespace, Name: name}, page)
+	exists := err == nil
+	switch {
+	case apierrors.IsNotFound(err) && doc != nil:
+		page = &frontendv1alpha1.FrontendPage{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: api.Namespace},
+		}
+	case err != nil:
+		return nil, err
+	}
+
+	if doc != nil {
+		page.Spec = frontendv1alpha1.FrontendPageSpec{
+			Content:  doc.Content,
+			Image:    doc.Image,
+			Replicas: doc.Replicas,
+			Port:     doc.Port,
+		}
+	}
+
+	owned := ctrl.BuildOwnedResources(page)
+	preview := &FrontendPagePreview{Name: name, Exists: exists}
+	for _, item := range []struct {
+		kind      string
+		desired   client.Object
+		live      client.Object
+		reconcile func(client.Object) bool
+	}{
+		{"Deployment", owned.Deployment, &appsv1.Deployment{}, func(live client.Object) bool {
+			return ctrl.ReconcileDeployment(live.(*appsv1.Deployment), owned.Deployment)
+		}},
+		{"Service", owned.Service, &corev1.Service{}, func(live client.Object) bool {
+			return ctrl.ReconcileService(live.(*corev1.Service), owned.Service)
+		}},
+		{"ConfigMap", owned.ConfigMap, &corev1.ConfigMap{}, func(live client.Object) bool {
+			return ctrl.ReconcileConfigMap(live.(*corev1.ConfigMap), owned.ConfigMap)
+		}},
+	} {
+		resource, err := api.previewResource(ctx, item.kind, item.desired, item.live, item.reconcile)
+		if err != nil {
+			return nil, err
+		}
+		preview.Resources = append(preview.Resources, *resource)
+	}
+	return preview, nil
+}
+
+// previewResource diffs desired against the live object. Reconcile only
+// updates some fields of existing objects, so reconcile applies those to a copy
+// of the live object, and only the changes it makes are previewed.
+func (api *FrontendPageApi) previewResource(ctx context.Context, kind string, desired, live client.Object, reconcile func(client.Object) bool) (*PreviewResource, error) {
+	desiredMap, err := toMap(desired)
+	if err != nil {
+		return nil, err
+	}
+	pruneEmpty(desiredMap)
+	resource := &PreviewResource{
+		Kind:    kind,
+		Name:    desired.GetName(),
+		Desired: desiredMap,
+	}
+
+	err = api.K8SClient.Get(ctx, client.ObjectKeyFromObject(desired), live)
+	if apierrors.IsNotFound(err) {
+		resource.Action = PreviewActionCreate
+		resource.Diff, err = unifiedDiff(nil, desiredMap)
+		return resource, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reconciled := live.DeepCopyObject().(client.Object)
+	changed := reconcile(reconciled)
+
+	// Only compare the fields the builder sets, so that server defaults and
+	// status don't show up as changes
+	liveMap, err := toMap(live)
+	if err != nil {
+		return nil, err
+	}
+	liveMap = pruneTo(liveMap, desiredMap).(map[string]any)
+	reconciledMap, err := toMap(reconciled)
+	if err != nil {
+		return nil, err
+	}
+	resource.Desired = pruneTo(reconciledMap, desiredMap).(map[string]any)
+
+	if !changed {
+		resource.Action = PreviewActionUnchanged
+		return resource, nil
+	}
+	resource.Action = PreviewActionUpdate
+	resource.Diff, err = unifiedDiff(liveMap, resource.Desired)
+	return resource, err
+}
+
+func toMap(obj any) (map[string]any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]any{}
+	return out, json.Unmarshal(data, &out)
+}
+
+// pruneEmpty drops nulls and empty objects that builders leave behind, such
+// as metadata.creationTimestamp and status
+func pruneEmpty(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]any:
+		for key, child := range v {
+			if pruneEmpty(child) {
+				delete(v, key)
+			}
+		}
+		return len(v) == 0
+	case []any:
+		for _, child := range v {
+			pruneEmpty(child)
+		}
+	}
+	return false
+}
+
+// pruneTo keeps only the parts of live that are also present in desired
+func pruneTo(live, desired any) any {
+	switch d := desired.(type) {
+	case map[string]any:
+		l, ok := live.(map[string]any)
+		if !ok {
+			return live
+		}
+		out := map[string]any{}
+		for key, value := range d {
+			if liveValue, ok := l[key]; ok {
+				out[key] = pruneTo(liveValue, value)
+			}
+		}
+		return out
+	case []any:
+		l, ok := live.([]any)
+		if !ok {
+			return live
+		}
+		out := make([]any, len(l))
+		for i := range l {
+			if i < len(d) {
+				out[i] = pruneTo(l[i], d[i])
+			} else {
+				out[i] = l[i]
+			}
+		}
+		return out
+	default:
+		return live
+	}
+}
+
+func unifiedDiff(live, desired map[string]any) (string, error) {
+	var liveYAML []byte
+	if live != nil {
+		var err error
+		if liveYAML, err = yaml.Marshal(live); err != nil {
+			return "", err
+		}
+	}
+	desiredYAML, err := yaml.Marshal(desired)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(liveYAML)),
+		B:        difflib.SplitLines(string(desiredYAML)),
+		FromFile: "live",
+		ToFile:   "desired",
+		Context:  3,
+	})
+}
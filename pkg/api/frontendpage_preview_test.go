@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/ctrl"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newPreviewApi(t *testing.T, objs ...client.Object) *FrontendPageApi {
+	scheme := runtime.NewScheme()
+	require.NoError(t, frontendv1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	return &FrontendPageApi{
+		K8SClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		Namespace: "default",
+	}
+}
+
+// routerFor returns a Router serving api with the default Config
+func routerFor(api *FrontendPageApi) *Router {
+	return &Router{Service: api, Config: NewConfig(), Watcher: api.Watcher}
+}
+
+func TestCreateFrontendPage_DryRun(t *testing.T) {
+	api := newPreviewApi(t)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/frontendpages?dryRun=All")
+	ctx.Request.SetBody([]byte(`{"name":"page","content":"<h1>x</h1>","image":"nginx:latest","replicas":1,"port":80}`))
+	routerFor(api).CreateFrontendPage(ctx)
+	require.Equal(t, fasthttp.StatusCreated, ctx.Response.StatusCode())
+
+	err := api.K8SClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: "page"}, &frontendv1alpha1.FrontendPage{})
+	require.Error(t, err)
+
+	// The answer is the object the service would create, not the request
+	router := &Router{Service: defaultingService{api}, Config: NewConfig()}
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/frontendpages?dryRun=All")
+	ctx.Request.SetBody([]byte(`{"name":"page","content":"<h1>x</h1>","replicas":1,"port":80}`))
+	router.CreateFrontendPage(ctx)
+	require.Equal(t, fasthttp.StatusCreated, ctx.Response.StatusCode())
+	var created FrontendPageDoc
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &created))
+	require.Equal(t, "nginx:latest", created.Image)
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/frontendpages?dryRun=yes")
+	routerFor(api).CreateFrontendPage(ctx)
+	require.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+}
+
+// defaultingService defaults the image of created pages, like a mutating
+// webhook would
+type defaultingService struct {
+	FrontendPageService
+}
+
+func (s defaultingService) CreateFrontendPageRaw(ctx context.Context, doc FrontendPageDoc, opts ...client.CreateOption) (FrontendPageDoc, error) {
+	if doc.Image == "" {
+		doc.Image = "nginx:latest"
+	}
+	return s.FrontendPageService.CreateFrontendPageRaw(ctx, doc, opts...)
+}
+
+func TestPatchFrontendPage(t *testing.T) {
+	api := newPreviewApi(t, testPage("page", "", nil))
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue("name", "page")
+	ctx.Request.SetBody([]byte(`{"replicas":3}`))
+	routerFor(api).PatchFrontendPage(ctx)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	page := &frontendv1alpha1.FrontendPage{}
+	require.NoError(t, api.K8SClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: "page"}, page))
+	require.Equal(t, 3, int(page.Spec.Replicas))
+	require.Equal(t, "<h1>page</h1>", page.Spec.Content)
+}
+
+func TestPreviewFrontendPage(t *testing.T) {
+	page := testPage("page", "", nil)
+	owned := ctrl.BuildOwnedResources(page)
+	api := newPreviewApi(t, page, owned.ConfigMap, owned.Deployment)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue("name", "page")
+	ctx.Request.SetBody([]byte(`{"name":"page","content":"<h1>page</h1>","image":"nginx:latest","replicas":3,"port":80}`))
+	routerFor(api).PreviewFrontendPage(ctx)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	var preview FrontendPagePreview
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &preview))
+	require.True(t, preview.Exists)
+
+	actions := map[string]PreviewResource{}
+	for _, resource := range preview.Resources {
+		actions[resource.Kind] = resource
+	}
+	require.Equal(t, PreviewActionUpdate, actions["Deployment"].Action)
+	require.Contains(t, actions["Deployment"].Diff, "-  replicas: 1")
+	require.Contains(t, actions["Deployment"].Diff, "+  replicas: 3")
+	require.Equal(t, PreviewActionCreate, actions["Service"].Action)
+	require.Equal(t, PreviewActionUnchanged, actions["ConfigMap"].Action)
+	require.Empty(t, actions["ConfigMap"].Diff)
+
+	// Nothing was written
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, api.K8SClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: "page"}, deployment))
+	require.Equal(t, int32(1), *deployment.Spec.Replicas)
+}
+
+func TestPreviewFrontendPage_UnreconciledFields(t *testing.T) {
+	page := testPage("page", "", nil)
+	owned := ctrl.BuildOwnedResources(page)
+	// The controller never updates the mounts or template labels of a live
+	// Deployment, only its replicas and image
+	owned.Deployment.Spec.Template.Labels["tier"] = "web"
+	owned.Deployment.Spec.Template.Spec.Containers[0].VolumeMounts[0].MountPath = "/srv"
+	api := newPreviewApi(t, page, owned.ConfigMap, owned.Deployment)
+
+	preview, err := api.PreviewFrontendPageRaw(context.Background(), "page", nil)
+	require.NoError(t, err)
+	require.Equal(t, "Deployment", preview.Resources[0].Kind)
+	require.Equal(t, PreviewActionUnchanged, preview.Resources[0].Action)
+	require.Empty(t, preview.Resources[0].Diff)
+
+	// Reconciled fields are still previewed, next to the live values of the others
+	preview, err = api.PreviewFrontendPageRaw(context.Background(), "page", &FrontendPageDoc{
+		Name: "page", Content: page.Spec.Content, Image: "nginx:1.27", Replicas: int(page.Spec.Replicas), Port: page.Spec.Port,
+	})
+	require.NoError(t, err)
+	deployment := preview.Resources[0]
+	require.Equal(t, PreviewActionUpdate, deployment.Action)
+	var changed []string
+	for _, line := range strings.Split(deployment.Diff, "\n") {
+		if strings.HasPrefix(line, "+ ") || strings.HasPrefix(line, "- ") {
+			changed = append(changed, strings.TrimSpace(line[1:]))
+		}
+	}
+	require.Equal(t, []string{"- image: nginx:latest", "- image: nginx:1.27"}, changed)
+}
+
+func TestPreviewFrontendPage_NotFound(t *testing.T) {
+	api := newPreviewApi(t)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue("name", "missing")
+	routerFor(api).PreviewFrontendPage(ctx)
+	require.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+}
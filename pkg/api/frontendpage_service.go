@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/bundle"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FrontendPageService is what the REST Router and the MCP server need from
+// the FrontendPage backend. FrontendPageApi implements it against the
+// cluster; package fake has an in-memory one for unit tests. Errors are
+// Problems or Kubernetes API errors, so ProblemFromError maps them.
+type FrontendPageService interface {
+	// PageNamespace is the namespace of the pages the service manages
+	PageNamespace() string
+	// AuthorizeIdentity checks a caller against the service's Authorizer
+	AuthorizeIdentity(ctx context.Context, identity *Identity, attrs Attributes) (Decision, error)
+
+	ListFrontendPagesRaw(ctx context.Context) ([]FrontendPageDoc, error)
+	GetFrontendPageRaw(ctx context.Context, name string) (FrontendPageDoc, error)
+	CreateFrontendPageRaw(ctx context.Context, doc FrontendPageDoc, opts ...client.CreateOption) (FrontendPageDoc, error)
+	UpdateFrontendPageRaw(ctx context.Context, name string, doc FrontendPageDoc, opts ...client.UpdateOption) (FrontendPageDoc, error)
+	PatchFrontendPageRaw(ctx context.Context, name string, patchDoc FrontendPagePatchDoc, opts ...client.PatchOption) (FrontendPageDoc, error)
+	DeleteFrontendPageRaw(ctx context.Context, name string, opts ...client.DeleteOption) error
+	FrontendPageDeletePlanRaw(ctx context.Context, name string) (*FrontendPageDeletePlan, error)
+	// ExportFrontendPagesRaw returns the pages cleaned for a bundle, sorted by
+	// name
+	ExportFrontendPagesRaw(ctx context.Context) ([]frontendv1alpha1.FrontendPage, error)
+	// ImportFrontendPagesRaw creates or updates pages in the service's
+	// namespace, see bundle.Import
+	ImportFrontendPagesRaw(ctx context.Context, pages []frontendv1alpha1.FrontendPage, opts bundle.ImportOptions) (*bundle.ImportReport, error)
+	PreviewFrontendPageRaw(ctx context.Context, name string, doc *FrontendPageDoc) (*FrontendPagePreview, error)
+
+	GetFrontendPageContentRaw(ctx context.Context, name string) (string, error)
+	FrontendPageStatusRaw(ctx context.Context, name string) (FrontendPageStatusDoc, error)
+	FrontendPageEventsRaw(ctx context.Context, name string) ([]EventDoc, error)
+	FrontendPagePodsRaw(ctx context.Context, name string) ([]PodStatusDoc, error)
+	FrontendPageLogsRaw(ctx context.Context, name string, opts PodLogsOptions) ([]PodLogsDoc, error)
+	FrontendPageRolloutHistoryRaw(ctx context.Context, name string) ([]RolloutRevisionDoc, error)
+	// FrontendPageChangesRaw returns the changes to a page, or to every page
+	// when name is empty, seen since the given time. It reports false when
+	// the service keeps no change history.
+	FrontendPageChangesRaw(name string, since time.Time) ([]PageChange, bool)
+}
+
+var _ FrontendPageService = &FrontendPageApi{}
+
+// PageNamespace implements FrontendPageService
+func (api *FrontendPageApi) PageNamespace() string {
+	return api.Namespace
+}
+
+// FrontendPageChangesRaw implements FrontendPageService with the Watcher's
+// history (for MCP usage)
+func (api *FrontendPageApi) FrontendPageChangesRaw(name string, since time.Time) ([]PageChange, bool) {
+	if api.Watcher == nil {
+		return nil, false
+	}
+	return api.Watcher.Changes(name, since), true
+}
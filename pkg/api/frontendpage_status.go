@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Phases of a FrontendPage, derived from its conditions and Deployment
+const (
+	FrontendPagePhaseReady       = "Ready"
+	FrontendPagePhaseProgressing = "Progressing"
+	FrontendPagePhaseNotDeployed = "NotDeployed"
+	FrontendPagePhaseFailed      = "Failed"
+)
+
+// DeploymentStatusDoc is the rollout state of the Deployment serving a page
+type DeploymentStatusDoc struct {
+	Name                string             `json:"name"`
+	DesiredReplicas     int32              `json:"desiredReplicas"`
+	ReadyReplicas       int32              `json:"readyReplicas"`
+	AvailableReplicas   int32              `json:"availableReplicas"`
+	UpdatedReplicas     int32              `json:"updatedReplicas"`
+	UnavailableReplicas int32              `json:"unavailableReplicas"`
+	Image               string             `json:"image,omitempty"`
+	Conditions          []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// FrontendPageStatusDoc is the observed state of a frontend page
+type FrontendPageStatusDoc struct {
+	Name       string               `json:"name"`
+	Phase      string               `json:"phase"`
+	Ready      bool                 `json:"ready"`
+	Replicas   int                  `json:"replicas"`
+	Conditions []metav1.Condition   `json:"conditions,omitempty"`
+	Deployment *DeploymentStatusDoc `json:"deployment,omitempty"`
+}
+
+// FrontendPageStatusRaw returns the conditions of a frontend page and the
+// rollout state of its Deployment (for MCP usage)
+func (api *FrontendPageApi) FrontendPageStatusRaw(ctx context.Context, name string) (FrontendPageStatusDoc, error) {
+	if name == "" {
+		return FrontendPageStatusDoc{}, Required("name")
+	}
+	page := &frontendv1alpha1.FrontendPage{}
+	if err := api.K8SClient.Get(ctx, client.ObjectKey{Namespace: api.Namespace, Name: name}, page); err != nil {
+		return FrontendPageStatusDoc{}, err
+	}
+	status := FrontendPageStatusDoc{
+		Name:       page.Name,
+		Replicas:   page.Spec.Replicas,
+		Conditions: page.Status.Conditions,
+	}
+
+	deployment := &appsv1.Deployment{}
+	err := api.K8SClient.Get(ctx, client.ObjectKey{Namespace: api.Namespace, Name: name}, deployment)
+	switch {
+	case apierrors.IsNotFound(err):
+		status.Phase = FrontendPagePhaseNotDeployed
+		return status, nil
+	case err != nil:
+		return FrontendPageStatusDoc{}, err
+	}
+	status.Deployment = deploymentStatusDoc(deployment)
+	status.Phase, status.Ready = frontendPagePhase(page, status.Deployment)
+	return status, nil
+}
+
+func deploymentStatusDoc(deployment *appsv1.Deployment) *DeploymentStatusDoc {
+	doc := &DeploymentStatusDoc{
+		Name:                deployment.Name,
+		ReadyReplicas:       deployment.Status.ReadyReplicas,
+		AvailableReplicas:   deployment.Status.AvailableReplicas,
+		UpdatedReplicas:     deployment.Status.UpdatedReplicas,
+		UnavailableReplicas: deployment.Status.UnavailableReplicas,
+	}
+	if deployment.Spec.Replicas != nil {
+		doc.DesiredReplicas = *deployment.Spec.Replicas
+	}
+	if containers := deployment.Spec.Template.Spec.Containers; len(containers) > 0 {
+		doc.Image = containers[0].Image
+	}
+	for _, c := range deployment.Status.Conditions {
+		doc.Conditions = append(doc.Conditions, metav1.Condition{
+			Type:               string(c.Type),
+			Status:             metav1.ConditionStatus(c.Status),
+			Reason:             c.Reason,
+			Message:            c.Message,
+			LastTransitionTime: c.LastTransitionTime,
+		})
+	}
+	return doc
+}
+
+// frontendPagePhase trusts a Ready condition on the page and otherwise
+// derives the phase from the Deployment
+func frontendPagePhase(page *frontendv1alpha1.FrontendPage, deployment *DeploymentStatusDoc) (string, bool) {
+	if meta.IsStatusConditionTrue(page.Status.Conditions, "Ready") {
+		return FrontendPagePhaseReady, true
+	}
+	if meta.IsStatusConditionFalse(deployment.Conditions, string(appsv1.DeploymentProgressing)) {
+		return FrontendPagePhaseFailed, false
+	}
+	if deployment.UpdatedReplicas == deployment.DesiredReplicas &&
+		deployment.AvailableReplicas == deployment.DesiredReplicas &&
+		deployment.UnavailableReplicas == 0 {
+		return FrontendPagePhaseReady, true
+	}
+	return FrontendPagePhaseProgressing, false
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFrontendPageStatusRaw(t *testing.T) {
+	replicas := int32(2)
+	deployment := func(name string, status appsv1.DeploymentStatus) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Image: "nginx:latest"}}}},
+			},
+			Status: status,
+		}
+	}
+	api := newPreviewApi(t,
+		testPage("ready", "", nil), deployment("ready", appsv1.DeploymentStatus{ReadyReplicas: 2, AvailableReplicas: 2, UpdatedReplicas: 2}),
+		testPage("stuck", "", nil), deployment("stuck", appsv1.DeploymentStatus{UpdatedReplicas: 1, UnavailableReplicas: 2,
+			Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded"}}}),
+		testPage("new", "", nil),
+	)
+	ctx := context.Background()
+
+	status, err := api.FrontendPageStatusRaw(ctx, "ready")
+	require.NoError(t, err)
+	require.Equal(t, FrontendPagePhaseReady, status.Phase)
+	require.True(t, status.Ready)
+	require.Equal(t, int32(2), status.Deployment.AvailableReplicas)
+	require.Equal(t, "nginx:latest", status.Deployment.Image)
+
+	status, err = api.FrontendPageStatusRaw(ctx, "stuck")
+	require.NoError(t, err)
+	require.Equal(t, FrontendPagePhaseFailed, status.Phase)
+	require.Equal(t, "ProgressDeadlineExceeded", status.Deployment.Conditions[0].Reason)
+
+	status, err = api.FrontendPageStatusRaw(ctx, "new")
+	require.NoError(t, err)
+	require.Equal(t, FrontendPagePhaseNotDeployed, status.Phase)
+	require.Nil(t, status.Deployment)
+
+	_, err = api.FrontendPageStatusRaw(ctx, "missing")
+	require.Equal(t, "NotFound", ProblemFromError(err).Reason)
+}
@@ -0,0 +1,486 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/fasthttp/websocket"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// Watch event types, same as the ones used by Kubernetes watch streams
+const (
+	WatchEventAdded    = "ADDED"
+	WatchEventModified = "MODIFIED"
+	WatchEventDeleted  = "DELETED"
+)
+
+const (
+	defaultWatchHistorySize = 256
+	defaultWatchHeartbeat   = 15 * time.Second
+	watchSubscriberBuffer   = 64
+)
+
+// ErrWatchExpired is returned when a client resumes from a resourceVersion
+// that is no longer kept in the watch history
+var ErrWatchExpired = &Problem{
+	Type:   "about:blank",
+	Title:  "Gone",
+	Status: fasthttp.StatusGone,
+	Detail: "resource version is too old, list again to get a fresh one",
+	Reason: string(metav1.StatusReasonExpired),
+}
+
+// WatchEvent is a single FrontendPage change delivered to watch clients
+type WatchEvent struct {
+	Type            string          `json:"type"`
+	ResourceVersion string          `json:"resourceVersion"`
+	Object          FrontendPageDoc `json:"object"`
+}
+
+type watchRecord struct {
+	eventType string
+	page      *frontendv1alpha1.FrontendPage
+	// observed is when the informer delivered the change, initial marks
+	// pages from the informer's first list, which aren't changes
+	observed time.Time
+	initial  bool
+}
+
+func (r watchRecord) event() WatchEvent {
+	return WatchEvent{
+		Type:            r.eventType,
+		ResourceVersion: r.page.ResourceVersion,
+		Object: FrontendPageDoc{
+			Name:     r.page.Name,
+			Content:  r.page.Spec.Content,
+			Image:    r.page.Spec.Image,
+			Replicas: r.page.Spec.Replicas,
+			Port:     r.page.Spec.Port,
+		},
+	}
+}
+
+// watchFilter holds the per-connection filters taken from the query string
+type watchFilter struct {
+	labels labels.Selector
+	fields fields.Selector
+	types  map[string]bool
+}
+
+func parseWatchFilter(args *fasthttp.Args) (watchFilter, error) {
+	filter := watchFilter{
+		labels: labels.Everything(),
+		fields: fields.Everything(),
+	}
+	if raw := string(args.Peek("labelSelector")); raw != "" {
+		selector, err := labels.Parse(raw)
+		if err != nil {
+			return filter, BadRequest("invalid labelSelector: %v", err)
+		}
+		filter.labels = selector
+	}
+	if raw := string(args.Peek("fieldSelector")); raw != "" {
+		selector, err := fields.ParseSelector(raw)
+		if err != nil {
+			return filter, BadRequest("invalid fieldSelector: %v", err)
+		}
+		filter.fields = selector
+	}
+	if raw := string(args.Peek("types")); raw != "" {
+		filter.types = map[string]bool{}
+		for _, t := range strings.Split(raw, ",") {
+			t = strings.ToUpper(strings.TrimSpace(t))
+			switch t {
+			case WatchEventAdded, WatchEventModified, WatchEventDeleted:
+				filter.types[t] = true
+			default:
+				return filter, BadRequest("invalid event type: %s", t)
+			}
+		}
+	}
+	return filter, nil
+}
+
+func (f watchFilter) matches(r watchRecord) bool {
+	if f.types != nil && !f.types[r.eventType] {
+		return false
+	}
+	if !f.labels.Matches(labels.Set(r.page.Labels)) {
+		return false
+	}
+	return f.fields.Matches(fields.Set{
+		"metadata.name":      r.page.Name,
+		"metadata.namespace": r.page.Namespace,
+	})
+}
+
+type watchSubscriber struct {
+	events chan watchRecord
+	filter watchFilter
+}
+
+// FrontendPageWatcher fans informer events out to watch connections and keeps
+// a short history of changes so that clients can resume from a resourceVersion
+type FrontendPageWatcher struct {
+	Namespace         string
+	HeartbeatInterval time.Duration
+	// CORS decides which other origins may open WebSocket watches. Nil only
+	// allows browsers on the server's own origin.
+	CORS *CORS
+
+	mu          sync.Mutex
+	pages       map[string]*frontendv1alpha1.FrontendPage
+	history     []watchRecord
+	historySize int
+	subscribers map[*watchSubscriber]struct{}
+}
+
+// NewFrontendPageWatcher creates a watcher for pages in the given namespace.
+// Register it as an event handler on the FrontendPage informer to feed it.
+func NewFrontendPageWatcher(namespace string) *FrontendPageWatcher {
+	return &FrontendPageWatcher{
+		Namespace:         namespace,
+		HeartbeatInterval: defaultWatchHeartbeat,
+		pages:             map[string]*frontendv1alpha1.FrontendPage{},
+		historySize:       defaultWatchHistorySize,
+		subscribers:       map[*watchSubscriber]struct{}{},
+	}
+}
+
+// OnAdd implements cache.ResourceEventHandler
+func (w *FrontendPageWatcher) OnAdd(obj interface{}, isInInitialList bool) {
+	w.record(WatchEventAdded, obj, isInInitialList)
+}
+
+// OnUpdate implements cache.ResourceEventHandler
+func (w *FrontendPageWatcher) OnUpdate(_, newObj interface{}) {
+	w.record(WatchEventModified, newObj, false)
+}
+
+// OnDelete implements cache.ResourceEventHandler
+func (w *FrontendPageWatcher) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	w.record(WatchEventDeleted, obj, false)
+}
+
+func (w *FrontendPageWatcher) record(eventType string, obj interface{}, initial bool) {
+	page, ok := obj.(*frontendv1alpha1.FrontendPage)
+	if !ok {
+		log.Error().Msgf("Watcher received unexpected object %T", obj)
+		return
+	}
+	if w.Namespace != "" && page.Namespace != w.Namespace {
+		return
+	}
+	rec := watchRecord{eventType: eventType, page: page.DeepCopy(), observed: time.Now(), initial: initial}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if eventType == WatchEventDeleted {
+		delete(w.pages, page.Name)
+	} else {
+		w.pages[page.Name] = rec.page
+	}
+	w.history = append(w.history, rec)
+	if len(w.history) > w.historySize {
+		w.history = w.history[len(w.history)-w.historySize:]
+	}
+
+	for sub := range w.subscribers {
+		if !sub.filter.matches(rec) {
+			continue
+		}
+		select {
+		case sub.events <- rec:
+		default:
+			// The client can't keep up; drop it so it reconnects from its last resourceVersion
+			log.Warn().Msg("Dropping slow watch subscriber")
+			delete(w.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// PageChange is a change to a FrontendPage seen by the watcher
+type PageChange struct {
+	Time time.Time `json:"time"`
+	WatchEvent
+}
+
+// Changes returns the changes to the named page, or to every page when name
+// is empty, seen since the given time and still kept in the watch history
+func (w *FrontendPageWatcher) Changes(name string, since time.Time) []PageChange {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var changes []PageChange
+	for _, rec := range w.history {
+		if rec.initial || rec.observed.Before(since) || (name != "" && rec.page.Name != name) {
+			continue
+		}
+		changes = append(changes, PageChange{Time: rec.observed, WatchEvent: rec.event()})
+	}
+	return changes
+}
+
+// subscribe registers a new subscriber and returns the events it has to replay
+// first: the current state when resourceVersion is empty, or the changes after
+// resourceVersion when resuming.
+func (w *FrontendPageWatcher) subscribe(resourceVersion string, filter watchFilter) (*watchSubscriber, []watchRecord, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var backlog []watchRecord
+	if resourceVersion == "" || resourceVersion == "0" {
+		names := make([]string, 0, len(w.pages))
+		for name := range w.pages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			rec := watchRecord{eventType: WatchEventAdded, page: w.pages[name]}
+			if filter.matches(rec) {
+				backlog = append(backlog, rec)
+			}
+		}
+	} else {
+		found := false
+		for i, rec := range w.history {
+			if rec.page.ResourceVersion != resourceVersion {
+				continue
+			}
+			found = true
+			for _, next := range w.history[i+1:] {
+				if filter.matches(next) {
+					backlog = append(backlog, next)
+				}
+			}
+			break
+		}
+		if !found {
+			return nil, nil, ErrWatchExpired
+		}
+	}
+
+	sub := &watchSubscriber{
+		events: make(chan watchRecord, watchSubscriberBuffer),
+		filter: filter,
+	}
+	w.subscribers[sub] = struct{}{}
+	return sub, backlog, nil
+}
+
+func (w *FrontendPageWatcher) unsubscribe(sub *watchSubscriber) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.subscribers[sub]; ok {
+		delete(w.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// ServeWatch streams FrontendPage changes as Server-Sent Events, or over a
+// WebSocket when the client asks for an upgrade
+func (w *FrontendPageWatcher) ServeWatch(ctx *fasthttp.RequestCtx) {
+	filter, err := parseWatchFilter(ctx.QueryArgs())
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+
+	resourceVersion := string(ctx.QueryArgs().Peek("resourceVersion"))
+	if resourceVersion == "" {
+		// EventSource sends the id of the last event it saw when reconnecting
+		resourceVersion = string(ctx.Request.Header.Peek("Last-Event-ID"))
+	}
+
+	sub, backlog, err := w.subscribe(resourceVersion, filter)
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+
+	websocketUpgrade := websocket.FastHTTPIsWebSocketUpgrade(ctx)
+	AddSpanAttributes(ctx,
+		attribute.String("watch.resource_version", resourceVersion),
+		attribute.Int("watch.backlog", len(backlog)),
+		attribute.Bool("watch.websocket", websocketUpgrade),
+	)
+
+	if websocketUpgrade {
+		w.serveWebSocket(ctx, sub, backlog)
+		return
+	}
+	w.serveSSE(ctx, sub, backlog)
+}
+
+func (w *FrontendPageWatcher) heartbeat() time.Duration {
+	if w.HeartbeatInterval <= 0 {
+		return defaultWatchHeartbeat
+	}
+	return w.HeartbeatInterval
+}
+
+func (w *FrontendPageWatcher) serveSSE(ctx *fasthttp.RequestCtx, sub *watchSubscriber, backlog []watchRecord) {
+	ctx.SetContentType("text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.Response.Header.Set("Connection", "keep-alive")
+	ctx.Response.Header.Set("X-Accel-Buffering", "no")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+
+	// The server's write timeout applies to the whole response, so streams
+	// push the deadline forward before each write
+	conn := ctx.Conn()
+	extendDeadline := func() {
+		if conn != nil {
+			conn.SetWriteDeadline(time.Now().Add(2 * w.heartbeat()))
+		}
+	}
+
+	ctx.SetBodyStreamWriter(func(bw *bufio.Writer) {
+		defer w.unsubscribe(sub)
+
+		extendDeadline()
+		// Without a first write the response headers wait for the first
+		// change, and clients can't tell that the watch started
+		if _, err := bw.WriteString(": watching\n\n"); err != nil {
+			return
+		}
+		for _, rec := range backlog {
+			if err := writeSSEEvent(bw, rec.event()); err != nil {
+				return
+			}
+		}
+		if err := bw.Flush(); err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(w.heartbeat())
+		defer ticker.Stop()
+		for {
+			select {
+			case rec, ok := <-sub.events:
+				if !ok {
+					return
+				}
+				extendDeadline()
+				if err := writeSSEEvent(bw, rec.event()); err != nil {
+					return
+				}
+			case <-ticker.C:
+				extendDeadline()
+				if _, err := bw.WriteString(": heartbeat\n\n"); err != nil {
+					return
+				}
+			}
+			// A failed flush means the client went away
+			if err := bw.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func writeSSEEvent(bw *bufio.Writer, event WatchEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(bw, "id: %s\ndata: %s\n\n", event.ResourceVersion, data)
+	return err
+}
+
+func (w *FrontendPageWatcher) serveWebSocket(ctx *fasthttp.RequestCtx, sub *watchSubscriber, backlog []watchRecord) {
+	upgrader := websocket.FastHTTPUpgrader{CheckOrigin: w.checkOrigin}
+
+	err := upgrader.Upgrade(ctx, func(conn *websocket.Conn) {
+		defer conn.Close()
+		defer w.unsubscribe(sub)
+
+		// Drain incoming frames so that close and pong frames are processed
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for _, rec := range backlog {
+			if err := conn.WriteJSON(rec.event()); err != nil {
+				return
+			}
+		}
+
+		ticker := time.NewTicker(w.heartbeat())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-closed:
+				return
+			case rec, ok := <-sub.events:
+				if !ok {
+					conn.WriteControl(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "watch closed"),
+						time.Now().Add(time.Second))
+					return
+				}
+				if err := conn.WriteJSON(rec.event()); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(w.heartbeat())); err != nil {
+					return
+				}
+			}
+		}
+	})
+	if err != nil {
+		w.unsubscribe(sub)
+		RecordSpanError(ctx, err)
+		log.Error().Err(err).Msg("Failed to upgrade watch connection")
+	}
+}
+
+// checkOrigin allows WebSocket upgrades from clients that send no Origin, from
+// the server's own origin and from origins the CORS policy allows. Browsers
+// don't apply CORS to WebSockets, and the watch accepts credentials a browser
+// sends on its own (access_token links, client certificates), so any other
+// site could open a watch with them.
+func (w *FrontendPageWatcher) checkOrigin(ctx *fasthttp.RequestCtx) bool {
+	origin := string(ctx.Request.Header.Peek("Origin"))
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, string(ctx.Host())) {
+		return true
+	}
+	return w.CORS != nil && w.CORS.AllowOrigin(origin)
+}
+
+// WatchFrontendPages handles GET /api/frontendpages?watch=true
+func (r *Router) WatchFrontendPages(ctx *fasthttp.RequestCtx) {
+	if r.Watcher == nil {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusServiceUnavailable, "watch is not enabled"))
+		return
+	}
+	r.Watcher.ServeWatch(ctx)
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testPage(name, resourceVersion string, labels map[string]string) *frontendv1alpha1.FrontendPage {
+	return &frontendv1alpha1.FrontendPage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			ResourceVersion: resourceVersion,
+			Labels:          labels,
+		},
+		Spec: frontendv1alpha1.FrontendPageSpec{
+			Content:  "<h1>" + name + "</h1>",
+			Image:    "nginx:latest",
+			Replicas: 1,
+			Port:     80,
+		},
+	}
+}
+
+func filterFromQuery(t *testing.T, query string) watchFilter {
+	args := fasthttp.AcquireArgs()
+	defer fasthttp.ReleaseArgs(args)
+	args.Parse(query)
+	filter, err := parseWatchFilter(args)
+	require.NoError(t, err)
+	return filter
+}
+
+func TestFrontendPageWatcher_SnapshotAndLiveEvents(t *testing.T) {
+	w := NewFrontendPageWatcher("default")
+	w.OnAdd(testPage("b", "2", nil), true)
+	w.OnAdd(testPage("a", "1", nil), true)
+	w.OnAdd(testPage("other", "3", nil), true)
+	w.OnAdd(&frontendv1alpha1.FrontendPage{ObjectMeta: metav1.ObjectMeta{Name: "x", Namespace: "kube-system"}}, true)
+
+	sub, backlog, err := w.subscribe("", filterFromQuery(t, ""))
+	require.NoError(t, err)
+	defer w.unsubscribe(sub)
+
+	require.Len(t, backlog, 3)
+	require.Equal(t, "a", backlog[0].event().Object.Name)
+	require.Equal(t, WatchEventAdded, backlog[0].eventType)
+
+	w.OnUpdate(testPage("a", "1", nil), testPage("a", "4", nil))
+	rec := <-sub.events
+	require.Equal(t, WatchEventModified, rec.eventType)
+	require.Equal(t, "4", rec.event().ResourceVersion)
+}
+
+func TestFrontendPageWatcher_Resume(t *testing.T) {
+	w := NewFrontendPageWatcher("default")
+	w.OnAdd(testPage("a", "1", nil), false)
+	w.OnUpdate(testPage("a", "1", nil), testPage("a", "2", nil))
+	w.OnDelete(testPage("a", "3", nil))
+
+	_, backlog, err := w.subscribe("1", filterFromQuery(t, ""))
+	require.NoError(t, err)
+	require.Len(t, backlog, 2)
+	require.Equal(t, WatchEventModified, backlog[0].eventType)
+	require.Equal(t, WatchEventDeleted, backlog[1].eventType)
+
+	_, _, err = w.subscribe("42", filterFromQuery(t, ""))
+	require.ErrorIs(t, err, ErrWatchExpired)
+}
+
+func TestFrontendPageWatcher_Changes(t *testing.T) {
+	w := NewFrontendPageWatcher("default")
+	w.OnAdd(testPage("a", "1", nil), true)
+	w.OnAdd(testPage("b", "2", nil), false)
+	w.OnUpdate(testPage("a", "1", nil), testPage("a", "3", nil))
+
+	changes := w.Changes("", time.Time{})
+	require.Len(t, changes, 2, "pages from the initial list aren't changes")
+	require.Equal(t, "b", changes[0].Object.Name)
+	require.Equal(t, WatchEventModified, changes[1].Type)
+	require.Len(t, w.Changes("a", time.Time{}), 1)
+	require.Empty(t, w.Changes("", time.Now().Add(time.Minute)))
+}
+
+func TestFrontendPageWatcher_Filters(t *testing.T) {
+	w := NewFrontendPageWatcher("default")
+	sub, _, err := w.subscribe("", filterFromQuery(t, "labelSelector=tier%3Dweb&types=DELETED"))
+	require.NoError(t, err)
+	defer w.unsubscribe(sub)
+
+	w.OnAdd(testPage("a", "1", map[string]string{"tier": "web"}), false)
+	w.OnDelete(testPage("b", "2", map[string]string{"tier": "api"}))
+	w.OnDelete(testPage("a", "3", map[string]string{"tier": "web"}))
+
+	rec := <-sub.events
+	require.Equal(t, WatchEventDeleted, rec.eventType)
+	require.Equal(t, "a", rec.page.Name)
+	require.Empty(t, sub.events)
+
+	args := fasthttp.AcquireArgs()
+	defer fasthttp.ReleaseArgs(args)
+	args.Parse("types=RENAMED")
+	_, err = parseWatchFilter(args)
+	require.Error(t, err)
+}
+
+func startWatchServer(t *testing.T, w *FrontendPageWatcher) *fasthttputil.InmemoryListener {
+	ln := fasthttputil.NewInmemoryListener()
+	api := &FrontendPageApi{Namespace: "default", Watcher: w}
+	go fasthttp.Serve(ln, routerFor(api).ListFrontendPages)
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+func TestWatchFrontendPages_SSE(t *testing.T) {
+	w := NewFrontendPageWatcher("default")
+	w.HeartbeatInterval = 50 * time.Millisecond
+	w.OnAdd(testPage("a", "1", nil), true)
+	ln := startWatchServer(t, w)
+
+	conn, err := ln.Dial()
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /api/frontendpages?watch=true HTTP/1.1\r\nHost: test\r\n\r\n"))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	readEvent := func() WatchEvent {
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if strings.HasPrefix(line, "data: ") {
+				var event WatchEvent
+				require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+				return event
+			}
+		}
+	}
+
+	event := readEvent()
+	require.Equal(t, WatchEventAdded, event.Type)
+	require.Equal(t, "a", event.Object.Name)
+
+	w.OnUpdate(testPage("a", "1", nil), testPage("a", "2", nil))
+	event = readEvent()
+	require.Equal(t, WatchEventModified, event.Type)
+	require.Equal(t, "2", event.ResourceVersion)
+}
+
+func TestWatchFrontendPages_Gone(t *testing.T) {
+	api := &FrontendPageApi{Namespace: "default", Watcher: NewFrontendPageWatcher("default")}
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/frontendpages?watch=true&resourceVersion=7")
+	routerFor(api).ListFrontendPages(ctx)
+	require.Equal(t, fasthttp.StatusGone, ctx.Response.StatusCode())
+}
+
+func TestWatchFrontendPages_WebSocket(t *testing.T) {
+	w := NewFrontendPageWatcher("default")
+	w.OnAdd(testPage("a", "1", nil), true)
+	ln := startWatchServer(t, w)
+
+	dialer := websocket.Dialer{
+		NetDial: func(_, _ string) (net.Conn, error) { return ln.Dial() },
+	}
+	conn, _, err := dialer.Dial("ws://test/api/frontendpages?watch=true", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var event WatchEvent
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, WatchEventAdded, event.Type)
+
+	w.OnDelete(testPage("a", "2", nil))
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, WatchEventDeleted, event.Type)
+	require.Equal(t, "2", event.ResourceVersion)
+}
+
+func TestWatchFrontendPages_WebSocketOrigin(t *testing.T) {
+	w := NewFrontendPageWatcher("default")
+	ln := startWatchServer(t, w)
+	dialer := websocket.Dialer{
+		NetDial: func(_, _ string) (net.Conn, error) { return ln.Dial() },
+	}
+	dial := func(origin string) int {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, resp, err := dialer.Dial("ws://test/api/frontendpages?watch=true", header)
+		if err != nil {
+			require.ErrorIs(t, err, websocket.ErrBadHandshake)
+			return resp.StatusCode
+		}
+		conn.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusSwitchingProtocols, dial(""), "clients without an Origin aren't browsers")
+	require.Equal(t, http.StatusSwitchingProtocols, dial("http://test"))
+	require.Equal(t, http.StatusForbidden, dial("https://evil.example.com"), "cross-origin upgrades need CORS")
+
+	cors, err := NewCORS(CORSOptions{AllowedOrigins: []string{"https://*.example.com"}})
+	require.NoError(t, err)
+	w.CORS = cors
+	require.Equal(t, http.StatusSwitchingProtocols, dial("https://ui.example.com"))
+	require.Equal(t, http.StatusForbidden, dial("https://evil.example.org"))
+}
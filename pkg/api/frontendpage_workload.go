@@ -0,0 +1,401 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Annotations the Deployment controller sets on ReplicaSets
+const (
+	revisionAnnotation    = "deployment.kubernetes.io/revision"
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+)
+
+// ContainerStatusDoc is the state of a container in a pod serving a page
+type ContainerStatusDoc struct {
+	Name         string `json:"name"`
+	Image        string `json:"image"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restartCount"`
+	// State is waiting, running or terminated
+	State   string `json:"state"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// LastTermination is why the previous instance of the container ended
+	LastTermination string `json:"lastTermination,omitempty"`
+}
+
+// PodStatusDoc is the state of a pod serving a page
+type PodStatusDoc struct {
+	Name       string               `json:"name"`
+	Phase      string               `json:"phase"`
+	Ready      bool                 `json:"ready"`
+	Restarts   int32                `json:"restarts"`
+	Node       string               `json:"node,omitempty"`
+	ReplicaSet string               `json:"replicaSet,omitempty"`
+	Reason     string               `json:"reason,omitempty"`
+	Message    string               `json:"message,omitempty"`
+	StartTime  *time.Time           `json:"startTime,omitempty"`
+	Containers []ContainerStatusDoc `json:"containers"`
+}
+
+// RolloutRevisionDoc is a revision of the Deployment serving a page, kept
+// as a ReplicaSet
+type RolloutRevisionDoc struct {
+	Revision      int64     `json:"revision"`
+	ReplicaSet    string    `json:"replicaSet"`
+	Images        []string  `json:"images"`
+	Replicas      int32     `json:"replicas"`
+	ReadyReplicas int32     `json:"readyReplicas"`
+	ChangeCause   string    `json:"changeCause,omitempty"`
+	Created       time.Time `json:"created"`
+	Current       bool      `json:"current"`
+}
+
+// PodLogsOptions selects and bounds the logs of the pods serving a page
+type PodLogsOptions struct {
+	// Pod and Container narrow the logs down, all pods and containers
+	// when empty
+	Pod       string
+	Container string
+	TailLines int64
+	// LimitBytes bounds the logs of each container
+	LimitBytes int64
+	// Previous reads the logs of the last terminated containers
+	Previous bool
+}
+
+// PodLogsDoc is the tail of the logs of a container serving a page
+type PodLogsDoc struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Logs      string `json:"logs"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ObjectRef names an object in the page's namespace
+type ObjectRef struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// FrontendPageDeletePlan is what deleting a page removes: the page and,
+// through garbage collection, the objects it controls
+type FrontendPageDeletePlan struct {
+	Name    string            `json:"name"`
+	UID     types.UID         `json:"uid"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Deletes []ObjectRef       `json:"deletes"`
+}
+
+// pageWorkload is a page and the objects it controls: the Deployment,
+// ConfigMap and Service the operator generates, and the ReplicaSets and
+// Pods of the Deployment. Ownership follows controller references, not
+// names, so objects named like the page but owned by something else are
+// left out.
+type pageWorkload struct {
+	page        *frontendv1alpha1.FrontendPage
+	deployments []appsv1.Deployment
+	replicaSets []appsv1.ReplicaSet
+	pods        []corev1.Pod
+	objects     map[ObjectRef]bool
+}
+
+func (w *pageWorkload) add(kind, name string) {
+	w.objects[ObjectRef{Kind: kind, Name: name}] = true
+}
+
+// owns reports whether the workload has an object, the page included
+func (w *pageWorkload) owns(kind, name string) bool {
+	return w.objects[ObjectRef{Kind: kind, Name: name}]
+}
+
+// pageWorkload resolves the objects controlled by the named page. They are
+// read from the API server, the manager doesn't cache ReplicaSets and Pods.
+func (api *FrontendPageApi) pageWorkload(ctx context.Context, name string) (*pageWorkload, error) {
+	if name == "" {
+		return nil, Required("name")
+	}
+	page := &frontendv1alpha1.FrontendPage{}
+	if err := api.K8SClient.Get(ctx, client.ObjectKey{Namespace: api.Namespace, Name: name}, page); err != nil {
+		return nil, err
+	}
+	w := &pageWorkload{page: page, objects: map[ObjectRef]bool{}}
+	w.add("FrontendPage", page.Name)
+	reader := api.apiReader()
+	inNamespace := client.InNamespace(api.Namespace)
+
+	deployments := &appsv1.DeploymentList{}
+	if err := reader.List(ctx, deployments, inNamespace); err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments.Items {
+		if metav1.IsControlledBy(&deployment, page) {
+			w.deployments = append(w.deployments, deployment)
+			w.add("Deployment", deployment.Name)
+		}
+	}
+	services := &corev1.ServiceList{}
+	if err := reader.List(ctx, services, inNamespace); err != nil {
+		return nil, err
+	}
+	for _, service := range services.Items {
+		if metav1.IsControlledBy(&service, page) {
+			w.add("Service", service.Name)
+		}
+	}
+	configMaps := &corev1.ConfigMapList{}
+	if err := reader.List(ctx, configMaps, inNamespace); err != nil {
+		return nil, err
+	}
+	for _, configMap := range configMaps.Items {
+		if metav1.IsControlledBy(&configMap, page) {
+			w.add("ConfigMap", configMap.Name)
+		}
+	}
+	if len(w.deployments) == 0 {
+		return w, nil
+	}
+
+	replicaSets := &appsv1.ReplicaSetList{}
+	if err := reader.List(ctx, replicaSets, inNamespace); err != nil {
+		return nil, err
+	}
+	for _, replicaSet := range replicaSets.Items {
+		for i := range w.deployments {
+			if metav1.IsControlledBy(&replicaSet, &w.deployments[i]) {
+				w.replicaSets = append(w.replicaSets, replicaSet)
+				w.add("ReplicaSet", replicaSet.Name)
+				break
+			}
+		}
+	}
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods, inNamespace); err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		for i := range w.replicaSets {
+			if metav1.IsControlledBy(&pod, &w.replicaSets[i]) {
+				w.pods = append(w.pods, pod)
+				w.add("Pod", pod.Name)
+				break
+			}
+		}
+	}
+	sort.Slice(w.pods, func(i, j int) bool { return w.pods[i].Name < w.pods[j].Name })
+	return w, nil
+}
+
+// workloadKindOrder lists the objects of a plan from the page down
+var workloadKindOrder = map[string]int{"FrontendPage": 0, "Deployment": 1, "ConfigMap": 2, "Service": 3, "ReplicaSet": 4, "Pod": 5}
+
+// FrontendPageDeletePlanRaw returns the objects deleting a page would
+// remove, without deleting anything (for MCP usage)
+func (api *FrontendPageApi) FrontendPageDeletePlanRaw(ctx context.Context, name string) (*FrontendPageDeletePlan, error) {
+	w, err := api.pageWorkload(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	plan := &FrontendPageDeletePlan{Name: w.page.Name, UID: w.page.UID, Labels: w.page.Labels, Deletes: []ObjectRef{}}
+	for ref := range w.objects {
+		plan.Deletes = append(plan.Deletes, ref)
+	}
+	sort.Slice(plan.Deletes, func(i, j int) bool {
+		a, b := plan.Deletes[i], plan.Deletes[j]
+		if a.Kind != b.Kind {
+			return workloadKindOrder[a.Kind] < workloadKindOrder[b.Kind]
+		}
+		return a.Name < b.Name
+	})
+	return plan, nil
+}
+
+// FrontendPagePodsRaw returns the state of the pods serving a page (for
+// MCP usage)
+func (api *FrontendPageApi) FrontendPagePodsRaw(ctx context.Context, name string) ([]PodStatusDoc, error) {
+	w, err := api.pageWorkload(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]PodStatusDoc, 0, len(w.pods))
+	for i := range w.pods {
+		pods = append(pods, podStatusDoc(&w.pods[i]))
+	}
+	return pods, nil
+}
+
+func podStatusDoc(pod *corev1.Pod) PodStatusDoc {
+	doc := PodStatusDoc{
+		Name:       pod.Name,
+		Phase:      string(pod.Status.Phase),
+		Node:       pod.Spec.NodeName,
+		Reason:     pod.Status.Reason,
+		Message:    pod.Status.Message,
+		Containers: []ContainerStatusDoc{},
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		doc.ReplicaSet = owner.Name
+	}
+	if pod.Status.StartTime != nil {
+		start := pod.Status.StartTime.Time
+		doc.StartTime = &start
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			doc.Ready = c.Status == corev1.ConditionTrue
+		}
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		doc.Restarts += status.RestartCount
+		container := ContainerStatusDoc{
+			Name:         status.Name,
+			Image:        status.Image,
+			Ready:        status.Ready,
+			RestartCount: status.RestartCount,
+		}
+		switch state := status.State; {
+		case state.Waiting != nil:
+			container.State = "waiting"
+			container.Reason = state.Waiting.Reason
+			container.Message = state.Waiting.Message
+		case state.Terminated != nil:
+			container.State = "terminated"
+			container.Reason = state.Terminated.Reason
+			container.Message = state.Terminated.Message
+		case state.Running != nil:
+			container.State = "running"
+		}
+		if last := status.LastTerminationState.Terminated; last != nil {
+			container.LastTermination = fmt.Sprintf("%s (exit code %d) at %s",
+				last.Reason, last.ExitCode, last.FinishedAt.UTC().Format(time.RFC3339))
+		}
+		doc.Containers = append(doc.Containers, container)
+	}
+	return doc
+}
+
+// FrontendPageRolloutHistoryRaw returns the revisions of the Deployment
+// serving a page, newest first (for MCP usage)
+func (api *FrontendPageApi) FrontendPageRolloutHistoryRaw(ctx context.Context, name string) ([]RolloutRevisionDoc, error) {
+	w, err := api.pageWorkload(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]string{}
+	for _, deployment := range w.deployments {
+		current[deployment.Name] = deployment.Annotations[revisionAnnotation]
+	}
+	revisions := make([]RolloutRevisionDoc, 0, len(w.replicaSets))
+	for _, replicaSet := range w.replicaSets {
+		revision := replicaSet.Annotations[revisionAnnotation]
+		number, _ := strconv.ParseInt(revision, 10, 64)
+		doc := RolloutRevisionDoc{
+			Revision:      number,
+			ReplicaSet:    replicaSet.Name,
+			Images:        []string{},
+			Replicas:      replicaSet.Status.Replicas,
+			ReadyReplicas: replicaSet.Status.ReadyReplicas,
+			ChangeCause:   replicaSet.Annotations[changeCauseAnnotation],
+			Created:       replicaSet.CreationTimestamp.Time,
+		}
+		if owner := metav1.GetControllerOf(&replicaSet); owner != nil && revision != "" {
+			doc.Current = current[owner.Name] == revision
+		}
+		for _, container := range replicaSet.Spec.Template.Spec.Containers {
+			doc.Images = append(doc.Images, container.Image)
+		}
+		revisions = append(revisions, doc)
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].Revision > revisions[j].Revision
+	})
+	return revisions, nil
+}
+
+// FrontendPageLogsRaw returns the tail of the logs of the pods serving a
+// page, bounded by opts. A container whose logs can't be read gets an
+// error instead, so one crashed pod doesn't hide the others (for MCP usage).
+func (api *FrontendPageApi) FrontendPageLogsRaw(ctx context.Context, name string, opts PodLogsOptions) ([]PodLogsDoc, error) {
+	if api.Clientset == nil {
+		return nil, NewProblem(http.StatusNotImplemented, "pod logs are not available, the server has no Kubernetes clientset")
+	}
+	w, err := api.pageWorkload(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	pods := w.pods
+	if opts.Pod != "" {
+		pods = nil
+		for _, pod := range w.pods {
+			if pod.Name == opts.Pod {
+				pods = append(pods, pod)
+			}
+		}
+		if len(pods) == 0 {
+			return nil, NewProblem(http.StatusNotFound, fmt.Sprintf("pod %s does not serve frontend page %s", opts.Pod, name))
+		}
+	}
+
+	logs := []PodLogsDoc{}
+	for _, pod := range pods {
+		found := false
+		for _, container := range pod.Spec.Containers {
+			if opts.Container != "" && container.Name != opts.Container {
+				continue
+			}
+			found = true
+			logs = append(logs, api.containerLogs(ctx, pod.Name, container.Name, opts))
+		}
+		if opts.Pod != "" && !found {
+			return nil, NewProblem(http.StatusNotFound, fmt.Sprintf("pod %s has no container %s", pod.Name, opts.Container))
+		}
+	}
+	return logs, nil
+}
+
+func (api *FrontendPageApi) containerLogs(ctx context.Context, pod, container string, opts PodLogsOptions) PodLogsDoc {
+	doc := PodLogsDoc{Pod: pod, Container: container}
+	logOptions := &corev1.PodLogOptions{Container: container, Previous: opts.Previous}
+	if opts.TailLines > 0 {
+		logOptions.TailLines = &opts.TailLines
+	}
+	if opts.LimitBytes > 0 {
+		logOptions.LimitBytes = &opts.LimitBytes
+	}
+	stream, err := api.Clientset.CoreV1().Pods(api.Namespace).GetLogs(pod, logOptions).Stream(ctx)
+	if err != nil {
+		doc.Error = err.Error()
+		return doc
+	}
+	defer stream.Close()
+	reader := io.Reader(stream)
+	if opts.LimitBytes > 0 {
+		// The API server honours limitBytes, this keeps a misbehaving one
+		// from flooding the caller
+		reader = io.LimitReader(stream, opts.LimitBytes+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		doc.Error = err.Error()
+	}
+	if opts.LimitBytes > 0 && int64(len(data)) >= opts.LimitBytes {
+		data = data[:opts.LimitBytes]
+		doc.Truncated = true
+	}
+	doc.Logs = string(data)
+	return doc
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// controlledBy returns object meta with a controller reference to owner
+func controlledBy(name string, uid types.UID, owner client.Object, kind string) metav1.ObjectMeta {
+	isController := true
+	return metav1.ObjectMeta{Name: name, Namespace: "default", UID: uid, OwnerReferences: []metav1.OwnerReference{{
+		Kind: kind, Name: owner.GetName(), UID: owner.GetUID(), Controller: &isController,
+	}}}
+}
+
+// pageWorkloadObjects is a page rolled out twice, with one pod running
+// the second revision, and a pod named like it that belongs to another
+// workload
+func pageWorkloadObjects() []client.Object {
+	page := &frontendv1alpha1.FrontendPage{ObjectMeta: metav1.ObjectMeta{Name: "page", Namespace: "default", UID: "page-uid"}}
+	deployment := &appsv1.Deployment{ObjectMeta: controlledBy("page", "deploy-uid", page, "FrontendPage")}
+	deployment.Annotations = map[string]string{revisionAnnotation: "2"}
+	configMap := &corev1.ConfigMap{ObjectMeta: controlledBy("page", "cm-uid", page, "FrontendPage")}
+	replicaSet := func(name string, uid types.UID, revision, image string) *appsv1.ReplicaSet {
+		rs := &appsv1.ReplicaSet{ObjectMeta: controlledBy(name, uid, deployment, "Deployment")}
+		rs.Annotations = map[string]string{revisionAnnotation: revision}
+		rs.Spec.Template.Spec.Containers = []corev1.Container{{Name: "frontend", Image: image}}
+		return rs
+	}
+	old := replicaSet("page-5b8f7c9d4", "rs1-uid", "1", "nginx:1.25")
+	current := replicaSet("page-6d4cf56db6", "rs2-uid", "2", "nginx:1.27")
+	current.Annotations[changeCauseAnnotation] = "image update"
+	current.Status.Replicas = 1
+
+	pod := &corev1.Pod{ObjectMeta: controlledBy("page-6d4cf56db6-x2x7q", "pod-uid", current, "ReplicaSet")}
+	pod.Spec.Containers = []corev1.Container{{Name: "frontend", Image: "nginx:1.27"}}
+	pod.Status = corev1.PodStatus{
+		Phase:      corev1.PodRunning,
+		Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}},
+		ContainerStatuses: []corev1.ContainerStatus{{
+			Name:         "frontend",
+			Image:        "nginx:1.27",
+			RestartCount: 3,
+			State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				Reason: "Error", ExitCode: 1, FinishedAt: metav1.NewTime(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)),
+			}},
+		}},
+	}
+	other := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "page-other", Namespace: "default", UID: "other-uid"}}
+	stranger := &corev1.Pod{ObjectMeta: controlledBy("page-other-abcde", "stranger-uid", other, "ReplicaSet")}
+	return []client.Object{page, deployment, configMap, old, current, pod, other, stranger}
+}
+
+func TestPageWorkload(t *testing.T) {
+	api := newPreviewApi(t, pageWorkloadObjects()...)
+	ctx := context.Background()
+
+	w, err := api.pageWorkload(ctx, "page")
+	require.NoError(t, err)
+	for _, ref := range []ObjectRef{{"FrontendPage", "page"}, {"Deployment", "page"}, {"ConfigMap", "page"},
+		{"ReplicaSet", "page-5b8f7c9d4"}, {"Pod", "page-6d4cf56db6-x2x7q"}} {
+		require.True(t, w.owns(ref.Kind, ref.Name), ref)
+	}
+	require.False(t, w.owns("Pod", "page-other-abcde"), "ownership follows controller references, not names")
+	require.False(t, w.owns("Service", "page"))
+
+	_, err = api.pageWorkload(ctx, "missing")
+	require.Error(t, err)
+	_, err = api.pageWorkload(ctx, "")
+	require.Error(t, err)
+}
+
+func TestFrontendPagePodsRaw(t *testing.T) {
+	api := newPreviewApi(t, pageWorkloadObjects()...)
+
+	pods, err := api.FrontendPagePodsRaw(context.Background(), "page")
+	require.NoError(t, err)
+	require.Len(t, pods, 1)
+	pod := pods[0]
+	require.Equal(t, "page-6d4cf56db6-x2x7q", pod.Name)
+	require.Equal(t, "Running", pod.Phase)
+	require.False(t, pod.Ready)
+	require.Equal(t, int32(3), pod.Restarts)
+	require.Equal(t, "page-6d4cf56db6", pod.ReplicaSet)
+	require.Equal(t, "waiting", pod.Containers[0].State)
+	require.Equal(t, "CrashLoopBackOff", pod.Containers[0].Reason)
+	require.Equal(t, "Error (exit code 1) at 2026-01-02T03:04:05Z", pod.Containers[0].LastTermination)
+}
+
+func TestFrontendPageRolloutHistoryRaw(t *testing.T) {
+	api := newPreviewApi(t, pageWorkloadObjects()...)
+
+	revisions, err := api.FrontendPageRolloutHistoryRaw(context.Background(), "page")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	require.Equal(t, int64(2), revisions[0].Revision)
+	require.True(t, revisions[0].Current)
+	require.Equal(t, []string{"nginx:1.27"}, revisions[0].Images)
+	require.Equal(t, "image update", revisions[0].ChangeCause)
+	require.Equal(t, int64(1), revisions[1].Revision)
+	require.False(t, revisions[1].Current)
+}
+
+func TestFrontendPageLogsRaw(t *testing.T) {
+	api := newPreviewApi(t, pageWorkloadObjects()...)
+	ctx := context.Background()
+
+	_, err := api.FrontendPageLogsRaw(ctx, "page", PodLogsOptions{})
+	require.Equal(t, 501, ProblemFromError(err).Status)
+
+	// The fake clientset answers every log request with "fake logs"
+	api.Clientset = k8sfake.NewSimpleClientset()
+	logs, err := api.FrontendPageLogsRaw(ctx, "page", PodLogsOptions{TailLines: 10})
+	require.NoError(t, err)
+	require.Equal(t, []PodLogsDoc{{Pod: "page-6d4cf56db6-x2x7q", Container: "frontend", Logs: "fake logs"}}, logs)
+
+	logs, err = api.FrontendPageLogsRaw(ctx, "page", PodLogsOptions{LimitBytes: 4})
+	require.NoError(t, err)
+	require.Equal(t, "fake", logs[0].Logs)
+	require.True(t, logs[0].Truncated)
+
+	_, err = api.FrontendPageLogsRaw(ctx, "page", PodLogsOptions{Pod: "page-other-abcde"})
+	require.Equal(t, 404, ProblemFromError(err).Status, "pods of other workloads are refused")
+	_, err = api.FrontendPageLogsRaw(ctx, "page", PodLogsOptions{Pod: "page-6d4cf56db6-x2x7q", Container: "sidecar"})
+	require.Equal(t, 404, ProblemFromError(err).Status)
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/valyala/fasthttp"
+)
+
+// HealthStatus is the response of the health check
+type HealthStatus struct {
+	Status string `json:"status"`
+}
+
+// HealthCheck serves GET /health
+func HealthCheck(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	json.NewEncoder(ctx).Encode(HealthStatus{Status: "ok"})
+}
+
+// ListDeployments serves GET /deployments
+func (r *Router) ListDeployments(ctx *fasthttp.RequestCtx) {
+	names := []string{}
+	if r.DeploymentNames != nil {
+		names = append(names, r.DeploymentNames()...)
+	}
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	json.NewEncoder(ctx).Encode(names)
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// maxJWKSSize limits how much of a JWKS response is read
+const maxJWKSSize = 1 << 20
+
+// jwk is the subset of RFC 7517 fields needed for signature keys
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type publicKey struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// parseJWKS reads the RS256, ES256 and EdDSA signature keys of a JSON Web Key
+// Set. Other keys, such as encryption keys, are skipped.
+func parseJWKS(data []byte) (map[string]publicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse jwks: %w", err)
+	}
+	keys := map[string]publicKey{}
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks key %d (kid %q): %w", i, k.Kid, err)
+		}
+		if key.key == nil {
+			continue
+		}
+		if k.Alg != "" && k.Alg != key.alg {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks has no supported signature keys")
+	}
+	return keys, nil
+}
+
+func decodeSegment(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(value)
+}
+
+// publicKey returns a zero publicKey for key types that aren't supported
+func (k *jwk) publicKey() (publicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeSegment(k.N)
+		if err != nil {
+			return publicKey{}, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeSegment(k.E)
+		if err != nil {
+			return publicKey{}, fmt.Errorf("invalid e: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return publicKey{}, errors.New("invalid exponent")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+		if key.N.BitLen() < 2048 {
+			return publicKey{}, errors.New("rsa keys must be at least 2048 bits")
+		}
+		return publicKey{alg: jwt.SigningMethodRS256.Alg(), key: key}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return publicKey{}, nil
+		}
+		x, err := decodeSegment(k.X)
+		if err != nil || len(x) != 32 {
+			return publicKey{}, errors.New("invalid x")
+		}
+		y, err := decodeSegment(k.Y)
+		if err != nil || len(y) != 32 {
+			return publicKey{}, errors.New("invalid y")
+		}
+		// Let crypto/ecdh check that the point is on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return publicKey{}, fmt.Errorf("invalid point: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return publicKey{alg: jwt.SigningMethodES256.Alg(), key: key}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return publicKey{}, nil
+		}
+		x, err := decodeSegment(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, errors.New("invalid x")
+		}
+		return publicKey{alg: jwt.SigningMethodEdDSA.Alg(), key: ed25519.PublicKey(x)}, nil
+	default:
+		return publicKey{}, nil
+	}
+}
+
+// JWKSSource loads a raw JWKS document
+type JWKSSource func(ctx context.Context) ([]byte, error)
+
+// JWKSFile reads the key set from a file
+func JWKSFile(path string) JWKSSource {
+	return func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}
+}
+
+// JWKSURL fetches the key set over HTTP
+func JWKSURL(url string) JWKSSource {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	return func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetch %s: unexpected status %s", url, resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	}
+}
+
+// KeySet holds the keys of a JWKS and refreshes them from their source
+type KeySet struct {
+	source JWKSSource
+	// MinRefreshInterval limits refreshes triggered by tokens with an
+	// unknown kid
+	MinRefreshInterval time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]publicKey
+	lastRefresh time.Time
+}
+
+// NewKeySet creates a key set. Call Refresh to load the keys.
+func NewKeySet(source JWKSSource) *KeySet {
+	return &KeySet{source: source, MinRefreshInterval: 10 * time.Second}
+}
+
+// Refresh reloads the keys. On failure the current keys are kept.
+func (k *KeySet) Refresh(ctx context.Context) error {
+	k.mu.Lock()
+	k.lastRefresh = time.Now()
+	k.mu.Unlock()
+
+	data, err := k.source(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+	return nil
+}
+
+// Run refreshes the keys every interval until ctx is done
+func (k *KeySet) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Refresh(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to refresh JWKS, keeping the current keys")
+			}
+		}
+	}
+}
+
+func (k *KeySet) lookup(kid string) (publicKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// Keyfunc selects the key for a token by its kid header. An unknown kid
+// triggers a refresh, so rotated keys are picked up before the next
+// scheduled refresh.
+func (k *KeySet) Keyfunc(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.lookup(kid)
+	if !ok {
+		k.mu.RLock()
+		recent := time.Since(k.lastRefresh) < k.MinRefreshInterval
+		k.mu.RUnlock()
+		if !recent {
+			if err := k.Refresh(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to refresh JWKS")
+			}
+			key, ok = k.lookup(kid)
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("no key with kid %q", kid)
+	}
+	if key.alg != token.Method.Alg() {
+		return nil, fmt.Errorf("key %q is for %s, not %s", kid, key.alg, token.Method.Alg())
+	}
+	return key.key, nil
+}
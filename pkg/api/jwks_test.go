@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey is a signing key of the local JWKS stand-in
+type testKey struct {
+	kid    string
+	method jwt.SigningMethod
+	signer crypto.Signer
+}
+
+func (k testKey) jwk() map[string]string {
+	enc := base64.RawURLEncoding.EncodeToString
+	switch pub := k.signer.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": k.kid, "use": "sig", "alg": "RS256",
+			"n": enc(pub.N.Bytes()), "e": enc(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": k.kid, "crv": "P-256",
+			"x": enc(pub.X.FillBytes(make([]byte, 32))), "y": enc(pub.Y.FillBytes(make([]byte, 32)))}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": k.kid, "crv": "Ed25519", "x": enc(pub)}
+	}
+	panic("unsupported key")
+}
+
+func (k testKey) sign(t *testing.T, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.kid
+	signed, err := token.SignedString(k.signer)
+	require.NoError(t, err)
+	return signed
+}
+
+func newTestKeys(t *testing.T) []testKey {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return []testKey{
+		{"rsa-1", jwt.SigningMethodRS256, rsaKey},
+		{"ec-1", jwt.SigningMethodES256, ecKey},
+		{"ed-1", jwt.SigningMethodEdDSA, edKey},
+	}
+}
+
+func jwksDocument(t *testing.T, keys ...testKey) []byte {
+	set := map[string][]map[string]string{"keys": {}}
+	for _, key := range keys {
+		set["keys"] = append(set["keys"], key.jwk())
+	}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	return data
+}
+
+// jwksServer serves a key set that tests can rotate
+type jwksServer struct {
+	mu       sync.Mutex
+	document []byte
+	requests int
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.document)
+}
+
+func (s *jwksServer) set(document []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.document = document
+}
+
+func oidcClaims(issuer, audience string, expires time.Time) jwt.Claims {
+	return Claims{
+		Groups: []string{"sso-editors"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "sso-user",
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+	}
+}
+
+func TestVerifyToken_OIDC(t *testing.T) {
+	t.Parallel()
+	keys := newTestKeys(t)
+	stub := &jwksServer{document: jwksDocument(t, keys...)}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	keySet := NewKeySet(JWKSURL(server.URL))
+	require.NoError(t, keySet.Refresh(context.Background()))
+	config := NewConfig()
+	config.OIDC = &OIDCConfig{KeySet: keySet, Issuer: "https://sso.example.com", Audience: "frontend-api"}
+	config.JWTLeeway = time.Minute
+
+	ctx := context.Background()
+	for _, key := range keys {
+		t.Run(key.method.Alg(), func(t *testing.T) {
+			claims, err := config.verifyToken(ctx, key.sign(t, oidcClaims("https://sso.example.com", "frontend-api", time.Now().Add(time.Hour))))
+			require.NoError(t, err)
+			require.Equal(t, "sso-user", claims.Subject)
+			require.Equal(t, []string{"sso-editors"}, claims.Groups)
+		})
+	}
+
+	// Within the leeway
+	_, err := config.verifyToken(ctx, keys[0].sign(t, oidcClaims("https://sso.example.com", "frontend-api", time.Now().Add(-30*time.Second))))
+	require.NoError(t, err)
+
+	for name, claims := range map[string]jwt.Claims{
+		"expired":        oidcClaims("https://sso.example.com", "frontend-api", time.Now().Add(-time.Hour)),
+		"wrong issuer":   oidcClaims("https://evil.example.com", "frontend-api", time.Now().Add(time.Hour)),
+		"wrong audience": oidcClaims("https://sso.example.com", "other-api", time.Now().Add(time.Hour)),
+	} {
+		_, err := config.verifyToken(ctx, keys[0].sign(t, claims))
+		require.Error(t, err, name)
+	}
+
+	// A key from one algorithm can't verify another
+	mismatched := keys[1]
+	mismatched.kid = keys[0].kid
+	_, err = config.verifyToken(ctx, mismatched.sign(t, oidcClaims("https://sso.example.com", "frontend-api", time.Now().Add(time.Hour))))
+	require.Error(t, err)
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	t.Parallel()
+	keys := newTestKeys(t)
+	stub := &jwksServer{document: jwksDocument(t, keys[0])}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	keySet := NewKeySet(JWKSURL(server.URL))
+	keySet.MinRefreshInterval = 0
+	require.NoError(t, keySet.Refresh(context.Background()))
+	config := NewConfig()
+	config.OIDC = &OIDCConfig{KeySet: keySet}
+
+	rotated := keys[2]
+	token := rotated.sign(t, oidcClaims("", "", time.Now().Add(time.Hour)))
+	_, err := config.verifyToken(context.Background(), token)
+	require.Error(t, err)
+
+	// A token with an unknown kid refreshes the key set
+	stub.set(jwksDocument(t, keys[0], rotated))
+	_, err = config.verifyToken(context.Background(), token)
+	require.NoError(t, err)
+	require.Equal(t, 3, stub.requests)
+}
+
+func TestKeySet_File(t *testing.T) {
+	t.Parallel()
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwksDocument(t, keys[1]), 0o600))
+
+	keySet := NewKeySet(JWKSFile(path))
+	require.NoError(t, keySet.Refresh(context.Background()))
+
+	// Only a single key may be used without a kid
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, oidcClaims("", "", time.Now().Add(time.Hour))).SignedString(keys[1].signer)
+	require.NoError(t, err)
+	config := NewConfig()
+	config.OIDC = &OIDCConfig{KeySet: keySet}
+	_, err = config.verifyToken(context.Background(), token)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"keys":[{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"}]}`), 0o600))
+	require.ErrorContains(t, keySet.Refresh(context.Background()), "no supported signature keys")
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/certs"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// newTestCA returns a CA and a function that issues client certificates
+// signed by it
+func newTestCA(t *testing.T) ([]byte, func(cn string, groups ...string) tls.Certificate) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	issue := func(cn string, groups ...string) tls.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(time.Now().UnixNano()),
+			Subject:      pkix.Name{CommonName: cn, Organization: groups},
+			NotBefore:    time.Now().Add(-time.Minute),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), issue
+}
+
+func TestJwtMiddleware_ClientCertificate(t *testing.T) {
+	caPEM, issue := newTestCA(t)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0o600))
+
+	reloader, err := certs.NewReloader(certs.Options{
+		SelfSigned:   true,
+		Hosts:        []string{"127.0.0.1"},
+		ClientCAFile: caFile,
+		ClientAuth:   certs.ClientAuthRequest,
+	})
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fasthttp.Server{Handler: NewConfig().JwtMiddleware(func(ctx *fasthttp.RequestCtx) {
+		json.NewEncoder(ctx).Encode(IdentityFromRequest(ctx))
+	})}
+	go server.Serve(tls.NewListener(ln, reloader.TLSConfig()))
+	t.Cleanup(func() { server.Shutdown() })
+
+	get := func(clientCerts ...tls.Certificate) *http.Response {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       clientCerts,
+		}}}
+		resp, err := client.Get("https://" + ln.Addr().String() + "/api/frontendpages")
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := get(issue("alice", "editors"))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var identity Identity
+	require.NoError(t, json.Unmarshal(body, &identity))
+	require.Equal(t, "alice", identity.Subject)
+	require.Equal(t, []string{"editors"}, identity.Groups)
+
+	// Without a certificate the request still needs a token
+	require.Equal(t, http.StatusUnauthorized, get().StatusCode)
+	// and a certificate without a common name doesn't identify anyone
+	require.Equal(t, http.StatusUnauthorized, get(issue("")).StatusCode)
+}
@@ -1,31 +1,231 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/valyala/fasthttp"
 )
 
-var JWTSecret string
+// OIDCConfig describes tokens issued by an external identity provider
+type OIDCConfig struct {
+	KeySet   *KeySet
+	Issuer   string
+	Audience string
+}
+
+var oidcMethods = []string{
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodES256.Alg(),
+	jwt.SigningMethodEdDSA.Alg(),
+}
+
+// bearerToken extracts the token from the Authorization header. Browsers can't
+// set headers on EventSource and WebSocket connections, so watch requests may
+// pass it in the access_token query parameter instead.
+func bearerToken(ctx *fasthttp.RequestCtx) (string, bool) {
+	header := string(ctx.Request.Header.Peek("Authorization"))
+	if strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer "), true
+	}
+	if ctx.QueryArgs().GetBool("watch") {
+		if token := string(ctx.QueryArgs().Peek("access_token")); token != "" {
+			return token, true
+		}
+	}
+	return "", false
+}
+
+// basicAuth returns the credentials of an HTTP Basic Authorization header
+func basicAuth(ctx *fasthttp.RequestCtx) (string, string, bool) {
+	header := string(ctx.Request.Header.Peek("Authorization"))
+	if !strings.HasPrefix(header, "Basic ") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+	if err != nil {
+		return "", "", false
+	}
+	username, password, ok := strings.Cut(string(decoded), ":")
+	return username, password, ok
+}
+
+// verifyToken checks the signature of a token with JWTSecret (HS256) or the
+// OIDC key set (RS256, ES256, EdDSA), then validates its claims against the
+// issuer and audience of whichever signed it
+func (c *Config) verifyToken(ctx context.Context, tokenString string) (*Claims, error) {
+	methods := []string{jwt.SigningMethodHS256.Alg()}
+	if c.OIDC != nil {
+		methods = append(methods, oidcMethods...)
+	}
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method == jwt.SigningMethodHS256 {
+			if c.JWTSecret == "" {
+				return nil, errors.New("no secret configured")
+			}
+			return []byte(c.JWTSecret), nil
+		}
+		return c.OIDC.KeySet.Keyfunc(ctx, token)
+	}, jwt.WithValidMethods(methods), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	issuer, audience := c.JWTIssuer, c.JWTAudience
+	if token.Method != jwt.SigningMethodHS256 {
+		issuer, audience = c.OIDC.Issuer, c.OIDC.Audience
+	}
+	opts := []jwt.ParserOption{jwt.WithLeeway(c.JWTLeeway), jwt.WithExpirationRequired()}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	if err := jwt.NewValidator(opts...).Validate(claims); err != nil {
+		return nil, err
+	}
+	if claims.ID != "" && c.Revocations.IsRevoked(claims.ID) {
+		return nil, errors.New("token has been revoked")
+	}
+	if claims.Session != "" && c.Revocations.IsRevoked(sessionRevocationKey(claims.Session)) {
+		return nil, errors.New("token session has been revoked")
+	}
+	return claims, nil
+}
+
+const identityKey = "identity"
+
+// identityFromClientCert maps a verified client certificate to an identity,
+// with the common name as subject and the organizations as groups
+func identityFromClientCert(state *tls.ConnectionState) *Identity {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	subject := state.VerifiedChains[0][0].Subject
+	if subject.CommonName == "" {
+		return nil
+	}
+	return &Identity{Subject: subject.CommonName, Groups: subject.Organization}
+}
+
+// IdentityFromRequest returns the caller authenticated by JwtMiddleware
+func IdentityFromRequest(ctx *fasthttp.RequestCtx) *Identity {
+	identity, _ := ctx.UserValue(identityKey).(*Identity)
+	return identity
+}
+
+type identityContextKey struct{}
 
-func JwtMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+// WithIdentity returns a context carrying the authenticated caller
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// IdentityFromContext returns the caller stored with WithIdentity. The
+// fasthttp adaptor makes the request the context of net/http handlers, so
+// the caller authenticated by JwtMiddleware is found too.
+func IdentityFromContext(ctx context.Context) *Identity {
+	if identity, ok := ctx.Value(identityContextKey{}).(*Identity); ok {
+		return identity
+	}
+	if requestCtx, ok := ctx.(*fasthttp.RequestCtx); ok {
+		return IdentityFromRequest(requestCtx)
+	}
+	return nil
+}
+
+// authenticate checks an API key, or else a bearer token. It returns a nil
+// identity and no problem when neither is given.
+func (c *Config) authenticate(ctx context.Context, apiKey, token string) (*Identity, *Problem) {
+	if apiKey != "" {
+		identity, err := c.authenticateAPIKey(ctx, apiKey)
+		if err != nil {
+			return nil, NewProblem(fasthttp.StatusUnauthorized, "invalid or expired API key")
+		}
+		return identity, nil
+	}
+	if token == "" {
+		return nil, nil
+	}
+	claims, err := c.verifyToken(ctx, token)
+	if err == nil && claims.TokenUse == TokenUseRefresh {
+		err = errors.New("refresh tokens can't be used for API requests")
+	}
+	if err != nil {
+		return nil, NewProblem(fasthttp.StatusUnauthorized, "invalid or expired token")
+	}
+	return &Identity{
+		Subject:    claims.Subject,
+		Groups:     claims.Groups,
+		Namespaces: claims.Namespaces,
+	}, nil
+}
+
+// JwtMiddleware checks the X-API-Key header, the bearer token or a verified
+// client certificate, in that order, and stores the caller's subject, groups
+// and namespaces for IdentityFromRequest
+func (c *Config) JwtMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
-		header := string(ctx.Request.Header.Peek("Authorization"))
-		if !strings.HasPrefix(header, "Bearer ") {
-			ctx.SetStatusCode(fasthttp.StatusUnauthorized)
-			ctx.SetBodyString(`{"error": "Unauthorized"}`)
-			return
+		token, _ := bearerToken(ctx)
+		identity, problem := c.authenticate(GetOtelContextFromRequest(ctx), string(ctx.Request.Header.Peek(APIKeyHeader)), token)
+		if identity == nil && problem == nil {
+			identity = identityFromClientCert(ctx.TLSConnectionState())
 		}
-		tokenSting := strings.TrimPrefix(header, "Bearer ")
-		token, err := jwt.Parse(tokenSting, func(token *jwt.Token) (interface{}, error) {
-			return []byte(JWTSecret), nil
-		})
-		if err != nil || !token.Valid {
-			ctx.SetStatusCode(fasthttp.StatusUnauthorized)
-			ctx.SetBodyString(`{"error": "Unauthorized"}`)
+		switch {
+		case problem != nil:
+			WriteProblem(ctx, problem)
+			return
+		case identity == nil:
+			WriteProblem(ctx, NewProblem(fasthttp.StatusUnauthorized, "missing bearer token"))
 			return
 		}
+		ctx.SetUserValue(identityKey, identity)
 		next(ctx)
 	}
 }
+
+// HTTPAuthMiddleware is JwtMiddleware for net/http listeners such as the MCP
+// SSE server. It stores the caller for IdentityFromContext.
+func (c *Config) HTTPAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			token = strings.TrimPrefix(header, "Bearer ")
+		}
+		identity, problem := c.authenticate(r.Context(), r.Header.Get(APIKeyHeader), token)
+		if identity == nil && problem == nil {
+			identity = identityFromClientCert(r.TLS)
+		}
+		switch {
+		case problem != nil:
+			writeHTTPProblem(w, r, problem)
+			return
+		case identity == nil:
+			writeHTTPProblem(w, r, NewProblem(http.StatusUnauthorized, "missing bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+	})
+}
+
+// writeHTTPProblem is WriteProblem for net/http handlers
+func writeHTTPProblem(w http.ResponseWriter, r *http.Request, problem *Problem) {
+	p := *problem
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	_, _ = io.WriteString(w, p.JSON())
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RevocationDataKey is the ConfigMap or Secret key that holds revoked token IDs
+const RevocationDataKey = "revoked.json"
+
+// RevocationBackend persists revoked token IDs with the expiry of each token
+type RevocationBackend interface {
+	Load(ctx context.Context) (map[string]time.Time, error)
+	// Update applies fn to the stored list and returns the result
+	Update(ctx context.Context, fn func(map[string]time.Time)) (map[string]time.Time, error)
+}
+
+// RevocationList keeps revoked token and session IDs in memory until the
+// tokens expire, optionally mirrored to a backend that survives restarts and
+// is shared between replicas
+type RevocationList struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+	backend RevocationBackend
+}
+
+// NewRevocationList creates a list persisted to backend, or only in memory
+// when backend is nil
+func NewRevocationList(backend RevocationBackend) *RevocationList {
+	return &RevocationList{revoked: map[string]time.Time{}, backend: backend}
+}
+
+func pruneRevoked(revoked map[string]time.Time, now time.Time) {
+	for jti, expires := range revoked {
+		if now.After(expires) {
+			delete(revoked, jti)
+		}
+	}
+}
+
+func (r *RevocationList) merge(revoked map[string]time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for jti, expires := range revoked {
+		r.revoked[jti] = expires
+	}
+	pruneRevoked(r.revoked, time.Now())
+}
+
+// Revoke adds a token ID. expires is the token's own expiry, after which the
+// entry is dropped.
+func (r *RevocationList) Revoke(ctx context.Context, jti string, expires time.Time) error {
+	r.merge(map[string]time.Time{jti: expires})
+	if r.backend == nil {
+		return nil
+	}
+	stored, err := r.backend.Update(ctx, func(revoked map[string]time.Time) {
+		revoked[jti] = expires
+		pruneRevoked(revoked, time.Now())
+	})
+	if err != nil {
+		return fmt.Errorf("persist revocation: %w", err)
+	}
+	r.merge(stored)
+	return nil
+}
+
+// RevokeIfActive revokes jti unless it is already revoked, and reports whether
+// this call revoked it. It is the compare-and-revoke that makes refresh tokens
+// single use when the same token is refreshed concurrently, also across
+// replicas sharing a backend.
+func (r *RevocationList) RevokeIfActive(ctx context.Context, jti string, expires time.Time) (bool, error) {
+	r.mu.Lock()
+	if _, ok := r.revoked[jti]; ok {
+		r.mu.Unlock()
+		return false, nil
+	}
+	r.revoked[jti] = expires
+	r.mu.Unlock()
+	if r.backend == nil {
+		return true, nil
+	}
+
+	active := false
+	stored, err := r.backend.Update(ctx, func(revoked map[string]time.Time) {
+		// Set on every attempt, Update retries fn on conflicts
+		_, ok := revoked[jti]
+		active = !ok
+		revoked[jti] = expires
+		pruneRevoked(revoked, time.Now())
+	})
+	if err != nil {
+		return false, fmt.Errorf("persist revocation: %w", err)
+	}
+	r.merge(stored)
+	return active, nil
+}
+
+// IsRevoked reports whether jti has been revoked
+func (r *RevocationList) IsRevoked(jti string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.revoked[jti]
+	return ok
+}
+
+// Sync loads revocations made by other replicas from the backend
+func (r *RevocationList) Sync(ctx context.Context) error {
+	if r.backend == nil {
+		return nil
+	}
+	stored, err := r.backend.Load(ctx)
+	if err != nil {
+		return err
+	}
+	r.merge(stored)
+	return nil
+}
+
+// Run syncs the list every interval until ctx is done
+func (r *RevocationList) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Sync(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to sync token revocations")
+			}
+		}
+	}
+}
+
+// KubernetesRevocationBackend stores revocations as JSON in a ConfigMap or Secret
+type KubernetesRevocationBackend struct {
+	Client    client.Client
+	Namespace string
+	Name      string
+	// Secret stores the list in a Secret instead of a ConfigMap
+	Secret bool
+}
+
+func (b *KubernetesRevocationBackend) newObject() client.Object {
+	meta := metav1.ObjectMeta{Namespace: b.Namespace, Name: b.Name}
+	if b.Secret {
+		return &corev1.Secret{ObjectMeta: meta}
+	}
+	return &corev1.ConfigMap{ObjectMeta: meta}
+}
+
+func (b *KubernetesRevocationBackend) decode(obj client.Object) (map[string]time.Time, error) {
+	var data []byte
+	switch o := obj.(type) {
+	case *corev1.Secret:
+		data = o.Data[RevocationDataKey]
+	case *corev1.ConfigMap:
+		data = []byte(o.Data[RevocationDataKey])
+	}
+	revoked := map[string]time.Time{}
+	if len(data) == 0 {
+		return revoked, nil
+	}
+	if err := json.Unmarshal(data, &revoked); err != nil {
+		return nil, fmt.Errorf("decode %s/%s: %w", b.Namespace, b.Name, err)
+	}
+	return revoked, nil
+}
+
+func encodeRevoked(obj client.Object, revoked map[string]time.Time) error {
+	data, err := json.Marshal(revoked)
+	if err != nil {
+		return err
+	}
+	switch o := obj.(type) {
+	case *corev1.Secret:
+		o.Data = map[string][]byte{RevocationDataKey: data}
+	case *corev1.ConfigMap:
+		o.Data = map[string]string{RevocationDataKey: string(data)}
+	}
+	return nil
+}
+
+// Load implements RevocationBackend
+func (b *KubernetesRevocationBackend) Load(ctx context.Context) (map[string]time.Time, error) {
+	obj := b.newObject()
+	err := b.Client.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+	if apierrors.IsNotFound(err) {
+		return map[string]time.Time{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b.decode(obj)
+}
+
+// Update implements RevocationBackend. Concurrent updates from other
+// replicas are retried on conflict.
+func (b *KubernetesRevocationBackend) Update(ctx context.Context, fn func(map[string]time.Time)) (map[string]time.Time, error) {
+	var revoked map[string]time.Time
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj := b.newObject()
+		err := b.Client.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		notFound := apierrors.IsNotFound(err)
+		if err != nil && !notFound {
+			return err
+		}
+		if revoked, err = b.decode(obj); err != nil {
+			return err
+		}
+		fn(revoked)
+		if err := encodeRevoked(obj, revoked); err != nil {
+			return err
+		}
+		if notFound {
+			err = b.Client.Create(ctx, obj)
+			if apierrors.IsAlreadyExists(err) {
+				// Another replica created it first, retry as an update
+				return apierrors.NewConflict(corev1.Resource("configmaps"), b.Name, err)
+			}
+			return err
+		}
+		return b.Client.Update(ctx, obj)
+	})
+	return revoked, err
+}
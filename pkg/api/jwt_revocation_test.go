@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRevocationList_SharedBackend(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+
+	for _, secret := range []bool{false, true} {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		backend := &KubernetesRevocationBackend{Client: c, Namespace: "default", Name: "revocations", Secret: secret}
+		replicaA := NewRevocationList(backend)
+		replicaB := NewRevocationList(backend)
+
+		require.NoError(t, replicaA.Revoke(ctx, "jti-1", time.Now().Add(time.Hour)))
+		require.NoError(t, replicaB.Revoke(ctx, "jti-2", time.Now().Add(time.Hour)))
+		// Expired entries are dropped
+		require.NoError(t, replicaB.Revoke(ctx, "jti-old", time.Now().Add(-time.Minute)))
+		require.True(t, replicaB.IsRevoked("jti-1"), "replica B sees A's revocation when it writes")
+
+		require.False(t, replicaA.IsRevoked("jti-2"))
+		require.NoError(t, replicaA.Sync(ctx))
+		require.True(t, replicaA.IsRevoked("jti-2"))
+		require.False(t, replicaA.IsRevoked("jti-old"))
+
+		// A restarted replica loads the stored list
+		restarted := NewRevocationList(backend)
+		require.NoError(t, restarted.Sync(ctx))
+		require.True(t, restarted.IsRevoked("jti-1"))
+
+		var obj client.Object = &corev1.ConfigMap{}
+		if secret {
+			obj = &corev1.Secret{}
+		}
+		require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "revocations"}, obj))
+	}
+}
+
+func TestRevocationList_RevokeIfActive(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	backend := &KubernetesRevocationBackend{Client: fake.NewClientBuilder().WithScheme(scheme).Build(), Namespace: "default", Name: "revocations"}
+	replicaA := NewRevocationList(backend)
+	replicaB := NewRevocationList(backend)
+	expires := time.Now().Add(time.Hour)
+
+	active, err := replicaA.RevokeIfActive(ctx, "jti-1", expires)
+	require.NoError(t, err)
+	require.True(t, active)
+	active, err = replicaA.RevokeIfActive(ctx, "jti-1", expires)
+	require.NoError(t, err)
+	require.False(t, active, "a revoked token is not revoked twice")
+
+	// Replica B hasn't synced, the backend still knows the token is revoked
+	require.False(t, replicaB.IsRevoked("jti-1"))
+	active, err = replicaB.RevokeIfActive(ctx, "jti-1", expires)
+	require.NoError(t, err)
+	require.False(t, active)
+	require.True(t, replicaB.IsRevoked("jti-1"))
+}
@@ -1,24 +1,280 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func TokenHandler(ctx *fasthttp.RequestCtx) {
-	claims := jwt.MapClaims{
-		"sub": "testuser",
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
+// Token uses, carried in the token_use claim
+const (
+	TokenUseAccess  = "access"
+	TokenUseRefresh = "refresh"
+)
+
+// Claims are carried by the tokens issued by TokenHandler
+type Claims struct {
+	Groups     []string `json:"groups,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	TokenUse   string   `json:"token_use,omitempty"`
+	// Session is shared by an access token, its refresh token and the pairs
+	// refreshed from them, so that revoking one token revokes them all
+	Session string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// sessionRevocationKey is the key of a session in the RevocationList, next to
+// the IDs of single tokens
+func sessionRevocationKey(session string) string {
+	return "session:" + session
+}
+
+// TokenResponse is returned by the token and refresh endpoints
+type TokenResponse struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+func (c *Config) signToken(identity *Identity, session, use string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	claims := Claims{
+		Groups:     identity.Groups,
+		Namespaces: identity.Namespaces,
+		TokenUse:   use,
+		Session:    session,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   identity.Subject,
+			Issuer:    c.JWTIssuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	if c.JWTAudience != "" {
+		claims.Audience = jwt.ClaimStrings{c.JWTAudience}
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(c.JWTSecret))
+	return token, claims.ExpiresAt.Time, err
+}
+
+// IssueToken signs an access token valid for JWTTTL and a refresh token valid
+// for JWTRefreshTTL, with the configured issuer and audience, in a new session
+func (c *Config) IssueToken(identity *Identity) (*TokenResponse, error) {
+	return c.issueTokens(identity, uuid.NewString())
+}
+
+func (c *Config) issueTokens(identity *Identity, session string) (*TokenResponse, error) {
+	resp := &TokenResponse{}
+	var err error
+	if resp.Token, resp.ExpiresAt, err = c.signToken(identity, session, TokenUseAccess, c.JWTTTL); err != nil {
+		return nil, err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(JWTSecret))
+	if resp.RefreshToken, resp.RefreshExpiresAt, err = c.signToken(identity, session, TokenUseRefresh, c.JWTRefreshTTL); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// TokenHandler serves POST /api/token
+func (c *Config) TokenHandler(ctx *fasthttp.RequestCtx) {
+	if c.TokenAuthenticator == nil {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusServiceUnavailable, "token issuance is not configured"))
+		return
+	}
+
+	var creds Credentials
+	if len(ctx.PostBody()) > 0 {
+		if err := json.Unmarshal(ctx.PostBody(), &creds); err != nil {
+			WriteProblem(ctx, BadRequest("invalid request body: %v", err))
+			return
+		}
+	}
+	if username, password, ok := basicAuth(ctx); ok {
+		creds.Username, creds.Password = username, password
+	}
+	if creds.Username == "" && creds.ServiceAccountToken == "" {
+		WriteProblem(ctx, BadRequest("username and password or serviceAccountToken are required"))
+		return
+	}
+
+	identity, err := c.TokenAuthenticator.Authenticate(ctx, creds)
+	if errors.Is(err, ErrInvalidCredentials) {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusUnauthorized, "invalid credentials"))
+		return
+	}
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+
+	resp, err := c.IssueToken(identity)
 	if err != nil {
-		ctx.SetStatusCode(fasthttp.StatusInternalServerError)
-		ctx.SetBodyString(`{"error": "Failed to generate token"}`)
+		WriteProblem(ctx, NewProblem(fasthttp.StatusInternalServerError, "failed to generate token"))
+		return
+	}
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(resp)
+}
+
+// RefreshRequest is the body of the refresh endpoint
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" openapi:"required"`
+}
+
+// RefreshTokenHandler serves POST /api/token/refresh
+func (c *Config) RefreshTokenHandler(ctx *fasthttp.RequestCtx) {
+	var req RefreshRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil || req.RefreshToken == "" {
+		WriteProblem(ctx, BadRequest("refreshToken is required"))
+		return
+	}
+
+	claims, err := c.verifyToken(GetOtelContextFromRequest(ctx), req.RefreshToken)
+	if err != nil || claims.TokenUse != TokenUseRefresh {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusUnauthorized, "invalid or expired refresh token"))
 		return
 	}
+	// Only the request that revokes the refresh token gets a new pair
+	active, err := c.Revocations.RevokeIfActive(GetOtelContextFromRequest(ctx), claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+	if !active {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusUnauthorized, "invalid or expired refresh token"))
+		return
+	}
+
+	// The new pair stays in the session, tokens from before sessions start one
+	session := claims.Session
+	if session == "" {
+		session = uuid.NewString()
+	}
+	resp, err := c.issueTokens(&Identity{
+		Subject:    claims.Subject,
+		Groups:     claims.Groups,
+		Namespaces: claims.Namespaces,
+	}, session)
+	if err != nil {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusInternalServerError, "failed to generate token"))
+		return
+	}
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(resp)
+}
+
+// TokenRequest names the token to revoke or introspect
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+// parseTokenRequest returns the claims of the token in the body, or of the
+// caller's own token when the body is empty. Tokens that fail verification
+// return nil claims.
+func (c *Config) parseTokenRequest(ctx *fasthttp.RequestCtx) (*Claims, *Problem) {
+	var req TokenRequest
+	if len(ctx.PostBody()) > 0 {
+		if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+			return nil, BadRequest("invalid request body: %v", err)
+		}
+	}
+	if req.Token == "" {
+		req.Token, _ = bearerToken(ctx)
+	}
+	claims, err := c.verifyToken(GetOtelContextFromRequest(ctx), req.Token)
+	if err != nil {
+		return nil, nil
+	}
+	return claims, nil
+}
+
+// RevokeTokenHandler serves POST /api/token/revoke
+func (c *Config) RevokeTokenHandler(ctx *fasthttp.RequestCtx) {
+	claims, problem := c.parseTokenRequest(ctx)
+	if problem != nil {
+		WriteProblem(ctx, problem)
+		return
+	}
+	// Invalid and expired tokens need no revocation (RFC 7009)
+	if claims == nil || claims.ID == "" {
+		ctx.SetStatusCode(fasthttp.StatusNoContent)
+		return
+	}
+	if caller := IdentityFromRequest(ctx); caller == nil || caller.Subject != claims.Subject {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusForbidden, "only your own tokens can be revoked"))
+		return
+	}
+	if err := c.Revocations.Revoke(GetOtelContextFromRequest(ctx), claims.ID, claims.ExpiresAt.Time); err != nil {
+		WriteError(ctx, err)
+		return
+	}
+	// The other tokens of the session, the refresh token of an access token
+	// included, expire by the time a refresh token issued now would
+	if claims.Session != "" {
+		expires := time.Now().Add(c.JWTRefreshTTL)
+		if claims.ExpiresAt.After(expires) {
+			expires = claims.ExpiresAt.Time
+		}
+		if err := c.Revocations.Revoke(GetOtelContextFromRequest(ctx), sessionRevocationKey(claims.Session), expires); err != nil {
+			WriteError(ctx, err)
+			return
+		}
+		AddSpanAttributes(ctx, attribute.String("token.sid", claims.Session))
+	}
+	AddSpanAttributes(ctx, attribute.String("token.jti", claims.ID))
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+// TokenIntrospection describes a token, following RFC 7662
+type TokenIntrospection struct {
+	Active     bool     `json:"active"`
+	Subject    string   `json:"sub,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	TokenUse   string   `json:"token_use,omitempty"`
+	ID         string   `json:"jti,omitempty"`
+	Session    string   `json:"sid,omitempty"`
+	Issuer     string   `json:"iss,omitempty"`
+	Audience   []string `json:"aud,omitempty"`
+	ExpiresAt  int64    `json:"exp,omitempty"`
+	IssuedAt   int64    `json:"iat,omitempty"`
+}
+
+// IntrospectTokenHandler serves POST /api/token/introspect
+func (c *Config) IntrospectTokenHandler(ctx *fasthttp.RequestCtx) {
+	claims, problem := c.parseTokenRequest(ctx)
+	if problem != nil {
+		WriteProblem(ctx, problem)
+		return
+	}
+	result := TokenIntrospection{}
+	if claims != nil {
+		result = TokenIntrospection{
+			Active:     true,
+			Subject:    claims.Subject,
+			Groups:     claims.Groups,
+			Namespaces: claims.Namespaces,
+			TokenUse:   claims.TokenUse,
+			ID:         claims.ID,
+			Session:    claims.Session,
+			Issuer:     claims.Issuer,
+			Audience:   claims.Audience,
+		}
+		if claims.ExpiresAt != nil {
+			result.ExpiresAt = claims.ExpiresAt.Unix()
+		}
+		if claims.IssuedAt != nil {
+			result.IssuedAt = claims.IssuedAt.Unix()
+		}
+	}
 	ctx.SetContentType("application/json")
-	ctx.SetBodyString(`{"token": "` + tokenString + `"}`)
-}
\ No newline at end of file
+	json.NewEncoder(ctx).Encode(result)
+}
@@ -1,23 +1,40 @@
 package api
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/require"
 	"github.com/valyala/fasthttp"
+	"golang.org/x/crypto/bcrypt"
 )
 
-type TokenResponse struct {
-	Token string `json:"token"`
+func newTestUsers(t *testing.T) *StaticUsers {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	users, err := NewStaticUsers([]StaticUser{{Username: "alice", PasswordHash: string(hash), Groups: []string{"editors"}}})
+	require.NoError(t, err)
+	return users
 }
 
 func TestTokenHandler_IssuesValidJWT(t *testing.T) {
-	JWTSecret = "test-secret"
+	t.Parallel()
+	config := NewConfig()
+	config.JWTSecret = "test-secret"
+	config.JWTIssuer, config.JWTAudience = "test-issuer", "test-audience"
+	config.TokenAuthenticator = newTestUsers(t)
+
 	ctx := &fasthttp.RequestCtx{}
-	TokenHandler(ctx)
+	ctx.Request.SetBody([]byte(`{"username":"alice","password":"s3cret"}`))
+	config.TokenHandler(ctx)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
 
 	var tokenResp TokenResponse
 	err := json.Unmarshal(ctx.Response.Body(), &tokenResp)
@@ -25,30 +42,76 @@ func TestTokenHandler_IssuesValidJWT(t *testing.T) {
 	require.NotEmpty(t, tokenResp.Token)
 
 	// Parse token
-	parsed, err := jwt.Parse(tokenResp.Token, func(token *jwt.Token) (interface{}, error) {
-		return []byte(JWTSecret), nil
-	})
+	claims, err := config.verifyToken(context.Background(), tokenResp.Token)
 	require.NoError(t, err)
-	require.True(t, parsed.Valid)
+	require.Equal(t, "alice", claims.Subject)
+	require.Equal(t, []string{"editors"}, claims.Groups)
+
+	// Basic auth is accepted too
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("alice:s3cret")))
+	config.TokenHandler(ctx)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	// Tokens for another audience are rejected
+	config.JWTAudience = "other-audience"
+	called := false
+	mw := config.JwtMiddleware(func(ctx *fasthttp.RequestCtx) { called = true })
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set("Authorization", "Bearer "+tokenResp.Token)
+	mw(ctx)
+	require.False(t, called)
+	require.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+}
+
+func TestTokenHandler_RejectsBadCredentials(t *testing.T) {
+	t.Parallel()
+	config := NewConfig()
+	ctx := &fasthttp.RequestCtx{}
+	config.TokenHandler(ctx)
+	require.Equal(t, fasthttp.StatusServiceUnavailable, ctx.Response.StatusCode())
+
+	config.TokenAuthenticator = newTestUsers(t)
+
+	for _, body := range []string{
+		`{"username":"alice","password":"wrong"}`,
+		`{"username":"bob","password":"s3cret"}`,
+	} {
+		ctx = &fasthttp.RequestCtx{}
+		ctx.Request.SetBody([]byte(body))
+		config.TokenHandler(ctx)
+		require.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode(), body)
+	}
+
+	ctx = &fasthttp.RequestCtx{}
+	config.TokenHandler(ctx)
+	require.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
 }
 
 func TestJWTMiddleware_ValidAndInvalidToken(t *testing.T) {
-	JWTSecret = "test-secret"
+	t.Parallel()
+	config := NewConfig()
+	config.JWTSecret = "test-secret"
 	claims := jwt.MapClaims{
 		"sub": "testuser",
 		"exp": time.Now().Add(time.Hour).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString([]byte(JWTSecret))
+	tokenStr, err := token.SignedString([]byte(config.JWTSecret))
 	require.NoError(t, err)
 
 	// Valid token
 	ctx := &fasthttp.RequestCtx{}
 	ctx.Request.Header.Set("Authorization", "Bearer "+tokenStr)
 	called := false
-	mw := JwtMiddleware(func(ctx *fasthttp.RequestCtx) { called = true })
+	var identity *Identity
+	mw := config.JwtMiddleware(func(ctx *fasthttp.RequestCtx) {
+		called = true
+		identity = IdentityFromRequest(ctx)
+	})
 	mw(ctx)
 	require.True(t, called, "middleware should call next handler for valid token")
+	require.Equal(t, "testuser", identity.Subject)
 
 	// Invalid token
 	ctx2 := &fasthttp.RequestCtx{}
@@ -64,7 +127,7 @@ func TestJWTMiddleware_ValidAndInvalidToken(t *testing.T) {
 		"exp": time.Now().Add(-time.Hour).Unix(),
 	}
 	expiredToken := jwt.NewWithClaims(jwt.SigningMethodHS256, expiredClaims)
-	expiredStr, err := expiredToken.SignedString([]byte(JWTSecret))
+	expiredStr, err := expiredToken.SignedString([]byte(config.JWTSecret))
 	require.NoError(t, err)
 	ctx3 := &fasthttp.RequestCtx{}
 	ctx3.Request.Header.Set("Authorization", "Bearer "+expiredStr)
@@ -73,3 +136,216 @@ func TestJWTMiddleware_ValidAndInvalidToken(t *testing.T) {
 	require.False(t, called, "middleware should not call next handler for expired token")
 	require.Equal(t, fasthttp.StatusUnauthorized, ctx3.Response.StatusCode())
 }
+
+func TestHTTPAuthMiddleware(t *testing.T) {
+	t.Parallel()
+	config := NewConfig()
+	config.JWTSecret = "test-secret"
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":    "testuser",
+		"groups": []string{"editors"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	})
+	tokenStr, err := token.SignedString([]byte(config.JWTSecret))
+	require.NoError(t, err)
+
+	var identity *Identity
+	handler := config.HTTPAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity = IdentityFromContext(r.Context())
+	}))
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		identity = nil
+		r := httptest.NewRequest(http.MethodPost, "/message", nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("Bearer " + tokenStr)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "testuser", identity.Subject)
+	require.Equal(t, []string{"editors"}, identity.Groups)
+
+	for _, authorization := range []string{"", "Bearer invalidtoken", "Basic dXNlcjpwYXNz"} {
+		w = serve(authorization)
+		require.Equal(t, http.StatusUnauthorized, w.Code, authorization)
+		require.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+		require.Nil(t, identity)
+	}
+}
+
+func TestJWTMiddleware_WatchQueryToken(t *testing.T) {
+	t.Parallel()
+	config := NewConfig()
+	config.JWTSecret = "test-secret"
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "testuser",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	tokenStr, err := token.SignedString([]byte(config.JWTSecret))
+	require.NoError(t, err)
+
+	called := false
+	mw := config.JwtMiddleware(func(ctx *fasthttp.RequestCtx) { called = true })
+
+	// access_token is accepted for watch requests
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/frontendpages?watch=true&access_token=" + tokenStr)
+	mw(ctx)
+	require.True(t, called)
+
+	// but not for regular requests
+	called = false
+	ctx2 := &fasthttp.RequestCtx{}
+	ctx2.Request.SetRequestURI("/api/frontendpages?access_token=" + tokenStr)
+	mw(ctx2)
+	require.False(t, called)
+	require.Equal(t, fasthttp.StatusUnauthorized, ctx2.Response.StatusCode())
+}
+
+func TestTokenLifecycle(t *testing.T) {
+	t.Parallel()
+	config := NewConfig()
+	config.JWTSecret = "test-secret"
+	config.TokenAuthenticator = newTestUsers(t)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetBody([]byte(`{"username":"alice","password":"s3cret"}`))
+	config.TokenHandler(ctx)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	var issued TokenResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &issued))
+	require.NotEmpty(t, issued.RefreshToken)
+
+	authorized := func(token string) bool {
+		called := false
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.Set("Authorization", "Bearer "+token)
+		config.JwtMiddleware(func(*fasthttp.RequestCtx) { called = true })(ctx)
+		return called
+	}
+	require.True(t, authorized(issued.Token))
+	require.False(t, authorized(issued.RefreshToken), "refresh tokens are not access tokens")
+
+	refresh := func(token string) (*TokenResponse, int) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetBody([]byte(`{"refreshToken":"` + token + `"}`))
+		config.RefreshTokenHandler(ctx)
+		var resp TokenResponse
+		json.Unmarshal(ctx.Response.Body(), &resp)
+		return &resp, ctx.Response.StatusCode()
+	}
+	refreshed, status := refresh(issued.RefreshToken)
+	require.Equal(t, fasthttp.StatusOK, status)
+	require.True(t, authorized(refreshed.Token))
+
+	// Refresh tokens are single use, and access tokens can't refresh
+	_, status = refresh(issued.RefreshToken)
+	require.Equal(t, fasthttp.StatusUnauthorized, status)
+
+	// Only one of concurrent refreshes with the same token succeeds
+	statuses := make(chan int, 8)
+	var wg sync.WaitGroup
+	for range cap(statuses) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, status := refresh(refreshed.RefreshToken)
+			statuses <- status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	succeeded := 0
+	for status := range statuses {
+		if status == fasthttp.StatusOK {
+			succeeded++
+		}
+	}
+	require.Equal(t, 1, succeeded)
+	_, status = refresh(refreshed.Token)
+	require.Equal(t, fasthttp.StatusUnauthorized, status)
+
+	introspect := func(token string) TokenIntrospection {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetBody([]byte(`{"token":"` + token + `"}`))
+		config.IntrospectTokenHandler(ctx)
+		var result TokenIntrospection
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &result))
+		return result
+	}
+	result := introspect(refreshed.Token)
+	require.True(t, result.Active)
+	require.Equal(t, "alice", result.Subject)
+	require.Equal(t, TokenUseAccess, result.TokenUse)
+
+	// Revoking without a body revokes the caller's own token
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set("Authorization", "Bearer "+refreshed.Token)
+	config.JwtMiddleware(config.RevokeTokenHandler)(ctx)
+	require.Equal(t, fasthttp.StatusNoContent, ctx.Response.StatusCode())
+	require.False(t, authorized(refreshed.Token))
+	require.False(t, introspect(refreshed.Token).Active)
+	require.False(t, authorized(issued.Token), "the whole session is revoked")
+
+	// Other users' tokens can't be revoked
+	alice, err := config.IssueToken(&Identity{Subject: "alice"})
+	require.NoError(t, err)
+	other, err := config.IssueToken(&Identity{Subject: "bob"})
+	require.NoError(t, err)
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set("Authorization", "Bearer "+other.Token)
+	ctx.Request.SetBody([]byte(`{"token":"` + alice.Token + `"}`))
+	config.JwtMiddleware(config.RevokeTokenHandler)(ctx)
+	require.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode())
+}
+
+func TestRevokeToken_RevokesSession(t *testing.T) {
+	t.Parallel()
+	config := NewConfig()
+	config.JWTSecret = "test-secret"
+	call := func(handler fasthttp.RequestHandler, token, body string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		if token != "" {
+			ctx.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		ctx.Request.SetBodyString(body)
+		handler(ctx)
+		return ctx
+	}
+	refresh := func(token string) int {
+		return call(config.RefreshTokenHandler, "", `{"refreshToken":"`+token+`"}`).Response.StatusCode()
+	}
+	authorized := func(token string) bool {
+		called := false
+		call(config.JwtMiddleware(func(*fasthttp.RequestCtx) { called = true }), token, "")
+		return called
+	}
+
+	// Revoking an access token revokes its refresh token
+	issued, err := config.IssueToken(&Identity{Subject: "alice"})
+	require.NoError(t, err)
+	require.Equal(t, fasthttp.StatusNoContent, call(config.JwtMiddleware(config.RevokeTokenHandler), issued.Token, "").Response.StatusCode())
+	require.Equal(t, fasthttp.StatusUnauthorized, refresh(issued.RefreshToken))
+
+	// and the pairs refreshed in the same session, but not other sessions
+	issued, err = config.IssueToken(&Identity{Subject: "alice"})
+	require.NoError(t, err)
+	other, err := config.IssueToken(&Identity{Subject: "alice"})
+	require.NoError(t, err)
+	ctx := call(config.RefreshTokenHandler, "", `{"refreshToken":"`+issued.RefreshToken+`"}`)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	var refreshed TokenResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &refreshed))
+	require.True(t, authorized(issued.Token))
+	require.Equal(t, fasthttp.StatusNoContent, call(config.JwtMiddleware(config.RevokeTokenHandler), other.Token,
+		`{"token":"`+refreshed.RefreshToken+`"}`).Response.StatusCode())
+	require.False(t, authorized(issued.Token))
+	require.False(t, authorized(refreshed.Token))
+	require.Equal(t, fasthttp.StatusUnauthorized, refresh(refreshed.RefreshToken))
+	require.True(t, authorized(other.Token))
+	require.Equal(t, fasthttp.StatusOK, refresh(other.RefreshToken))
+}
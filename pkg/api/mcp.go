@@ -0,0 +1,25 @@
+package api
+
+import (
+	"github.com/valyala/fasthttp"
+)
+
+// DefaultMCPPath is where the MCP streamable HTTP transport is served
+const DefaultMCPPath = "/mcp"
+
+// mcpPath returns the path of the MCP endpoint
+func (r *Router) mcpPath() string {
+	if r.MCPPath != "" {
+		return r.MCPPath
+	}
+	return DefaultMCPPath
+}
+
+// ServeMCP serves the MCP streamable HTTP transport
+func (r *Router) ServeMCP(ctx *fasthttp.RequestCtx) {
+	if r.MCP == nil {
+		WriteProblem(ctx, NewProblem(fasthttp.StatusServiceUnavailable, "MCP is not enabled"))
+		return
+	}
+	r.MCP(ctx)
+}
@@ -0,0 +1,485 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification the document follows
+const OpenAPIVersion = "3.1.0"
+
+// Security schemes of authenticated routes
+const (
+	SecurityBearer = "bearerAuth"
+	SecurityAPIKey = "apiKeyAuth"
+)
+
+// Route is an entry of the route table. The table registers the handlers on
+// the router, generates the OpenAPI document and validates requests, so the
+// three can't drift apart.
+type Route struct {
+	Method string
+	// Path uses OpenAPI templates such as /api/frontendpages/{name}
+	Path string
+	// Operation is the operationId, and names the span and rate limit bucket
+	Operation   string
+	Summary     string
+	Description string
+	Tag         string
+	// Authenticated routes require a bearer token or API key
+	Authenticated bool
+	Params        []Parameter
+	Body          *RouteBody
+	Responses     []RouteResponse
+	// Handler runs once the request is authenticated and valid
+	Handler fasthttp.RequestHandler
+}
+
+// RouteBody describes the request body of a route
+type RouteBody struct {
+	Description string
+	Required    bool
+	// Type is a value of the Go type the body decodes to. Bodies without a
+	// type are documented as strings and not validated.
+	Type         any
+	ContentTypes []string
+}
+
+// RouteResponse describes one response status of a route
+type RouteResponse struct {
+	Status      int
+	Description string
+	// Type is a value of the Go type of the body, nil for string or empty bodies
+	Type         any
+	ContentTypes []string
+}
+
+// OpenAPI is an OpenAPI 3.1 document
+type OpenAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       OpenAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+// OpenAPIInfo describes the API
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Components hold the schemas of the Go types and the security schemes
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is a way to authenticate
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// Operation is a method of a path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the documented body of an operation
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Response is the documented response for a status
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// SchemaType is one JSON type, or several when a value may also be null
+type SchemaType []string
+
+// MarshalJSON writes a single type as a string
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// Schema is the subset of JSON Schema the document uses
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        SchemaType         `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// AdditionalProperties is false or a *Schema
+	AdditionalProperties any `json:"additionalProperties,omitempty"`
+	// Defs holds the named schemas of a standalone JSON Schema, see JSONSchema
+	Defs map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// NewOpenAPI generates the document for the route table. Bodies are described
+// by the schemas of their Go types, see schemaFor for the openapi struct tag.
+func NewOpenAPI(routes []Route) *OpenAPI {
+	doc := &OpenAPI{
+		OpenAPI: OpenAPIVersion,
+		Info: OpenAPIInfo{
+			Title:       "K8s Controller Tutorial API",
+			Description: "Manage FrontendPages and the credentials to do so",
+			Version:     "1.0",
+		},
+		Paths: map[string]map[string]*Operation{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{
+				SecurityBearer: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				SecurityAPIKey: {Type: "apiKey", In: "header", Name: APIKeyHeader},
+			},
+		},
+	}
+	for _, route := range routes {
+		if doc.Paths[route.Path] == nil {
+			doc.Paths[route.Path] = map[string]*Operation{}
+		}
+		doc.Paths[route.Path][strings.ToLower(route.Method)] = doc.operation(route)
+	}
+	return doc
+}
+
+// problemResponse is a Problem body for status
+func problemResponse(status int, description string) RouteResponse {
+	return RouteResponse{Status: status, Description: description, Type: Problem{}, ContentTypes: []string{ProblemContentType}}
+}
+
+func (doc *OpenAPI) operation(route Route) *Operation {
+	op := &Operation{
+		OperationID: route.Operation,
+		Summary:     route.Summary,
+		Description: route.Description,
+		Parameters:  route.Params,
+		Responses:   map[string]*Response{},
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+	if route.Authenticated {
+		op.Security = []map[string][]string{{SecurityBearer: {}}, {SecurityAPIKey: {}}}
+	}
+	if route.Body != nil {
+		op.RequestBody = &RequestBody{
+			Description: route.Body.Description,
+			Required:    route.Body.Required,
+			Content:     doc.content(route.Body.Type, route.Body.ContentTypes),
+		}
+	}
+
+	// Every route may fail validation, authentication and rate limits
+	responses := slices.Clone(route.Responses)
+	if len(route.Params) > 0 || route.Body != nil {
+		responses = append(responses, problemResponse(fasthttp.StatusBadRequest, "The request doesn't match this document"))
+	}
+	if route.Body != nil {
+		responses = append(responses, problemResponse(fasthttp.StatusUnsupportedMediaType, "The request body has a media type this operation doesn't accept"))
+	}
+	if route.Authenticated {
+		responses = append(responses,
+			problemResponse(fasthttp.StatusUnauthorized, "Missing or invalid credentials"),
+			problemResponse(fasthttp.StatusForbidden, "The caller may not do this"),
+		)
+	}
+	responses = append(responses, problemResponse(fasthttp.StatusTooManyRequests, "Rate limit exceeded"))
+	for _, resp := range responses {
+		status := strconv.Itoa(resp.Status)
+		if _, ok := op.Responses[status]; ok {
+			continue
+		}
+		op.Responses[status] = &Response{
+			Description: resp.Description,
+			Content:     doc.content(resp.Type, resp.ContentTypes),
+		}
+	}
+	op.Responses["default"] = &Response{
+		Description: "Unexpected error",
+		Content:     doc.content(Problem{}, []string{ProblemContentType}),
+	}
+	return op
+}
+
+func (doc *OpenAPI) content(v any, contentTypes []string) map[string]MediaType {
+	if len(contentTypes) == 0 {
+		return nil
+	}
+	schema := &Schema{Type: SchemaType{"string"}}
+	if v != nil {
+		schema = doc.schemaFor(reflect.TypeOf(v), false)
+	}
+	content := map[string]MediaType{}
+	for _, contentType := range contentTypes {
+		content[contentType] = MediaType{Schema: schema}
+	}
+	return content
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	metav1TimeType = reflect.TypeOf(metav1.Time{})
+)
+
+// schemaName is the component name of a named Go type, such as
+// api.FrontendPageDoc
+func schemaName(typ reflect.Type) string {
+	return path.Base(typ.PkgPath()) + "." + typ.Name()
+}
+
+// schemaFor returns the schema of a Go type. Named structs become components
+// and are referenced. Slices and maps may be null unless omitted when empty.
+func (doc *OpenAPI) schemaFor(typ reflect.Type, nullable bool) *Schema {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	var schema *Schema
+	switch {
+	case typ == timeType || typ == metav1TimeType:
+		schema = &Schema{Type: SchemaType{"string"}, Format: "date-time"}
+	case typ.Kind() == reflect.Struct && typ.Name() != "":
+		name := schemaName(typ)
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			// Reserve the name first for types that refer to themselves
+			doc.Components.Schemas[name] = &Schema{}
+			*doc.Components.Schemas[name] = *doc.structSchema(typ)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	case typ.Kind() == reflect.Struct:
+		schema = doc.structSchema(typ)
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
+		schema = &Schema{Type: SchemaType{"string"}, Format: "byte"}
+	case typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array:
+		schema = &Schema{Type: SchemaType{"array"}, Items: doc.schemaFor(typ.Elem(), false)}
+	case typ.Kind() == reflect.Map:
+		schema = &Schema{Type: SchemaType{"object"}}
+		if typ.Elem().Kind() != reflect.Interface {
+			schema.AdditionalProperties = doc.schemaFor(typ.Elem(), false)
+		}
+	case typ.Kind() == reflect.Interface:
+		return &Schema{}
+	case typ.Kind() == reflect.String:
+		schema = &Schema{Type: SchemaType{"string"}}
+	case typ.Kind() == reflect.Bool:
+		schema = &Schema{Type: SchemaType{"boolean"}}
+	case typ.Kind() == reflect.Int64 || typ.Kind() == reflect.Uint64:
+		schema = &Schema{Type: SchemaType{"integer"}, Format: "int64"}
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64:
+		schema = &Schema{Type: SchemaType{"integer"}}
+	case typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64:
+		schema = &Schema{Type: SchemaType{"number"}}
+	default:
+		panic(fmt.Sprintf("openapi: no schema for %s", typ))
+	}
+	if nullable && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map) {
+		schema.Type = append(schema.Type, "null")
+	}
+	return schema
+}
+
+// JSONSchema returns the JSON Schema of the encoding of v, a struct, as the
+// OpenAPI document would describe it. The named structs it refers to are
+// under $defs, so the schema stands on its own, such as for MCP tool output.
+func JSONSchema(v any) json.RawMessage {
+	typ := reflect.TypeOf(v)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	doc := &OpenAPI{Components: Components{Schemas: map[string]*Schema{}}}
+	schema := doc.structSchema(typ)
+	if len(doc.Components.Schemas) > 0 {
+		schema.Defs = doc.Components.Schemas
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		panic(err)
+	}
+	return json.RawMessage(strings.ReplaceAll(string(data), `"#/components/schemas/`, `"#/$defs/`))
+}
+
+// structSchema describes the JSON encoding of a struct. Unknown properties are
+// rejected. The openapi tag adds constraints to a field:
+//
+//	Port int `json:"port" openapi:"minimum=0,maximum=65535"`
+//
+// with required, minimum, maximum, minLength, maxLength and enum, whose
+// values are separated by |.
+func (doc *OpenAPI) structSchema(typ reflect.Type) *Schema {
+	schema := &Schema{
+		Type:                 SchemaType{"object"},
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: false,
+	}
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := doc.structSchema(field.Type)
+			for property, s := range embedded.Properties {
+				schema.Properties[property] = s
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		omitempty := strings.Contains(options, "omitempty")
+		property := doc.schemaFor(field.Type, !omitempty)
+		if applyTag(property, field.Tag.Get("openapi")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// applyTag adds the constraints of an openapi struct tag to schema and
+// reports whether the field is required
+func applyTag(schema *Schema, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	required := false
+	for _, option := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "required":
+			required = true
+		case "minimum", "maximum":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				panic(fmt.Sprintf("openapi: invalid %s %q", key, value))
+			}
+			if key == "minimum" {
+				schema.Minimum = &number
+			} else {
+				schema.Maximum = &number
+			}
+		case "minLength", "maxLength":
+			length, err := strconv.Atoi(value)
+			if err != nil {
+				panic(fmt.Sprintf("openapi: invalid %s %q", key, value))
+			}
+			if key == "minLength" {
+				schema.MinLength = &length
+			} else {
+				schema.MaxLength = &length
+			}
+		case "enum":
+			schema.Enum = strings.Split(value, "|")
+		default:
+			panic(fmt.Sprintf("openapi: unknown option %q", key))
+		}
+	}
+	return required
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  *OpenAPI
+	openAPIJSON []byte
+)
+
+// openAPIDocument returns the document of the route table, which is the same
+// for every Router, and its JSON
+func openAPIDocument() (*OpenAPI, []byte) {
+	openAPIOnce.Do(func() {
+		openAPIDoc = NewOpenAPI((&Router{}).Routes())
+		data, err := json.MarshalIndent(openAPIDoc, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		openAPIJSON = append(data, '\n')
+	})
+	return openAPIDoc, openAPIJSON
+}
+
+// OpenAPIDocument returns the OpenAPI document of the REST API as JSON
+func OpenAPIDocument() []byte {
+	_, data := openAPIDocument()
+	return data
+}
+
+// ServeOpenAPI serves the OpenAPI document
+func ServeOpenAPI(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(OpenAPIDocument())
+}
+
+// serveOpenAPI serves the document of the route table, which only differs
+// from the generated one when MCP is served away from DefaultMCPPath
+func (r *Router) serveOpenAPI(ctx *fasthttp.RequestCtx) {
+	if r.mcpPath() == DefaultMCPPath {
+		ServeOpenAPI(ctx)
+		return
+	}
+	data, err := json.MarshalIndent(NewOpenAPI(r.Routes()), "", "  ")
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(append(data, '\n'))
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/apikey"
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/ctrl"
+	"github.com/buaazp/fasthttprouter"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOpenAPIDocumentIsCurrent(t *testing.T) {
+	committed, err := os.ReadFile("../../docs/openapi.json")
+	require.NoError(t, err)
+	// Compared as bytes, a diff of the whole document is too long to read
+	require.True(t, bytes.Equal(OpenAPIDocument(), committed),
+		"docs/openapi.json is stale, regenerate it with: go run . openapi -o docs/openapi.json")
+}
+
+// newRouteTableRouter registers the route table backed by the fake client,
+// where alice can get tokens with the password s3cret. The returned Config
+// issues tokens the router accepts.
+func newRouteTableRouter(t *testing.T) (*fasthttprouter.Router, *Config) {
+	t.Helper()
+	config := newAPIKeysConfig(t)
+	config.JWTSecret, config.TokenAuthenticator = "test-secret", newTestUsers(t)
+
+	page := testPage("home", "", nil)
+	owned := ctrl.BuildOwnedResources(page)
+	controller := true
+	owned.ConfigMap.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: frontendv1alpha1.SchemeGroupVersion.String(),
+		Kind:       "FrontendPage",
+		Name:       "home",
+		Controller: &controller,
+	}}
+	api := &Router{
+		Service:         newPreviewApi(t, page, owned.ConfigMap),
+		Config:          config,
+		DeploymentNames: func() []string { return []string{"home"} },
+	}
+
+	router := fasthttprouter.New()
+	api.RegisterRoutes(router, nil)
+	router.NotFound = func(ctx *fasthttp.RequestCtx) { ctx.SetStatusCode(599) }
+	router.MethodNotAllowed = router.NotFound
+	return router, config
+}
+
+// serveRoute sends a request through the router and returns the response
+func serveRoute(router *fasthttprouter.Router, method, uri, token, contentType, body string) *fasthttp.Response {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(uri)
+	if token != "" {
+		ctx.Request.Header.Set("Authorization", "Bearer "+token)
+	}
+	if contentType != "" {
+		ctx.Request.Header.SetContentType(contentType)
+	}
+	ctx.Request.SetBodyString(body)
+	router.Handler(ctx)
+	resp := &fasthttp.Response{}
+	ctx.Response.CopyTo(resp)
+	return resp
+}
+
+// TestRoutesMatchOpenAPI calls every operation of the document through the
+// router, and checks that the status and body of the response are documented
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	t.Parallel()
+	router, config := newRouteTableRouter(t)
+	doc, _ := openAPIDocument()
+
+	tokens, err := config.IssueToken(&Identity{Subject: "alice"})
+	require.NoError(t, err)
+	other, err := config.IssueToken(&Identity{Subject: "alice"})
+	require.NoError(t, err)
+	_, key, err := config.APIKeys.Create(context.Background(), apikey.CreateOptions{Name: "old", Verbs: []string{"get"}, Namespaces: []string{"default"}, CreatedBy: "alice"})
+	require.NoError(t, err)
+	bundleYAML := "apiVersion: " + frontendv1alpha1.SchemeGroupVersion.String() + "\nkind: FrontendPage\nmetadata:\n  name: imported\nspec:\n  content: <h1>imported</h1>\n  image: nginx:latest\n  replicas: 1\n  port: 80\n"
+
+	steps := []struct {
+		method, path string
+		uri          string
+		contentType  string
+		body         string
+		status       int
+	}{
+		{"GET", "/openapi.json", "/openapi.json", "", "", 200},
+		{"GET", "/health", "/health", "", "", 200},
+		{"GET", "/deployments", "/deployments", "", "", 200},
+		{"POST", "/api/token", "/api/token", "application/json", `{"username":"alice","password":"s3cret"}`, 200},
+		{"POST", "/api/token/refresh", "/api/token/refresh", "application/json", `{"refreshToken":"` + tokens.RefreshToken + `"}`, 200},
+		{"POST", "/api/token/introspect", "/api/token/introspect", "", "", 200},
+		{"POST", "/api/token/revoke", "/api/token/revoke", "application/json", `{"token":"` + other.Token + `"}`, 204},
+		{"GET", "/api/frontendpages", "/api/frontendpages?limit=1", "", "", 200},
+		{"POST", "/api/frontendpages", "/api/frontendpages", "application/json", `{"name":"docs","content":"<h1>docs</h1>","image":"nginx:latest","replicas":1,"port":80}`, 201},
+		{"GET", "/api/frontendpages/export", "/api/frontendpages/export?format=json", "", "", 200},
+		{"POST", "/api/frontendpages/import", "/api/frontendpages/import?conflict=skip", "application/yaml", bundleYAML, 200},
+		{"GET", "/api/frontendpages/{name}", "/api/frontendpages/home", "", "", 200},
+		{"PUT", "/api/frontendpages/{name}", "/api/frontendpages/docs?dryRun=All", "application/json", `{"name":"docs","content":"<h1>v2</h1>","image":"nginx:latest","replicas":2,"port":80}`, 200},
+		{"PATCH", "/api/frontendpages/{name}", "/api/frontendpages/docs", "application/json", `{"replicas":3}`, 200},
+		{"GET", "/api/frontendpages/{name}/content", "/api/frontendpages/home/content", "", "", 200},
+		{"POST", "/api/frontendpages/{name}/content", "/api/frontendpages/draft/content", "application/json", `{"content":"<h1>draft</h1>"}`, 200},
+		{"POST", "/api/frontendpages/{name}/preview", "/api/frontendpages/home/preview", "", "", 200},
+		{"DELETE", "/api/frontendpages/{name}", "/api/frontendpages/docs", "", "", 200},
+		// MCP is served by cmd, see TestMCPTransports_ShareRegistry there
+		{"POST", "/mcp", "/mcp", "application/json", `{}`, 503},
+		{"GET", "/mcp", "/mcp", "", "", 503},
+		{"DELETE", "/mcp", "/mcp", "", "", 503},
+		{"GET", "/api/apikeys", "/api/apikeys", "", "", 200},
+		{"POST", "/api/apikeys", "/api/apikeys", "application/json", `{"name":"ci","verbs":["get"]}`, 201},
+		{"DELETE", "/api/apikeys/{id}", "/api/apikeys/" + key.ID, "", "", 204},
+	}
+
+	called := map[string]bool{}
+	for _, step := range steps {
+		name := step.method + " " + step.path
+		called[name] = true
+		op := doc.Paths[step.path][strings.ToLower(step.method)]
+		require.NotNil(t, op, "%s is not in the OpenAPI document", name)
+
+		token := ""
+		if len(op.Security) > 0 {
+			token = tokens.Token
+		}
+		resp := serveRoute(router, step.method, step.uri, token, step.contentType, step.body)
+		require.Equal(t, step.status, resp.StatusCode(), "%s: %s", name, resp.Body())
+
+		documented, ok := op.Responses[strconv.Itoa(resp.StatusCode())]
+		require.True(t, ok, "%s answers %d, which is not documented", name, resp.StatusCode())
+		if len(documented.Content) == 0 {
+			require.Empty(t, resp.Body(), name)
+			continue
+		}
+		mediaType, _, err := mime.ParseMediaType(string(resp.Header.ContentType()))
+		require.NoError(t, err, name)
+		content, ok := documented.Content[mediaType]
+		require.True(t, ok, "%s answers %s, which is not documented", name, mediaType)
+		if !isJSON(mediaType) {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(resp.Body()))
+		decoder.UseNumber()
+		var body any
+		require.NoError(t, decoder.Decode(&body), name)
+		v := &schemaValidator{doc: doc}
+		v.validate(content.Schema, "#", "", body)
+		require.Empty(t, v.causes, "%s answers a body that doesn't match its schema", name)
+	}
+
+	// Every operation of the document must be called above
+	for path, item := range doc.Paths {
+		for method := range item {
+			name := strings.ToUpper(method) + " " + path
+			require.True(t, called[name], "%s is documented but not tested, add it to the steps", name)
+		}
+	}
+}
+
+func TestRegisterRoutes_ReservedNames(t *testing.T) {
+	t.Parallel()
+	router, config := newRouteTableRouter(t)
+	tokens, err := config.IssueToken(&Identity{Subject: "alice"})
+	require.NoError(t, err)
+
+	// import is only reserved for POST, other names have no POST route
+	resp := serveRoute(router, "POST", "/api/frontendpages/home", tokens.Token, "", "")
+	require.Equal(t, fasthttp.StatusNotFound, resp.StatusCode())
+	require.Equal(t, ProblemContentType, string(resp.Header.ContentType()))
+
+	resp = serveRoute(router, "PUT", "/api/frontendpages", tokens.Token, "", "")
+	require.Equal(t, 599, resp.StatusCode(), "the router answers undocumented methods")
+}
+
+func TestServeOpenAPI_MCPPath(t *testing.T) {
+	router := fasthttprouter.New()
+	(&Router{Config: NewConfig(), MCPPath: "/rpc"}).RegisterRoutes(router, nil)
+
+	resp := serveRoute(router, "GET", "/openapi.json", "", "", "")
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+	var doc struct {
+		Paths map[string]any `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body(), &doc))
+	require.Contains(t, doc.Paths, "/rpc")
+	require.NotContains(t, doc.Paths, DefaultMCPPath)
+
+	resp = serveRoute(router, "POST", "/rpc", "", "application/json", "{}")
+	require.Equal(t, fasthttp.StatusUnauthorized, resp.StatusCode())
+}
+
+func TestJSONSchema(t *testing.T) {
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(JSONSchema(FrontendPageDocList{}), &schema))
+	require.Equal(t, "object", schema["type"])
+	items := schema["properties"].(map[string]any)["items"].(map[string]any)
+	require.Equal(t, map[string]any{"$ref": "#/$defs/api.FrontendPageDoc"}, items["items"])
+	require.Contains(t, schema["$defs"], "api.FrontendPageDoc")
+
+	// metav1.Time encodes as a timestamp, not as the struct it is
+	require.NoError(t, json.Unmarshal(JSONSchema(metav1.Condition{}), &schema))
+	lastTransition := schema["properties"].(map[string]any)["lastTransitionTime"].(map[string]any)
+	require.Equal(t, "date-time", lastTransition["format"])
+}
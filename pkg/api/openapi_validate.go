@@ -0,0 +1,297 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/big"
+	"mime"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// patterns caches the compiled schema patterns
+var patterns sync.Map
+
+// schemaValidator collects the places where a value doesn't match a schema.
+// Every cause carries the JSON pointer of the failing keyword in the document.
+type schemaValidator struct {
+	doc    *OpenAPI
+	causes []ProblemCause
+}
+
+// pointerEscape escapes a JSON pointer reference token (RFC 6901)
+func pointerEscape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// operationPointer is the JSON pointer of an operation in the document
+func operationPointer(route Route) string {
+	return "#/paths/" + pointerEscape(route.Path) + "/" + strings.ToLower(route.Method)
+}
+
+func joinField(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+func (v *schemaValidator) fail(field, reason, schemaPath, format string, args ...any) {
+	subject := field
+	if subject == "" {
+		subject = "request body"
+	}
+	v.causes = append(v.causes, ProblemCause{
+		Field:      field,
+		Reason:     reason,
+		Message:    subject + " " + fmt.Sprintf(format, args...),
+		SchemaPath: schemaPath,
+	})
+}
+
+// jsonType is the JSON Schema type of a value decoded with UseNumber
+func jsonType(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, ok := new(big.Int).SetString(value.String(), 10); ok {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// validate checks value, found at field, against the schema at schemaPath
+func (v *schemaValidator) validate(schema *Schema, schemaPath, field string, value any) {
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		v.validate(v.doc.Components.Schemas[name], schema.Ref, field, value)
+		return
+	}
+
+	if len(schema.Type) > 0 {
+		actual := jsonType(value)
+		matches := false
+		for _, typ := range schema.Type {
+			if typ == actual || (typ == "number" && actual == "integer") {
+				matches = true
+			}
+		}
+		if !matches {
+			v.fail(field, string(metav1.CauseTypeTypeInvalid), schemaPath+"/type", "must be of type %s", strings.Join(schema.Type, " or "))
+			return
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		v.validateString(schema, schemaPath, field, value)
+	case json.Number:
+		number, _ := strconv.ParseFloat(value.String(), 64)
+		if schema.Minimum != nil && number < *schema.Minimum {
+			v.fail(field, string(metav1.CauseTypeFieldValueInvalid), schemaPath+"/minimum", "must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && number > *schema.Maximum {
+			v.fail(field, string(metav1.CauseTypeFieldValueInvalid), schemaPath+"/maximum", "must be at most %v", *schema.Maximum)
+		}
+	case []any:
+		if schema.Items != nil {
+			for i, item := range value {
+				v.validate(schema.Items, schemaPath+"/items", fmt.Sprintf("%s[%d]", field, i), item)
+			}
+		}
+	case map[string]any:
+		v.validateObject(schema, schemaPath, field, value)
+	}
+}
+
+func (v *schemaValidator) validateString(schema *Schema, schemaPath, field, value string) {
+	if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, value) {
+		v.fail(field, string(metav1.CauseTypeFieldValueNotSupported), schemaPath+"/enum", "must be one of %s, not %q", strings.Join(schema.Enum, ", "), value)
+	}
+	length := utf8.RuneCountInString(value)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		v.fail(field, string(metav1.CauseTypeFieldValueInvalid), schemaPath+"/minLength", "must be at least %d characters", *schema.MinLength)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		v.fail(field, string(metav1.CauseTypeTooLong), schemaPath+"/maxLength", "must be at most %d characters", *schema.MaxLength)
+	}
+	if schema.Pattern != "" {
+		compiled, ok := patterns.Load(schema.Pattern)
+		if !ok {
+			compiled, _ = patterns.LoadOrStore(schema.Pattern, regexp.MustCompile(schema.Pattern))
+		}
+		if !compiled.(*regexp.Regexp).MatchString(value) {
+			v.fail(field, string(metav1.CauseTypeFieldValueInvalid), schemaPath+"/pattern", "must match %s", schema.Pattern)
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(schema *Schema, schemaPath, field string, value map[string]any) {
+	for _, name := range schema.Required {
+		if _, ok := value[name]; !ok {
+			v.fail(joinField(field, name), string(metav1.CauseTypeFieldValueRequired), schemaPath+"/required", "is required")
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(value)) {
+		if property, ok := schema.Properties[name]; ok {
+			v.validate(property, schemaPath+"/properties/"+pointerEscape(name), joinField(field, name), value[name])
+			continue
+		}
+		switch additional := schema.AdditionalProperties.(type) {
+		case bool:
+			if !additional {
+				v.fail(joinField(field, name), string(metav1.CauseTypeFieldValueNotSupported), schemaPath+"/additionalProperties", "is not a known field")
+			}
+		case *Schema:
+			v.validate(additional, schemaPath+"/additionalProperties", joinField(field, name), value[name])
+		}
+	}
+}
+
+// ValidateRequest rejects requests whose parameters or JSON body don't match
+// the route in the OpenAPI document with a 400 Problem, with a cause and its
+// schema path for every mismatch. Bodies in media types the route doesn't
+// accept are rejected with 415.
+func ValidateRequest(route Route, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	if len(route.Params) == 0 && route.Body == nil {
+		return next
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		doc, _ := openAPIDocument()
+		if problem := validateRequest(doc, route, ctx); problem != nil {
+			WriteProblem(ctx, problem)
+			return
+		}
+		next(ctx)
+	}
+}
+
+func validateRequest(doc *OpenAPI, route Route, ctx *fasthttp.RequestCtx) *Problem {
+	v := &schemaValidator{doc: doc}
+	pointer := operationPointer(route)
+	for i, param := range route.Params {
+		schemaPath := fmt.Sprintf("%s/parameters/%d", pointer, i)
+		var raw string
+		var ok bool
+		switch param.In {
+		case "path":
+			raw, ok = ctx.UserValue(param.Name).(string)
+		case "query":
+			if value := ctx.QueryArgs().Peek(param.Name); value != nil {
+				raw, ok = string(value), true
+			}
+		}
+		if !ok {
+			if param.Required {
+				v.fail(param.Name, string(metav1.CauseTypeFieldValueRequired), schemaPath+"/required", "is required")
+			}
+			continue
+		}
+		v.validate(param.Schema, schemaPath+"/schema", param.Name, parameterValue(param.Schema, raw))
+	}
+
+	body := ctx.PostBody()
+	if route.Body != nil && len(body) > 0 {
+		if contentType := string(ctx.Request.Header.ContentType()); !acceptsContentType(route.Body.ContentTypes, contentType) {
+			return NewProblem(fasthttp.StatusUnsupportedMediaType,
+				fmt.Sprintf("unsupported media type %q, send %s", contentType, strings.Join(route.Body.ContentTypes, " or ")))
+		}
+	}
+	if route.Body != nil && route.Body.Type != nil && isJSON(string(ctx.Request.Header.ContentType())) {
+		schemaPath := pointer + "/requestBody/content/" + pointerEscape("application/json") + "/schema"
+		switch {
+		case len(bytes.TrimSpace(body)) == 0:
+			if route.Body.Required {
+				v.fail("", string(metav1.CauseTypeFieldValueRequired), pointer+"/requestBody/required", "is required")
+			}
+		default:
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+			var value any
+			if err := decoder.Decode(&value); err != nil {
+				return BadRequest("invalid request body: %v", err)
+			}
+			v.validate(doc.Paths[route.Path][strings.ToLower(route.Method)].RequestBody.Content["application/json"].Schema, schemaPath, "", value)
+		}
+	}
+
+	if len(v.causes) == 0 {
+		return nil
+	}
+	problem := BadRequest("request doesn't match the API schema")
+	if len(v.causes) == 1 {
+		problem.Detail = v.causes[0].Message
+	}
+	problem.Causes = v.causes
+	return problem
+}
+
+// parameterValue converts a raw parameter to the JSON value its schema
+// expects, or leaves it a string for validate to reject
+func parameterValue(schema *Schema, raw string) any {
+	if len(schema.Type) == 0 {
+		return raw
+	}
+	switch schema.Type[0] {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			return json.Number(raw)
+		}
+	case "boolean":
+		if value, err := strconv.ParseBool(raw); err == nil {
+			return value
+		}
+	}
+	return raw
+}
+
+// acceptsContentType reports whether a request content type is one of the
+// accepted media types. JSON routes also accept the +json suffix. Requests
+// without a content type are assumed to send the first one, like the handlers
+// do.
+func acceptsContentType(accepted []string, contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasSuffix(mediaType, "+json") {
+		mediaType = "application/json"
+	}
+	return slices.Contains(accepted, mediaType)
+}
+
+// isJSON reports whether a request content type is JSON. Requests without a
+// content type are assumed to send JSON, like the handlers do.
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestValidateRequest(t *testing.T) {
+	t.Parallel()
+	router, config := newRouteTableRouter(t)
+	tokens, err := config.IssueToken(&Identity{Subject: "alice"})
+	require.NoError(t, err)
+
+	problemOf := func(resp *fasthttp.Response) Problem {
+		t.Helper()
+		require.Equal(t, fasthttp.StatusBadRequest, resp.StatusCode(), string(resp.Body()))
+		var problem Problem
+		require.NoError(t, json.Unmarshal(resp.Body(), &problem))
+		require.Equal(t, "BadRequest", problem.Reason)
+		return problem
+	}
+
+	problem := problemOf(serveRoute(router, "PUT", "/api/frontendpages/home?dryRun=yes", tokens.Token, "application/json",
+		`{"name":"home","contents":"<h1>typo</h1>","replicas":"2","port":70000}`))
+	require.Equal(t, []ProblemCause{
+		{Field: "dryRun", Reason: "FieldValueNotSupported", Message: `dryRun must be one of All, not "yes"`, SchemaPath: "#/paths/~1api~1frontendpages~1{name}/put/parameters/1/schema/enum"},
+		{Field: "contents", Reason: "FieldValueNotSupported", Message: "contents is not a known field", SchemaPath: "#/components/schemas/api.FrontendPageDoc/additionalProperties"},
+		{Field: "port", Reason: "FieldValueInvalid", Message: "port must be at most 65535", SchemaPath: "#/components/schemas/api.FrontendPageDoc/properties/port/maximum"},
+		{Field: "replicas", Reason: "FieldValueTypeInvalid", Message: "replicas must be of type integer", SchemaPath: "#/components/schemas/api.FrontendPageDoc/properties/replicas/type"},
+	}, problem.Causes)
+
+	problem = problemOf(serveRoute(router, "GET", "/api/frontendpages?limit=0&watch=maybe", tokens.Token, "", ""))
+	require.Equal(t, []string{"limit", "watch"}, []string{problem.Causes[0].Field, problem.Causes[1].Field})
+	require.Equal(t, "#/paths/~1api~1frontendpages/get/parameters/0/schema/minimum", problem.Causes[0].SchemaPath)
+
+	problem = problemOf(serveRoute(router, "GET", "/api/frontendpages/Not_A_Name", tokens.Token, "", ""))
+	require.Equal(t, "name", problem.Causes[0].Field)
+	require.Equal(t, "#/paths/~1api~1frontendpages~1{name}/get/parameters/0/schema/pattern", problem.Causes[0].SchemaPath)
+
+	problem = problemOf(serveRoute(router, "POST", "/api/apikeys", tokens.Token, "application/json", `{"verbs":[1]}`))
+	require.Equal(t, []ProblemCause{
+		{Field: "name", Reason: "FieldValueRequired", Message: "name is required", SchemaPath: "#/components/schemas/api.CreateAPIKeyRequest/required"},
+		{Field: "verbs[0]", Reason: "FieldValueTypeInvalid", Message: "verbs[0] must be of type string", SchemaPath: "#/components/schemas/api.CreateAPIKeyRequest/properties/verbs/items/type"},
+	}, problem.Causes)
+
+	problem = problemOf(serveRoute(router, "POST", "/api/frontendpages", tokens.Token, "application/json", ""))
+	require.Equal(t, "request body is required", problem.Detail)
+
+	problem = problemOf(serveRoute(router, "POST", "/api/frontendpages", tokens.Token, "application/json", "{"))
+	require.Empty(t, problem.Causes)
+
+	// Bodies in media types the route doesn't accept are refused
+	resp := serveRoute(router, "POST", "/api/frontendpages/home/preview", tokens.Token, "text/plain", "not json")
+	require.Equal(t, fasthttp.StatusUnsupportedMediaType, resp.StatusCode())
+	require.Contains(t, string(resp.Body()), `unsupported media type \"text/plain\"`)
+	resp = serveRoute(router, "POST", "/api/frontendpages/import", tokens.Token, "application/x-www-form-urlencoded", "name=home")
+	require.Equal(t, fasthttp.StatusUnsupportedMediaType, resp.StatusCode())
+	resp = serveRoute(router, "POST", "/api/frontendpages/home/preview", tokens.Token, "application/merge-patch+json; charset=utf-8", "{}")
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), string(resp.Body()))
+
+	// Requests are authenticated before they are validated
+	resp = serveRoute(router, "GET", "/api/frontendpages?limit=0", "", "", "")
+	require.Equal(t, fasthttp.StatusUnauthorized, resp.StatusCode())
+}
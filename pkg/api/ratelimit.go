@@ -0,0 +1,189 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Reasons requests are rejected before reaching a handler
+const (
+	RejectReasonRateLimited  = "rate_limited"
+	RejectReasonBodyTooLarge = "body_too_large"
+	RejectReasonTimeout      = "timeout"
+	RejectReasonMalformed    = "malformed"
+)
+
+// rejectedRequests counts rejected requests on the manager's metrics endpoint
+var rejectedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "frontendpage_api_rejected_requests_total",
+	Help: "Requests rejected by the API server before reaching a handler, by route and reason",
+}, []string{"route", "reason"})
+
+func init() {
+	metrics.Registry.MustRegister(rejectedRequests)
+}
+
+// RateLimit is a token bucket: Rate requests per second on average, with
+// bursts of up to Burst requests. A zero Rate means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseRouteRateLimit parses a per-route budget in the form Route=rate:burst,
+// for example ListFrontendPages=5:10
+func ParseRouteRateLimit(value string) (string, RateLimit, error) {
+	route, budget, ok := strings.Cut(value, "=")
+	if !ok || route == "" {
+		return "", RateLimit{}, fmt.Errorf("rate limit %q is not in the form Route=rate:burst", value)
+	}
+	rateValue, burstValue, ok := strings.Cut(budget, ":")
+	if !ok {
+		return "", RateLimit{}, fmt.Errorf("rate limit %q is not in the form Route=rate:burst", value)
+	}
+	r, err := strconv.ParseFloat(rateValue, 64)
+	if err != nil || r < 0 {
+		return "", RateLimit{}, fmt.Errorf("invalid rate in %q", value)
+	}
+	burst, err := strconv.Atoi(burstValue)
+	if err != nil || burst < 1 {
+		return "", RateLimit{}, fmt.Errorf("invalid burst in %q", value)
+	}
+	return route, RateLimit{Rate: r, Burst: burst}, nil
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimiter keeps a token bucket per route and client. Buckets of clients
+// that have been idle for IdleTimeout are dropped.
+type RateLimiter struct {
+	Default     RateLimit
+	Routes      map[string]RateLimit
+	IdleTimeout time.Duration
+
+	mu        sync.Mutex
+	limiters  map[string]*clientLimiter
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter applies def to routes without their own budget
+func NewRateLimiter(def RateLimit, routes map[string]RateLimit) *RateLimiter {
+	return &RateLimiter{
+		Default:     def,
+		Routes:      routes,
+		IdleTimeout: 10 * time.Minute,
+		limiters:    map[string]*clientLimiter{},
+		now:         time.Now,
+	}
+}
+
+func (l *RateLimiter) budget(route string) RateLimit {
+	if budget, ok := l.Routes[route]; ok {
+		return budget
+	}
+	return l.Default
+}
+
+// Allow takes a token for client on route. When the bucket is empty it
+// returns false and how long until a token is available.
+func (l *RateLimiter) Allow(route, client string) (bool, time.Duration) {
+	budget := l.budget(route)
+	if budget.Rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastPrune) > l.IdleTimeout {
+		for key, cl := range l.limiters {
+			if now.Sub(cl.lastSeen) > l.IdleTimeout {
+				delete(l.limiters, key)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	key := route + "\x00" + client
+	cl, ok := l.limiters[key]
+	if !ok {
+		cl = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(budget.Rate), max(budget.Burst, 1))}
+		l.limiters[key] = cl
+	}
+	cl.lastSeen = now
+
+	reservation := cl.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// rateLimitClient identifies the caller by token subject, or by IP address
+// before authentication
+func rateLimitClient(ctx *fasthttp.RequestCtx) string {
+	if identity := IdentityFromRequest(ctx); identity != nil {
+		return "user:" + identity.Subject
+	}
+	return "ip:" + ctx.RemoteIP().String()
+}
+
+// RateLimitMiddleware wraps the handler for route with the RateLimits budget.
+// Behind JwtMiddleware clients are told apart by subject, elsewhere by IP.
+func (c *Config) RateLimitMiddleware(route string, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if c.RateLimits == nil {
+			next(ctx)
+			return
+		}
+		client := rateLimitClient(ctx)
+		allowed, retryAfter := c.RateLimits.Allow(route, client)
+		if !allowed {
+			rejectedRequests.WithLabelValues(route, RejectReasonRateLimited).Inc()
+			AddSpanEventToRequest(ctx, "rate_limited",
+				attribute.String("ratelimit.client", client),
+				attribute.Float64("ratelimit.retry_after_seconds", retryAfter.Seconds()),
+			)
+			ctx.Response.Header.Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			problem := NewProblem(fasthttp.StatusTooManyRequests, fmt.Sprintf("rate limit exceeded, retry in %s", retryAfter.Round(time.Millisecond)))
+			problem.Reason = "TooManyRequests"
+			WriteProblem(ctx, problem)
+			return
+		}
+		next(ctx)
+	}
+}
+
+// ServerErrorHandler answers requests fasthttp rejects before routing them,
+// such as oversized bodies and read timeouts, with problem details
+func ServerErrorHandler(ctx *fasthttp.RequestCtx, err error) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, fasthttp.ErrBodyTooLarge):
+		rejectedRequests.WithLabelValues("", RejectReasonBodyTooLarge).Inc()
+		WriteProblem(ctx, NewProblem(fasthttp.StatusRequestEntityTooLarge, "request body is too large"))
+	case errors.As(err, &netErr) && netErr.Timeout():
+		rejectedRequests.WithLabelValues("", RejectReasonTimeout).Inc()
+		WriteProblem(ctx, NewProblem(fasthttp.StatusRequestTimeout, "timed out reading the request"))
+	default:
+		rejectedRequests.WithLabelValues("", RejectReasonMalformed).Inc()
+		WriteProblem(ctx, BadRequest("malformed request: %v", err))
+	}
+}
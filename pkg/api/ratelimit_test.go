@@ -0,0 +1,150 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// rejectedCount reads the rejected requests counter for route and reason
+func rejectedCount(t *testing.T, route, reason string) float64 {
+	t.Helper()
+	var metric dto.Metric
+	require.NoError(t, rejectedRequests.WithLabelValues(route, reason).Write(&metric))
+	return metric.GetCounter().GetValue()
+}
+
+func TestParseRouteRateLimit(t *testing.T) {
+	route, budget, err := ParseRouteRateLimit("ListFrontendPages=2.5:5")
+	require.NoError(t, err)
+	require.Equal(t, "ListFrontendPages", route)
+	require.Equal(t, RateLimit{Rate: 2.5, Burst: 5}, budget)
+
+	for _, value := range []string{"ListFrontendPages", "=1:1", "ListFrontendPages=1", "ListFrontendPages=x:1", "ListFrontendPages=1:0"} {
+		_, _, err := ParseRouteRateLimit(value)
+		require.Error(t, err, value)
+	}
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := NewRateLimiter(RateLimit{Rate: 1, Burst: 2}, map[string]RateLimit{
+		"ListFrontendPages": {Rate: 0.5, Burst: 1},
+		"GetFrontendPage":   {},
+	})
+	limiter.now = func() time.Time { return now }
+
+	for range 2 {
+		allowed, _ := limiter.Allow("CreateFrontendPage", "alice")
+		require.True(t, allowed)
+	}
+	allowed, retryAfter := limiter.Allow("CreateFrontendPage", "alice")
+	require.False(t, allowed)
+	require.Equal(t, time.Second, retryAfter)
+
+	// Clients and routes have their own buckets
+	allowed, _ = limiter.Allow("CreateFrontendPage", "bob")
+	require.True(t, allowed)
+	allowed, _ = limiter.Allow("ListFrontendPages", "alice")
+	require.True(t, allowed)
+	allowed, retryAfter = limiter.Allow("ListFrontendPages", "alice")
+	require.False(t, allowed)
+	require.Equal(t, 2*time.Second, retryAfter)
+
+	// A zero rate disables the limit for a route
+	for range 10 {
+		allowed, _ = limiter.Allow("GetFrontendPage", "alice")
+		require.True(t, allowed)
+	}
+
+	now = now.Add(time.Second)
+	allowed, _ = limiter.Allow("CreateFrontendPage", "alice")
+	require.True(t, allowed, "the bucket refills over time")
+
+	now = now.Add(time.Hour)
+	limiter.Allow("CreateFrontendPage", "carol")
+	require.Len(t, limiter.limiters, 1, "idle clients are dropped")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	config := NewConfig()
+	config.RateLimits = NewRateLimiter(RateLimit{Rate: 1, Burst: 1}, nil)
+
+	ok := func(ctx *fasthttp.RequestCtx) { ctx.SetStatusCode(fasthttp.StatusOK) }
+	handler := config.RateLimitMiddleware("ListFrontendPages", ok)
+	request := func(identity *Identity) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		if identity != nil {
+			ctx.SetUserValue(identityKey, identity)
+		}
+		handler(ctx)
+		return ctx
+	}
+	rejected := rejectedCount(t, "ListFrontendPages", RejectReasonRateLimited)
+
+	require.Equal(t, fasthttp.StatusOK, request(&Identity{Subject: "alice"}).Response.StatusCode())
+	ctx := request(&Identity{Subject: "alice"})
+	require.Equal(t, fasthttp.StatusTooManyRequests, ctx.Response.StatusCode())
+	require.Equal(t, "1", string(ctx.Response.Header.Peek("Retry-After")))
+	require.Contains(t, string(ctx.Response.Body()), "TooManyRequests")
+	require.Equal(t, rejected+1, rejectedCount(t, "ListFrontendPages", RejectReasonRateLimited))
+
+	// Other subjects and anonymous callers, keyed by IP, are not affected
+	require.Equal(t, fasthttp.StatusOK, request(&Identity{Subject: "bob"}).Response.StatusCode())
+	require.Equal(t, fasthttp.StatusOK, request(nil).Response.StatusCode())
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestServerErrorHandler(t *testing.T) {
+	for _, tt := range []struct {
+		err    error
+		status int
+		reason string
+	}{
+		{fasthttp.ErrBodyTooLarge, fasthttp.StatusRequestEntityTooLarge, RejectReasonBodyTooLarge},
+		{timeoutError{}, fasthttp.StatusRequestTimeout, RejectReasonTimeout},
+		{errors.New("cannot parse request line"), fasthttp.StatusBadRequest, RejectReasonMalformed},
+	} {
+		before := rejectedCount(t, "", tt.reason)
+		ctx := &fasthttp.RequestCtx{}
+		ServerErrorHandler(ctx, tt.err)
+		require.Equal(t, tt.status, ctx.Response.StatusCode())
+		require.Equal(t, before+1, rejectedCount(t, "", tt.reason))
+	}
+}
+
+func TestServer_MaxRequestBodySize(t *testing.T) {
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{
+		Handler:            func(ctx *fasthttp.RequestCtx) { ctx.SetStatusCode(fasthttp.StatusOK) },
+		MaxRequestBodySize: 16,
+		ErrorHandler:       ServerErrorHandler,
+	}
+	go server.Serve(ln)
+	t.Cleanup(func() { ln.Close() })
+	client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+
+	post := func(body string) int {
+		req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetRequestURI("http://api/api/frontendpages")
+		req.SetBodyString(body)
+		require.NoError(t, client.Do(req, resp))
+		return resp.StatusCode()
+	}
+	require.Equal(t, fasthttp.StatusOK, post(`{"name":"page"}`))
+	require.Equal(t, fasthttp.StatusRequestEntityTooLarge, post(`{"name":"a-much-longer-page"}`))
+}
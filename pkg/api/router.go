@@ -0,0 +1,505 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/apikey"
+	"github.com/JRaver/k8s-controller-tutorial/pkg/bundle"
+	"github.com/buaazp/fasthttprouter"
+	"github.com/valyala/fasthttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dns1123Subdomain is the format of Kubernetes object names
+const dns1123Subdomain = `^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+
+var (
+	maxNameLength = 253
+	minListLimit  = 1.0
+	maxLimit      = float64(maxListLimit)
+)
+
+func stringSchema() *Schema {
+	return &Schema{Type: SchemaType{"string"}}
+}
+
+func queryParam(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+var pageNameParam = Parameter{
+	Name:        "name",
+	In:          "path",
+	Description: "Name of the frontend page",
+	Required:    true,
+	Schema:      &Schema{Type: SchemaType{"string"}, MaxLength: &maxNameLength, Pattern: dns1123Subdomain},
+}
+
+var dryRunParam = queryParam("dryRun", "Validate the request with the API server without persisting it",
+	&Schema{Type: SchemaType{"string"}, Enum: []string{metav1.DryRunAll}})
+
+var jsonContent = []string{"application/json"}
+
+// Router serves the REST API of a FrontendPageService
+type Router struct {
+	Service FrontendPageService
+	// Config authenticates, audits and rate limits the requests
+	Config *Config
+	// Watcher streams changes to watch requests. Nil leaves watches
+	// unavailable.
+	Watcher *FrontendPageWatcher
+	// DeploymentNames lists the Deployments served at /deployments. Nil
+	// lists none.
+	DeploymentNames func() []string
+	// MCP serves the MCP streamable HTTP transport at MCPPath, which
+	// defaults to DefaultMCPPath. Nil leaves the endpoint unavailable.
+	MCP     fasthttp.RequestHandler
+	MCPPath string
+}
+
+// secured authorizes the verbs of a FrontendPage route and audits it
+func (r *Router) secured(route Route, verbs ...string) Route {
+	route.Authenticated = true
+	route.Tag = "frontendpages"
+	route.Handler = r.Audit(route.Operation, r.Authorize(route.Handler, verbs...), verbs...)
+	return route
+}
+
+// Routes is the route table of the REST API, see Route
+func (r *Router) Routes() []Route {
+	pageResponse := func(status int, description string) RouteResponse {
+		return RouteResponse{Status: status, Description: description, Type: FrontendPageDoc{}, ContentTypes: jsonContent}
+	}
+	htmlResponse := RouteResponse{Status: fasthttp.StatusOK, Description: "Rendered page content", ContentTypes: []string{"text/html"}}
+	notFound := problemResponse(fasthttp.StatusNotFound, "The frontend page doesn't exist")
+	conflict := problemResponse(fasthttp.StatusConflict, "The frontend page exists or was changed concurrently")
+	invalid := problemResponse(fasthttp.StatusUnprocessableEntity, "The API server rejected the page")
+	unavailable := problemResponse(fasthttp.StatusServiceUnavailable, "The feature is not configured")
+	unavailableMCP := problemResponse(fasthttp.StatusServiceUnavailable, "MCP is not enabled")
+	tokenResponse := RouteResponse{Status: fasthttp.StatusOK, Description: "New access and refresh token", Type: TokenResponse{}, ContentTypes: jsonContent}
+
+	return []Route{
+		{
+			Method:      fasthttp.MethodGet,
+			Path:        "/openapi.json",
+			Operation:   "GetOpenAPI",
+			Summary:     "Get the OpenAPI document",
+			Description: "Describe this API as an OpenAPI 3.1 document, generated from the route table",
+			Tag:         "meta",
+			Responses:   []RouteResponse{{Status: fasthttp.StatusOK, Description: "OpenAPI document", Type: map[string]any{}, ContentTypes: jsonContent}},
+			Handler:     r.serveOpenAPI,
+		},
+		{
+			Method:      fasthttp.MethodGet,
+			Path:        "/health",
+			Operation:   "HealthCheck",
+			Summary:     "Check the server health",
+			Description: "Report that the server is up",
+			Tag:         "meta",
+			Responses:   []RouteResponse{{Status: fasthttp.StatusOK, Description: "The server is up", Type: HealthStatus{}, ContentTypes: jsonContent}},
+			Handler:     HealthCheck,
+		},
+		{
+			Method:      fasthttp.MethodGet,
+			Path:        "/deployments",
+			Operation:   "ListDeployments",
+			Summary:     "List deployments",
+			Description: "List the names of the Deployments seen by the deployment informer",
+			Tag:         "meta",
+			Responses:   []RouteResponse{{Status: fasthttp.StatusOK, Description: "Names of the Deployments", Type: []string{}, ContentTypes: jsonContent}},
+			Handler:     r.ListDeployments,
+		},
+
+		{
+			Method:      fasthttp.MethodPost,
+			Path:        "/api/token",
+			Operation:   "GenerateToken",
+			Summary:     "Issue an API token",
+			Description: "Exchange a username and password (JSON body or HTTP Basic auth) or a Kubernetes ServiceAccount token for an API token",
+			Tag:         "auth",
+			Body:        &RouteBody{Description: "Credentials", Type: Credentials{}, ContentTypes: jsonContent},
+			Responses: []RouteResponse{
+				tokenResponse,
+				problemResponse(fasthttp.StatusUnauthorized, "Invalid credentials"),
+				unavailable,
+			},
+			Handler: r.Config.TokenHandler,
+		},
+		{
+			Method:      fasthttp.MethodPost,
+			Path:        "/api/token/refresh",
+			Operation:   "RefreshToken",
+			Summary:     "Refresh an API token",
+			Description: "Exchange a refresh token for a new access and refresh token. The old refresh token is revoked, so each one can only be used once.",
+			Tag:         "auth",
+			Body:        &RouteBody{Description: "Refresh token", Required: true, Type: RefreshRequest{}, ContentTypes: jsonContent},
+			Responses: []RouteResponse{
+				tokenResponse,
+				problemResponse(fasthttp.StatusUnauthorized, "Invalid, expired or used refresh token"),
+			},
+			Handler: r.Config.RefreshTokenHandler,
+		},
+		{
+			Method:        fasthttp.MethodPost,
+			Path:          "/api/token/revoke",
+			Operation:     "RevokeToken",
+			Summary:       "Revoke an API token",
+			Description:   "Revoke an access or refresh token of the caller. Without a body the token used for the request is revoked.",
+			Tag:           "auth",
+			Authenticated: true,
+			Body:          &RouteBody{Description: "Token to revoke", Type: TokenRequest{}, ContentTypes: jsonContent},
+			Responses:     []RouteResponse{{Status: fasthttp.StatusNoContent, Description: "The token is revoked"}},
+			Handler:       r.Config.RevokeTokenHandler,
+		},
+		{
+			Method:        fasthttp.MethodPost,
+			Path:          "/api/token/introspect",
+			Operation:     "IntrospectToken",
+			Summary:       "Introspect an API token",
+			Description:   "Report whether a token is active and what it carries. Without a body the token used for the request is described.",
+			Tag:           "auth",
+			Authenticated: true,
+			Body:          &RouteBody{Description: "Token to introspect", Type: TokenRequest{}, ContentTypes: jsonContent},
+			Responses:     []RouteResponse{{Status: fasthttp.StatusOK, Description: "Description of the token", Type: TokenIntrospection{}, ContentTypes: jsonContent}},
+			Handler:       r.Config.IntrospectTokenHandler,
+		},
+
+		r.secured(Route{
+			Method:      fasthttp.MethodGet,
+			Path:        "/api/frontendpages",
+			Operation:   "ListFrontendPages",
+			Summary:     "List frontend pages",
+			Description: "List the frontend pages by name, or stream changes to them with watch=true as Server-Sent Events, or over a WebSocket when the request upgrades",
+			Params: []Parameter{
+				queryParam("limit", "Maximum number of pages to return", &Schema{Type: SchemaType{"integer"}, Minimum: &minListLimit, Maximum: &maxLimit}),
+				queryParam("continue", "Continue token of the previous list response", stringSchema()),
+				queryParam("watch", "Stream changes instead of listing", &Schema{Type: SchemaType{"boolean"}}),
+				queryParam("resourceVersion", "Resume a watch after this resource version", stringSchema()),
+				queryParam("labelSelector", "Only watch pages matching this label selector", stringSchema()),
+				queryParam("fieldSelector", "Only watch pages matching this field selector", stringSchema()),
+				queryParam("types", "Comma separated event types to watch (ADDED, MODIFIED, DELETED)", stringSchema()),
+				queryParam("access_token", "Bearer token for watch clients that can't set headers", stringSchema()),
+			},
+			Responses: []RouteResponse{
+				{Status: fasthttp.StatusOK, Description: "Frontend pages, or watch events as text/event-stream", Type: FrontendPageDocList{}, ContentTypes: jsonContent},
+				problemResponse(fasthttp.StatusGone, "The watch can't resume from the resource version"),
+			},
+			Handler: r.ListFrontendPages,
+		}, VerbList),
+		r.secured(Route{
+			Method:      fasthttp.MethodPost,
+			Path:        "/api/frontendpages",
+			Operation:   "CreateFrontendPage",
+			Summary:     "Create a frontend page",
+			Description: "Create a new frontend page. A dry run also answers 201, with the page as it would have been created, and persists nothing.",
+			Params:      []Parameter{dryRunParam},
+			Body:        &RouteBody{Description: "Frontend page to create", Required: true, Type: FrontendPageDoc{}, ContentTypes: jsonContent},
+			Responses:   []RouteResponse{pageResponse(fasthttp.StatusCreated, "The page as the API server stored it, or would have for a dry run"), conflict, invalid},
+			Handler:     r.CreateFrontendPage,
+		}, VerbCreate),
+		r.secured(Route{
+			Method:      fasthttp.MethodGet,
+			Path:        "/api/frontendpages/export",
+			Operation:   "ExportFrontendPages",
+			Summary:     "Export frontend pages",
+			Description: "Export all frontend pages as a multi-document YAML bundle or a JSON list, with server-managed fields stripped",
+			Params: []Parameter{
+				queryParam("format", "Bundle format, defaults to yaml unless the client accepts only JSON", &Schema{Type: SchemaType{"string"}, Enum: []string{bundle.FormatYAML, bundle.FormatJSON}}),
+			},
+			Responses: []RouteResponse{{Status: fasthttp.StatusOK, Description: "FrontendPageList of the manifests", Type: map[string]any{}, ContentTypes: []string{"application/yaml", "application/json"}}},
+			Handler:   r.ExportFrontendPages,
+		}, VerbList),
+		r.secured(Route{
+			Method:      fasthttp.MethodPost,
+			Path:        "/api/frontendpages/import",
+			Operation:   "ImportFrontendPages",
+			Summary:     "Import frontend pages",
+			Description: "Create or update frontend pages in bulk from a YAML or JSON bundle",
+			Params: []Parameter{
+				queryParam("dryRun", "Validate the import with the API server without persisting anything", &Schema{Type: SchemaType{"boolean"}}),
+				queryParam("conflict", "What to do with pages that already exist, defaults to fail", &Schema{Type: SchemaType{"string"}, Enum: []string{bundle.ConflictSkip, bundle.ConflictOverwrite, bundle.ConflictFail}}),
+			},
+			Body: &RouteBody{Description: "Bundle of FrontendPage manifests", Required: true, ContentTypes: []string{"application/yaml", "application/json"}},
+			Responses: []RouteResponse{
+				{Status: fasthttp.StatusOK, Description: "Outcome for every page", Type: bundle.ImportReport{}, ContentTypes: jsonContent},
+				{Status: fasthttp.StatusConflict, Description: "Some pages already exist", Type: bundle.ImportReport{}, ContentTypes: jsonContent},
+			},
+			Handler: r.ImportFrontendPages,
+		}, VerbCreate, VerbUpdate),
+		r.secured(Route{
+			Method:      fasthttp.MethodGet,
+			Path:        "/api/frontendpages/{name}",
+			Operation:   "GetFrontendPage",
+			Summary:     "Get a frontend page",
+			Description: "Get a frontend page by name",
+			Params:      []Parameter{pageNameParam},
+			Responses:   []RouteResponse{pageResponse(fasthttp.StatusOK, "The page"), notFound},
+			Handler:     r.GetFrontendPage,
+		}, VerbGet),
+		r.secured(Route{
+			Method:      fasthttp.MethodPut,
+			Path:        "/api/frontendpages/{name}",
+			Operation:   "UpdateFrontendPage",
+			Summary:     "Update a frontend page",
+			Description: "Replace the spec of a frontend page. The name in the body is ignored.",
+			Params:      []Parameter{pageNameParam, dryRunParam},
+			Body:        &RouteBody{Description: "New state of the frontend page", Required: true, Type: FrontendPageDoc{}, ContentTypes: jsonContent},
+			Responses:   []RouteResponse{pageResponse(fasthttp.StatusOK, "The new state of the page"), notFound, conflict, invalid},
+			Handler:     r.UpdateFrontendPage,
+		}, VerbUpdate),
+		r.secured(Route{
+			Method:      fasthttp.MethodPatch,
+			Path:        "/api/frontendpages/{name}",
+			Operation:   "PatchFrontendPage",
+			Summary:     "Patch a frontend page",
+			Description: "Change only the given fields of a frontend page",
+			Params:      []Parameter{pageNameParam, dryRunParam},
+			Body:        &RouteBody{Description: "Fields to change", Required: true, Type: FrontendPagePatchDoc{}, ContentTypes: jsonContent},
+			Responses:   []RouteResponse{pageResponse(fasthttp.StatusOK, "The patched page"), notFound, invalid},
+			Handler:     r.PatchFrontendPage,
+		}, VerbPatch),
+		r.secured(Route{
+			Method:      fasthttp.MethodDelete,
+			Path:        "/api/frontendpages/{name}",
+			Operation:   "DeleteFrontendPage",
+			Summary:     "Delete a frontend page",
+			Description: "Delete a frontend page by name",
+			Params:      []Parameter{pageNameParam},
+			Responses: []RouteResponse{
+				{Status: fasthttp.StatusOK, Description: "The page is deleted", Type: StatusMessage{}, ContentTypes: jsonContent},
+				notFound,
+			},
+			Handler: r.DeleteFrontendPage,
+		}, VerbDelete),
+		r.secured(Route{
+			Method:      fasthttp.MethodGet,
+			Path:        "/api/frontendpages/{name}/content",
+			Operation:   "GetFrontendPageContent",
+			Summary:     "Get frontend page content",
+			Description: "Serve the rendered content of a frontend page from its generated ConfigMap",
+			Params:      []Parameter{pageNameParam},
+			Responses:   []RouteResponse{htmlResponse, problemResponse(fasthttp.StatusNotFound, "The content hasn't been generated")},
+			Handler:     r.GetFrontendPageContent,
+		}, VerbGet),
+		r.secured(Route{
+			Method:      fasthttp.MethodPost,
+			Path:        "/api/frontendpages/{name}/content",
+			Operation:   "RenderFrontendPageDraft",
+			Summary:     "Render a frontend page draft",
+			Description: "Render unsaved page content the same way the controller does, without writing anything to the cluster",
+			Params:      []Parameter{pageNameParam},
+			Body:        &RouteBody{Description: "Draft of the frontend page", Required: true, Type: FrontendPageDoc{}, ContentTypes: jsonContent},
+			Responses:   []RouteResponse{htmlResponse},
+			Handler:     r.RenderFrontendPageDraft,
+		}, VerbGet),
+		r.secured(Route{
+			Method:      fasthttp.MethodPost,
+			Path:        "/api/frontendpages/{name}/preview",
+			Operation:   "PreviewFrontendPage",
+			Summary:     "Preview a frontend page change",
+			Description: "Show the Deployment, Service and ConfigMap the controller would produce for the page, with a diff against the live objects. The body is optional and defaults to the current page.",
+			Params:      []Parameter{pageNameParam},
+			Body:        &RouteBody{Description: "Proposed state of the frontend page", Type: FrontendPageDoc{}, ContentTypes: jsonContent},
+			Responses: []RouteResponse{
+				{Status: fasthttp.StatusOK, Description: "The resources the controller would produce", Type: FrontendPagePreview{}, ContentTypes: jsonContent},
+				notFound,
+			},
+			Handler: r.PreviewFrontendPage,
+		}, VerbGet),
+
+		{
+			Method:        fasthttp.MethodPost,
+			Path:          r.mcpPath(),
+			Operation:     "MCPMessage",
+			Summary:       "Send an MCP message",
+			Description:   "Send a JSON-RPC request, notification or response over the MCP streamable HTTP transport. The initialize response sets the Mcp-Session-Id header, which later messages send back.",
+			Tag:           "mcp",
+			Authenticated: true,
+			Body:          &RouteBody{Description: "JSON-RPC message", Required: true, ContentTypes: jsonContent},
+			Responses: []RouteResponse{
+				{Status: fasthttp.StatusOK, Description: "JSON-RPC response, or a stream of messages ending with it", ContentTypes: []string{"application/json", "text/event-stream"}},
+				{Status: fasthttp.StatusAccepted, Description: "The notification or response is accepted"},
+				unavailableMCP,
+			},
+			Handler: r.ServeMCP,
+		},
+		{
+			Method:        fasthttp.MethodGet,
+			Path:          r.mcpPath(),
+			Operation:     "MCPStream",
+			Summary:       "Open an MCP stream",
+			Description:   "The server has no server-initiated stream, clients POST their requests instead",
+			Tag:           "mcp",
+			Authenticated: true,
+			Responses:     []RouteResponse{problemResponse(fasthttp.StatusMethodNotAllowed, "Streams are not supported"), unavailableMCP},
+			Handler:       r.ServeMCP,
+		},
+		{
+			Method:        fasthttp.MethodDelete,
+			Path:          r.mcpPath(),
+			Operation:     "MCPEndSession",
+			Summary:       "End an MCP session",
+			Description:   "End the session named by the Mcp-Session-Id header",
+			Tag:           "mcp",
+			Authenticated: true,
+			Responses:     []RouteResponse{{Status: fasthttp.StatusOK, Description: "The session is ended"}, unavailableMCP},
+			Handler:       r.ServeMCP,
+		},
+
+		{
+			Method:        fasthttp.MethodGet,
+			Path:          "/api/apikeys",
+			Operation:     "ListAPIKeys",
+			Summary:       "List API keys",
+			Description:   "List the API keys created by the caller, with their last use and usage count",
+			Tag:           "apikeys",
+			Authenticated: true,
+			Responses: []RouteResponse{
+				{Status: fasthttp.StatusOK, Description: "The caller's API keys", Type: []apikey.Key{}, ContentTypes: jsonContent},
+				unavailable,
+			},
+			Handler: r.ListAPIKeys,
+		},
+		{
+			Method:        fasthttp.MethodPost,
+			Path:          "/api/apikeys",
+			Operation:     "CreateAPIKey",
+			Summary:       "Create an API key",
+			Description:   "Create a long-lived key for automation clients, sent in the " + APIKeyHeader + " header. The caller must be allowed every verb it grants in every namespace of the key. The key is only shown in this response.",
+			Tag:           "apikeys",
+			Authenticated: true,
+			Body:          &RouteBody{Description: "Scope of the key", Required: true, Type: CreateAPIKeyRequest{}, ContentTypes: jsonContent},
+			Responses: []RouteResponse{
+				{Status: fasthttp.StatusCreated, Description: "The new key", Type: CreateAPIKeyResponse{}, ContentTypes: jsonContent},
+				unavailable,
+			},
+			Handler: r.AuditAPIKeys("CreateAPIKey", r.CreateAPIKey, VerbCreate),
+		},
+		{
+			Method:        fasthttp.MethodDelete,
+			Path:          "/api/apikeys/{id}",
+			Operation:     "DeleteAPIKey",
+			Summary:       "Delete an API key",
+			Description:   "Delete one of the caller's API keys. It stops working immediately on this replica and within the sync interval on others.",
+			Tag:           "apikeys",
+			Authenticated: true,
+			Params:        []Parameter{{Name: "id", In: "path", Description: "ID of the API key", Required: true, Schema: stringSchema()}},
+			Responses: []RouteResponse{
+				{Status: fasthttp.StatusNoContent, Description: "The key is deleted"},
+				problemResponse(fasthttp.StatusNotFound, "The caller has no such key"),
+				unavailable,
+			},
+			Handler: r.AuditAPIKeys("DeleteAPIKey", r.DeleteAPIKey, VerbDelete),
+		},
+	}
+}
+
+// handler wraps the route handler with validation, a rate limit per caller,
+// authentication when required and tracing, then with wrap
+func (route Route) handler(config *Config, wrap func(fasthttp.RequestHandler) fasthttp.RequestHandler) fasthttp.RequestHandler {
+	handler := config.RateLimitMiddleware(route.Operation, ValidateRequest(route, route.Handler))
+	if route.Authenticated {
+		handler = config.JwtMiddleware(handler)
+	}
+	return wrap(TraceableHandler(route.Operation, handler))
+}
+
+// routerPath converts an OpenAPI path template to a fasthttprouter path
+func routerPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = ":" + strings.Trim(segment, "{}")
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// reservedName reports whether the last segment of route is static where
+// another route of the same method has a wildcard, and returns the router
+// path of that wildcard
+func reservedName(route Route, routes []Route) (string, string, bool) {
+	segments := strings.Split(route.Path, "/")
+	last := len(segments) - 1
+	if strings.HasPrefix(segments[last], "{") {
+		return "", "", false
+	}
+	for _, other := range routes {
+		otherSegments := strings.Split(other.Path, "/")
+		if other.Method != route.Method || len(otherSegments) <= last || !slices.Equal(otherSegments[:last], segments[:last]) {
+			continue
+		}
+		if wildcard := otherSegments[last]; strings.HasPrefix(wildcard, "{") {
+			if wildcard != "{name}" {
+				panic(fmt.Sprintf("route %s %s is reserved under %s, only {name} has reserved names", route.Method, route.Path, wildcard))
+			}
+			return routerPath(strings.Join(append(segments[:last:last], wildcard), "/")), segments[last], true
+		}
+	}
+	return "", "", false
+}
+
+// RegisterRoutes adds the routes of the route table to router. wrap is
+// applied to every handler, such as OtelMiddleware, nil adds nothing.
+func (r *Router) RegisterRoutes(router *fasthttprouter.Router, wrap func(fasthttp.RequestHandler) fasthttp.RequestHandler) {
+	if wrap == nil {
+		wrap = func(handler fasthttp.RequestHandler) fasthttp.RequestHandler { return handler }
+	}
+
+	routes := r.Routes()
+	var keys []string
+	handlers := map[string]fasthttp.RequestHandler{}
+	reserved := map[string]map[string]fasthttp.RequestHandler{}
+	for _, route := range routes {
+		key := route.Method + " " + routerPath(route.Path)
+		if parent, name, ok := reservedName(route, routes); ok {
+			key = route.Method + " " + parent
+			if reserved[key] == nil {
+				reserved[key] = map[string]fasthttp.RequestHandler{}
+			}
+			reserved[key][name] = route.handler(r.Config, wrap)
+		} else {
+			handlers[key] = route.handler(r.Config, wrap)
+		}
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		method, path, _ := strings.Cut(key, " ")
+		handler, ok := handlers[key]
+		if !ok {
+			handler = NotFound
+		}
+		if names := reserved[key]; names != nil {
+			handler = WithReservedNames(names, handler)
+		}
+		router.Handle(method, path, handler)
+	}
+}
+
+// WithReservedNames sends requests whose :name parameter is one of the reserved
+// names to their own handler, and everything else to next. fasthttprouter
+// can't register a static segment like /api/frontendpages/export next to the
+// /api/frontendpages/:name wildcard, so the static routes are dispatched here.
+// Reserved handlers don't see a :name parameter, since it names no page.
+func WithReservedNames(reserved map[string]fasthttp.RequestHandler, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if name, ok := ctx.UserValue("name").(string); ok {
+			if handler, ok := reserved[name]; ok {
+				ctx.RemoveUserValue("name")
+				handler(ctx)
+				return
+			}
+		}
+		next(ctx)
+	}
+}
+
+// NotFound answers requests for routes that only exist as reserved names
+func NotFound(ctx *fasthttp.RequestCtx) {
+	WriteProblem(ctx, NewProblem(fasthttp.StatusNotFound, "no route for "+string(ctx.Path())))
+}
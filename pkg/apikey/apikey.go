@@ -0,0 +1,415 @@
+// Package apikey manages long-lived API keys for automation clients. Keys are
+// stored hashed in a Kubernetes Secret and are shared by the REST API and the
+// apikey CLI command.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Prefix starts every API key, so leaked keys are easy to spot
+const Prefix = "fpk"
+
+// Verbs that can be granted to a key
+var Verbs = []string{"get", "list", "watch", "create", "update", "patch", "delete", "logs"}
+
+var (
+	// ErrInvalidKey is returned for keys that are malformed or unknown
+	ErrInvalidKey = errors.New("invalid API key")
+	// ErrExpired is returned for keys past their expiry
+	ErrExpired = errors.New("API key has expired")
+	// ErrNotFound is returned when deleting an unknown key
+	ErrNotFound = errors.New("API key not found")
+)
+
+// Key is a stored API key. Only the SHA-256 hash of its secret is kept.
+type Key struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Hash       string     `json:"hash,omitempty"`
+	Namespaces []string   `json:"namespaces,omitempty"`
+	Verbs      []string   `json:"verbs"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	CreatedBy  string     `json:"createdBy,omitempty"`
+	// CreatorGroups are the groups of CreatedBy when the key was created. The
+	// key only works while the creator with these groups is authorized.
+	CreatorGroups []string   `json:"creatorGroups,omitempty"`
+	LastUsedAt    *time.Time `json:"lastUsedAt,omitempty"`
+	UsageCount    int64      `json:"usageCount"`
+}
+
+// Public returns a copy of the key without its hash
+func (k Key) Public() Key {
+	k.Hash = ""
+	return k
+}
+
+// CreateOptions describe a new key
+type CreateOptions struct {
+	Name       string
+	Namespaces []string
+	Verbs      []string
+	ExpiresAt  *time.Time
+	CreatedBy  string
+	// CreatorGroups are the groups of CreatedBy, for authorizing the key
+	CreatorGroups []string
+}
+
+// Validate checks the options coming from user input
+func (o *CreateOptions) Validate() error {
+	if o.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(o.Verbs) == 0 {
+		return errors.New("at least one verb is required")
+	}
+	for _, verb := range o.Verbs {
+		if !slices.Contains(Verbs, verb) {
+			return fmt.Errorf("unsupported verb %q, use %s", verb, strings.Join(Verbs, ", "))
+		}
+	}
+	if o.ExpiresAt != nil && o.ExpiresAt.Before(time.Now()) {
+		return errors.New("expiry is in the past")
+	}
+	return nil
+}
+
+type usage struct {
+	lastUsed time.Time
+	count    int64
+}
+
+// Store keeps the keys of a Secret cached in memory. Usage is counted in
+// memory and written back by Flush.
+type Store struct {
+	Client    client.Client
+	Namespace string
+	Name      string
+
+	mu         sync.RWMutex
+	keys       map[string]*Key
+	usage      map[string]usage
+	lastLoad   time.Time
+	reloadWait time.Duration
+}
+
+// NewStore creates a store for the Secret namespace/name. Call Load to read
+// the existing keys.
+func NewStore(c client.Client, namespace, name string) *Store {
+	return &Store{
+		Client:     c,
+		Namespace:  namespace,
+		Name:       name,
+		keys:       map[string]*Key{},
+		usage:      map[string]usage{},
+		reloadWait: 5 * time.Second,
+	}
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return encode(buf), nil
+}
+
+func decodeKeys(secret *corev1.Secret) (map[string]*Key, error) {
+	keys := map[string]*Key{}
+	for id, data := range secret.Data {
+		key := &Key{}
+		if err := json.Unmarshal(data, key); err != nil {
+			return nil, fmt.Errorf("decode API key %s: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+// update applies fn to the stored keys, retrying on conflicts with other
+// replicas, and refreshes the cache
+func (s *Store) update(ctx context.Context, fn func(keys map[string]*Key) error) error {
+	var keys map[string]*Key
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: s.Namespace, Name: s.Name}}
+		err := s.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)
+		notFound := apierrors.IsNotFound(err)
+		if err != nil && !notFound {
+			return err
+		}
+		if keys, err = decodeKeys(secret); err != nil {
+			return err
+		}
+		if err := fn(keys); err != nil {
+			return err
+		}
+		secret.Data = map[string][]byte{}
+		for id, key := range keys {
+			data, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			secret.Data[id] = data
+		}
+		if notFound {
+			err = s.Client.Create(ctx, secret)
+			if apierrors.IsAlreadyExists(err) {
+				return apierrors.NewConflict(corev1.Resource("secrets"), s.Name, err)
+			}
+			return err
+		}
+		return s.Client.Update(ctx, secret)
+	})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.lastLoad = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// Load reads the keys from the Secret
+func (s *Store) Load(ctx context.Context) error {
+	s.mu.Lock()
+	s.lastLoad = time.Now()
+	s.mu.Unlock()
+
+	secret := &corev1.Secret{}
+	err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.Name}, secret)
+	if apierrors.IsNotFound(err) {
+		secret = &corev1.Secret{}
+	} else if err != nil {
+		return err
+	}
+	keys, err := decodeKeys(secret)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+// Create generates a key and stores its hash. The returned plaintext key is
+// not stored anywhere and can't be shown again.
+func (s *Store) Create(ctx context.Context, opts CreateOptions) (string, *Key, error) {
+	if err := opts.Validate(); err != nil {
+		return "", nil, err
+	}
+	id, err := randomString(6, hex.EncodeToString)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return "", nil, err
+	}
+	key := &Key{
+		ID:            id,
+		Name:          opts.Name,
+		Hash:          hashSecret(secret),
+		Namespaces:    opts.Namespaces,
+		Verbs:         opts.Verbs,
+		ExpiresAt:     opts.ExpiresAt,
+		CreatedAt:     time.Now().UTC().Truncate(time.Second),
+		CreatedBy:     opts.CreatedBy,
+		CreatorGroups: opts.CreatorGroups,
+	}
+	err = s.update(ctx, func(keys map[string]*Key) error {
+		if _, ok := keys[id]; ok {
+			return fmt.Errorf("API key id %s is already in use", id)
+		}
+		keys[id] = key
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	public := key.Public()
+	return fmt.Sprintf("%s_%s_%s", Prefix, id, secret), &public, nil
+}
+
+// List returns the cached keys without their hashes, sorted by name
+func (s *Store) List() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]Key, 0, len(s.keys))
+	for id, key := range s.keys {
+		public := key.Public()
+		if u, ok := s.usage[id]; ok {
+			public.UsageCount += u.count
+			lastUsed := u.lastUsed
+			public.LastUsedAt = &lastUsed
+		}
+		keys = append(keys, public)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Name != keys[j].Name {
+			return keys[i].Name < keys[j].Name
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+// Get returns a cached key without its hash
+func (s *Store) Get(id string) (*Key, bool) {
+	for _, key := range s.List() {
+		if key.ID == id {
+			return &key, true
+		}
+	}
+	return nil, false
+}
+
+// Delete removes a key
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return s.update(ctx, func(keys map[string]*Key) error {
+		if _, ok := keys[id]; !ok {
+			return ErrNotFound
+		}
+		delete(keys, id)
+		return nil
+	})
+}
+
+func (s *Store) lookup(id string) (*Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[id]
+	return key, ok
+}
+
+// Authenticate checks a plaintext key and records its use. Keys created by
+// other replicas are picked up by reloading the Secret, at most every few
+// seconds.
+func (s *Store) Authenticate(ctx context.Context, raw string) (*Key, error) {
+	parts := strings.SplitN(raw, "_", 3)
+	if len(parts) != 3 || parts[0] != Prefix {
+		return nil, ErrInvalidKey
+	}
+	id, secret := parts[1], parts[2]
+
+	key, ok := s.lookup(id)
+	if !ok {
+		s.mu.RLock()
+		recent := time.Since(s.lastLoad) < s.reloadWait
+		s.mu.RUnlock()
+		if !recent {
+			if err := s.Load(ctx); err != nil {
+				return nil, err
+			}
+			key, ok = s.lookup(id)
+		}
+	}
+	if !ok || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashSecret(secret))) != 1 {
+		return nil, ErrInvalidKey
+	}
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return nil, ErrExpired
+	}
+
+	s.mu.Lock()
+	u := s.usage[id]
+	u.count++
+	u.lastUsed = time.Now().UTC().Truncate(time.Second)
+	s.usage[id] = u
+	s.mu.Unlock()
+
+	public := key.Public()
+	return &public, nil
+}
+
+// Flush writes the usage recorded since the last flush to the Secret
+func (s *Store) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	pending := s.usage
+	s.usage = map[string]usage{}
+	s.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := s.update(ctx, func(keys map[string]*Key) error {
+		for id, u := range pending {
+			key, ok := keys[id]
+			if !ok {
+				continue
+			}
+			key.UsageCount += u.count
+			if key.LastUsedAt == nil || u.lastUsed.After(*key.LastUsedAt) {
+				lastUsed := u.lastUsed
+				key.LastUsedAt = &lastUsed
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// Keep the counts for the next flush
+		s.mu.Lock()
+		for id, u := range pending {
+			current := s.usage[id]
+			current.count += u.count
+			if u.lastUsed.After(current.lastUsed) {
+				current.lastUsed = u.lastUsed
+			}
+			s.usage[id] = current
+		}
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// Run flushes usage and reloads the keys every interval until ctx is done,
+// with a final flush on the way out
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := s.Flush(flushCtx); err != nil {
+				log.Error().Err(err).Msg("Failed to record API key usage")
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := s.Flush(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to record API key usage")
+			}
+			if err := s.Load(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to reload API keys")
+			}
+		}
+	}
+}
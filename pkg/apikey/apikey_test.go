@@ -0,0 +1,109 @@
+package apikey
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newFakeClient(t *testing.T) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+func TestStore_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	store := NewStore(c, "default", "apikeys")
+
+	raw, key, err := store.Create(ctx, CreateOptions{
+		Name:       "ci",
+		Namespaces: []string{"default"},
+		Verbs:      []string{"create", "update"},
+		CreatedBy:  "alice",
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(raw, Prefix+"_"+key.ID+"_"))
+	require.Empty(t, key.Hash, "the hash is never returned")
+
+	// Only the hash is stored
+	secret := &corev1.Secret{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "apikeys"}, secret))
+	require.NotContains(t, string(secret.Data[key.ID]), strings.SplitN(raw, "_", 3)[2])
+
+	authenticated, err := store.Authenticate(ctx, raw)
+	require.NoError(t, err)
+	require.Equal(t, "ci", authenticated.Name)
+	require.Equal(t, []string{"create", "update"}, authenticated.Verbs)
+	_, err = store.Authenticate(ctx, raw)
+	require.NoError(t, err)
+
+	_, err = store.Authenticate(ctx, raw+"x")
+	require.ErrorIs(t, err, ErrInvalidKey)
+	_, err = store.Authenticate(ctx, "not-a-key")
+	require.ErrorIs(t, err, ErrInvalidKey)
+
+	// Usage is visible right away and persisted by Flush
+	require.Equal(t, int64(2), store.List()[0].UsageCount)
+	require.NoError(t, store.Flush(ctx))
+	replica := NewStore(c, "default", "apikeys")
+	require.NoError(t, replica.Load(ctx))
+	listed := replica.List()
+	require.Len(t, listed, 1)
+	require.Equal(t, int64(2), listed[0].UsageCount)
+	require.NotNil(t, listed[0].LastUsedAt)
+
+	require.NoError(t, store.Delete(ctx, key.ID))
+	require.ErrorIs(t, store.Delete(ctx, key.ID), ErrNotFound)
+	_, err = store.Authenticate(ctx, raw)
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestStore_KeysFromOtherReplicas(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	replicaA := NewStore(c, "default", "apikeys")
+	replicaB := NewStore(c, "default", "apikeys")
+	require.NoError(t, replicaB.Load(ctx))
+
+	raw, _, err := replicaA.Create(ctx, CreateOptions{Name: "ci", Verbs: []string{"get"}})
+	require.NoError(t, err)
+
+	// B loaded just now, so the unknown key is rejected until the reload wait passes
+	_, err = replicaB.Authenticate(ctx, raw)
+	require.ErrorIs(t, err, ErrInvalidKey)
+	replicaB.reloadWait = 0
+	_, err = replicaB.Authenticate(ctx, raw)
+	require.NoError(t, err)
+}
+
+func TestStore_Expiry(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(newFakeClient(t), "default", "apikeys")
+
+	past := time.Now().Add(-time.Minute)
+	_, _, err := store.Create(ctx, CreateOptions{Name: "old", Verbs: []string{"get"}, ExpiresAt: &past})
+	require.Error(t, err)
+
+	future := time.Now().Add(time.Hour)
+	raw, key, err := store.Create(ctx, CreateOptions{Name: "ci", Verbs: []string{"get"}, ExpiresAt: &future})
+	require.NoError(t, err)
+	store.keys[key.ID].ExpiresAt = &past
+	_, err = store.Authenticate(ctx, raw)
+	require.ErrorIs(t, err, ErrExpired)
+}
+
+func TestCreateOptions_Validate(t *testing.T) {
+	require.Error(t, (&CreateOptions{Verbs: []string{"get"}}).Validate())
+	require.Error(t, (&CreateOptions{Name: "ci"}).Validate())
+	require.Error(t, (&CreateOptions{Name: "ci", Verbs: []string{"escalate"}}).Validate())
+	require.NoError(t, (&CreateOptions{Name: "ci", Verbs: Verbs}).Validate())
+}
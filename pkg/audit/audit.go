@@ -0,0 +1,261 @@
+// Package audit records who changed which object through the REST API and the
+// MCP tools. Events are filtered by a policy, modelled on the kube-apiserver
+// audit policy, and written to pluggable sinks.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"sigs.k8s.io/yaml"
+)
+
+// Level controls how much of a request is recorded
+type Level string
+
+// Audit levels, from least to most detail
+const (
+	// LevelNone records nothing
+	LevelNone Level = "None"
+	// LevelMetadata records the actor, target, body hash, outcome and latency
+	LevelMetadata Level = "Metadata"
+	// LevelRequest adds the request body
+	LevelRequest Level = "Request"
+	// LevelRequestResponse adds the response body
+	LevelRequestResponse Level = "RequestResponse"
+)
+
+var levels = []Level{LevelNone, LevelMetadata, LevelRequest, LevelRequestResponse}
+
+// atLeast reports whether l records as much as other
+func (l Level) atLeast(other Level) bool {
+	return slices.Index(levels, l) >= slices.Index(levels, other)
+}
+
+// Sources of audited operations
+const (
+	SourceAPI = "api"
+	SourceMCP = "mcp"
+)
+
+// Outcomes of audited operations
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Anonymous is the actor of operations without an authenticated caller
+const Anonymous = "system:anonymous"
+
+// MaxBodySize limits the request and response bodies kept in an event
+const MaxBodySize = 64 << 10
+
+// Actor is the authenticated caller
+type Actor struct {
+	Subject string   `json:"subject"`
+	Groups  []string `json:"groups,omitempty"`
+}
+
+// Event is one audited operation
+type Event struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Level    Level     `json:"level"`
+	Source   string    `json:"source"`
+	Actor    Actor     `json:"actor"`
+	SourceIP string    `json:"sourceIP,omitempty"`
+	// Operation is the REST handler or the MCP tool
+	Operation string `json:"operation"`
+	// Route is the HTTP method and path, for REST requests
+	Route     string `json:"route,omitempty"`
+	Verb      string `json:"verb"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	DryRun    bool   `json:"dryRun,omitempty"`
+
+	RequestSHA256 string `json:"requestSHA256,omitempty"`
+	RequestBody   string `json:"requestBody,omitempty"`
+	ResponseBody  string `json:"responseBody,omitempty"`
+
+	Outcome   string  `json:"outcome"`
+	Status    int     `json:"status,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+// Rule sets the level for matching events. Empty fields match everything.
+type Rule struct {
+	Level      Level    `json:"level"`
+	Users      []string `json:"users,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	Verbs      []string `json:"verbs,omitempty"`
+	Resources  []string `json:"resources,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Sources    []string `json:"sources,omitempty"`
+}
+
+func (r *Rule) matches(ev *Event) bool {
+	if len(r.Users) > 0 && !slices.Contains(r.Users, ev.Actor.Subject) {
+		return false
+	}
+	if len(r.Groups) > 0 && !slices.ContainsFunc(ev.Actor.Groups, func(g string) bool { return slices.Contains(r.Groups, g) }) {
+		return false
+	}
+	for _, field := range []struct {
+		allowed []string
+		value   string
+	}{
+		{r.Verbs, ev.Verb},
+		{r.Resources, ev.Resource},
+		{r.Namespaces, ev.Namespace},
+		{r.Sources, ev.Source},
+	} {
+		if len(field.allowed) > 0 && !slices.Contains(field.allowed, field.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Policy picks the level of the first matching rule. Events no rule matches
+// are not recorded:
+//
+//	rules:
+//	  - level: None
+//	    users: [system:serviceaccount:ci:publisher]
+//	  - level: RequestResponse
+//	    verbs: [delete]
+//	  - level: Metadata
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// DefaultPolicy records metadata for every operation
+var DefaultPolicy = &Policy{Rules: []Rule{{Level: LevelMetadata}}}
+
+// ParsePolicy reads a YAML or JSON policy
+func ParsePolicy(data []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("parse audit policy: %w", err)
+	}
+	for i, rule := range policy.Rules {
+		if !slices.Contains(levels, rule.Level) {
+			return nil, fmt.Errorf("rule %d: unknown level %q", i, rule.Level)
+		}
+	}
+	return policy, nil
+}
+
+// LoadPolicyFile reads a policy file
+func LoadPolicyFile(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(data)
+}
+
+// LevelFor returns the level of the first rule matching ev
+func (p *Policy) LevelFor(ev *Event) Level {
+	for i := range p.Rules {
+		if p.Rules[i].matches(ev) {
+			return p.Rules[i].Level
+		}
+	}
+	return LevelNone
+}
+
+// Sink receives recorded events
+type Sink interface {
+	Write(ctx context.Context, ev *Event) error
+}
+
+// Logger applies the policy to events and writes them to every sink
+type Logger struct {
+	Policy *Policy
+	Sinks  []Sink
+}
+
+// NewLogger uses DefaultPolicy when policy is nil
+func NewLogger(policy *Policy, sinks ...Sink) *Logger {
+	if policy == nil {
+		policy = DefaultPolicy
+	}
+	return &Logger{Policy: policy, Sinks: sinks}
+}
+
+func truncate(body []byte) string {
+	if len(body) > MaxBodySize {
+		return string(body[:MaxBodySize])
+	}
+	return string(body)
+}
+
+// Record completes ev and writes it at the level the policy picks. Callers
+// fill in everything but the ID, level and bodies. Pass nil bodies for
+// operations whose payloads must never be stored. Sink failures are logged
+// and don't fail the operation.
+func (l *Logger) Record(ctx context.Context, ev Event, requestBody, responseBody []byte) {
+	if l == nil {
+		return
+	}
+	if ev.Actor.Subject == "" {
+		ev.Actor.Subject = Anonymous
+	}
+	ev.Level = l.Policy.LevelFor(&ev)
+	if ev.Level == LevelNone {
+		return
+	}
+	ev.ID = uuid.NewString()
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	ev.Time = ev.Time.UTC()
+	if len(requestBody) > 0 {
+		sum := sha256.Sum256(requestBody)
+		ev.RequestSHA256 = hex.EncodeToString(sum[:])
+	}
+	if ev.Level.atLeast(LevelRequest) {
+		ev.RequestBody = truncate(requestBody)
+	}
+	if ev.Level.atLeast(LevelRequestResponse) {
+		ev.ResponseBody = truncate(responseBody)
+	}
+	for _, sink := range l.Sinks {
+		if err := sink.Write(ctx, &ev); err != nil {
+			log.Error().Err(err).Str("audit_id", ev.ID).Msgf("Failed to write audit event to %T", sink)
+		}
+	}
+}
+
+// IsMutating reports whether verb changes objects and is audited
+func IsMutating(verb string) bool {
+	switch verb {
+	case "create", "update", "patch", "delete":
+		return true
+	}
+	return false
+}
+
+type sourceIPKey struct{}
+
+// WithSourceIP stores the caller's address for operations, such as MCP tool
+// calls, that don't see the HTTP request
+func WithSourceIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, sourceIPKey{}, ip)
+}
+
+// SourceIPFromContext returns the address stored by WithSourceIP
+func SourceIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(sourceIPKey{}).(string)
+	return ip
+}
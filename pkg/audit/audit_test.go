@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// captureSink keeps events in memory
+type captureSink struct {
+	events []Event
+}
+
+func (s *captureSink) Write(_ context.Context, ev *Event) error {
+	s.events = append(s.events, *ev)
+	return nil
+}
+
+func TestPolicy_LevelFor(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`
+rules:
+  - level: None
+    users: [ci]
+  - level: RequestResponse
+    verbs: [delete]
+    groups: [admins]
+  - level: Request
+    sources: [mcp]
+  - level: Metadata
+    resources: [frontendpages]
+`))
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		ev   Event
+		want Level
+	}{
+		{Event{Actor: Actor{Subject: "ci"}, Verb: "delete", Resource: "frontendpages"}, LevelNone},
+		{Event{Actor: Actor{Subject: "alice", Groups: []string{"admins"}}, Verb: "delete", Resource: "frontendpages"}, LevelRequestResponse},
+		{Event{Actor: Actor{Subject: "bob"}, Verb: "delete", Resource: "frontendpages"}, LevelMetadata},
+		{Event{Actor: Actor{Subject: "bob"}, Verb: "create", Resource: "frontendpages", Source: SourceMCP}, LevelRequest},
+		{Event{Actor: Actor{Subject: "bob"}, Verb: "create", Resource: "apikeys"}, LevelNone},
+	} {
+		require.Equal(t, tt.want, policy.LevelFor(&tt.ev), "%+v", tt.ev)
+	}
+
+	_, err = ParsePolicy([]byte(`rules: [{level: Everything}]`))
+	require.Error(t, err)
+	_, err = ParsePolicy([]byte(`rules: [{level: None, user: [ci]}]`))
+	require.Error(t, err, "unknown fields are rejected")
+}
+
+func TestLogger_Record(t *testing.T) {
+	ctx := context.Background()
+	sink := &captureSink{}
+	policy, err := ParsePolicy([]byte(`
+rules:
+  - level: RequestResponse
+    verbs: [delete]
+  - level: Request
+    verbs: [create]
+  - level: Metadata
+`))
+	require.NoError(t, err)
+	logger := NewLogger(policy, sink)
+
+	body, response := []byte(`{"name":"page"}`), []byte(`{"ok":true}`)
+	logger.Record(ctx, Event{Verb: "update"}, body, response)
+	logger.Record(ctx, Event{Verb: "create"}, body, response)
+	logger.Record(ctx, Event{Verb: "delete"}, body, response)
+	require.Len(t, sink.events, 3)
+
+	metadata := sink.events[0]
+	require.Equal(t, LevelMetadata, metadata.Level)
+	require.Equal(t, Anonymous, metadata.Actor.Subject)
+	require.NotEmpty(t, metadata.ID)
+	require.False(t, metadata.Time.IsZero())
+	require.Len(t, metadata.RequestSHA256, 64)
+	require.Empty(t, metadata.RequestBody)
+
+	require.Equal(t, string(body), sink.events[1].RequestBody)
+	require.Empty(t, sink.events[1].ResponseBody)
+	require.Equal(t, string(response), sink.events[2].ResponseBody)
+
+	// A nil logger is a no-op
+	var disabled *Logger
+	disabled.Record(ctx, Event{Verb: "delete"}, nil, nil)
+}
@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// WriterSink writes events as JSON lines, for example to stdout
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink writes to w
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Write implements Sink
+func (s *WriterSink) Write(_ context.Context, ev *Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+// FileSink writes JSON lines to a file and rotates it once it grows past
+// MaxSize, keeping MaxBackups old files as path.1, path.2 and so on
+type FileSink struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileSink opens path for appending
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{Path: path, MaxSize: maxSize, MaxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if s.MaxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", s.Path, s.MaxBackups))
+		for i := s.MaxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", s.Path, i), fmt.Sprintf("%s.%d", s.Path, i+1))
+		}
+		if err := os.Rename(s.Path, s.Path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.Path); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+// Write implements Sink
+func (s *FileSink) Write(_ context.Context, ev *Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.MaxSize > 0 && s.size > 0 && s.size+int64(len(data)) > s.MaxSize {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("rotate %s: %w", s.Path, err)
+		}
+	}
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	return err
+}
+
+// Close closes the current file
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// EventSink records operations on FrontendPages as Kubernetes Events on the
+// page, so they show up in kubectl describe
+type EventSink struct {
+	Recorder record.EventRecorder
+}
+
+// Write implements Sink. Events for other resources are skipped.
+func (s *EventSink) Write(_ context.Context, ev *Event) error {
+	if ev.Resource != "frontendpages" || ev.Name == "" || ev.Verb == "" || ev.DryRun {
+		return nil
+	}
+	page := &frontendv1alpha1.FrontendPage{
+		TypeMeta:   metav1.TypeMeta{APIVersion: frontendv1alpha1.SchemeGroupVersion.String(), Kind: "FrontendPage"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: ev.Namespace, Name: ev.Name},
+	}
+	eventType, reason := corev1.EventTypeNormal, "Audit"+strings.ToUpper(ev.Verb[:1])+ev.Verb[1:]
+	if ev.Outcome != OutcomeSuccess {
+		eventType, reason = corev1.EventTypeWarning, reason+"Failed"
+	}
+	s.Recorder.AnnotatedEventf(page, map[string]string{"audit.frontend.jraver.io/id": ev.ID}, eventType, reason,
+		"%s %s via %s %s (status %d, %.0fms)", ev.Actor.Subject, ev.Verb, ev.Source, ev.Operation, ev.Status, ev.LatencyMs)
+	return nil
+}
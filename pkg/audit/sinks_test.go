@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/record"
+)
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var ev Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &ev))
+		lines++
+	}
+	return lines
+}
+
+func TestFileSink_Rotation(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	ev := &Event{ID: "1", Verb: "update", Resource: "frontendpages", Name: strings.Repeat("x", 100)}
+	line, err := json.Marshal(ev)
+	require.NoError(t, err)
+
+	// Room for two events per file
+	sink, err := NewFileSink(path, int64(2*(len(line)+1)), 2)
+	require.NoError(t, err)
+	for range 7 {
+		require.NoError(t, sink.Write(ctx, ev))
+	}
+	require.NoError(t, sink.Close())
+
+	require.Equal(t, 1, countLines(t, path))
+	require.Equal(t, 2, countLines(t, path+".1"))
+	require.Equal(t, 2, countLines(t, path+".2"))
+	_, err = os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err), "only MaxBackups files are kept")
+
+	// Reopening appends to the current file
+	sink, err = NewFileSink(path, int64(2*(len(line)+1)), 2)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write(ctx, ev))
+	require.NoError(t, sink.Close())
+	require.Equal(t, 2, countLines(t, path))
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+	require.NoError(t, sink.Write(context.Background(), &Event{ID: "1"}))
+	require.NoError(t, sink.Write(context.Background(), &Event{ID: "2"}))
+	require.Equal(t, 2, strings.Count(buf.String(), "\n"))
+}
+
+func TestEventSink(t *testing.T) {
+	ctx := context.Background()
+	recorder := record.NewFakeRecorder(10)
+	sink := &EventSink{Recorder: recorder}
+
+	require.NoError(t, sink.Write(ctx, &Event{
+		Actor: Actor{Subject: "alice"}, Source: SourceAPI, Operation: "UpdateFrontendPage",
+		Verb: "update", Resource: "frontendpages", Namespace: "default", Name: "page",
+		Outcome: OutcomeSuccess, Status: 200,
+	}))
+	require.NoError(t, sink.Write(ctx, &Event{
+		Actor: Actor{Subject: "bob"}, Source: SourceMCP, Operation: "delete_frontendpage",
+		Verb: "delete", Resource: "frontendpages", Namespace: "default", Name: "page",
+		Outcome: OutcomeFailure,
+	}))
+	// Dry runs and other resources don't produce events
+	require.NoError(t, sink.Write(ctx, &Event{Verb: "update", Resource: "frontendpages", Name: "page", DryRun: true}))
+	require.NoError(t, sink.Write(ctx, &Event{Verb: "create", Resource: "apikeys", Name: "ci"}))
+
+	require.Len(t, recorder.Events, 2)
+	require.Contains(t, <-recorder.Events, "Normal AuditUpdate alice update via api UpdateFrontendPage")
+	require.Contains(t, <-recorder.Events, "Warning AuditDeleteFailed bob delete via mcp delete_frontendpage")
+}
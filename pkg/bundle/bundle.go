@@ -0,0 +1,316 @@
+// Package bundle exports and imports FrontendPages as portable YAML or JSON
+// bundles. It is shared by the REST API and the import/export CLI commands.
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Supported bundle formats
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// Conflict policies for pages that already exist in the target namespace
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictFail      = "fail"
+)
+
+// Actions reported for each imported page
+const (
+	ActionCreated  = "created"
+	ActionUpdated  = "updated"
+	ActionSkipped  = "skipped"
+	ActionConflict = "conflict"
+	ActionFailed   = "failed"
+)
+
+const (
+	kindFrontendPage     = "FrontendPage"
+	kindFrontendPageList = "FrontendPageList"
+)
+
+// ErrConflict is returned by Import with the fail policy when some pages exist
+var ErrConflict = errors.New("some pages already exist")
+
+// ErrInvalidBundle is returned by Import for bundles that can't be applied as a whole
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// annotations that are managed by tools and should not travel between clusters
+var strippedAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+}
+
+// Export lists the pages in namespace and strips server-managed fields so the
+// result can be applied to another cluster
+func Export(ctx context.Context, c client.Reader, namespace string) ([]frontendv1alpha1.FrontendPage, error) {
+	list := &frontendv1alpha1.FrontendPageList{}
+	if err := c.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	pages := make([]frontendv1alpha1.FrontendPage, 0, len(list.Items))
+	for i := range list.Items {
+		pages = append(pages, Clean(&list.Items[i]))
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Name < pages[j].Name })
+	return pages, nil
+}
+
+// Clean returns a copy of page with only its portable fields: name, labels,
+// annotations and spec
+func Clean(page *frontendv1alpha1.FrontendPage) frontendv1alpha1.FrontendPage {
+	clean := frontendv1alpha1.FrontendPage{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: frontendv1alpha1.SchemeGroupVersion.String(),
+			Kind:       kindFrontendPage,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        page.Name,
+			Labels:      page.Labels,
+			Annotations: page.Annotations,
+		},
+		Spec: page.Spec,
+	}
+	clean = *clean.DeepCopy()
+	for _, key := range strippedAnnotations {
+		delete(clean.Annotations, key)
+	}
+	if len(clean.Annotations) == 0 {
+		clean.Annotations = nil
+	}
+	return clean
+}
+
+// Encode writes pages as a multi-document YAML stream or a JSON FrontendPageList
+func Encode(w io.Writer, pages []frontendv1alpha1.FrontendPage, format string) error {
+	switch format {
+	case FormatJSON:
+		list := frontendv1alpha1.FrontendPageList{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: frontendv1alpha1.SchemeGroupVersion.String(),
+				Kind:       kindFrontendPageList,
+			},
+			Items: pages,
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(list)
+	case FormatYAML, "":
+		for i := range pages {
+			data, err := yaml.Marshal(&pages[i])
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "---\n%s", data); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported format %q, use %s or %s", format, FormatYAML, FormatJSON)
+	}
+}
+
+// Decode reads FrontendPages from YAML or JSON. Both single pages and
+// FrontendPageLists are accepted, in any number of YAML documents.
+func Decode(r io.Reader) ([]frontendv1alpha1.FrontendPage, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	var pages []frontendv1alpha1.FrontendPage
+	for doc := 1; ; doc++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return pages, nil
+			}
+			return nil, fmt.Errorf("document %d: %w", doc, err)
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+			continue
+		}
+
+		var typeMeta metav1.TypeMeta
+		if err := json.Unmarshal(raw, &typeMeta); err != nil {
+			return nil, fmt.Errorf("document %d: %w", doc, err)
+		}
+		if typeMeta.APIVersion != "" && typeMeta.APIVersion != frontendv1alpha1.SchemeGroupVersion.String() {
+			return nil, fmt.Errorf("document %d: unsupported apiVersion %q", doc, typeMeta.APIVersion)
+		}
+
+		switch typeMeta.Kind {
+		case kindFrontendPageList, "List":
+			list := frontendv1alpha1.FrontendPageList{}
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, fmt.Errorf("document %d: %w", doc, err)
+			}
+			pages = append(pages, list.Items...)
+		case kindFrontendPage, "":
+			page := frontendv1alpha1.FrontendPage{}
+			if err := json.Unmarshal(raw, &page); err != nil {
+				return nil, fmt.Errorf("document %d: %w", doc, err)
+			}
+			pages = append(pages, page)
+		default:
+			return nil, fmt.Errorf("document %d: unsupported kind %q", doc, typeMeta.Kind)
+		}
+	}
+}
+
+// ImportOptions control how Import treats existing pages
+type ImportOptions struct {
+	Namespace string
+	DryRun    bool
+	Conflict  string
+}
+
+// ImportResult is the outcome for a single page
+type ImportResult struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	DryRun   bool           `json:"dryRun"`
+	Conflict string         `json:"conflict"`
+	Created  int            `json:"created"`
+	Updated  int            `json:"updated"`
+	Skipped  int            `json:"skipped"`
+	Failed   int            `json:"failed"`
+	Items    []ImportResult `json:"items"`
+}
+
+func (r *ImportReport) add(name, action string, err error) {
+	result := ImportResult{Name: name, Action: action}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	switch action {
+	case ActionCreated:
+		r.Created++
+	case ActionUpdated:
+		r.Updated++
+	case ActionSkipped:
+		r.Skipped++
+	case ActionFailed, ActionConflict:
+		r.Failed++
+	}
+	r.Items = append(r.Items, result)
+}
+
+// ValidateConflictPolicy checks a conflict policy coming from user input
+func ValidateConflictPolicy(policy string) error {
+	switch policy {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return nil
+	default:
+		return fmt.Errorf("unsupported conflict policy %q, use %s, %s or %s", policy, ConflictSkip, ConflictOverwrite, ConflictFail)
+	}
+}
+
+// Import creates or updates pages in opts.Namespace. With the fail policy
+// nothing is written when any page already exists, and ErrConflict is returned
+// along with the report. Other per-page errors are only recorded in the report.
+func Import(ctx context.Context, c client.Client, pages []frontendv1alpha1.FrontendPage, opts ImportOptions) (*ImportReport, error) {
+	if opts.Conflict == "" {
+		opts.Conflict = ConflictFail
+	}
+	if err := ValidateConflictPolicy(opts.Conflict); err != nil {
+		return nil, err
+	}
+	report := &ImportReport{DryRun: opts.DryRun, Conflict: opts.Conflict, Items: []ImportResult{}}
+
+	var createOpts []client.CreateOption
+	var updateOpts []client.UpdateOption
+	if opts.DryRun {
+		createOpts = append(createOpts, client.DryRunAll)
+		updateOpts = append(updateOpts, client.DryRunAll)
+	}
+
+	existing := make([]*frontendv1alpha1.FrontendPage, len(pages))
+	seen := map[string]bool{}
+	conflicts := false
+	for i := range pages {
+		name := pages[i].Name
+		if name == "" {
+			continue
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: page %q appears more than once", ErrInvalidBundle, name)
+		}
+		seen[name] = true
+
+		current := &frontendv1alpha1.FrontendPage{}
+		err := c.Get(ctx, client.ObjectKey{Namespace: opts.Namespace, Name: name}, current)
+		switch {
+		case err == nil:
+			existing[i] = current
+			conflicts = true
+		case !apierrors.IsNotFound(err):
+			return nil, err
+		}
+	}
+
+	if conflicts && opts.Conflict == ConflictFail {
+		for i := range pages {
+			if existing[i] != nil {
+				report.add(pages[i].Name, ActionConflict, fmt.Errorf("page already exists"))
+			} else {
+				report.add(pages[i].Name, ActionSkipped, nil)
+			}
+		}
+		return report, ErrConflict
+	}
+
+	for i := range pages {
+		page := pages[i]
+		if page.Name == "" {
+			report.add("", ActionFailed, fmt.Errorf("name is required"))
+			continue
+		}
+
+		current := existing[i]
+		if current == nil {
+			object := Clean(&page)
+			object.Namespace = opts.Namespace
+			if err := c.Create(ctx, &object, createOpts...); err != nil {
+				report.add(page.Name, ActionFailed, err)
+				continue
+			}
+			report.add(page.Name, ActionCreated, nil)
+			continue
+		}
+
+		if opts.Conflict == ConflictSkip {
+			report.add(page.Name, ActionSkipped, nil)
+			continue
+		}
+
+		current.Labels = page.Labels
+		current.Annotations = page.Annotations
+		current.Spec = page.Spec
+		if err := c.Update(ctx, current, updateOpts...); err != nil {
+			report.add(page.Name, ActionFailed, err)
+			continue
+		}
+		report.add(page.Name, ActionUpdated, nil)
+	}
+	return report, nil
+}
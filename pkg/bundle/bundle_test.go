@@ -0,0 +1,131 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newPage(name, content string) *frontendv1alpha1.FrontendPage {
+	return &frontendv1alpha1.FrontendPage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{"team": "web"},
+			Annotations: map[string]string{
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+			},
+		},
+		Spec: frontendv1alpha1.FrontendPageSpec{
+			Content:  content,
+			Image:    "nginx:latest",
+			Replicas: 1,
+			Port:     80,
+		},
+	}
+}
+
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, frontendv1alpha1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	c := newFakeClient(t, newPage("b", "<h1>B</h1>"), newPage("a", "<h1>A</h1>"))
+
+	pages, err := Export(context.Background(), c, "default")
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+	require.Equal(t, "a", pages[0].Name)
+	require.Empty(t, pages[0].Namespace)
+	require.Empty(t, pages[0].ResourceVersion)
+	require.Nil(t, pages[0].Annotations)
+
+	for _, format := range []string{FormatYAML, FormatJSON} {
+		var buf bytes.Buffer
+		require.NoError(t, Encode(&buf, pages, format))
+		if format == FormatYAML {
+			require.Equal(t, 2, strings.Count(buf.String(), "---\n"))
+			require.NotContains(t, buf.String(), "resourceVersion")
+		}
+
+		decoded, err := Decode(&buf)
+		require.NoError(t, err, format)
+		require.Equal(t, pages, decoded, format)
+	}
+}
+
+func TestDecodeRejectsOtherKinds(t *testing.T) {
+	_, err := Decode(strings.NewReader("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: x\n"))
+	require.Error(t, err)
+}
+
+func TestImportConflictPolicies(t *testing.T) {
+	ctx := context.Background()
+	bundled := []frontendv1alpha1.FrontendPage{
+		Clean(newPage("existing", "<h1>New</h1>")),
+		Clean(newPage("fresh", "<h1>Fresh</h1>")),
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		c := newFakeClient(t, newPage("existing", "<h1>Old</h1>"))
+		report, err := Import(ctx, c, bundled, ImportOptions{Namespace: "default", Conflict: ConflictFail})
+		require.ErrorIs(t, err, ErrConflict)
+		require.Equal(t, ActionConflict, report.Items[0].Action)
+
+		// Nothing is written when the import fails
+		err = c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "fresh"}, &frontendv1alpha1.FrontendPage{})
+		require.Error(t, err)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		c := newFakeClient(t, newPage("existing", "<h1>Old</h1>"))
+		report, err := Import(ctx, c, bundled, ImportOptions{Namespace: "default", Conflict: ConflictSkip})
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Skipped)
+		require.Equal(t, 1, report.Created)
+
+		page := &frontendv1alpha1.FrontendPage{}
+		require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "existing"}, page))
+		require.Equal(t, "<h1>Old</h1>", page.Spec.Content)
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		c := newFakeClient(t, newPage("existing", "<h1>Old</h1>"))
+		report, err := Import(ctx, c, bundled, ImportOptions{Namespace: "default", Conflict: ConflictOverwrite})
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Updated)
+		require.Equal(t, 1, report.Created)
+
+		page := &frontendv1alpha1.FrontendPage{}
+		require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "existing"}, page))
+		require.Equal(t, "<h1>New</h1>", page.Spec.Content)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		c := newFakeClient(t)
+		report, err := Import(ctx, c, bundled, ImportOptions{Namespace: "default", DryRun: true})
+		require.NoError(t, err)
+		require.True(t, report.DryRun)
+		require.Equal(t, 2, report.Created)
+
+		list := &frontendv1alpha1.FrontendPageList{}
+		require.NoError(t, c.List(ctx, list))
+		require.Empty(t, list.Items)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		c := newFakeClient(t)
+		_, err := Import(ctx, c, append(bundled, bundled[0]), ImportOptions{Namespace: "default"})
+		require.ErrorIs(t, err, ErrInvalidBundle)
+	})
+}
@@ -0,0 +1,264 @@
+// Package certs serves TLS for the API and MCP listeners. Certificates are
+// reloaded from disk when they change, so rotated Secrets don't need a restart.
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Client certificate policies
+const (
+	// ClientAuthRequest verifies client certificates that are presented
+	ClientAuthRequest = "request"
+	// ClientAuthRequire rejects connections without a valid client certificate
+	ClientAuthRequire = "require"
+)
+
+// Options configure TLS for a listener
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables client certificate verification against these CAs
+	ClientCAFile string
+	// ClientAuth is ClientAuthRequest or ClientAuthRequire. It only applies
+	// with a ClientCAFile.
+	ClientAuth string
+	// SelfSigned generates a certificate for Hosts instead of reading files
+	SelfSigned bool
+	Hosts      []string
+}
+
+// Enabled reports whether the options turn TLS on
+func (o *Options) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != "" || o.SelfSigned
+}
+
+// Validate checks that the options are consistent
+func (o *Options) Validate() error {
+	switch {
+	case o.SelfSigned && (o.CertFile != "" || o.KeyFile != ""):
+		return errors.New("a self-signed certificate can't be combined with certificate files")
+	case !o.SelfSigned && (o.CertFile == "") != (o.KeyFile == ""):
+		return errors.New("both a certificate and a key file are required")
+	case o.ClientCAFile != "" && !o.Enabled():
+		return errors.New("client certificate verification needs TLS")
+	}
+	if o.ClientCAFile != "" && o.ClientAuth != ClientAuthRequest && o.ClientAuth != ClientAuthRequire {
+		return fmt.Errorf("unsupported client auth %q, use %s or %s", o.ClientAuth, ClientAuthRequest, ClientAuthRequire)
+	}
+	return nil
+}
+
+// Reloader holds the current certificate and client CAs
+type Reloader struct {
+	opts Options
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	loaded    [][]byte
+}
+
+// NewReloader loads the certificate, or generates one when opts.SelfSigned
+// is set
+func NewReloader(opts Options) (*Reloader, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	r := &Reloader{opts: opts}
+	if opts.SelfSigned {
+		certPEM, keyPEM, err := GenerateSelfSigned(opts.Hosts, 365*24*time.Hour)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, err
+		}
+		r.cert = &cert
+		if opts.ClientCAFile == "" {
+			return r, nil
+		}
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again and reports whether anything changed. On
+// failure the current certificate stays in use.
+func (r *Reloader) Reload() (bool, error) {
+	if r.opts.SelfSigned && r.opts.ClientCAFile == "" {
+		return false, nil
+	}
+	files := []string{r.opts.ClientCAFile}
+	if !r.opts.SelfSigned {
+		files = append(files, r.opts.CertFile, r.opts.KeyFile)
+	}
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		if file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return false, err
+		}
+		contents[i] = data
+	}
+
+	r.mu.RLock()
+	unchanged := r.loaded != nil
+	for i := range contents {
+		if unchanged && !bytes.Equal(contents[i], r.loaded[i]) {
+			unchanged = false
+		}
+	}
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	var clientCAs *x509.CertPool
+	if contents[0] != nil {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(contents[0]) {
+			return false, fmt.Errorf("no certificates in %s", r.opts.ClientCAFile)
+		}
+	}
+	cert := r.cert
+	if !r.opts.SelfSigned {
+		pair, err := tls.X509KeyPair(contents[1], contents[2])
+		if err != nil {
+			return false, fmt.Errorf("load %s: %w", r.opts.CertFile, err)
+		}
+		cert = &pair
+	}
+
+	r.mu.Lock()
+	r.cert = cert
+	r.clientCAs = clientCAs
+	r.loaded = contents
+	r.mu.Unlock()
+	return true, nil
+}
+
+// Run reloads the files every interval until ctx is done
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.Reload()
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to reload TLS certificate, keeping the current one")
+			} else if changed {
+				log.Info().Msg("Reloaded TLS certificate")
+			}
+		}
+	}
+}
+
+// Certificate returns the certificate currently served
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// TLSConfig returns a config that picks up reloaded certificates and client
+// CAs on each handshake
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+				NextProtos:   []string{"http/1.1"},
+			}
+			if r.clientCAs != nil {
+				config.ClientCAs = r.clientCAs
+				config.ClientAuth = tls.VerifyClientCertIfGiven
+				if r.opts.ClientAuth == ClientAuthRequire {
+					config.ClientAuth = tls.RequireAndVerifyClientCert
+				}
+			}
+			return config, nil
+		},
+	}
+}
+
+// Fingerprint is the SHA-256 of a certificate's DER encoding, for pinning
+// self-signed certificates
+func Fingerprint(cert *tls.Certificate) string {
+	sum := sha256.Sum256(cert.Certificate[0])
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateSelfSigned creates a P-256 certificate for hosts, which may be DNS
+// names or IP addresses. It is meant for development only.
+func GenerateSelfSigned(hosts []string, validFor time.Duration) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "k8s-controller-tutorial", Organization: []string{"k8s-controller-tutorial development"}},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
@@ -0,0 +1,101 @@
+package certs
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writePair(t *testing.T, dir string, hosts ...string) (string, string, []byte) {
+	t.Helper()
+	certPEM, keyPEM, err := GenerateSelfSigned(hosts, time.Hour)
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	return certFile, keyFile, certPEM
+}
+
+// servedCertificate completes a handshake and returns the server's leaf
+// certificate in DER form
+func servedCertificate(t *testing.T, config *tls.Config) []byte {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Raw
+}
+
+func TestReloader_HotReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writePair(t, dir, "localhost")
+	reloader, err := NewReloader(Options{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	config := reloader.TLSConfig()
+	first := servedCertificate(t, config)
+
+	changed, err := reloader.Reload()
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	writePair(t, dir, "localhost")
+	changed, err = reloader.Reload()
+	require.NoError(t, err)
+	require.True(t, changed)
+	second := servedCertificate(t, config)
+	require.NotEqual(t, first, second, "the same config serves the rotated certificate")
+
+	// A broken file keeps the current certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	_, err = reloader.Reload()
+	require.Error(t, err)
+	require.Equal(t, second, servedCertificate(t, config))
+}
+
+func TestReloader_SelfSigned(t *testing.T) {
+	reloader, err := NewReloader(Options{SelfSigned: true, Hosts: []string{"localhost", "127.0.0.1"}})
+	require.NoError(t, err)
+	leaf := reloader.Certificate().Leaf
+	require.NotNil(t, leaf)
+	require.Contains(t, leaf.DNSNames, "localhost")
+	require.Len(t, leaf.IPAddresses, 1)
+	require.Len(t, Fingerprint(reloader.Certificate()), 64)
+
+	changed, err := reloader.Reload()
+	require.NoError(t, err)
+	require.False(t, changed)
+}
+
+func TestOptions_Validate(t *testing.T) {
+	require.False(t, (&Options{}).Enabled())
+	require.NoError(t, (&Options{}).Validate())
+	require.Error(t, (&Options{CertFile: "tls.crt"}).Validate())
+	require.Error(t, (&Options{SelfSigned: true, CertFile: "tls.crt", KeyFile: "tls.key"}).Validate())
+	require.Error(t, (&Options{ClientCAFile: "ca.crt", ClientAuth: ClientAuthRequire}).Validate())
+	require.Error(t, (&Options{SelfSigned: true, ClientCAFile: "ca.crt", ClientAuth: "maybe"}).Validate())
+	require.NoError(t, (&Options{SelfSigned: true, ClientCAFile: "ca.crt", ClientAuth: ClientAuthRequest}).Validate())
+}
+
+func TestGenerateSelfSigned_ServesHosts(t *testing.T) {
+	certPEM, keyPEM, err := GenerateSelfSigned([]string{"127.0.0.1"}, time.Hour)
+	require.NoError(t, err)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	require.NotNil(t, cert.Leaf)
+	require.True(t, cert.Leaf.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")))
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before it expires a token is replaced, so
+// that it doesn't expire in flight
+const tokenRefreshMargin = 30 * time.Second
+
+// TokenSource returns the bearer token of a request
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a TokenSource that always returns the same token
+type StaticToken string
+
+// Token implements TokenSource
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// expiringTokenSource is a TokenSource that can get a new token when the
+// server rejects the current one
+type expiringTokenSource interface {
+	TokenSource
+	expire()
+}
+
+// credentialsTokenSource exchanges credentials for tokens. It uses the
+// refresh token while it is valid, and the credentials again after that.
+type credentialsTokenSource struct {
+	client *Client
+	creds  Credentials
+
+	mu      sync.Mutex
+	current *TokenResponse
+}
+
+// Token implements TokenSource
+func (s *credentialsTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.current != nil && now.Add(tokenRefreshMargin).Before(s.current.ExpiresAt) {
+		return s.current.Token, nil
+	}
+	var resp *TokenResponse
+	var err error
+	if s.current != nil && s.current.RefreshToken != "" && now.Add(tokenRefreshMargin).Before(s.current.RefreshExpiresAt) {
+		resp, err = s.client.RefreshToken(ctx, s.current.RefreshToken)
+	}
+	if resp == nil {
+		// Refresh tokens are single use and can be revoked, so a failed
+		// refresh falls back to the credentials
+		resp, err = s.client.IssueToken(ctx, s.creds)
+	}
+	if err != nil {
+		s.current = nil
+		return "", err
+	}
+	s.current = resp
+	return resp.Token, nil
+}
+
+// expire drops the access token, keeping the refresh token
+func (s *credentialsTokenSource) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		s.current.Token, s.current.ExpiresAt = "", time.Time{}
+	}
+}
+
+// IssueToken exchanges credentials for an access and a refresh token. Clients
+// created WithCredentials do this themselves.
+func (c *Client) IssueToken(ctx context.Context, creds Credentials) (*TokenResponse, error) {
+	resp := &TokenResponse{}
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/token", body: creds, anonymous: true}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RefreshToken exchanges a refresh token for new tokens. The server revokes
+// the refresh token, so it can only be used once.
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	resp := &TokenResponse{}
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/token/refresh", body: refreshRequest{RefreshToken: refreshToken}, anonymous: true}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
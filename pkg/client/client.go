@@ -0,0 +1,190 @@
+// Package client is a typed Go client for the FrontendPage REST API served
+// by the server command. It acquires and refreshes tokens, pages through
+// lists, follows watch streams and returns API errors as *Problem.
+//
+//	c, err := client.New("https://pages.example.com",
+//		client.WithCredentials(client.Credentials{Username: "alice", Password: password}))
+//	pages, err := c.ListAll(ctx)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// APIKeyHeader carries API keys, like api.APIKeyHeader
+const APIKeyHeader = "X-API-Key"
+
+// maxErrorBody caps how much of an error response is read
+const maxErrorBody = 1 << 20
+
+// Client calls the REST API of one server. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	tokens     TokenSource
+	apiKey     string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with httpClient instead of http.DefaultClient,
+// for TLS settings or timeouts. Watch streams stay open for as long as the
+// context of Watch, so keep the client's Timeout unset when watching.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken authenticates with a bearer token that is never refreshed
+func WithToken(token string) Option {
+	return WithTokenSource(StaticToken(token))
+}
+
+// WithTokenSource authenticates with the tokens of source
+func WithTokenSource(source TokenSource) Option {
+	return func(c *Client) {
+		c.tokens = source
+	}
+}
+
+// WithCredentials exchanges creds for a token on the first request, and
+// refreshes it before it expires
+func WithCredentials(creds Credentials) Option {
+	return func(c *Client) {
+		c.tokens = &credentialsTokenSource{client: c, creds: creds}
+	}
+}
+
+// WithAPIKey authenticates with an API key, which takes precedence over
+// tokens on the server
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// New returns a client for the server at baseURL, such as
+// https://pages.example.com
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("base URL %q must be an http or https URL", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	c := &Client{baseURL: u, httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// request is an API call, kept so that it can be sent again with a new token
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	// accept is the media type of the response, JSON when empty
+	accept string
+	// anonymous requests carry no credentials, like the token endpoints
+	anonymous bool
+}
+
+func (c *Client) newRequest(ctx context.Context, r request) (*http.Request, error) {
+	// Paths come escaped, so names can't add segments
+	u, err := c.baseURL.Parse(c.baseURL.EscapedPath() + r.path)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = r.query.Encode()
+
+	var body io.Reader
+	if r.body != nil {
+		data, err := json.Marshal(r.body)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if r.accept == "" {
+		r.accept = "application/json"
+	}
+	req.Header.Set("Accept", r.accept)
+	if r.body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.anonymous {
+		return req, nil
+	}
+	if c.apiKey != "" {
+		req.Header.Set(APIKeyHeader, c.apiKey)
+	} else if c.tokens != nil {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// send sends a request and returns the response of a 2xx status, the body of
+// which the caller closes. A token the server rejects is replaced once.
+func (c *Client) send(ctx context.Context, r request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := c.newRequest(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		resp.Body.Close()
+
+		// The token may have been revoked or signed with a rotated key
+		if expiring, ok := c.tokens.(expiringTokenSource); ok && resp.StatusCode == http.StatusUnauthorized &&
+			attempt == 0 && !r.anonymous && c.apiKey == "" {
+			expiring.expire()
+			continue
+		}
+		return nil, problemFromResponse(resp, body)
+	}
+}
+
+// do sends a request and decodes the JSON response into out, unless out is nil
+func (c *Client) do(ctx context.Context, r request, out any) error {
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", r.method, r.path, err)
+	}
+	return nil
+}
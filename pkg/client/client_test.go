@@ -0,0 +1,244 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JRaver/k8s-controller-tutorial/pkg/api"
+	frontendv1alpha1 "github.com/JRaver/k8s-controller-tutorial/pkg/apis/frontend/v1alpha1"
+	"github.com/buaazp/fasthttprouter"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"golang.org/x/crypto/bcrypt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testServerURL = "http://pages.test"
+
+// countingAuthenticator counts the credentials exchanged for tokens
+type countingAuthenticator struct {
+	api.Authenticator
+	calls atomic.Int32
+}
+
+func (a *countingAuthenticator) Authenticate(ctx context.Context, creds api.Credentials) (*api.Identity, error) {
+	a.calls.Add(1)
+	return a.Authenticator.Authenticate(ctx, creds)
+}
+
+func testPage(name string) *frontendv1alpha1.FrontendPage {
+	return &frontendv1alpha1.FrontendPage{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", ResourceVersion: "1"},
+		Spec:       frontendv1alpha1.FrontendPageSpec{Content: "<h1>" + name + "</h1>", Image: "nginx:latest", Replicas: 1, Port: 80},
+	}
+}
+
+// startServer serves the REST routes in process, backed by the fake client,
+// and returns the HTTP client that reaches it at testServerURL, along with the
+// security Config of the server. alice can get tokens with the password
+// "secret".
+func startServer(t *testing.T, pages ...*frontendv1alpha1.FrontendPage) (*http.Client, *api.FrontendPageApi, *api.Config) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	users, err := api.NewStaticUsers([]api.StaticUser{{Username: "alice", PasswordHash: string(hash)}})
+	require.NoError(t, err)
+	config := api.NewConfig()
+	config.JWTSecret, config.TokenAuthenticator = "test-secret", &countingAuthenticator{Authenticator: users}
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, frontendv1alpha1.AddToScheme(scheme))
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, page := range pages {
+		builder = builder.WithObjects(page)
+	}
+	frontendApi := &api.FrontendPageApi{
+		K8SClient: builder.Build(),
+		Namespace: "default",
+		Watcher:   api.NewFrontendPageWatcher("default"),
+	}
+	router := fasthttprouter.New()
+	(&api.Router{Service: frontendApi, Config: config, Watcher: frontendApi.Watcher}).RegisterRoutes(router, nil)
+
+	ln := fasthttputil.NewInmemoryListener()
+	go fasthttp.Serve(ln, router.Handler)
+	t.Cleanup(func() { ln.Close() })
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(context.Context, string, string) (net.Conn, error) { return ln.Dial() },
+	}}
+	return httpClient, frontendApi, config
+}
+
+func newTestClient(t *testing.T, httpClient *http.Client, opts ...Option) *Client {
+	t.Helper()
+	c, err := New(testServerURL, append([]Option{WithHTTPClient(httpClient)}, opts...)...)
+	require.NoError(t, err)
+	return c
+}
+
+var alice = Credentials{Username: "alice", Password: "secret"}
+
+func TestClient_CRUD(t *testing.T) {
+	httpClient, _, _ := startServer(t, testPage("home"))
+	c := newTestClient(t, httpClient, WithCredentials(alice))
+	ctx := context.Background()
+
+	page, err := c.Get(ctx, "home")
+	require.NoError(t, err)
+	require.Equal(t, &Page{Name: "home", Content: "<h1>home</h1>", Image: "nginx:latest", Replicas: 1, Port: 80}, page)
+
+	_, err = c.Create(ctx, Page{Name: "docs", Content: "<h1>docs</h1>", Image: "nginx:latest", Replicas: 1, Port: 80}, WriteOptions{DryRun: true})
+	require.NoError(t, err)
+	_, err = c.Get(ctx, "docs")
+	require.True(t, IsNotFound(err), "dry runs persist nothing: %v", err)
+
+	created, err := c.Create(ctx, Page{Name: "docs", Content: "<h1>docs</h1>", Image: "nginx:latest", Replicas: 1, Port: 80}, WriteOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "docs", created.Name)
+	_, err = c.Create(ctx, *created, WriteOptions{})
+	require.True(t, IsAlreadyExists(err), err)
+
+	image := "nginx:1.27"
+	patched, err := c.Patch(ctx, "docs", PagePatch{Image: &image}, WriteOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "nginx:1.27", patched.Image)
+	require.Equal(t, "<h1>docs</h1>", patched.Content)
+
+	patched.Replicas = 3
+	updated, err := c.Update(ctx, *patched, WriteOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 3, updated.Replicas)
+
+	require.NoError(t, c.Delete(ctx, "docs"))
+	err = c.Delete(ctx, "docs")
+	require.True(t, IsNotFound(err), err)
+	var problem *Problem
+	require.ErrorAs(t, err, &problem)
+	require.Equal(t, "NotFound", problem.Reason)
+}
+
+func TestClient_ListPagination(t *testing.T) {
+	httpClient, _, _ := startServer(t, testPage("a"), testPage("b"), testPage("c"), testPage("d"), testPage("e"))
+	c := newTestClient(t, httpClient, WithCredentials(alice))
+	ctx := context.Background()
+
+	list, err := c.List(ctx, ListOptions{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+	require.NotEmpty(t, list.Continue)
+
+	pages, err := c.ListAll(ctx, 2)
+	require.NoError(t, err)
+	var names []string
+	for _, page := range pages {
+		names = append(names, page.Name)
+	}
+	require.Equal(t, []string{"a", "b", "c", "d", "e"}, names)
+
+	_, err = c.List(ctx, ListOptions{Limit: 1000})
+	require.True(t, IsBadRequest(err), err)
+	var problem *Problem
+	require.ErrorAs(t, err, &problem)
+	require.Equal(t, "limit", problem.Causes[0].Field)
+	require.Equal(t, "#/paths/~1api~1frontendpages/get/parameters/0/schema/maximum", problem.Causes[0].SchemaPath)
+}
+
+func TestClient_Tokens(t *testing.T) {
+	httpClient, _, config := startServer(t, testPage("home"))
+	authenticator := config.TokenAuthenticator.(*countingAuthenticator)
+	ctx := context.Background()
+
+	_, err := newTestClient(t, httpClient).Get(ctx, "home")
+	require.True(t, IsUnauthorized(err), err)
+	_, err = newTestClient(t, httpClient, WithCredentials(Credentials{Username: "alice", Password: "wrong"})).Get(ctx, "home")
+	require.True(t, IsUnauthorized(err), err)
+	authenticator.calls.Store(0)
+
+	// Tokens that are about to expire are refreshed without the password
+	config.JWTTTL = 10 * time.Second
+	c := newTestClient(t, httpClient, WithCredentials(alice))
+	for range 3 {
+		_, err := c.Get(ctx, "home")
+		require.NoError(t, err)
+	}
+	require.EqualValues(t, 1, authenticator.calls.Load())
+
+	// Tokens the server rejects, here after a key rotation, are replaced
+	config.JWTTTL = time.Hour
+	_, err = c.Get(ctx, "home")
+	require.NoError(t, err)
+	config.JWTSecret = "rotated-secret"
+	_, err = c.Get(ctx, "home")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, authenticator.calls.Load())
+
+	tokens, err := c.IssueToken(ctx, alice)
+	require.NoError(t, err)
+	_, err = newTestClient(t, httpClient, WithToken(tokens.Token)).Get(ctx, "home")
+	require.NoError(t, err)
+}
+
+func TestClient_Watch(t *testing.T) {
+	httpClient, frontendApi, _ := startServer(t)
+	c := newTestClient(t, httpClient, WithCredentials(alice))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	watcher, err := c.Watch(ctx, WatchOptions{Types: []string{EventAdded, EventDeleted}})
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	page := testPage("home")
+	frontendApi.Watcher.OnAdd(page, false)
+	updated := page.DeepCopy()
+	updated.ResourceVersion = "2"
+	frontendApi.Watcher.OnUpdate(page, updated)
+	deleted := updated.DeepCopy()
+	deleted.ResourceVersion = "3"
+	frontendApi.Watcher.OnDelete(deleted)
+
+	event, err := watcher.Next()
+	require.NoError(t, err)
+	require.Equal(t, Event{Type: EventAdded, ResourceVersion: "1", Object: Page{Name: "home", Content: "<h1>home</h1>", Image: "nginx:latest", Replicas: 1, Port: 80}}, event)
+	event, err = watcher.Next()
+	require.NoError(t, err)
+	require.Equal(t, EventDeleted, event.Type, "MODIFIED events were filtered out")
+	require.Equal(t, "3", watcher.ResourceVersion())
+
+	require.NoError(t, watcher.Close())
+	_, err = watcher.Next()
+	require.Error(t, err)
+	require.NotErrorIs(t, err, io.EOF)
+
+	// Resuming replays the changes after the resource version
+	watcher, err = c.Watch(ctx, WatchOptions{ResourceVersion: "2"})
+	require.NoError(t, err)
+	defer watcher.Close()
+	event, err = watcher.Next()
+	require.NoError(t, err)
+	require.Equal(t, EventDeleted, event.Type)
+	require.Equal(t, "3", event.ResourceVersion)
+
+	_, err = c.Watch(ctx, WatchOptions{ResourceVersion: "42"})
+	require.True(t, IsGone(err), err)
+}
+
+func TestNew(t *testing.T) {
+	for _, baseURL := range []string{"pages.example.com", "ftp://pages.example.com", "://"} {
+		_, err := New(baseURL)
+		require.Error(t, err, baseURL)
+	}
+	c, err := New("https://pages.example.com/prefix/")
+	require.NoError(t, err)
+	req, err := c.newRequest(context.Background(), request{method: http.MethodGet, path: pagePath("a/b")})
+	require.NoError(t, err)
+	require.Equal(t, "https://pages.example.com/prefix/api/frontendpages/a%2Fb", req.URL.String())
+}
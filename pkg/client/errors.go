@@ -0,0 +1,112 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ProblemCause describes a single field that caused a request to fail
+type ProblemCause struct {
+	Field   string `json:"field,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message"`
+	// SchemaPath points to the keyword of the OpenAPI document the field failed
+	SchemaPath string `json:"schemaPath,omitempty"`
+}
+
+// Problem is the RFC 7807 error the API returns (api.Problem). Every non-2xx
+// response is returned as a *Problem, also when the body wasn't one.
+type Problem struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail,omitempty"`
+	Instance string         `json:"instance,omitempty"`
+	Reason   string         `json:"reason,omitempty"`
+	Causes   []ProblemCause `json:"causes,omitempty"`
+}
+
+// Error implements error
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return fmt.Sprintf("%d %s", p.Status, p.Title)
+	}
+	return fmt.Sprintf("%d %s: %s", p.Status, p.Title, p.Detail)
+}
+
+// problemFromResponse decodes the problem in an error response
+func problemFromResponse(resp *http.Response, body []byte) *Problem {
+	problem := &Problem{}
+	if err := json.Unmarshal(body, problem); err != nil || problem.Status == 0 {
+		problem = &Problem{Type: "about:blank", Title: http.StatusText(resp.StatusCode), Detail: string(body)}
+	}
+	problem.Status = resp.StatusCode
+	return problem
+}
+
+// StatusCode returns the HTTP status of a *Problem in err's chain, or 0
+func StatusCode(err error) int {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem.Status
+	}
+	return 0
+}
+
+// IsNotFound reports whether the page or route doesn't exist
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsAlreadyExists reports whether a created page already exists
+func IsAlreadyExists(err error) bool {
+	return StatusCode(err) == http.StatusConflict && reason(err) == "AlreadyExists"
+}
+
+// IsConflict reports whether the request conflicted with the current state
+func IsConflict(err error) bool {
+	return StatusCode(err) == http.StatusConflict
+}
+
+// IsBadRequest reports whether the request didn't match the OpenAPI document
+// of the server. The Causes of the Problem name the fields and schema paths.
+func IsBadRequest(err error) bool {
+	return StatusCode(err) == http.StatusBadRequest
+}
+
+// IsInvalid reports whether the request failed validation, the Causes of the
+// Problem name the fields
+func IsInvalid(err error) bool {
+	return StatusCode(err) == http.StatusUnprocessableEntity
+}
+
+// IsUnauthorized reports whether the credentials were missing or rejected
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == http.StatusUnauthorized
+}
+
+// IsForbidden reports whether the caller may not do what it asked
+func IsForbidden(err error) bool {
+	return StatusCode(err) == http.StatusForbidden
+}
+
+// IsGone reports whether a watch resumed from a resource version the server
+// no longer keeps. List again and watch from the start.
+func IsGone(err error) bool {
+	return StatusCode(err) == http.StatusGone
+}
+
+// IsTooManyRequests reports whether the caller was rate limited
+func IsTooManyRequests(err error) bool {
+	return StatusCode(err) == http.StatusTooManyRequests
+}
+
+func reason(err error) string {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem.Reason
+	}
+	return ""
+}
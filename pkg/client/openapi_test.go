@@ -0,0 +1,102 @@
+package client
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// openAPIDoc is the part of docs/openapi.json the client is checked against
+type openAPIDoc struct {
+	Paths map[string]map[string]struct {
+		Parameters []struct {
+			Name string `json:"name"`
+			In   string `json:"in"`
+		} `json:"parameters"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPI(t *testing.T) openAPIDoc {
+	t.Helper()
+	data, err := os.ReadFile("../../docs/openapi.json")
+	require.NoError(t, err)
+	var doc openAPIDoc
+	require.NoError(t, json.Unmarshal(data, &doc))
+	return doc
+}
+
+// jsonFields returns the JSON names of the fields of a struct
+func jsonFields(v any) []string {
+	var names []string
+	typ := reflect.TypeOf(v)
+	for i := range typ.NumField() {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestTypesMatchOpenAPI(t *testing.T) {
+	doc := loadOpenAPI(t)
+	for name, v := range map[string]any{
+		"api.FrontendPageDoc":      Page{},
+		"api.FrontendPageDocList":  PageList{},
+		"api.FrontendPagePatchDoc": PagePatch{},
+		"api.Credentials":          Credentials{},
+		"api.TokenResponse":        TokenResponse{},
+		"api.RefreshRequest":       refreshRequest{},
+		"api.Problem":              Problem{},
+		"api.ProblemCause":         ProblemCause{},
+	} {
+		schema, ok := doc.Components.Schemas[name]
+		require.True(t, ok, "%s is not in docs/openapi.json", name)
+		var properties []string
+		for name := range schema.Properties {
+			properties = append(properties, name)
+		}
+		slices.Sort(properties)
+		require.Equal(t, properties, jsonFields(v), "%T and %s differ", v, name)
+	}
+}
+
+func TestRequestsMatchOpenAPI(t *testing.T) {
+	doc := loadOpenAPI(t)
+	for _, op := range []struct {
+		method, path string
+		query        []string
+	}{
+		{"get", "/api/frontendpages", []string{"limit", "continue", "watch", "resourceVersion", "labelSelector", "fieldSelector", "types"}},
+		{"post", "/api/frontendpages", []string{"dryRun"}},
+		{"get", "/api/frontendpages/{name}", nil},
+		{"put", "/api/frontendpages/{name}", []string{"dryRun"}},
+		{"patch", "/api/frontendpages/{name}", []string{"dryRun"}},
+		{"delete", "/api/frontendpages/{name}", nil},
+		{"post", "/api/token", nil},
+		{"post", "/api/token/refresh", nil},
+	} {
+		operation, ok := doc.Paths[op.path][op.method]
+		require.True(t, ok, "%s %s is not in docs/openapi.json", op.method, op.path)
+		var query []string
+		for _, param := range operation.Parameters {
+			if param.In == "query" {
+				query = append(query, param.Name)
+			}
+		}
+		for _, name := range op.query {
+			require.Contains(t, query, name, "%s %s", op.method, op.path)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const pagesPath = "/api/frontendpages"
+
+// ListOptions selects one page of a list
+type ListOptions struct {
+	// Limit is the most pages to return, up to 500. Zero returns all of them.
+	Limit int
+	// Continue is the Continue token of the previous PageList
+	Continue string
+}
+
+// WriteOptions apply to requests that change pages
+type WriteOptions struct {
+	// DryRun has the server validate the request without persisting it
+	DryRun bool
+}
+
+func (o WriteOptions) query() url.Values {
+	query := url.Values{}
+	if o.DryRun {
+		query.Set("dryRun", "All")
+	}
+	return query
+}
+
+func pagePath(name string) string {
+	return pagesPath + "/" + url.PathEscape(name)
+}
+
+// List returns one page of the FrontendPages, in name order
+func (c *Client) List(ctx context.Context, opts ListOptions) (*PageList, error) {
+	query := url.Values{}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Continue != "" {
+		query.Set("continue", opts.Continue)
+	}
+	list := &PageList{}
+	if err := c.do(ctx, request{method: http.MethodGet, path: pagesPath, query: query}, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ListAll returns every FrontendPage, listing limit at a time. A limit of
+// zero lists them in one request.
+func (c *Client) ListAll(ctx context.Context, limit int) ([]Page, error) {
+	var pages []Page
+	opts := ListOptions{Limit: limit}
+	for {
+		list, err := c.List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, list.Items...)
+		if list.Continue == "" {
+			return pages, nil
+		}
+		opts.Continue = list.Continue
+	}
+}
+
+// Get returns a FrontendPage by name
+func (c *Client) Get(ctx context.Context, name string) (*Page, error) {
+	page := &Page{}
+	if err := c.do(ctx, request{method: http.MethodGet, path: pagePath(name)}, page); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Create creates a FrontendPage and returns it as the server stored it
+func (c *Client) Create(ctx context.Context, page Page, opts WriteOptions) (*Page, error) {
+	created := &Page{}
+	if err := c.do(ctx, request{method: http.MethodPost, path: pagesPath, query: opts.query(), body: page}, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Update replaces the spec of a FrontendPage. Fields left empty are cleared,
+// use Patch to change some of them.
+func (c *Client) Update(ctx context.Context, page Page, opts WriteOptions) (*Page, error) {
+	updated := &Page{}
+	if err := c.do(ctx, request{method: http.MethodPut, path: pagePath(page.Name), query: opts.query(), body: page}, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Patch changes the fields of a FrontendPage that are set in patch
+func (c *Client) Patch(ctx context.Context, name string, patch PagePatch, opts WriteOptions) (*Page, error) {
+	patched := &Page{}
+	if err := c.do(ctx, request{method: http.MethodPatch, path: pagePath(name), query: opts.query(), body: patch}, patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// Delete deletes a FrontendPage, and with it the objects the controller
+// created for it
+func (c *Client) Delete(ctx context.Context, name string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: pagePath(name)}, nil)
+}
@@ -0,0 +1,62 @@
+package client
+
+import "time"
+
+// Page is a FrontendPage as the REST API serves it (api.FrontendPageDoc)
+type Page struct {
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	Image    string `json:"image"`
+	Replicas int    `json:"replicas"`
+	Port     int    `json:"port"`
+}
+
+// PageList is one page of a list response (api.FrontendPageDocList)
+type PageList struct {
+	Items []Page `json:"items"`
+	// Continue is set when more pages follow
+	Continue string `json:"continue,omitempty"`
+}
+
+// PagePatch changes only the fields that are set (api.FrontendPagePatchDoc)
+type PagePatch struct {
+	Content  *string `json:"content,omitempty"`
+	Image    *string `json:"image,omitempty"`
+	Replicas *int    `json:"replicas,omitempty"`
+	Port     *int    `json:"port,omitempty"`
+}
+
+// Credentials are exchanged for a token (api.Credentials). Either Username
+// and Password or ServiceAccountToken is set.
+type Credentials struct {
+	Username            string `json:"username,omitempty"`
+	Password            string `json:"password,omitempty"`
+	ServiceAccountToken string `json:"serviceAccountToken,omitempty"`
+}
+
+// TokenResponse is returned by the token endpoints (api.TokenResponse)
+type TokenResponse struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+// refreshRequest is the body of the refresh endpoint (api.RefreshRequest)
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Watch event types
+const (
+	EventAdded    = "ADDED"
+	EventModified = "MODIFIED"
+	EventDeleted  = "DELETED"
+)
+
+// Event is a change delivered by Watch
+type Event struct {
+	Type            string `json:"type"`
+	ResourceVersion string `json:"resourceVersion"`
+	Object          Page   `json:"object"`
+}
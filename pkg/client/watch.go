@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WatchOptions select the changes a watch delivers
+type WatchOptions struct {
+	// ResourceVersion resumes after this change. When empty the watch starts
+	// with an ADDED event for every current page.
+	ResourceVersion string
+	LabelSelector   string
+	FieldSelector   string
+	// Types are the event types to deliver, all of them when empty
+	Types []string
+}
+
+// Watcher reads the Server-Sent Events of a watch stream
+type Watcher struct {
+	body            io.ReadCloser
+	reader          *bufio.Reader
+	resourceVersion string
+}
+
+// Watch streams the changes to FrontendPages until ctx is done or the
+// watcher is closed. Resuming from a resource version the server no longer
+// keeps fails with a Problem that IsGone reports.
+func (c *Client) Watch(ctx context.Context, opts WatchOptions) (*Watcher, error) {
+	query := url.Values{"watch": {"true"}}
+	if opts.ResourceVersion != "" {
+		query.Set("resourceVersion", opts.ResourceVersion)
+	}
+	if opts.LabelSelector != "" {
+		query.Set("labelSelector", opts.LabelSelector)
+	}
+	if opts.FieldSelector != "" {
+		query.Set("fieldSelector", opts.FieldSelector)
+	}
+	if len(opts.Types) > 0 {
+		query.Set("types", strings.Join(opts.Types, ","))
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: pagesPath, query: query, accept: "text/event-stream"})
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{
+		body:            resp.Body,
+		reader:          bufio.NewReader(resp.Body),
+		resourceVersion: opts.ResourceVersion,
+	}, nil
+}
+
+// Next blocks until the next change. It returns io.EOF when the server ends
+// the stream, and the error of the connection once the watch is closed.
+func (w *Watcher) Next() (Event, error) {
+	var id string
+	var data strings.Builder
+	for {
+		line, err := w.reader.ReadString('\n')
+		if err != nil {
+			return Event{}, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return Event{}, fmt.Errorf("decode watch event: %w", err)
+			}
+			if id != "" {
+				w.resourceVersion = id
+			}
+			return event, nil
+		case strings.HasPrefix(line, ":"):
+			// Heartbeats are comments
+		case strings.HasPrefix(line, "id:"):
+			id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
+
+// ResourceVersion is the resource version of the last event read, to resume
+// the watch from after a disconnect
+func (w *Watcher) ResourceVersion() string {
+	return w.resourceVersion
+}
+
+// Close ends the watch
+func (w *Watcher) Close() error {
+	return w.body.Close()
+}
@@ -26,6 +26,9 @@ type FrontendPageReconciler struct {
 	Scheme *runtime.Scheme
 }
 
+// ContentKey is the ConfigMap key that holds the rendered page content
+const ContentKey = "content"
+
 func buildConfigMap(frontendPage *frontendv1alpha1.FrontendPage) *corev1.ConfigMap {
 	return &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -33,7 +36,7 @@ func buildConfigMap(frontendPage *frontendv1alpha1.FrontendPage) *corev1.ConfigM
 			Namespace: frontendPage.Namespace,
 		},
 		Data: map[string]string{
-			"content": frontendPage.Spec.Content,
+			ContentKey: frontendPage.Spec.Content,
 		},
 	}
 }
@@ -110,6 +113,63 @@ func buildDeployment(frontendPage *frontendv1alpha1.FrontendPage) *appsv1.Deploy
 		},
 	}
 }
+
+// OwnedResources are the objects the reconciler manages for a FrontendPage
+type OwnedResources struct {
+	ConfigMap  *corev1.ConfigMap
+	Service    *corev1.Service
+	Deployment *appsv1.Deployment
+}
+
+// BuildOwnedResources renders the objects the reconciler creates for a
+// FrontendPage, using the same builders as Reconcile
+func BuildOwnedResources(frontendPage *frontendv1alpha1.FrontendPage) OwnedResources {
+	return OwnedResources{
+		ConfigMap:  buildConfigMap(frontendPage),
+		Service:    buildService(frontendPage),
+		Deployment: buildDeployment(frontendPage),
+	}
+}
+
+// ReconcileService copies the fields Reconcile manages from desired into
+// existing, and reports whether existing changed
+func ReconcileService(existing, desired *corev1.Service) bool {
+	if reflect.DeepEqual(existing.Spec, desired.Spec) {
+		return false
+	}
+	existing.Spec = desired.Spec
+	return true
+}
+
+// ReconcileConfigMap copies the fields Reconcile manages from desired into
+// existing, and reports whether existing changed
+func ReconcileConfigMap(existing, desired *corev1.ConfigMap) bool {
+	if reflect.DeepEqual(existing.Data, desired.Data) {
+		return false
+	}
+	existing.Data = desired.Data
+	return true
+}
+
+// ReconcileDeployment copies the fields Reconcile manages from desired into
+// existing, the replicas and the image, and reports whether existing changed
+func ReconcileDeployment(existing, desired *appsv1.Deployment) bool {
+	updated := false
+	if existing.Spec.Replicas == nil || *existing.Spec.Replicas != *desired.Spec.Replicas {
+		existing.Spec.Replicas = desired.Spec.Replicas
+		updated = true
+	}
+	if len(existing.Spec.Template.Spec.Containers) == 0 {
+		existing.Spec.Template.Spec.Containers = desired.Spec.Template.Spec.Containers
+		return true
+	}
+	if existing.Spec.Template.Spec.Containers[0].Image != desired.Spec.Template.Spec.Containers[0].Image {
+		existing.Spec.Template.Spec.Containers[0].Image = desired.Spec.Template.Spec.Containers[0].Image
+		updated = true
+	}
+	return updated
+}
+
 func (r *FrontendPageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	var frontendPage frontendv1alpha1.FrontendPage
 	err := r.Get(ctx, req.NamespacedName, &frontendPage)
@@ -132,8 +192,7 @@ func (r *FrontendPageReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			return ctrl.Result{}, err
 		}
 		log.Info().Msgf("Created FrontendPage Service: %s/%s", req.Namespace, req.Name)
-	} else if !reflect.DeepEqual(existingService.Spec, svc.Spec) {
-		existingService.Spec = svc.Spec
+	} else if ReconcileService(&existingService, svc) {
 		if err := r.Update(ctx, &existingService); err != nil {
 			return ctrl.Result{}, err
 		}
@@ -157,8 +216,7 @@ func (r *FrontendPageReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		if err := r.Create(ctx, cm); err != nil {
 			return ctrl.Result{}, err
 		}
-	} else if !reflect.DeepEqual(existingConfigMap.Data, cm.Data) {
-		existingConfigMap.Data = cm.Data
+	} else if ReconcileConfigMap(&existingConfigMap, cm) {
 		if err := r.Update(ctx, &existingConfigMap); err != nil {
 			return ctrl.Result{}, err
 		}
@@ -185,16 +243,7 @@ func (r *FrontendPageReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}
 		log.Info().Msgf("Created FrontendPage Deployment: %s/%s", req.Namespace, req.Name)
 	} else {
-		updated := false
-		if *existingDeployment.Spec.Replicas != *deployment.Spec.Replicas {
-			existingDeployment.Spec.Replicas = deployment.Spec.Replicas
-			updated = true
-		}
-		if existingDeployment.Spec.Template.Spec.Containers[0].Image != deployment.Spec.Template.Spec.Containers[0].Image {
-			existingDeployment.Spec.Template.Spec.Containers[0].Image = deployment.Spec.Template.Spec.Containers[0].Image
-			updated = true
-		}
-		if updated {
+		if ReconcileDeployment(&existingDeployment, deployment) {
 			if err := r.Update(ctx, &existingDeployment); err != nil {
 				if !errors.IsConflict(err) {
 					return ctrl.Result{}, err